@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"coin-app/internal/config"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/services/audit"
+	"coin-app/internal/storage/postgres"
+)
+
+// Walks the audit log from the first record and checks every hash and link.
+// Prints the result as JSON and exits with 3 if the chain is broken.
+//
+//	go run ./cmd/audit-verify --config=../config/local.yaml
+func main() {
+	var batchSize int
+
+	flag.IntVar(&batchSize, "batch-size", 1000, "records per batch")
+
+	cfg := config.MustLoad()
+
+	log := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	if batchSize <= 0 {
+		log.Error("--batch-size must be positive")
+		os.Exit(2)
+	}
+
+	storage, err := postgres.New(postgres.Options{
+		DSN:          cfg.Postgres.DSN(),
+		MaxOpenConns: 2,
+	})
+	if err != nil {
+		log.Error("failed to init storage", sl.Err(err))
+		os.Exit(1)
+	}
+	defer storage.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	result, err := audit.New(log, storage).Verify(ctx, batchSize)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(result)
+
+	if err != nil {
+		log.Error("audit verification failed", sl.Err(err))
+
+		if result.BrokenAt != 0 {
+			os.Exit(3)
+		}
+		os.Exit(1)
+	}
+
+	log.Info("audit chain is intact", slog.Int64("records", result.Checked))
+}
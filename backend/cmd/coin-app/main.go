@@ -2,25 +2,95 @@ package main
 
 import (
 	"coin-app/internal/config"
+	"coin-app/internal/domain/models"
+	"coin-app/internal/http-server/handlers/adjustment/approve"
+	adjustmentList "coin-app/internal/http-server/handlers/adjustment/list"
+	"coin-app/internal/http-server/handlers/adjustment/propose"
+	"coin-app/internal/http-server/handlers/adjustment/reject"
+	"coin-app/internal/http-server/handlers/apikey/issue"
+	"coin-app/internal/http-server/handlers/apikey/list"
+	"coin-app/internal/http-server/handlers/apikey/revoke"
+	"coin-app/internal/http-server/handlers/apikey/rotate"
+	"coin-app/internal/http-server/handlers/audit/query"
+	fxExecute "coin-app/internal/http-server/handlers/fx/execute"
+	fxQuote "coin-app/internal/http-server/handlers/fx/quote"
+	fxRates "coin-app/internal/http-server/handlers/fx/rates"
+	fxSetRates "coin-app/internal/http-server/handlers/fx/setrates"
+	"coin-app/internal/http-server/handlers/health/liveness"
+	"coin-app/internal/http-server/handlers/health/readiness"
+	policyGet "coin-app/internal/http-server/handlers/policy/get"
+	policySet "coin-app/internal/http-server/handlers/policy/set"
+	"coin-app/internal/http-server/handlers/review/review"
+	"coin-app/internal/http-server/handlers/review/reviews"
+	scheduleCancel "coin-app/internal/http-server/handlers/schedule/cancel"
+	scheduleCreate "coin-app/internal/http-server/handlers/schedule/create"
+	scheduleGet "coin-app/internal/http-server/handlers/schedule/get"
+	scheduleList "coin-app/internal/http-server/handlers/schedule/list"
+	scheduleRuns "coin-app/internal/http-server/handlers/schedule/runs"
+	scheduleUpdate "coin-app/internal/http-server/handlers/schedule/update"
+	"coin-app/internal/http-server/handlers/wallet/batch"
 	"coin-app/internal/http-server/handlers/wallet/create"
+	"coin-app/internal/http-server/handlers/wallet/lookup"
 	"coin-app/internal/http-server/handlers/wallet/transaction"
 	"coin-app/internal/http-server/handlers/wallet/wallet"
+	"coin-app/internal/http-server/handlers/webhook/deliveries"
+	webhookList "coin-app/internal/http-server/handlers/webhook/list"
+	"coin-app/internal/http-server/handlers/webhook/redeliver"
+	"coin-app/internal/http-server/handlers/webhook/subscribe"
+	"coin-app/internal/http-server/handlers/webhook/unsubscribe"
+	"coin-app/internal/http-server/middleware/consistency"
+	"coin-app/internal/http-server/middleware/inflight"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/lib/certs"
 	"coin-app/internal/lib/logger/handlers/slogpretty"
 	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/lib/metrics"
+	"coin-app/internal/lib/ratelimit"
+	"coin-app/internal/lib/signature"
+	"coin-app/internal/lib/tracing"
+	"coin-app/internal/services/adjustment"
+	"coin-app/internal/services/apikey"
+	"coin-app/internal/services/audit"
+	"coin-app/internal/services/fx"
+	"coin-app/internal/services/health"
+	"coin-app/internal/services/outbox"
+	"coin-app/internal/services/outbox/publishers/writer"
+	"coin-app/internal/services/policy"
+	"coin-app/internal/services/scheduler"
+	"coin-app/internal/services/wallet/cache"
+	"coin-app/internal/services/wallet/fees"
+	"coin-app/internal/services/wallet/rules"
+	"coin-app/internal/services/webhook"
 	"coin-app/internal/storage/postgres"
 	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	mwAudit "coin-app/internal/http-server/middleware/audit"
+	mwAuth "coin-app/internal/http-server/middleware/auth"
+	mwLimits "coin-app/internal/http-server/middleware/limits"
 	mwLogger "coin-app/internal/http-server/middleware/logger"
+	mwMetrics "coin-app/internal/http-server/middleware/metrics"
+	mwRateLimit "coin-app/internal/http-server/middleware/ratelimit"
+	mwSignature "coin-app/internal/http-server/middleware/signature"
+	mwTracing "coin-app/internal/http-server/middleware/tracing"
 	walletService "coin-app/internal/services/wallet"
+	walletMetrics "coin-app/internal/services/wallet/instrumented"
+	storageMetrics "coin-app/internal/storage/instrumented"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -34,21 +104,314 @@ func main() {
 	cfg := config.MustLoad()
 
 	// Init logger: slog
-	log := setupLogger(cfg.Env)
+	log := setupLogger(cfg.Env, cfg.Logging)
 	log.Info("starting driver server", slog.String("env", cfg.Env))
 	log.Debug("debug messages are enabled")
+	log.Debug("effective config", slog.Any("config", cfg.Redacted()))
+
+	// Init tracing: opentelemetry
+	if cfg.Features.Tracing {
+		shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+			ServiceName: cfg.Tracing.ServiceName,
+			Exporter:    cfg.Tracing.Exporter,
+			Endpoint:    cfg.Tracing.Endpoint,
+			Insecure:    cfg.Tracing.Insecure,
+			SampleRatio: cfg.Tracing.SampleRatio,
+		})
+		if err != nil {
+			log.Error("failed to init tracing", sl.Err(err))
+			os.Exit(1)
+		}
+		defer func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				log.Error("failed to flush traces", sl.Err(err))
+			}
+		}()
+	}
+
+	// Init metrics: prometheus
+	m := metrics.New()
 
 	// Init storage: postgresql
-	storage, err := postgres.New()
+	isolation, err := postgres.ParseIsolation(cfg.Tx.Isolation)
+	if err != nil {
+		log.Error("invalid transaction isolation", sl.Err(err))
+		os.Exit(1)
+	}
+
+	pgStorage, err := postgres.New(postgres.Options{
+		DSN:             cfg.Postgres.DSN(),
+		MaxOpenConns:    cfg.Postgres.MaxOpenConns,
+		MaxIdleConns:    cfg.Postgres.MaxIdleConns,
+		ConnMaxLifetime: cfg.Postgres.ConnMaxLifetime,
+		Replicas: postgres.ReplicaOptions{
+			DSNs:                cfg.Postgres.Replicas.DSNs,
+			MaxLag:              cfg.Postgres.Replicas.MaxLag,
+			HealthCheckInterval: cfg.Postgres.Replicas.HealthCheckInterval,
+		},
+		Isolation: isolation,
+		Retry: postgres.RetryPolicy{
+			MaxAttempts: cfg.Tx.MaxAttempts,
+			MinBackoff:  cfg.Tx.MinBackoff,
+			MaxBackoff:  cfg.Tx.MaxBackoff,
+			OnRetry: func(reason string) {
+				m.TxRetries.WithLabelValues(reason).Inc()
+			},
+			OnGiveUp: func(reason string) {
+				m.TxConflicts.WithLabelValues(reason).Inc()
+			},
+		},
+	})
 	if err != nil {
 		log.Error("failed to init storage", sl.Err(err))
 		os.Exit(1)
 	}
 
-	walletService := walletService.New(log, storage, storage)
+	m.Registry.MustRegister(collectors.NewDBStatsCollector(pgStorage.DB(), "primary"))
+	storage := storageMetrics.New(pgStorage, m)
+
+	// Init balance cache
+	var walletObserver walletService.WalletObserver
+	var walletReader walletService.WalletReader
+	var walletCache *cache.Cache
+	if cfg.Features.Cache {
+		walletCache = cache.New(cfg.Cache.Size, cfg.Cache.TTL, cfg.Cache.WatermarkTTL)
+		walletObserver = walletCache
+		walletReader = walletCache.Wrap(storage)
+		registerCacheMetrics(m, walletCache)
+	}
+
+	tiers := make(map[string]models.Limits, len(cfg.Policy.Tiers))
+	for name, tier := range cfg.Policy.Tiers {
+		tiers[name] = models.Limits(tier)
+	}
+	policyService := policy.New(log, storage, tiers, cfg.Policy.DefaultTier)
+
+	// The review queue stays available after the rules are switched off
+	var ruleEngine walletService.RuleEngine
+	var configured []rules.Configured
+	if cfg.Features.Rules {
+		configured, err = setupRules(cfg.Rules, storage)
+		if err != nil {
+			log.Error("failed to init rules", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+	rulesService := rules.New(log, storage, configured)
+	if cfg.Features.Rules {
+		ruleEngine = rulesService
+	}
+
+	var feeCalculator walletService.FeeCalculator
+	if cfg.Features.Fees {
+		feeEngine := setupFees(log, cfg.Fees, policyService)
+		if err := checkRevenueWallets(feeEngine, storage); err != nil {
+			log.Error("failed to check revenue wallets", sl.Err(err))
+			os.Exit(1)
+		}
+		feeCalculator = feeEngine
+	}
+
+	walletService := walletMetrics.New(
+		walletService.New(log, storage, storage, storage, storage, walletObserver, walletReader, policyService, ruleEngine, feeCalculator),
+		m,
+	)
+	webhookService := webhook.New(log, storage, walletService, cfg.Webhooks.AllowPrivateNetworks)
+	apiKeyService := apikey.New(log, storage)
+	auditService := audit.New(log, storage)
+	adjustmentService := adjustment.New(log, storage, storage, walletService)
+	healthService := health.New(log, pgStorage, postgres.SchemaVersion)
+	schedulerService := scheduler.New(log, storage, storage, walletService, auditService, apiKeyService, scheduler.Options{
+		PollInterval: cfg.Scheduler.PollInterval,
+		BatchSize:    cfg.Scheduler.BatchSize,
+		MaxAttempts:  cfg.Scheduler.MaxAttempts,
+		MinBackoff:   cfg.Scheduler.MinBackoff,
+		MaxBackoff:   cfg.Scheduler.MaxBackoff,
+		MinEvery:     cfg.Scheduler.MinEvery,
+	})
+	fxService := fx.New(log, storage, storage, walletService, fx.Options{
+		Currencies: cfg.FX.Currencies,
+		SpreadBps:  cfg.FX.SpreadBps,
+		QuoteTTL:   cfg.FX.QuoteTTL,
+	})
+
+	if cfg.FX.RatesFile != "" {
+		n, err := loadRates(fxService, cfg.FX.RatesFile)
+		if err != nil {
+			log.Error("failed to load exchange rates", slog.String("file", cfg.FX.RatesFile), sl.Err(err))
+			os.Exit(1)
+		}
+		log.Info("exchange rates loaded", slog.String("file", cfg.FX.RatesFile), slog.Int("count", n))
+	}
+
+	// Init background workers: outbox relay, webhook dispatcher, scheduler, fee settler
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	if cfg.Features.Outbox {
+		publisher, err := setupPublisher(cfg.Outbox)
+		if err != nil {
+			log.Error("failed to init event publisher", sl.Err(err))
+			os.Exit(1)
+		}
+		defer publisher.Close()
+
+		publishers := outbox.Fanout{publisher}
+		if cfg.Features.Webhooks {
+			publishers = append(publishers, webhookService)
+		}
+
+		relay := outbox.New(log, storage, publishers, outbox.Options{
+			PollInterval: cfg.Outbox.PollInterval,
+			BatchSize:    cfg.Outbox.BatchSize,
+			Lease:        cfg.Outbox.Lease,
+			MinBackoff:   cfg.Outbox.MinBackoff,
+			MaxBackoff:   cfg.Outbox.MaxBackoff,
+		})
+
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			relay.Run(workersCtx)
+		}()
+	}
+
+	if cfg.Features.Webhooks {
+		dispatcher := webhook.NewDispatcher(log, storage, webhook.NewClient(cfg.Webhooks.RequestTimeout, cfg.Webhooks.AllowPrivateNetworks), webhook.DispatcherOptions{
+			PollInterval: cfg.Webhooks.PollInterval,
+			BatchSize:    cfg.Webhooks.BatchSize,
+			MaxAttempts:  cfg.Webhooks.MaxAttempts,
+			Lease:        cfg.Webhooks.Lease,
+			MinBackoff:   cfg.Webhooks.MinBackoff,
+			MaxBackoff:   cfg.Webhooks.MaxBackoff,
+		})
+
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			dispatcher.Run(workersCtx)
+		}()
+	}
+
+	if cfg.Features.Scheduler {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			schedulerService.Run(workersCtx)
+		}()
+	}
+
+	if cfg.Features.Fees {
+		settler := fees.NewSettler(log, storage, walletObserver, fees.SettlerOptions{
+			Interval:  cfg.Fees.SettleInterval,
+			BatchSize: cfg.Fees.SettleBatchSize,
+		})
+
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			settler.Run(workersCtx)
+		}()
+	}
+
+	if walletCache != nil && cfg.Cache.StatsInterval > 0 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			walletCache.ReportStats(workersCtx, log, cfg.Cache.StatsInterval)
+		}()
+	}
+
+	// Init authentication: JWT bearer tokens for users, API keys and client certificates for services
+	authn := mwAuth.Anonymous()
+	if cfg.Features.Auth {
+		var verifier mwAuth.Verifier
+		if cfg.Auth.JWTSecret != "" || cfg.Auth.JWKSFile != "" {
+			jwtVerifier, err := auth.NewJWTVerifier(auth.JWTOptions{
+				HMACSecret: cfg.Auth.JWTSecret,
+				JWKSFile:   cfg.Auth.JWKSFile,
+				Issuer:     cfg.Auth.Issuer,
+				Audience:   cfg.Auth.Audience,
+				Leeway:     cfg.Auth.Leeway,
+			})
+			if err != nil {
+				log.Error("failed to init authentication", sl.Err(err))
+				os.Exit(1)
+			}
+			verifier = jwtVerifier
+		}
+
+		var keys mwAuth.KeyAuthenticator
+		if cfg.Auth.APIKeys {
+			keys = apiKeyService
+		}
+
+		var certs mwAuth.CertAuthenticator
+		if cfg.Features.TLS && len(cfg.HTTPServer.TLS.Clients) > 0 {
+			certClients, err := auth.NewCertClients(cfg.HTTPServer.TLS.Clients)
+			if err != nil {
+				log.Error("failed to init client certificate identities", sl.Err(err))
+				os.Exit(1)
+			}
+			certs = certClients
+		}
+
+		authn = mwAuth.New(log, verifier, keys, certs)
+	} else {
+		log.Warn("authentication is disabled, every caller acts as an anonymous service on any wallet, the admin API is closed")
+	}
+
+	// Init rate limiter: in memory or shared through postgres
+	var limiter mwRateLimit.Limiter
+	if cfg.Features.RateLimit {
+		switch cfg.RateLimit.Backend {
+		case "memory":
+			limiter = ratelimit.NewMemory(cfg.RateLimit.IdleTTL)
+		case "postgres":
+			pgLimiter := postgres.NewRateLimiter(pgStorage)
+			limiter = pgLimiter
+
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				deleteIdleBuckets(workersCtx, log, pgLimiter, cfg.RateLimit.IdleTTL)
+			}()
+		default:
+			log.Error("unknown rate limit backend", slog.String("backend", cfg.RateLimit.Backend))
+			os.Exit(1)
+		}
+	}
+
+	// Init request signatures: nonces in memory or shared through postgres
+	var signed func(http.Handler) http.Handler
+	if cfg.Features.Signing {
+		var nonces mwSignature.NonceStore
+		switch cfg.Signing.Backend {
+		case "memory":
+			nonces = signature.NewMemory()
+		case "postgres":
+			pgNonces := postgres.NewNonceStore(pgStorage)
+			nonces = pgNonces
+
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				deleteExpiredNonces(workersCtx, log, pgNonces, cfg.Signing.MaxSkew)
+			}()
+		default:
+			log.Error("unknown nonce store backend", slog.String("backend", cfg.Signing.Backend))
+			os.Exit(1)
+		}
+
+		signed = mwSignature.New(log, nonces, mwSignature.Options{
+			Clients: cfg.Signing.Clients,
+			MaxSkew: cfg.Signing.MaxSkew,
+		})
+	}
 
 	// Init router: chi, "chi render"
-	router := setupRouter(log, walletService)
+	tracker := inflight.New()
+	router := setupRouter(log, m, cfg.Limits, authn, cfg.Auth, cfg.RateLimit, limiter, signed, tracker, walletService, webhookService, apiKeyService, auditService, adjustmentService, policyService, rulesService, schedulerService, fxService, healthService)
 
 	// Init server
 	srv := &http.Server{
@@ -59,9 +422,36 @@ func main() {
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
 
+	// Init TLS: certificates are reloaded from disk when they change
+	if cfg.Features.TLS {
+		reloader, err := setupTLS(cfg.HTTPServer.TLS)
+		if err != nil {
+			log.Error("failed to init TLS", sl.Err(err))
+			os.Exit(1)
+		}
+		srv.TLSConfig = reloader.Config()
+
+		log.Info("TLS enabled",
+			slog.String("client_auth", cfg.HTTPServer.TLS.ClientAuth),
+			slog.Time("not_after", reloader.NotAfter()),
+		)
+
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			reloadCertificates(workersCtx, log, reloader, cfg.HTTPServer.TLS.ReloadInterval)
+		}()
+	}
+
 	// Run server
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Error("failed to start server", sl.Err(err))
 			os.Exit(1)
 		}
@@ -75,34 +465,275 @@ func main() {
 	sign := <-stop
 	log.Info("stopping server", slog.String("signal", sign.String()))
 
-	// TODO: move timeout to config
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Stop receiving new traffic: the balancer sees /readyz fail first
+	healthService.Drain()
+	time.Sleep(cfg.Shutdown.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Error("failed to stop server", sl.Err(err))
+	}
 
-		return
+	if err := tracker.Wait(ctx); err != nil {
+		log.Error("wallet operations did not finish in time", sl.Err(err))
 	}
 
-	// TODO: Добавить отдельную остановку для SQLite сервера
+	stopWorkers()
+	workers.Wait()
+
+	if err := pgStorage.Close(); err != nil {
+		log.Error("failed to close storage", sl.Err(err))
+	}
 
 	log.Info("server gracefully stopped")
 }
 
-func setupRouter(log *slog.Logger, walletService *walletService.Wallet) http.Handler {
+func setupPublisher(cfg config.Outbox) (*writer.Publisher, error) {
+	switch cfg.Publisher {
+	case "stdout":
+		return writer.NewStdout(), nil
+	case "file":
+		return writer.NewFile(cfg.FilePath)
+	default:
+		return nil, fmt.Errorf("unknown outbox publisher: %s", cfg.Publisher)
+	}
+}
+
+func setupTLS(cfg config.TLS) (*certs.Reloader, error) {
+	minVersion, err := certs.ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := certs.ParseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth, err := certs.ParseClientAuth(cfg.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	return certs.New(certs.Options{
+		CertFile:     cfg.CertFile,
+		KeyFile:      cfg.KeyFile,
+		ClientCAFile: cfg.ClientCAFile,
+		ClientAuth:   clientAuth,
+		MinVersion:   minVersion,
+		CipherSuites: cipherSuites,
+	})
+}
+
+func setupRules(cfg []config.Rule, history rules.History) ([]rules.Configured, error) {
+	configured := make([]rules.Configured, 0, len(cfg))
+	for _, c := range cfg {
+		rule := rules.Configured{
+			Name:      c.Name,
+			Action:    models.RuleAction(strings.ToUpper(c.Action)),
+			Operation: c.Operation,
+		}
+
+		switch c.Type {
+		case "velocity":
+			rule.Rule = rules.Velocity{MaxCount: c.MaxCount, Window: c.Window, History: history}
+		case "new_wallet":
+			rule.Rule = rules.NewWallet{MinAge: c.MinAge, History: history}
+		case "amount_spike":
+			rule.Rule = rules.AmountSpike{Factor: c.Factor, MinHistory: c.MinHistory, History: history}
+		case "small_amount":
+			rule.Rule = rules.SmallAmount{Max: c.MaxAmount}
+		default:
+			return nil, fmt.Errorf("unknown rule type: %s", c.Type)
+		}
+
+		configured = append(configured, rule)
+	}
+
+	return configured, nil
+}
+
+// setupFees builds the fee engine from the validated config.
+func setupFees(log *slog.Logger, cfg config.Fees, tiers fees.TierResolver) *fees.Engine {
+	schedules := make([]models.FeeSchedule, 0, len(cfg.Schedules))
+	for _, c := range cfg.Schedules {
+		schedule := models.FeeSchedule{
+			Name:      c.Name,
+			Operation: c.Operation,
+			Tier:      c.Tier,
+			Type:      models.FeeType(c.Type),
+			Amount:    c.Amount,
+			Bps:       c.Bps,
+			Min:       c.Min,
+			Max:       c.Max,
+		}
+		for _, band := range c.Bands {
+			schedule.Bands = append(schedule.Bands, models.FeeBand(band))
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	revenue := make(map[string]uuid.UUID, len(cfg.RevenueWallets))
+	for currency, id := range cfg.RevenueWallets {
+		revenue[currency] = uuid.MustParse(id)
+	}
+
+	return fees.New(log, tiers, schedules, revenue)
+}
+
+// checkRevenueWallets fails if a revenue wallet is missing or holds another currency than it collects.
+func checkRevenueWallets(engine *fees.Engine, wallets fees.WalletGetter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return engine.CheckRevenueWallets(ctx, wallets)
+}
+
+// loadRates stores the exchange rates of a CSV file, see fx.FX.LoadRates.
+func loadRates(fxService *fx.FX, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return fxService.LoadRates(ctx, f, path)
+}
+
+func setupRouter(
+	log *slog.Logger,
+	m *metrics.Metrics,
+	limits config.Limits,
+	authn func(http.Handler) http.Handler,
+	authCfg config.Auth,
+	rateLimit config.RateLimit,
+	limiter mwRateLimit.Limiter,
+	signed func(http.Handler) http.Handler,
+	tracker *inflight.Tracker,
+	walletService *walletMetrics.Wallet,
+	webhookService *webhook.Webhook,
+	apiKeyService *apikey.APIKey,
+	auditService *audit.Audit,
+	adjustmentService *adjustment.Adjustment,
+	policyService *policy.Policy,
+	rulesService *rules.Engine,
+	schedulerService *scheduler.Scheduler,
+	fxService *fx.FX,
+	healthService *health.Health,
+) http.Handler {
 	r := chi.NewRouter()
 
+	r.Use(mwTracing.New())
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(mwLogger.New(log))
+	r.Use(mwMetrics.New(m))
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.URLFormat)
+	r.Use(consistency.New())
+	r.Use(mwLimits.New(limits.MaxBodyBytes, limits.RequestTimeout))
+
+	r.Get("/healthz", liveness.New())
+	r.Get("/readyz", readiness.New(healthService))
+
+	r.Group(func(r chi.Router) {
+		r.Use(authn)
+
+		if limiter != nil {
+			r.Use(mwRateLimit.New(log, limiter, mwRateLimit.Options{
+				Client: ratelimit.Limit{Rate: rateLimit.ClientRate, Burst: rateLimit.ClientBurst},
+				Wallet: ratelimit.Limit{Rate: rateLimit.WalletRate, Burst: rateLimit.WalletBurst},
+			}))
+		}
+
+		// Every mutating call below is recorded with its caller
+		r.Use(mwAudit.New(log, auditService))
 
-	r.Post("/wallet/create", create.New(log, walletService))
-	r.Post("/wallet", transaction.New(log, walletService))
-	r.Get("/wallet/{walletId}", wallet.New(log, walletService))
+		r.Group(func(r chi.Router) {
+			r.Use(tracker.Middleware)
+
+			// The service checks the scope of the exact operation on /wallet
+			r.With(mwAuth.RequireScope(auth.ScopeDeposit)).Post("/wallet/create", create.New(log, walletService))
+			r.With(mwAuth.RequireScope(auth.ScopeRead)).Get("/wallet/{walletId}", wallet.New(log, walletService))
+			r.With(mwAuth.RequireScope(auth.ScopeRead)).Get("/wallet/{walletId}/transactions", lookup.New(log, walletService))
+
+			// Partners call it over the public internet, so it may require signed requests
+			r.Group(func(r chi.Router) {
+				if signed != nil {
+					r.Use(signed)
+				}
+
+				r.With(mwAuth.RequireScope(auth.ScopeDeposit, auth.ScopeWithdraw)).Post("/wallet", transaction.New(log, walletService))
+				r.With(mwAuth.RequireScope(auth.ScopeDeposit, auth.ScopeWithdraw)).Post("/wallets/operations/batch", batch.New(log, walletService, limits.MaxBatchItems))
+			})
+
+			// The service checks the caller may act on the wallet with the scope of the operation
+			r.With(mwAuth.RequireScope(auth.ScopeDeposit, auth.ScopeWithdraw)).Post("/schedules", scheduleCreate.New(log, schedulerService))
+			r.With(mwAuth.RequireScope(auth.ScopeRead)).Get("/schedules", scheduleList.New(log, schedulerService))
+			r.With(mwAuth.RequireScope(auth.ScopeRead)).Get("/schedules/{scheduleId}", scheduleGet.New(log, schedulerService))
+			r.With(mwAuth.RequireScope(auth.ScopeRead)).Get("/schedules/{scheduleId}/runs", scheduleRuns.New(log, schedulerService))
+			r.With(mwAuth.RequireScope(auth.ScopeDeposit, auth.ScopeWithdraw)).Patch("/schedules/{scheduleId}", scheduleUpdate.New(log, schedulerService))
+			r.With(mwAuth.RequireScope(auth.ScopeDeposit, auth.ScopeWithdraw)).Delete("/schedules/{scheduleId}", scheduleCancel.New(log, schedulerService))
+
+			// A conversion debits one wallet and credits the other, so it takes both scopes
+			r.With(mwAuth.RequireScope(auth.ScopeRead)).Get("/fx/rates", fxRates.New(log, fxService))
+			r.With(mwAuth.RequireScope(auth.ScopeDeposit, auth.ScopeWithdraw)).Post("/fx/quotes", fxQuote.New(log, fxService))
+			r.With(mwAuth.RequireScope(auth.ScopeDeposit, auth.ScopeWithdraw)).Post("/fx/quotes/{quoteId}/execute", fxExecute.New(log, fxService))
+		})
+
+		// Subscriptions receive events of the wallets their owner may access,
+		// the service scopes every route to the subscriptions of the caller
+		r.Group(func(r chi.Router) {
+			r.Use(mwAuth.RequireScope(auth.ScopeRead))
+
+			r.Post("/webhooks", subscribe.New(log, webhookService))
+			r.Get("/webhooks", webhookList.New(log, webhookService))
+			r.Delete("/webhooks/{subscriptionId}", unsubscribe.New(log, webhookService))
+			r.Get("/webhooks/{subscriptionId}/deliveries", deliveries.New(log, webhookService))
+			r.Post("/webhooks/deliveries/{deliveryId}/redeliver", redeliver.New(log, webhookService))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(mwAuth.RequireScope(auth.ScopeAdmin))
+
+			r.Post("/admin/api-keys", issue.New(log, apiKeyService))
+			r.Get("/admin/api-keys", list.New(log, apiKeyService))
+			r.Delete("/admin/api-keys/{keyId}", revoke.New(log, apiKeyService))
+			r.Post("/admin/api-keys/{keyId}/rotate", rotate.New(log, apiKeyService, authCfg.RotationGrace))
+
+			r.Get("/admin/audit", query.New(log, auditService))
+
+			r.Get("/admin/wallets/{walletId}/policy", policyGet.New(log, policyService))
+			r.Put("/admin/wallets/{walletId}/policy", policySet.New(log, policyService))
+
+			r.Post("/admin/fx/rates", fxSetRates.New(log, fxService))
+		})
+
+		// Manual balance changes, proposed by one operator and approved by another
+		r.Group(func(r chi.Router) {
+			r.Use(mwAuth.RequireScope(auth.ScopeAdmin))
+			r.Use(tracker.Middleware)
+
+			r.Post("/admin/adjustments", propose.New(log, adjustmentService))
+			r.Get("/admin/adjustments", adjustmentList.New(log, adjustmentService))
+			r.Post("/admin/adjustments/{adjustmentId}/approve", approve.New(log, adjustmentService))
+			r.Post("/admin/adjustments/{adjustmentId}/reject", reject.New(log, adjustmentService))
+		})
+
+		// Operations flagged by the fraud rules
+		r.Group(func(r chi.Router) {
+			r.Use(mwAuth.RequireScope(auth.ScopeAdmin))
+
+			r.Get("/admin/reviews", reviews.New(log, rulesService))
+			r.Post("/admin/reviews/{decisionId}", review.New(log, rulesService))
+		})
+	})
+
+	r.Handle("/metrics", promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{}))
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("welcome anonymous"))
@@ -111,30 +742,124 @@ func setupRouter(log *slog.Logger, walletService *walletService.Wallet) http.Han
 	return r
 }
 
-func setupLogger(env string) *slog.Logger {
-	var log *slog.Logger
+// reloadCertificates checks the certificate files every interval until ctx is done.
+// A broken new version is logged and the previous certificates keep being served.
+func reloadCertificates(ctx context.Context, log *slog.Logger, reloader *certs.Reloader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	switch env {
-	case envLocal:
-		log = setupPrettySlog()
-	case envDev:
-		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
-		)
-	case envProd:
-		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
-		)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := reloader.Reload()
+			if err != nil {
+				log.Error("failed to reload certificates", sl.Err(err))
+				continue
+			}
+			if reloaded {
+				log.Info("certificates reloaded", slog.Time("not_after", reloader.NotAfter()))
+			}
+		}
 	}
+}
 
-	return log
+// deleteExpiredNonces purges nonces that can no longer be replayed every interval until ctx is done.
+func deleteExpiredNonces(ctx context.Context, log *slog.Logger, nonces *postgres.NonceStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := nonces.DeleteExpired(ctx); err != nil {
+				log.Error("failed to delete expired nonces", sl.Err(err))
+			}
+		}
+	}
 }
 
-func setupPrettySlog() *slog.Logger {
+// deleteIdleBuckets purges rate limit buckets that have been idle for ttl until ctx is done.
+func deleteIdleBuckets(ctx context.Context, log *slog.Logger, limiter *postgres.RateLimiter, ttl time.Duration) {
+	ticker := time.NewTicker(ttl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := limiter.DeleteIdle(ctx, ttl); err != nil {
+				log.Error("failed to delete idle rate limit buckets", sl.Err(err))
+			}
+		}
+	}
+}
+
+func registerCacheMetrics(m *metrics.Metrics, c *cache.Cache) {
+	m.Registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "coin",
+			Subsystem: "wallet_cache",
+			Name:      "hits_total",
+			Help:      "Balance reads served from the cache.",
+		}, func() float64 { return float64(c.Stats().Hits) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "coin",
+			Subsystem: "wallet_cache",
+			Name:      "misses_total",
+			Help:      "Balance reads that missed the cache.",
+		}, func() float64 { return float64(c.Stats().Misses) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "coin",
+			Subsystem: "wallet_cache",
+			Name:      "stale_reads_total",
+			Help:      "Reads repeated on the primary because a replica was behind.",
+		}, func() float64 { return float64(c.Stats().StaleReads) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "coin",
+			Subsystem: "wallet_cache",
+			Name:      "entries",
+			Help:      "Wallets currently cached.",
+		}, func() float64 { return float64(c.Stats().Size) }),
+	)
+}
+
+func setupLogger(env string, cfg config.Logging) *slog.Logger {
+	level := slog.LevelDebug
+	if env == envProd {
+		level = slog.LevelInfo
+	}
+	if cfg.Level != "" {
+		_ = level.UnmarshalText([]byte(cfg.Level))
+	}
+
+	format := cfg.Format
+	if format == "" {
+		format = "json"
+		if env == envLocal {
+			format = "pretty"
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch format {
+	case "pretty":
+		return setupPrettySlog(opts)
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stdout, opts))
+	default:
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts))
+	}
+}
+
+func setupPrettySlog(slogOpts *slog.HandlerOptions) *slog.Logger {
 	opts := slogpretty.PrettyHandlerOptions{
-		SlogOpts: &slog.HandlerOptions{
-			Level: slog.LevelDebug,
-		},
+		SlogOpts: slogOpts,
 	}
 
 	handler := opts.NewPrettyHandler(os.Stdout)
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"coin-app/internal/lib/logger/sl"
+
+	"github.com/google/uuid"
+)
+
+// Creates a wallet on a running instance, fires concurrent deposits and withdrawals
+// at it at a target rate, then checks that the final balance equals the sum of the
+// accepted operations and that no response was a 5xx.
+//
+//	go run ./cmd/loadtest --url=http://localhost:8080 --rps=1000 --duration=30s
+//
+// Exit code 1 means the run failed its checks, 2 means bad flags.
+func main() {
+	var (
+		opts   Options
+		userId string
+		output string
+	)
+
+	flag.StringVar(&opts.BaseURL, "url", "http://localhost:8080", "base URL of the instance")
+	flag.Float64Var(&opts.RPS, "rps", 1000, "target requests per second")
+	flag.DurationVar(&opts.Duration, "duration", 30*time.Second, "how long to send requests")
+	flag.IntVar(&opts.Concurrency, "concurrency", 200, "max requests in flight")
+	flag.Float64Var(&opts.DepositRatio, "deposit-ratio", 0.5, "share of deposits among operations, 0..1")
+	flag.IntVar(&opts.MaxAmount, "max-amount", 100, "operation amounts are random in 1..max-amount")
+	flag.IntVar(&opts.InitialBalance, "initial-balance", 1_000_000, "balance of the created wallet")
+	flag.DurationVar(&opts.Timeout, "timeout", 30*time.Second, "timeout of a single request")
+	flag.StringVar(&opts.Token, "token", "", "bearer token for the wallet owner or an admin")
+	flag.StringVar(&userId, "user-id", "", "owner of the created wallet, random by default; must match the token subject unless it is an admin token")
+	flag.StringVar(&opts.ClientId, "client-id", "", "signing client ID, when the instance requires signed requests")
+	flag.StringVar(&opts.ClientSecret, "client-secret", "", "signing client secret")
+	flag.StringVar(&opts.Profile, "profile", "config/local.yaml,config/loadtest.yaml", "config the instance runs with, recorded in the report")
+	flag.StringVar(&output, "output", "", "report file, stdout by default")
+	flag.Parse()
+
+	log := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	if opts.RPS <= 0 || opts.Duration <= 0 || opts.Concurrency <= 0 || opts.MaxAmount <= 0 ||
+		opts.DepositRatio < 0 || opts.DepositRatio > 1 {
+		log.Error("invalid options", slog.Any("options", opts))
+		os.Exit(2)
+	}
+	opts.BaseURL = strings.TrimRight(opts.BaseURL, "/")
+
+	opts.UserId = uuid.New()
+	if userId != "" {
+		id, err := uuid.Parse(userId)
+		if err != nil {
+			log.Error("invalid user id", sl.Err(err))
+			os.Exit(2)
+		}
+		opts.UserId = id
+	}
+
+	out := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			log.Error("failed to create report file", sl.Err(err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        opts.Concurrency,
+			MaxIdleConnsPerHost: opts.Concurrency,
+		},
+	}
+
+	report, err := NewRunner(log, client, opts).Run(ctx)
+	if err != nil {
+		log.Error("load test failed", sl.Err(err))
+		os.Exit(1)
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		log.Error("failed to write report", sl.Err(err))
+		os.Exit(1)
+	}
+
+	if !report.Passed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Report struct {
+	// Passed is true when no response was a 5xx and the balance matched.
+	Passed   bool           `json:"passed"`
+	WalletId uuid.UUID      `json:"walletId"`
+	Options  ReportOptions  `json:"options"`
+	Requests RequestSummary `json:"requests"`
+	Latency  LatencySummary `json:"latencyMs"`
+	Outcomes map[string]int `json:"outcomes"`
+	Statuses map[int]int    `json:"statuses"`
+	Balance  BalanceSummary `json:"balance"`
+	ByType   map[string]int `json:"appliedByType"`
+}
+
+type ReportOptions struct {
+	TargetRPS    float64 `json:"targetRps"`
+	Duration     string  `json:"duration"`
+	Concurrency  int     `json:"concurrency"`
+	DepositRatio float64 `json:"depositRatio"`
+	MaxAmount    int     `json:"maxAmount"`
+	Profile      string  `json:"profile"`
+}
+
+type RequestSummary struct {
+	Sent         int     `json:"sent"`
+	Applied      int     `json:"applied"`
+	Rejected     int     `json:"rejected"`
+	Unknown      int     `json:"unknown"`
+	Dropped      int     `json:"dropped"`
+	Responses5xx int     `json:"responses5xx"`
+	AchievedRPS  float64 `json:"achievedRps"`
+}
+
+type LatencySummary struct {
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+}
+
+type BalanceSummary struct {
+	Initial  int     `json:"initial"`
+	Expected int     `json:"expected"`
+	Actual   float64 `json:"actual"`
+	Matches  bool    `json:"matches"`
+	// Inconclusive is set when some operations have an unknown outcome,
+	// so a mismatch does not necessarily mean lost or duplicated updates.
+	Inconclusive bool `json:"inconclusive"`
+}
+
+type stats struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	outcomes  map[string]int
+	statuses  map[int]int
+	byType    map[string]int
+	applied   int
+	rejected  int
+	unknown   int
+	fivexx    int
+	delta     int
+}
+
+func newStats() *stats {
+	return &stats{
+		outcomes: make(map[string]int),
+		statuses: make(map[int]int),
+		byType:   make(map[string]int),
+	}
+}
+
+func (s *stats) add(res result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latencies = append(s.latencies, res.latency)
+	s.outcomes[res.outcome]++
+	if res.statusCode != 0 {
+		s.statuses[res.statusCode]++
+	}
+	if res.statusCode >= 500 {
+		s.fivexx++
+	}
+
+	switch {
+	case res.applied:
+		s.applied++
+		s.byType[res.operation]++
+		if res.operation == operationDeposit {
+			s.delta += res.amount
+		} else {
+			s.delta -= res.amount
+		}
+	case res.unknown:
+		s.unknown++
+	default:
+		s.rejected++
+	}
+}
+
+func (s *stats) report(opts Options, walletId uuid.UUID, elapsed time.Duration, dropped int, balance float64) *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expected := opts.InitialBalance + s.delta
+
+	r := &Report{
+		WalletId: walletId,
+		Options: ReportOptions{
+			TargetRPS:    opts.RPS,
+			Duration:     opts.Duration.String(),
+			Concurrency:  opts.Concurrency,
+			DepositRatio: opts.DepositRatio,
+			MaxAmount:    opts.MaxAmount,
+			Profile:      opts.Profile,
+		},
+		Requests: RequestSummary{
+			Sent:         len(s.latencies),
+			Applied:      s.applied,
+			Rejected:     s.rejected,
+			Unknown:      s.unknown,
+			Dropped:      dropped,
+			Responses5xx: s.fivexx,
+			AchievedRPS:  round(float64(len(s.latencies)) / elapsed.Seconds()),
+		},
+		Latency:  latencies(s.latencies),
+		Outcomes: s.outcomes,
+		Statuses: s.statuses,
+		ByType:   s.byType,
+		Balance: BalanceSummary{
+			Initial:      opts.InitialBalance,
+			Expected:     expected,
+			Actual:       balance,
+			Matches:      balance == float64(expected),
+			Inconclusive: s.unknown > 0,
+		},
+	}
+	r.Passed = s.fivexx == 0 && r.Balance.Matches
+
+	return r
+}
+
+func latencies(d []time.Duration) LatencySummary {
+	if len(d) == 0 {
+		return LatencySummary{}
+	}
+
+	sorted := slices.Clone(d)
+	slices.Sort(sorted)
+
+	var sum time.Duration
+	for _, v := range sorted {
+		sum += v
+	}
+
+	return LatencySummary{
+		P50:  ms(percentile(sorted, 50)),
+		P90:  ms(percentile(sorted, 90)),
+		P95:  ms(percentile(sorted, 95)),
+		P99:  ms(percentile(sorted, 99)),
+		Max:  ms(sorted[len(sorted)-1]),
+		Mean: ms(sum / time.Duration(len(sorted))),
+	}
+}
+
+// percentile uses the nearest-rank method on sorted values.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+
+	return sorted[max(rank-1, 0)]
+}
+
+func ms(d time.Duration) float64 {
+	return round(float64(d) / float64(time.Millisecond))
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"coin-app/internal/lib/signature"
+
+	"github.com/google/uuid"
+)
+
+const (
+	operationDeposit  = "DEPOSIT"
+	operationWithdraw = "WITHDRAW"
+)
+
+type Options struct {
+	BaseURL        string
+	RPS            float64
+	Duration       time.Duration
+	Concurrency    int
+	DepositRatio   float64
+	MaxAmount      int
+	InitialBalance int
+	Timeout        time.Duration
+	Token          string
+	// UserId owns the created wallet, taken from the token subject if there is one.
+	UserId uuid.UUID
+	// ClientId and ClientSecret sign POST requests when the instance requires signatures.
+	ClientId     string
+	ClientSecret string
+	// Profile names the server config, a throughput figure means nothing without it.
+	Profile string
+}
+
+type Runner struct {
+	log    *slog.Logger
+	client *http.Client
+	opts   Options
+}
+
+func NewRunner(log *slog.Logger, client *http.Client, opts Options) *Runner {
+	return &Runner{log: log, client: client, opts: opts}
+}
+
+// apiResponse covers the fields of the wallet API responses the runner looks at.
+type apiResponse struct {
+	Status   string    `json:"status"`
+	Error    string    `json:"error"`
+	WalletId uuid.UUID `json:"walletId"`
+	Wallet   struct {
+		Balance float64 `json:"balance"`
+	} `json:"wallet"`
+}
+
+// result is the outcome of a single operation.
+type result struct {
+	operation  string
+	amount     int
+	statusCode int
+	latency    time.Duration
+	// outcome is "ok", the API error message or the transport error class.
+	outcome string
+	applied bool
+	// unknown means the operation may or may not have been applied, e.g. on a client timeout.
+	unknown bool
+}
+
+// Run creates the wallet, sends operations for the configured duration and verifies the balance.
+func (r *Runner) Run(ctx context.Context) (*Report, error) {
+	walletId, err := r.createWallet(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wallet: %w", err)
+	}
+
+	r.log.Info("wallet created, sending operations",
+		slog.String("walletId", walletId.String()),
+		slog.Float64("rps", r.opts.RPS),
+		slog.String("duration", r.opts.Duration.String()),
+	)
+
+	stats := newStats()
+	started := time.Now()
+	dropped := r.fire(ctx, walletId, stats)
+	elapsed := time.Since(started)
+
+	// The final read goes to the primary and around the cache
+	balance, err := r.balance(context.Background(), walletId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read final balance: %w", err)
+	}
+
+	return stats.report(r.opts, walletId, elapsed, dropped, balance), nil
+}
+
+// fire schedules operations at the target rate. Operations that cannot start
+// because all workers are busy are dropped and counted, the rate is not caught up later.
+func (r *Runner) fire(ctx context.Context, walletId uuid.UUID, stats *stats) int {
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Duration)
+	defer cancel()
+
+	jobs := make(chan struct{}, r.opts.Concurrency)
+
+	var wg sync.WaitGroup
+	for range r.opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				// In-flight operations are allowed to finish after the run ends
+				stats.add(r.operation(context.Background(), walletId))
+			}
+		}()
+	}
+
+	interval := time.Duration(float64(time.Second) / r.opts.RPS)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	dropped := 0
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+			select {
+			case jobs <- struct{}{}:
+			default:
+				dropped++
+			}
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return dropped
+}
+
+func (r *Runner) operation(ctx context.Context, walletId uuid.UUID) result {
+	res := result{
+		operation: operationWithdraw,
+		amount:    1 + rand.IntN(r.opts.MaxAmount),
+	}
+	if rand.Float64() < r.opts.DepositRatio {
+		res.operation = operationDeposit
+	}
+
+	t1 := time.Now()
+	statusCode, body, err := r.post(ctx, "/wallet", map[string]any{
+		"walletId":      walletId,
+		"operationType": res.operation,
+		"amount":        res.amount,
+	})
+	res.latency = time.Since(t1)
+	res.statusCode = statusCode
+
+	switch {
+	case err != nil:
+		res.outcome = transportError(err)
+		res.unknown = true
+	case statusCode >= 500:
+		res.outcome = fmt.Sprintf("http %d", statusCode)
+		res.unknown = true
+	case statusCode != http.StatusOK:
+		res.outcome = fmt.Sprintf("http %d", statusCode)
+	case body.Status != "OK":
+		res.outcome = body.Error
+	default:
+		res.outcome = "ok"
+		res.applied = true
+	}
+
+	return res
+}
+
+func (r *Runner) createWallet(ctx context.Context) (uuid.UUID, error) {
+	statusCode, body, err := r.post(ctx, "/wallet/create", map[string]any{
+		"userId": r.opts.UserId,
+		"amount": r.opts.InitialBalance,
+	})
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if statusCode != http.StatusOK || body.Status != "OK" {
+		return uuid.UUID{}, fmt.Errorf("status %d: %s", statusCode, body.Error)
+	}
+
+	return body.WalletId, nil
+}
+
+func (r *Runner) balance(ctx context.Context, walletId uuid.UUID) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.opts.BaseURL+"/wallet/"+walletId.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("X-Consistency", "strong")
+
+	statusCode, body, err := r.do(req)
+	if err != nil {
+		return 0, err
+	}
+	if statusCode != http.StatusOK || body.Status != "OK" {
+		return 0, fmt.Errorf("status %d: %s", statusCode, body.Error)
+	}
+
+	return body.Wallet.Balance, nil
+}
+
+func (r *Runner) post(ctx context.Context, path string, payload any) (int, apiResponse, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return 0, apiResponse{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.opts.BaseURL+path, bytes.NewReader(b))
+	if err != nil {
+		return 0, apiResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if r.opts.ClientId != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonce := uuid.NewString()
+
+		req.Header.Set(signature.HeaderClientId, r.opts.ClientId)
+		req.Header.Set(signature.HeaderTimestamp, timestamp)
+		req.Header.Set(signature.HeaderNonce, nonce)
+		req.Header.Set(signature.HeaderSignature,
+			signature.Sign(r.opts.ClientSecret, http.MethodPost, req.URL.EscapedPath(), timestamp, nonce, b))
+	}
+
+	return r.do(req)
+}
+
+// do sends req and decodes the JSON body. Bodies of error statuses may be empty or not JSON.
+func (r *Runner) do(req *http.Request) (int, apiResponse, error) {
+	if r.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.opts.Token)
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return 0, apiResponse{}, err
+	}
+	defer res.Body.Close()
+
+	var body apiResponse
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil && res.StatusCode == http.StatusOK {
+		return res.StatusCode, body, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return res.StatusCode, body, nil
+}
+
+func transportError(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "client timeout"
+	default:
+		var netErr interface{ Timeout() bool }
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return "client timeout"
+		}
+
+		return "transport error"
+	}
+}
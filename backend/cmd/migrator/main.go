@@ -4,7 +4,9 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"os"
+	"net/url"
+
+	"coin-app/internal/config"
 
 	// Библиотека для миграций
 	"github.com/golang-migrate/migrate/v4"
@@ -20,19 +22,16 @@ func main() {
 
 	flag.StringVar(&migrationsPath, "migrations-path", "", "path to migrations")
 	flag.StringVar(&migrationsTable, "migrations-table", "migrations", "name of migrations table")
-	flag.Parse()
+	// Параметры подключения берутся из конфига: --config или CONFIG_PATH
+	cfg := config.MustLoad()
 
 	if migrationsPath == "" {
 		panic("migrations-path is required")
 	}
 
-	user := os.Getenv("POSTGRES_USER")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	dbname := os.Getenv("POSTGRES_DB")
-
 	m, err := migrate.New(
 		"file://"+migrationsPath,
-		fmt.Sprintf("postgres://%s:%s@dbase:5432/%s?x-migrations-table=%s&sslmode=disable", user, password, dbname, migrationsTable),
+		cfg.Postgres.URL()+"&x-migrations-table="+url.QueryEscape(migrationsTable),
 	)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"os/user"
+	"syscall"
+	"time"
+
+	"coin-app/internal/config"
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/services/audit"
+	"coin-app/internal/services/reconcile"
+	"coin-app/internal/storage/postgres"
+
+	"github.com/google/uuid"
+)
+
+// Recomputes every wallet balance from its opening balance and ledger
+// and reports wallets whose stored balance differs.
+//
+//	go run ./cmd/reconcile --config=../config/local.yaml --format=csv --output=report.csv
+//	go run ./cmd/reconcile --fix --reason="INC-42 lost balance updates"
+func main() {
+	var (
+		format    string
+		output    string
+		batchSize int
+		pause     time.Duration
+		fix       bool
+		reason    string
+		all       bool
+	)
+
+	flag.StringVar(&format, "format", "json", "report format: json or csv")
+	flag.StringVar(&output, "output", "", "report file, stdout by default")
+	flag.IntVar(&batchSize, "batch-size", 500, "wallets per batch")
+	flag.DurationVar(&pause, "pause", 0, "pause between batches")
+	flag.BoolVar(&fix, "fix", false, "post adjusting entries for mismatched wallets")
+	flag.StringVar(&reason, "reason", "", "audit reason for adjusting entries, required with --fix")
+	flag.BoolVar(&all, "all", false, "report matching wallets too")
+
+	cfg := config.MustLoad()
+
+	log := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	if format != "json" && format != "csv" {
+		log.Error("unknown report format", slog.String("format", format))
+		os.Exit(2)
+	}
+	if fix && reason == "" {
+		log.Error("--reason is required with --fix")
+		os.Exit(2)
+	}
+
+	out := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			log.Error("failed to create report file", sl.Err(err))
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	storage, err := postgres.New(postgres.Options{
+		DSN:          cfg.Postgres.DSN(),
+		MaxOpenConns: 2,
+	})
+	if err != nil {
+		log.Error("failed to init storage", sl.Err(err))
+		os.Exit(1)
+	}
+	defer storage.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	report := newReport(format, out, all)
+	auditor := newAuditor(audit.New(log, storage))
+
+	summary, err := reconcile.New(log, storage).Run(ctx, reconcile.Options{
+		BatchSize: batchSize,
+		Pause:     pause,
+		Fix:       fix,
+		Reason:    reason,
+	}, func(row reconcile.Row) error {
+		if err := auditor.add(ctx, row); err != nil {
+			return err
+		}
+
+		return report.add(row)
+	})
+	if err != nil {
+		log.Error("reconciliation failed", sl.Err(err))
+	}
+
+	if err := report.flush(summary); err != nil {
+		log.Error("failed to write report", sl.Err(err))
+		os.Exit(1)
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+	if summary.Mismatched > summary.Fixed {
+		os.Exit(3)
+	}
+}
+
+// auditor records every adjusting entry in the audit log, like the API does for its mutating calls.
+// All entries of one run share the request ID.
+type auditor struct {
+	audit *audit.Audit
+	actor string
+	host  string
+	runId string
+}
+
+func newAuditor(a *audit.Audit) *auditor {
+	actor := "cli:reconcile"
+	if u, err := user.Current(); err == nil {
+		actor += ":" + u.Username
+	}
+
+	host, _ := os.Hostname()
+
+	return &auditor{audit: a, actor: actor, host: host, runId: uuid.NewString()}
+}
+
+func (a *auditor) add(ctx context.Context, row reconcile.Row) error {
+	if row.Adjustment == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(row.Adjustment)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.audit.Record(ctx, models.AuditRecord{
+		Actor:         a.actor,
+		ClientIP:      a.host,
+		RequestId:     a.runId,
+		Action:        "RECONCILE --fix",
+		Path:          "/wallet/" + row.WalletId.String(),
+		Status:        http.StatusOK,
+		Outcome:       "OK",
+		PayloadDigest: audit.Digest(payload),
+	})
+
+	return err
+}
+
+type report struct {
+	format string
+	all    bool
+	out    io.Writer
+	csv    *csv.Writer
+	rows   []reconcile.Row
+}
+
+func newReport(format string, out io.Writer, all bool) *report {
+	r := &report{format: format, all: all, out: out, rows: []reconcile.Row{}}
+
+	if format == "csv" {
+		r.csv = csv.NewWriter(out)
+		_ = r.csv.Write([]string{
+			"wallet_id", "status", "opening_balance", "ledger_sum", "expected", "balance", "difference",
+			"adjustment_transaction_id", "adjustment_amount",
+		})
+	}
+
+	return r
+}
+
+func (r *report) add(row reconcile.Row) error {
+	if row.Status == reconcile.StatusOK && !r.all {
+		return nil
+	}
+
+	if r.format == "json" {
+		r.rows = append(r.rows, row)
+
+		return nil
+	}
+
+	var adjustmentId, adjustmentAmount string
+	if row.Adjustment != nil {
+		adjustmentId = row.Adjustment.TransactionId.String()
+		adjustmentAmount = row.Adjustment.Amount
+	}
+
+	return r.csv.Write([]string{
+		row.WalletId.String(),
+		string(row.Status),
+		deref(row.OpeningBalance),
+		row.LedgerSum,
+		deref(row.Expected),
+		row.Balance,
+		deref(row.Difference),
+		adjustmentId,
+		adjustmentAmount,
+	})
+}
+
+func (r *report) flush(summary reconcile.Summary) error {
+	if r.format == "csv" {
+		r.csv.Flush()
+
+		return r.csv.Error()
+	}
+
+	enc := json.NewEncoder(r.out)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(struct {
+		Summary reconcile.Summary `json:"summary"`
+		Wallets []reconcile.Row   `json:"wallets"`
+	}{summary, r.rows})
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
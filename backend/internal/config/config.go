@@ -1,63 +1,517 @@
 package config
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// Config is loaded in layers, each one overriding the previous:
+// YAML file, config.env file, environment variables, command line flags.
+// Feature flags default to off, so enable them explicitly in the YAML.
 type Config struct {
 	// Setup environment with default. Else use env-required:"true"
 	Env        string `yaml:"env" env:"ENV" env-default:"local"`
 	HTTPServer `yaml:"http_server"`
+	Postgres   Postgres  `yaml:"postgres"`
+	Logging    Logging   `yaml:"logging"`
+	Limits     Limits    `yaml:"limits"`
+	Shutdown   Shutdown  `yaml:"shutdown"`
+	Features   Features  `yaml:"features"`
+	Outbox     Outbox    `yaml:"outbox"`
+	Webhooks   Webhooks  `yaml:"webhooks"`
+	Cache      Cache     `yaml:"cache"`
+	Tracing    Tracing   `yaml:"tracing"`
+	RateLimit  RateLimit `yaml:"rate_limit"`
+	Tx         Tx        `yaml:"tx"`
+	Auth       Auth      `yaml:"auth"`
+	Signing    Signing   `yaml:"signing"`
+	Policy     Policy    `yaml:"policy"`
+	Rules      []Rule    `yaml:"rules"`
+	Scheduler  Scheduler `yaml:"scheduler"`
+	FX         FX        `yaml:"fx"`
+	Fees       Fees      `yaml:"fees"`
 }
 
 type HTTPServer struct {
-	Address     string        `yaml:"address" env-default:"localhost:8080"`
+	Address     string        `yaml:"address" env:"HTTP_ADDRESS" env-default:"localhost:8080"`
 	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	TLS         TLS           `yaml:"tls"`
 }
 
+// TLS is used with features.tls. Changed certificate and CA files are picked up every ReloadInterval.
+type TLS struct {
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE"`
+	// MinVersion is one of: 1.2, 1.3
+	MinVersion string `yaml:"min_version" env:"TLS_MIN_VERSION" env-default:"1.2"`
+	// CipherSuites are crypto/tls names of TLS 1.2 suites, empty means the Go defaults.
+	CipherSuites   []string      `yaml:"cipher_suites" env:"TLS_CIPHER_SUITES" env-separator:","`
+	ReloadInterval time.Duration `yaml:"reload_interval" env-default:"30s"`
+	// ClientAuth is one of: none, optional, require
+	ClientAuth   string `yaml:"client_auth" env:"TLS_CLIENT_AUTH" env-default:"none"`
+	ClientCAFile string `yaml:"client_ca_file" env:"TLS_CLIENT_CA_FILE"`
+	// Clients maps a client certificate identity (URI SAN, DNS SAN or common name) to its scopes.
+	Clients map[string][]string `yaml:"clients"`
+}
+
+type Postgres struct {
+	Host     string `yaml:"host" env:"POSTGRES_HOST" env-default:"dbase"`
+	Port     int    `yaml:"port" env:"POSTGRES_PORT" env-default:"5432"`
+	User     string `yaml:"user" env:"POSTGRES_USER"`
+	Password string `yaml:"password" env:"POSTGRES_PASSWORD"`
+	DB       string `yaml:"db" env:"POSTGRES_DB"`
+	// SSLMode is one of: disable, require, verify-ca, verify-full
+	SSLMode         string        `yaml:"sslmode" env:"POSTGRES_SSLMODE" env-default:"disable"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env-default:"50"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env-default:"10"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env-default:"30m"`
+	Replicas        Replicas      `yaml:"replicas"`
+}
+
+// Replicas are read-only postgres replicas used for balance reads.
+type Replicas struct {
+	DSNs                []string      `yaml:"dsns" env:"POSTGRES_REPLICAS" env-separator:","`
+	MaxLag              time.Duration `yaml:"max_lag" env-default:"5s"`
+	HealthCheckInterval time.Duration `yaml:"health_check_interval" env-default:"2s"`
+}
+
+// DSN returns the connection string of the primary in the key=value form used by lib/pq.
+func (p Postgres) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		p.Host, p.Port, p.User, quote(p.Password), p.DB, p.SSLMode)
+}
+
+// URL returns the connection string of the primary as a postgres:// URL, e.g. for migrate.
+func (p Postgres) URL() string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(p.User, p.Password),
+		Host:     fmt.Sprintf("%s:%d", p.Host, p.Port),
+		Path:     p.DB,
+		RawQuery: url.Values{"sslmode": {p.SSLMode}}.Encode(),
+	}
+
+	return u.String()
+}
+
+// Logging defaults depend on Env: pretty debug logs locally, JSON elsewhere, info level in prod.
+type Logging struct {
+	// Level is one of: debug, info, warn, error
+	Level string `yaml:"level" env:"LOG_LEVEL"`
+	// Format is one of: pretty, json, text
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+}
+
+// Limits protect the server from oversized and hanging requests.
+type Limits struct {
+	MaxBodyBytes int64 `yaml:"max_body_bytes" env-default:"1048576"`
+	// RequestTimeout is the deadline of the request context, zero disables it.
+	RequestTimeout time.Duration `yaml:"request_timeout" env-default:"10s"`
+	// MaxBatchItems caps the operations of one POST /wallets/operations/batch.
+	MaxBatchItems int `yaml:"max_batch_items" env-default:"1000"`
+}
+
+type Shutdown struct {
+	// DrainDelay is how long /readyz reports draining before the server stops accepting connections.
+	DrainDelay time.Duration `yaml:"drain_delay" env-default:"0s"`
+	Timeout    time.Duration `yaml:"timeout" env-default:"10s"`
+}
+
+// Features switch optional subsystems on. Their settings live in the sections of the same name.
+type Features struct {
+	Outbox    bool `yaml:"outbox" env:"OUTBOX_ENABLED"`
+	Webhooks  bool `yaml:"webhooks" env:"WEBHOOKS_ENABLED"`
+	Cache     bool `yaml:"cache" env:"CACHE_ENABLED"`
+	Tracing   bool `yaml:"tracing" env:"TRACING_ENABLED"`
+	RateLimit bool `yaml:"rate_limit" env:"RATE_LIMIT_ENABLED"`
+	// Auth off lets every caller move money on any wallet without the admin API, use it only locally.
+	Auth bool `yaml:"auth" env:"AUTH_ENABLED"`
+	// Signing requires HMAC signed requests on POST /wallet.
+	Signing bool `yaml:"signing" env:"SIGNING_ENABLED"`
+	// TLS serves HTTPS, optionally verifying client certificates.
+	TLS bool `yaml:"tls" env:"TLS_ENABLED"`
+	// Rules screens deposits and withdrawals with the fraud rules.
+	Rules bool `yaml:"rules" env:"RULES_ENABLED"`
+	// Scheduler runs due scheduled operations, the endpoints to manage them are always on.
+	Scheduler bool `yaml:"scheduler" env:"SCHEDULER_ENABLED"`
+	// Fees charges deposits and withdrawals by the fee schedules.
+	Fees bool `yaml:"fees" env:"FEES_ENABLED"`
+}
+
+type Outbox struct {
+	// Publisher is one of: stdout, file
+	Publisher    string        `yaml:"publisher" env-default:"stdout"`
+	FilePath     string        `yaml:"file_path" env-default:"events.log"`
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"100"`
+	// Lease reserves a claimed batch for one relay, it must be longer than publishing the batch takes.
+	Lease      time.Duration `yaml:"lease" env-default:"1m"`
+	MinBackoff time.Duration `yaml:"min_backoff" env-default:"1s"`
+	MaxBackoff time.Duration `yaml:"max_backoff" env-default:"5m"`
+}
+
+type Webhooks struct {
+	PollInterval   time.Duration `yaml:"poll_interval" env-default:"1s"`
+	BatchSize      int           `yaml:"batch_size" env-default:"50"`
+	RequestTimeout time.Duration `yaml:"request_timeout" env-default:"5s"`
+	// MaxAttempts after which delivery goes to the dead-letter state
+	MaxAttempts int `yaml:"max_attempts" env-default:"10"`
+	// Lease reserves a claimed batch for one dispatcher, it must exceed batch_size * request_timeout.
+	Lease      time.Duration `yaml:"lease" env-default:"5m"`
+	MinBackoff time.Duration `yaml:"min_backoff" env-default:"5s"`
+	MaxBackoff time.Duration `yaml:"max_backoff" env-default:"1h"`
+	// AllowPrivateNetworks lets subscriptions target loopback, private and link-local addresses.
+	// Keep it off outside local development.
+	AllowPrivateNetworks bool `yaml:"allow_private_networks" env-default:"false"`
+}
+
+// Cache is the in-process balance cache.
+type Cache struct {
+	Size int           `yaml:"size" env-default:"10000"`
+	TTL  time.Duration `yaml:"ttl" env-default:"5s"`
+	// WatermarkTTL must be longer than the replication lag.
+	WatermarkTTL  time.Duration `yaml:"watermark_ttl" env-default:"1m"`
+	StatsInterval time.Duration `yaml:"stats_interval" env-default:"1m"`
+}
+
+// Tracing configures OpenTelemetry span export.
+type Tracing struct {
+	ServiceName string `yaml:"service_name" env-default:"coin-app"`
+	// Exporter is one of: stdout, otlp
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"otlp"`
+	// Endpoint of the OTLP/HTTP collector
+	Endpoint    string  `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" env-default:"otel-collector:4318"`
+	Insecure    bool    `yaml:"insecure" env-default:"true"`
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`
+}
+
+// RateLimit throttles requests per client and per client and wallet.
+// Rates are tokens per second, a zero rate disables the bucket.
+type RateLimit struct {
+	// Backend is one of: memory, postgres
+	Backend     string  `yaml:"backend" env:"RATE_LIMIT_BACKEND" env-default:"memory"`
+	ClientRate  float64 `yaml:"client_rate" env-default:"20"`
+	ClientBurst int     `yaml:"client_burst" env-default:"40"`
+	// WalletRate and WalletBurst limit one client on one wallet
+	WalletRate  float64 `yaml:"wallet_rate" env-default:"5"`
+	WalletBurst int     `yaml:"wallet_burst" env-default:"10"`
+	// IdleTTL after which an untouched bucket is forgotten
+	IdleTTL time.Duration `yaml:"idle_ttl" env-default:"10m"`
+}
+
+// Tx configures database transactions and their retries
+// after serialization failures and deadlocks.
+type Tx struct {
+	// Isolation is one of: read committed, repeatable read, serializable
+	Isolation string `yaml:"isolation" env:"TX_ISOLATION" env-default:"read committed"`
+	// MaxAttempts includes the first attempt
+	MaxAttempts int           `yaml:"max_attempts" env-default:"5"`
+	MinBackoff  time.Duration `yaml:"min_backoff" env-default:"10ms"`
+	MaxBackoff  time.Duration `yaml:"max_backoff" env-default:"500ms"`
+}
+
+// Auth validates JWT bearer tokens: HS256 with JWTSecret and/or RS256 with the keys in JWKSFile.
+type Auth struct {
+	JWTSecret string        `yaml:"jwt_secret" env:"JWT_SECRET"`
+	JWKSFile  string        `yaml:"jwks_file" env:"JWKS_FILE"`
+	Issuer    string        `yaml:"issuer" env:"JWT_ISSUER"`
+	Audience  string        `yaml:"audience" env:"JWT_AUDIENCE"`
+	Leeway    time.Duration `yaml:"leeway" env-default:"30s"`
+	// APIKeys lets service callers authenticate with an X-API-Key header.
+	APIKeys bool `yaml:"api_keys" env:"AUTH_API_KEYS" env-default:"true"`
+	// RotationGrace is how long a rotated API key keeps working by default.
+	RotationGrace time.Duration `yaml:"rotation_grace" env-default:"24h"`
+}
+
+type Signing struct {
+	// Backend of the nonce store is one of: memory, postgres
+	Backend string `yaml:"backend" env:"SIGNING_BACKEND" env-default:"memory"`
+	// MaxSkew is how far the request timestamp may be from the server clock.
+	MaxSkew time.Duration `yaml:"max_skew" env-default:"5m"`
+	// Clients maps a client ID to its shared secret, in env as "id1:secret1,id2:secret2".
+	Clients map[string]string `yaml:"clients" env:"SIGNING_CLIENTS"`
+}
+
+// Policy defines the limit tiers wallets are assigned to, a wallet without a tier gets DefaultTier.
+type Policy struct {
+	DefaultTier string                `yaml:"default_tier" env:"POLICY_DEFAULT_TIER" env-default:"standard"`
+	Tiers       map[string]PolicyTier `yaml:"tiers"`
+}
+
+// PolicyTier mirrors models.Limits, an omitted limit is not enforced.
+type PolicyTier struct {
+	MaxBalance        *int `yaml:"max_balance"`
+	MinAmount         *int `yaml:"min_amount"`
+	MaxAmount         *int `yaml:"max_amount"`
+	DailyWithdrawal   *int `yaml:"daily_withdrawal"`
+	MonthlyWithdrawal *int `yaml:"monthly_withdrawal"`
+}
+
+// Rule is a fraud rule, rules are evaluated in order and the first one that triggers decides.
+type Rule struct {
+	Name string `yaml:"name"`
+	// Type is one of: velocity, new_wallet, amount_spike, small_amount
+	Type string `yaml:"type"`
+	// Action is one of: allow, block, flag
+	Action string `yaml:"action"`
+	// Operation is DEPOSIT or WITHDRAW, empty matches both.
+	Operation string `yaml:"operation"`
+
+	// velocity: more than MaxCount operations within Window
+	MaxCount int           `yaml:"max_count"`
+	Window   time.Duration `yaml:"window"`
+	// new_wallet: the wallet is younger than MinAge
+	MinAge time.Duration `yaml:"min_age"`
+	// amount_spike: the amount is above Factor times the average of at least MinHistory earlier operations
+	Factor     float64 `yaml:"factor"`
+	MinHistory int     `yaml:"min_history"`
+	// small_amount: the amount is at most MaxAmount
+	MaxAmount int `yaml:"max_amount"`
+}
+
+// Scheduler runs scheduled operations. Any number of replicas may run it, each operation is claimed by one.
+type Scheduler struct {
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"5s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"50"`
+	// MaxAttempts of an occurrence, then a one-off operation fails and a recurring one skips to the next.
+	MaxAttempts int           `yaml:"max_attempts" env-default:"5"`
+	MinBackoff  time.Duration `yaml:"min_backoff" env-default:"1m"`
+	MaxBackoff  time.Duration `yaml:"max_backoff" env-default:"1h"`
+	// MinEvery is the shortest interval of a recurring operation.
+	MinEvery time.Duration `yaml:"min_every" env-default:"1m"`
+}
+
+// FX configures currency conversions between wallets.
+type FX struct {
+	// RatesFile is a CSV of rates loaded at startup, see fx.FX.LoadRates. Empty means rates are set by admins only.
+	RatesFile string `yaml:"rates_file" env:"FX_RATES_FILE"`
+	// QuoteTTL is how long a quote locks its rate.
+	QuoteTTL time.Duration `yaml:"quote_ttl" env-default:"30s"`
+	// SpreadBps is taken off the market rate, in basis points.
+	SpreadBps int `yaml:"spread_bps" env:"FX_SPREAD_BPS"`
+	// Currencies maps the ISO 4217 codes that may be converted to their decimal places.
+	Currencies map[string]int `yaml:"currencies"`
+}
+
+// Fees are charged by the schedule of the operation and wallet tier and credited to the revenue wallet of the currency.
+type Fees struct {
+	// RevenueWallets maps a currency to the ID of the system wallet its fees are credited to.
+	RevenueWallets map[string]string `yaml:"revenue_wallets"`
+	Schedules      []FeeSchedule     `yaml:"schedules"`
+	// SettleInterval is how often the accrued fees are credited to the revenue wallets.
+	SettleInterval  time.Duration `yaml:"settle_interval" env-default:"1s"`
+	SettleBatchSize int           `yaml:"settle_batch_size" env-default:"1000"`
+}
+
+// FeeSchedule mirrors models.FeeSchedule.
+type FeeSchedule struct {
+	Name string `yaml:"name"`
+	// Operation is DEPOSIT or WITHDRAW.
+	Operation string `yaml:"operation"`
+	// Tier is a policy tier, empty matches the wallets of every tier.
+	Tier string `yaml:"tier"`
+	// Type is one of: flat, percentage, tiered
+	Type   string    `yaml:"type"`
+	Amount int       `yaml:"amount"`
+	Bps    int       `yaml:"bps"`
+	Bands  []FeeBand `yaml:"bands"`
+	Min    int       `yaml:"min"`
+	Max    int       `yaml:"max"`
+}
+
+// FeeBand applies to amounts up to UpTo, zero is unbounded.
+type FeeBand struct {
+	UpTo   int `yaml:"up_to"`
+	Amount int `yaml:"amount"`
+	Bps    int `yaml:"bps"`
+}
+
+// flags are the command line options shared by all commands.
+type flags struct {
+	configPath  string
+	envPath     string
+	printConfig bool
+	env         string
+	address     string
+	logLevel    string
+}
+
+// MustLoad parses the command line and loads the config.
+// Commands register their own flags before calling it.
+// With --print-config it prints the effective config with secrets redacted and exits.
 func MustLoad() *Config {
-	configPath := fetchConfigPath()
-	if configPath == "" {
-		log.Fatal("config path is empty")
+	f := parseFlags()
+
+	cfg, err := Load(f.configPath, f.envPath)
+	if err != nil {
+		log.Fatalf("cannot read config: %s", err)
 	}
 
-	return MustLoadPath(configPath)
+	f.apply(cfg)
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid config:\n%s", err)
+	}
+
+	if f.printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("cannot print config: %s", err)
+		}
+		os.Exit(0)
+	}
+
+	return cfg
 }
 
+// MustLoadPath loads and validates the config without looking at the command line.
 func MustLoadPath(configPath string) *Config {
-	// check if file exists
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		log.Fatalf("config file does not exist: %s", configPath)
+	cfg, err := Load(configPath, "")
+	if err != nil {
+		log.Fatalf("cannot read config: %s", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid config:\n%s", err)
+	}
+
+	return cfg
+}
+
+// Load reads the YAML files in configPath, a comma-separated list where every file overrides
+// the values the previous ones set, and the optional dotenv file at envPath,
+// then applies environment variables. Variables already set in the environment
+// take precedence over the dotenv file.
+// An empty envPath means config.env next to the first YAML file, if it exists.
+func Load(configPath string, envPath string) (*Config, error) {
+	if configPath == "" {
+		return nil, errors.New("config path is empty")
+	}
+
+	paths := strings.Split(configPath, ",")
+	for i, path := range paths {
+		paths[i] = strings.TrimSpace(path)
+		if _, err := os.Stat(paths[i]); os.IsNotExist(err) {
+			return nil, fmt.Errorf("config file does not exist: %s", paths[i])
+		}
+	}
+
+	if envPath == "" {
+		envPath = filepath.Join(filepath.Dir(paths[0]), "config.env")
+		if _, err := os.Stat(envPath); os.IsNotExist(err) {
+			envPath = ""
+		}
+	}
+
+	if envPath != "" {
+		if err := godotenv.Load(envPath); err != nil {
+			return nil, fmt.Errorf("cannot read env file %s: %w", envPath, err)
+		}
 	}
 
 	var cfg Config
 
-	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
-		log.Fatalf("cannot read config: %s", err)
+	for _, path := range paths {
+		if err := readYAML(path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		return nil, err
 	}
 
-	return &cfg
+	return &cfg, nil
 }
 
-// fetchConfigPath fetches config path from command line flag or environment variable.
-// Priority: flag > env > default.
+// readYAML decodes the file over cfg: keys it sets replace the current values, maps are merged
+// and values it leaves out are kept.
+func readYAML(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := yaml.NewDecoder(f).Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// parseFlags fetches the config location and overrides from the command line.
+// Config path priority: flag > env > default.
 // Default value is empty string.
-func fetchConfigPath() string {
-	var res string
+func parseFlags() flags {
+	var f flags
 
 	// --config="path/to/config.yaml"
-	flag.StringVar(&res, "config", "", "path to config file")
+	flag.StringVar(&f.configPath, "config", "", "path to config file, a comma-separated list applies each file over the previous ones")
+	flag.StringVar(&f.envPath, "env-file", "", "path to config.env, next to the config file by default")
+	flag.BoolVar(&f.printConfig, "print-config", false, "print the effective config with secrets redacted and exit")
+	flag.StringVar(&f.env, "env", "", "override env: local, dev or prod")
+	flag.StringVar(&f.address, "address", "", "override http_server.address")
+	flag.StringVar(&f.logLevel, "log-level", "", "override logging.level")
 	flag.Parse()
 
-	if res == "" {
-		res = os.Getenv("CONFIG_PATH")
+	if f.configPath == "" {
+		f.configPath = os.Getenv("CONFIG_PATH")
+	}
+	if f.envPath == "" {
+		f.envPath = os.Getenv("CONFIG_ENV_PATH")
+	}
+
+	return f
+}
+
+func (f flags) apply(cfg *Config) {
+	if f.env != "" {
+		cfg.Env = f.env
+	}
+	if f.address != "" {
+		cfg.HTTPServer.Address = f.address
+	}
+	if f.logLevel != "" {
+		cfg.Logging.Level = f.logLevel
+	}
+}
+
+// Print writes the config as YAML with secrets redacted.
+func (c Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+
+	return enc.Encode(c.Redacted())
+}
+
+// quote escapes a value for a key=value connection string.
+func quote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	out := make([]byte, 0, len(s)+2)
+	out = append(out, '\'')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\'' || s[i] == '\\' {
+			out = append(out, '\\')
+		}
+		out = append(out, s[i])
 	}
 
-	return res
+	return string(append(out, '\''))
 }
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	return path
+}
+
+func TestLoadAppliesFilesInOrder(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "base.yaml", `
+logging:
+  level: "debug"
+  format: "pretty"
+features:
+  rules: true
+  scheduler: true
+tx:
+  isolation: "serializable"
+policy:
+  default_tier: "standard"
+  tiers:
+    standard:
+      max_balance: 1000
+`)
+	override := writeFile(t, dir, "override.yaml", `
+logging:
+  level: "info"
+features:
+  rules: false
+policy:
+  default_tier: "unlimited"
+  tiers:
+    unlimited: {}
+`)
+
+	cfg, err := Load(base+", "+override, "")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Logging.Level != "info" || cfg.Features.Rules {
+		t.Errorf("override not applied: logging.level = %q, features.rules = %v", cfg.Logging.Level, cfg.Features.Rules)
+	}
+	if cfg.Logging.Format != "pretty" || !cfg.Features.Scheduler || cfg.Tx.Isolation != "serializable" {
+		t.Errorf("base values the override leaves out not kept: logging.format = %q, features.scheduler = %v, tx.isolation = %q",
+			cfg.Logging.Format, cfg.Features.Scheduler, cfg.Tx.Isolation)
+	}
+	if _, ok := cfg.Policy.Tiers["standard"]; !ok || cfg.Policy.DefaultTier != "unlimited" {
+		t.Errorf("policy tiers = %v, default %q, want both tiers and the unlimited default", cfg.Policy.Tiers, cfg.Policy.DefaultTier)
+	}
+}
+
+func TestLoadRejectsMissingFile(t *testing.T) {
+	base := writeFile(t, t.TempDir(), "base.yaml", "env: \"local\"\n")
+
+	_, err := Load(base+",missing.yaml", "")
+	if err == nil || !strings.Contains(err.Error(), "missing.yaml") {
+		t.Fatalf("Load() error = %v, want the missing file named", err)
+	}
+}
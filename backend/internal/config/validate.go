@@ -0,0 +1,308 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const redacted = "REDACTED"
+
+// Validate reports every invalid value at once, one per line, named by its YAML path.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, path string, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+		}
+	}
+	oneOf := func(value string, path string, allowed ...string) {
+		check(slices.Contains(allowed, value), path, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+
+	oneOf(c.Env, "env", "local", "dev", "prod")
+
+	check(c.HTTPServer.Address != "", "http_server.address", "must not be empty")
+	check(c.HTTPServer.Timeout > 0, "http_server.timeout", "must be positive")
+	check(c.HTTPServer.IdleTimeout > 0, "http_server.idle_timeout", "must be positive")
+
+	if c.Features.TLS {
+		t := c.HTTPServer.TLS
+		check(t.CertFile != "", "http_server.tls.cert_file", "must not be empty (TLS_CERT_FILE)")
+		check(t.KeyFile != "", "http_server.tls.key_file", "must not be empty (TLS_KEY_FILE)")
+		oneOf(t.MinVersion, "http_server.tls.min_version", "1.2", "1.3")
+		check(len(t.CipherSuites) == 0 || t.MinVersion == "1.2", "http_server.tls.cipher_suites", "only apply to TLS 1.2")
+		for _, name := range t.CipherSuites {
+			check(secureCipherSuite(name), "http_server.tls.cipher_suites", "unknown or insecure suite %q", name)
+		}
+		check(t.ReloadInterval > 0, "http_server.tls.reload_interval", "must be positive")
+		oneOf(t.ClientAuth, "http_server.tls.client_auth", "none", "optional", "require")
+		check(t.ClientAuth == "none" || t.ClientCAFile != "", "http_server.tls.client_ca_file", "must not be empty with client_auth %s", t.ClientAuth)
+		check(len(t.Clients) == 0 || t.ClientAuth != "none", "http_server.tls.clients", "need client_auth optional or require")
+		for identity, scopes := range t.Clients {
+			check(len(scopes) > 0, "http_server.tls.clients."+identity, "must have at least one scope")
+		}
+	}
+
+	check(c.Postgres.Host != "", "postgres.host", "must not be empty (POSTGRES_HOST)")
+	check(c.Postgres.Port > 0 && c.Postgres.Port < 65536, "postgres.port", "must be a TCP port, got %d", c.Postgres.Port)
+	check(c.Postgres.User != "", "postgres.user", "must not be empty (POSTGRES_USER)")
+	check(c.Postgres.DB != "", "postgres.db", "must not be empty (POSTGRES_DB)")
+	oneOf(c.Postgres.SSLMode, "postgres.sslmode", "disable", "require", "verify-ca", "verify-full")
+	check(c.Postgres.MaxOpenConns >= 0, "postgres.max_open_conns", "must not be negative")
+	check(c.Postgres.MaxIdleConns >= 0, "postgres.max_idle_conns", "must not be negative")
+	check(c.Postgres.Replicas.MaxLag > 0, "postgres.replicas.max_lag", "must be positive")
+	check(c.Postgres.Replicas.HealthCheckInterval > 0, "postgres.replicas.health_check_interval", "must be positive")
+
+	if c.Logging.Level != "" {
+		oneOf(c.Logging.Level, "logging.level", "debug", "info", "warn", "error")
+	}
+	if c.Logging.Format != "" {
+		oneOf(c.Logging.Format, "logging.format", "pretty", "json", "text")
+	}
+
+	check(c.Limits.MaxBodyBytes > 0, "limits.max_body_bytes", "must be positive")
+	check(c.Limits.RequestTimeout >= 0, "limits.request_timeout", "must not be negative")
+	check(c.Limits.MaxBatchItems > 0, "limits.max_batch_items", "must be positive")
+
+	check(c.Shutdown.DrainDelay >= 0, "shutdown.drain_delay", "must not be negative")
+	check(c.Shutdown.Timeout > 0, "shutdown.timeout", "must be positive")
+
+	if c.Features.Outbox {
+		oneOf(c.Outbox.Publisher, "outbox.publisher", "stdout", "file")
+		check(c.Outbox.Publisher != "file" || c.Outbox.FilePath != "", "outbox.file_path", "must not be empty with the file publisher")
+		check(c.Outbox.PollInterval > 0, "outbox.poll_interval", "must be positive")
+		check(c.Outbox.BatchSize > 0, "outbox.batch_size", "must be positive")
+		check(c.Outbox.Lease > 0, "outbox.lease", "must be positive")
+		check(c.Outbox.MinBackoff <= c.Outbox.MaxBackoff, "outbox.min_backoff", "must not exceed max_backoff")
+	}
+
+	if c.Features.Webhooks {
+		check(c.Features.Outbox, "features.webhooks", "requires features.outbox")
+		check(c.Webhooks.PollInterval > 0, "webhooks.poll_interval", "must be positive")
+		check(c.Webhooks.BatchSize > 0, "webhooks.batch_size", "must be positive")
+		check(c.Webhooks.RequestTimeout > 0, "webhooks.request_timeout", "must be positive")
+		check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts", "must be positive")
+		check(c.Webhooks.Lease > time.Duration(c.Webhooks.BatchSize)*c.Webhooks.RequestTimeout, "webhooks.lease", "must exceed batch_size * request_timeout")
+		check(c.Webhooks.MinBackoff <= c.Webhooks.MaxBackoff, "webhooks.min_backoff", "must not exceed max_backoff")
+	}
+
+	if c.Features.Cache {
+		check(c.Cache.Size > 0, "cache.size", "must be positive")
+		check(c.Cache.TTL > 0, "cache.ttl", "must be positive")
+		check(c.Cache.WatermarkTTL > c.Postgres.Replicas.MaxLag, "cache.watermark_ttl", "must be longer than postgres.replicas.max_lag")
+	}
+
+	if c.Features.Tracing {
+		oneOf(c.Tracing.Exporter, "tracing.exporter", "stdout", "otlp")
+		check(c.Tracing.Exporter != "otlp" || c.Tracing.Endpoint != "", "tracing.endpoint", "must not be empty with the otlp exporter")
+		check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be within [0, 1], got %g", c.Tracing.SampleRatio)
+	}
+
+	if c.Features.RateLimit {
+		oneOf(c.RateLimit.Backend, "rate_limit.backend", "memory", "postgres")
+		check(c.RateLimit.ClientRate >= 0, "rate_limit.client_rate", "must not be negative")
+		check(c.RateLimit.ClientRate == 0 || c.RateLimit.ClientBurst >= 1, "rate_limit.client_burst", "must be at least 1")
+		check(c.RateLimit.WalletRate >= 0, "rate_limit.wallet_rate", "must not be negative")
+		check(c.RateLimit.WalletRate == 0 || c.RateLimit.WalletBurst >= 1, "rate_limit.wallet_burst", "must be at least 1")
+		check(c.RateLimit.IdleTTL > 0, "rate_limit.idle_ttl", "must be positive")
+	}
+
+	if c.Features.Signing {
+		oneOf(c.Signing.Backend, "signing.backend", "memory", "postgres")
+		check(c.Signing.MaxSkew > 0, "signing.max_skew", "must be positive")
+		check(len(c.Signing.Clients) > 0, "signing.clients", "at least one client (SIGNING_CLIENTS) must be set")
+		for id, secret := range c.Signing.Clients {
+			check(len(secret) >= 32, "signing.clients."+id, "secret must be at least 32 bytes")
+		}
+	}
+
+	if c.Features.Auth {
+		check(c.Auth.JWTSecret != "" || c.Auth.JWKSFile != "" || c.Auth.APIKeys, "auth", "jwt_secret (JWT_SECRET), jwks_file or api_keys must be set")
+		check(c.Auth.RotationGrace >= 0, "auth.rotation_grace", "must not be negative")
+		check(c.Auth.JWTSecret == "" || len(c.Auth.JWTSecret) >= 32, "auth.jwt_secret", "must be at least 32 bytes")
+		check(c.Auth.Leeway >= 0, "auth.leeway", "must not be negative")
+	}
+
+	if len(c.Policy.Tiers) > 0 {
+		_, ok := c.Policy.Tiers[c.Policy.DefaultTier]
+		check(ok, "policy.default_tier", "must be one of the tiers, got %q", c.Policy.DefaultTier)
+	}
+	for name, tier := range c.Policy.Tiers {
+		path := "policy.tiers." + name
+		for field, v := range map[string]*int{
+			"max_balance":        tier.MaxBalance,
+			"min_amount":         tier.MinAmount,
+			"max_amount":         tier.MaxAmount,
+			"daily_withdrawal":   tier.DailyWithdrawal,
+			"monthly_withdrawal": tier.MonthlyWithdrawal,
+		} {
+			check(v == nil || *v >= 0, path+"."+field, "must not be negative")
+		}
+		check(tier.MinAmount == nil || tier.MaxAmount == nil || *tier.MinAmount <= *tier.MaxAmount,
+			path+".min_amount", "must not exceed max_amount")
+	}
+
+	if c.Features.Rules {
+		names := make(map[string]bool, len(c.Rules))
+		for i, rule := range c.Rules {
+			path := fmt.Sprintf("rules[%d]", i)
+			check(rule.Name != "", path+".name", "must not be empty")
+			check(!names[rule.Name], path+".name", "duplicate rule %q", rule.Name)
+			names[rule.Name] = true
+
+			oneOf(rule.Action, path+".action", "allow", "block", "flag")
+			if rule.Operation != "" {
+				oneOf(rule.Operation, path+".operation", "DEPOSIT", "WITHDRAW")
+			}
+
+			switch rule.Type {
+			case "velocity":
+				check(rule.MaxCount >= 0, path+".max_count", "must not be negative")
+				check(rule.Window > 0, path+".window", "must be positive")
+			case "new_wallet":
+				check(rule.MinAge > 0, path+".min_age", "must be positive")
+			case "amount_spike":
+				check(rule.Factor > 0, path+".factor", "must be positive")
+				check(rule.MinHistory >= 1, path+".min_history", "must be at least 1")
+			case "small_amount":
+				check(rule.MaxAmount > 0, path+".max_amount", "must be positive")
+			default:
+				oneOf(rule.Type, path+".type", "velocity", "new_wallet", "amount_spike", "small_amount")
+			}
+		}
+	}
+
+	if c.Features.Fees {
+		check(c.Fees.SettleInterval > 0, "fees.settle_interval", "must be positive")
+		check(c.Fees.SettleBatchSize > 0, "fees.settle_batch_size", "must be positive")
+		for code, id := range c.Fees.RevenueWallets {
+			check(currencyCode.MatchString(code), "fees.revenue_wallets", "%q is not an ISO 4217 code", code)
+			_, err := uuid.Parse(id)
+			check(err == nil, "fees.revenue_wallets."+code, "must be a wallet ID")
+		}
+
+		names := make(map[string]bool, len(c.Fees.Schedules))
+		for i, schedule := range c.Fees.Schedules {
+			path := fmt.Sprintf("fees.schedules[%d]", i)
+			check(schedule.Name != "", path+".name", "must not be empty")
+			check(!names[schedule.Name], path+".name", "duplicate schedule %q", schedule.Name)
+			names[schedule.Name] = true
+
+			oneOf(schedule.Operation, path+".operation", "DEPOSIT", "WITHDRAW")
+			if schedule.Tier != "" {
+				_, ok := c.Policy.Tiers[schedule.Tier]
+				check(ok, path+".tier", "must be one of the policy tiers, got %q", schedule.Tier)
+			}
+			check(schedule.Amount >= 0, path+".amount", "must not be negative")
+			check(schedule.Bps >= 0 && schedule.Bps <= 10000, path+".bps", "must be in [0, 10000]")
+			check(schedule.Min >= 0, path+".min", "must not be negative")
+			check(schedule.Max >= 0, path+".max", "must not be negative")
+			check(schedule.Max == 0 || schedule.Min <= schedule.Max, path+".min", "must not exceed max")
+
+			switch schedule.Type {
+			case "flat", "percentage":
+				check(len(schedule.Bands) == 0, path+".bands", "are only used by tiered fees")
+			case "tiered":
+				check(len(schedule.Bands) > 0, path+".bands", "must not be empty")
+				prev := 0
+				for j, band := range schedule.Bands {
+					bandPath := fmt.Sprintf("%s.bands[%d]", path, j)
+					check(band.Amount >= 0, bandPath+".amount", "must not be negative")
+					check(band.Bps >= 0 && band.Bps <= 10000, bandPath+".bps", "must be in [0, 10000]")
+					if band.UpTo == 0 {
+						check(j == len(schedule.Bands)-1, bandPath+".up_to", "only the last band may be unbounded")
+					} else {
+						check(band.UpTo > prev, bandPath+".up_to", "must be ascending")
+					}
+					prev = band.UpTo
+				}
+			default:
+				oneOf(schedule.Type, path+".type", "flat", "percentage", "tiered")
+			}
+		}
+	}
+
+	if c.Features.Scheduler {
+		check(c.Scheduler.PollInterval > 0, "scheduler.poll_interval", "must be positive")
+		check(c.Scheduler.BatchSize > 0, "scheduler.batch_size", "must be positive")
+		check(c.Scheduler.MaxAttempts > 0, "scheduler.max_attempts", "must be positive")
+		check(c.Scheduler.MinBackoff > 0, "scheduler.min_backoff", "must be positive")
+		check(c.Scheduler.MinBackoff <= c.Scheduler.MaxBackoff, "scheduler.min_backoff", "must not exceed max_backoff")
+	}
+	check(c.Scheduler.MinEvery > 0, "scheduler.min_every", "must be positive")
+
+	check(c.FX.QuoteTTL > 0, "fx.quote_ttl", "must be positive")
+	check(c.FX.SpreadBps >= 0 && c.FX.SpreadBps < 10000, "fx.spread_bps", "must be in [0, 10000)")
+	for code, places := range c.FX.Currencies {
+		check(currencyCode.MatchString(code), "fx.currencies", "%q is not an ISO 4217 code", code)
+		check(places >= 0 && places <= 2, "fx.currencies", "%s must have 0 to 2 decimal places", code)
+	}
+
+	oneOf(strings.ToLower(c.Tx.Isolation), "tx.isolation", "read committed", "repeatable read", "serializable")
+	check(c.Tx.MaxAttempts >= 1, "tx.max_attempts", "must be at least 1")
+	check(c.Tx.MinBackoff <= c.Tx.MaxBackoff, "tx.min_backoff", "must not exceed max_backoff")
+
+	return errors.Join(errs...)
+}
+
+func secureCipherSuite(name string) bool {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Redacted returns a copy of the config that is safe to log.
+func (c Config) Redacted() Config {
+	if c.Postgres.Password != "" {
+		c.Postgres.Password = redacted
+	}
+
+	if c.Auth.JWTSecret != "" {
+		c.Auth.JWTSecret = redacted
+	}
+
+	if c.Signing.Clients != nil {
+		clients := make(map[string]string, len(c.Signing.Clients))
+		for id := range c.Signing.Clients {
+			clients[id] = redacted
+		}
+		c.Signing.Clients = clients
+	}
+
+	dsns := make([]string, len(c.Postgres.Replicas.DSNs))
+	for i, dsn := range c.Postgres.Replicas.DSNs {
+		dsns[i] = redactDSN(dsn)
+	}
+	c.Postgres.Replicas.DSNs = dsns
+
+	return c
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+var dsnPassword = regexp.MustCompile(`password=('(\\.|[^'])*'|\S+)`)
+
+// redactDSN hides the password of a URL or key=value connection string.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+
+		return u.String()
+	}
+
+	return dsnPassword.ReplaceAllString(dsn, "password="+redacted)
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AdjustmentStatus string
+
+const (
+	AdjustmentPending  AdjustmentStatus = "PENDING"
+	AdjustmentApproved AdjustmentStatus = "APPROVED"
+	AdjustmentRejected AdjustmentStatus = "REJECTED"
+)
+
+// Valid reports whether s is one of the known statuses.
+func (s AdjustmentStatus) Valid() bool {
+	switch s {
+	case AdjustmentPending, AdjustmentApproved, AdjustmentRejected:
+		return true
+	}
+
+	return false
+}
+
+// AdjustmentRequest is a manual balance change proposed by one operator
+// and approved or rejected by another one.
+type AdjustmentRequest struct {
+	Id       uuid.UUID `json:"id"`
+	WalletId uuid.UUID `json:"walletId"`
+	// Amount is signed, a positive amount credits the wallet.
+	Amount     int              `json:"amount"`
+	Reason     string           `json:"reason"`
+	Status     AdjustmentStatus `json:"status"`
+	ProposedBy string           `json:"proposedBy"`
+	DecidedBy  string           `json:"decidedBy,omitempty"`
+	// Proposer and Decider are the operators behind ProposedBy and DecidedBy,
+	// the same person may propose with a token and decide with an API key.
+	Proposer string `json:"proposer"`
+	Decider  string `json:"decider,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+	// TransactionId is the ADJUSTMENT entry posted on approval.
+	TransactionId *uuid.UUID `json:"transactionId,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	DecidedAt     *time.Time `json:"decidedAt,omitempty"`
+}
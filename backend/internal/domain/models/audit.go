@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// AuditRecord is one entry of the append-only audit log.
+// Hash covers every other field and the hash of the previous record.
+type AuditRecord struct {
+	Seq       int64     `json:"seq"`
+	CreatedAt time.Time `json:"createdAt"`
+	Actor     string    `json:"actor"`
+	ClientIP  string    `json:"clientIp"`
+	RequestId string    `json:"requestId"`
+	// Action is the method and route pattern, e.g. "POST /wallet".
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+	// Outcome is the status of the API response, "OK" or "Error",
+	// or AuditStarted for the record written before the request is handled.
+	Outcome string `json:"outcome"`
+	// PayloadDigest is the hex SHA-256 of the request body.
+	PayloadDigest string `json:"payloadDigest"`
+	PrevHash      string `json:"prevHash"`
+	Hash          string `json:"hash"`
+}
+
+// AuditStarted is the outcome of the record written before a mutating request is handled.
+// The record of how it ended follows with the same request id.
+const AuditStarted = "Started"
+
+// AuditFilter selects audit records, zero fields match everything.
+type AuditFilter struct {
+	Actor     string
+	RequestId string
+	// AfterSeq returns records with a greater seq, for paging.
+	AfterSeq int64
+	Limit    int
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventWalletCreated  EventType = "WalletCreated"
+	EventFundsDeposited EventType = "FundsDeposited"
+	EventFundsWithdrawn EventType = "FundsWithdrawn"
+	EventFundsAdjusted  EventType = "FundsAdjusted"
+	EventFundsConverted EventType = "FundsConverted"
+)
+
+// Valid reports whether t is one of the known event types.
+func (t EventType) Valid() bool {
+	switch t {
+	case EventWalletCreated, EventFundsDeposited, EventFundsWithdrawn, EventFundsAdjusted, EventFundsConverted:
+		return true
+	}
+
+	return false
+}
+
+// Event is a domain event stored in the outbox.
+// AggregateId is the id of the wallet the event belongs to,
+// events of one aggregate are published in the order they were saved.
+type Event struct {
+	Id          uuid.UUID       `json:"id"`
+	AggregateId uuid.UUID       `json:"aggregateId"`
+	Type        EventType       `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	Attempts    int             `json:"-"`
+}
+
+type WalletCreatedPayload struct {
+	WalletId uuid.UUID `json:"walletId"`
+	UserId   uuid.UUID `json:"userId"`
+	Balance  int       `json:"balance"`
+	Currency string    `json:"currency"`
+}
+
+type FundsMovedPayload struct {
+	TransactionId uuid.UUID `json:"transactionId"`
+	WalletId      uuid.UUID `json:"walletId"`
+	OperationType string    `json:"operationType"`
+	Amount        int       `json:"amount"`
+	TransactionDetails
+	// Fee is taken out of Amount and credited to the revenue wallet in FeeTransactionId.
+	Fee              int        `json:"fee,omitempty"`
+	FeeTransactionId *uuid.UUID `json:"feeTransactionId,omitempty"`
+}
+
+// FundsAdjustedPayload describes an approved manual adjustment, Amount is signed.
+type FundsAdjustedPayload struct {
+	TransactionId uuid.UUID `json:"transactionId"`
+	WalletId      uuid.UUID `json:"walletId"`
+	Amount        int       `json:"amount"`
+	Reason        string    `json:"reason"`
+}
+
+// FundsConvertedPayload describes an executed conversion, it is saved for both wallets.
+type FundsConvertedPayload struct {
+	QuoteId             uuid.UUID `json:"quoteId"`
+	FromWalletId        uuid.UUID `json:"fromWalletId"`
+	ToWalletId          uuid.UUID `json:"toWalletId"`
+	DebitTransactionId  uuid.UUID `json:"debitTransactionId"`
+	CreditTransactionId uuid.UUID `json:"creditTransactionId"`
+	SellAmount          int       `json:"sellAmount"`
+	SellCurrency        string    `json:"sellCurrency"`
+	BuyAmount           string    `json:"buyAmount"`
+	BuyCurrency         string    `json:"buyCurrency"`
+	Rate                string    `json:"rate"`
+	Spread              string    `json:"spread"`
+}
+
+// NewEvent builds an event with a fresh id for the given aggregate.
+func NewEvent(aggregateId uuid.UUID, eventType EventType, payload any) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		Id:          uuid.New(),
+		AggregateId: aggregateId,
+		Type:        eventType,
+		Payload:     data,
+		CreatedAt:   time.Now().UTC(),
+	}, nil
+}
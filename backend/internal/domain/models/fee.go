@@ -0,0 +1,86 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+type FeeType string
+
+const (
+	FeeFlat       FeeType = "flat"
+	FeePercentage FeeType = "percentage"
+	// FeeTiered prices by the first band the amount falls into.
+	FeeTiered FeeType = "tiered"
+)
+
+// FeeSchedule prices the operations of one type on the wallets of one tier, an empty Tier matches every tier.
+type FeeSchedule struct {
+	Name      string
+	Operation string
+	Tier      string
+	Type      FeeType
+	// Amount is the flat fee, Bps the percentage in basis points.
+	Amount int
+	Bps    int
+	Bands  []FeeBand
+	// Min and Max cap the fee, zero is no cap.
+	Min int
+	Max int
+}
+
+// FeeBand applies to amounts up to UpTo, zero is unbounded. The fee is Amount plus Bps of the amount.
+type FeeBand struct {
+	UpTo   int
+	Amount int
+	Bps    int
+}
+
+// Fee returns the fee on amount, capped by Min and Max and never above the amount itself.
+// Percentages are rounded half up to whole units.
+func (s FeeSchedule) Fee(amount int) int {
+	var fee int
+	switch s.Type {
+	case FeeFlat:
+		fee = s.Amount
+	case FeePercentage:
+		fee = percent(amount, s.Bps)
+	case FeeTiered:
+		for _, band := range s.Bands {
+			if band.UpTo == 0 || amount <= band.UpTo {
+				fee = band.Amount + percent(amount, band.Bps)
+				break
+			}
+		}
+	}
+
+	if s.Min > 0 && fee < s.Min {
+		fee = s.Min
+	}
+	if s.Max > 0 && fee > s.Max {
+		fee = s.Max
+	}
+
+	return max(0, min(fee, amount))
+}
+
+func percent(amount, bps int) int {
+	return (amount*bps + 5000) / 10000
+}
+
+// Fee is charged on an operation and credited to RevenueWalletId.
+type Fee struct {
+	Amount          int
+	Schedule        string
+	RevenueWalletId uuid.UUID
+}
+
+// Receipt is the outcome of a deposit or withdrawal. The fee is taken out of the gross amount:
+// a deposit credits the net amount, a withdrawal debits the gross one and pays out the net one.
+type Receipt struct {
+	TransactionId uuid.UUID `json:"transactionId"`
+	// FeeTransactionId is the credit of the revenue wallet, set if a fee was charged.
+	FeeTransactionId *uuid.UUID `json:"feeTransactionId,omitempty"`
+	Gross            int        `json:"gross"`
+	Fee              int        `json:"fee"`
+	Net              int        `json:"net"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FXRate is the price of one unit of Base in Quote from EffectiveAt on.
+// Rates and amounts with a fractional part are exact decimal strings.
+type FXRate struct {
+	Base        string    `json:"base"`
+	Quote       string    `json:"quote"`
+	Rate        string    `json:"rate"`
+	EffectiveAt time.Time `json:"effectiveAt"`
+	// Source is the file the rate was loaded from or the operator who set it.
+	Source string `json:"source"`
+}
+
+// FXQuote locks a conversion of SellAmount from one wallet into BuyAmount on another until ExpiresAt.
+// BuyAmount is SellAmount times AppliedRate, the market Rate less the Spread,
+// rounded to the precision of the buy currency.
+type FXQuote struct {
+	Id           uuid.UUID `json:"id"`
+	FromWalletId uuid.UUID `json:"fromWalletId"`
+	ToWalletId   uuid.UUID `json:"toWalletId"`
+	FromCurrency string    `json:"fromCurrency"`
+	ToCurrency   string    `json:"toCurrency"`
+	SellAmount   int       `json:"sellAmount"`
+	BuyAmount    string    `json:"buyAmount"`
+	Rate         string    `json:"rate"`
+	// Spread is a fraction, e.g. "0.005" for 0.5%.
+	Spread      string    `json:"spread"`
+	AppliedRate string    `json:"appliedRate"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	CreatedBy   string    `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+	// Set once the quote is executed.
+	ExecutedAt          *time.Time `json:"executedAt,omitempty"`
+	DebitTransactionId  *uuid.UUID `json:"debitTransactionId,omitempty"`
+	CreditTransactionId *uuid.UUID `json:"creditTransactionId,omitempty"`
+}
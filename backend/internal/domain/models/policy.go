@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Limits are the product rules of a wallet, a nil limit is not enforced.
+type Limits struct {
+	MaxBalance *int `json:"maxBalance,omitempty" yaml:"max_balance"`
+	// MinAmount and MaxAmount bound a single deposit or withdrawal.
+	MinAmount *int `json:"minAmount,omitempty" yaml:"min_amount"`
+	MaxAmount *int `json:"maxAmount,omitempty" yaml:"max_amount"`
+	// DailyWithdrawal and MonthlyWithdrawal cap the withdrawn total
+	// since the start of the current day and month.
+	DailyWithdrawal   *int `json:"dailyWithdrawal,omitempty" yaml:"daily_withdrawal"`
+	MonthlyWithdrawal *int `json:"monthlyWithdrawal,omitempty" yaml:"monthly_withdrawal"`
+}
+
+// Override returns l with every limit set in o replaced.
+func (l Limits) Override(o Limits) Limits {
+	if o.MaxBalance != nil {
+		l.MaxBalance = o.MaxBalance
+	}
+	if o.MinAmount != nil {
+		l.MinAmount = o.MinAmount
+	}
+	if o.MaxAmount != nil {
+		l.MaxAmount = o.MaxAmount
+	}
+	if o.DailyWithdrawal != nil {
+		l.DailyWithdrawal = o.DailyWithdrawal
+	}
+	if o.MonthlyWithdrawal != nil {
+		l.MonthlyWithdrawal = o.MonthlyWithdrawal
+	}
+
+	return l
+}
+
+// WalletPolicy assigns a tier to a wallet together with per-wallet overrides.
+type WalletPolicy struct {
+	WalletId  uuid.UUID `json:"walletId"`
+	Tier      string    `json:"tier"`
+	Overrides Limits    `json:"overrides"`
+	// Effective are the tier limits with the overrides applied.
+	Effective Limits     `json:"effective"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
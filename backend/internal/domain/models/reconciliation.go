@@ -0,0 +1,23 @@
+package models
+
+import "github.com/google/uuid"
+
+// LedgerBalance compares the stored balance of a wallet with its ledger.
+// Amounts are kept as decimal strings to avoid rounding.
+type LedgerBalance struct {
+	WalletId uuid.UUID `json:"walletId"`
+	// OpeningBalance is nil for wallets created before it was recorded.
+	OpeningBalance *string `json:"openingBalance"`
+	Balance        string  `json:"balance"`
+	LedgerSum      string  `json:"ledgerSum"`
+	// Expected is OpeningBalance plus LedgerSum, Difference is Balance minus Expected.
+	Expected   *string `json:"expected"`
+	Difference *string `json:"difference"`
+}
+
+type Adjustment struct {
+	TransactionId uuid.UUID `json:"transactionId"`
+	WalletId      uuid.UUID `json:"walletId"`
+	Amount        string    `json:"amount"`
+	Reason        string    `json:"reason"`
+}
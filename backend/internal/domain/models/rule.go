@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RuleAction is what the rules engine does with an operation.
+type RuleAction string
+
+const (
+	RuleAllow RuleAction = "ALLOW"
+	RuleBlock RuleAction = "BLOCK"
+	// RuleFlag lets the operation through and puts it in the review queue.
+	RuleFlag RuleAction = "FLAG"
+)
+
+// Valid reports whether a is one of the known actions.
+func (a RuleAction) Valid() bool {
+	switch a {
+	case RuleAllow, RuleBlock, RuleFlag:
+		return true
+	}
+
+	return false
+}
+
+type ReviewStatus string
+
+const (
+	ReviewPending ReviewStatus = "PENDING"
+	ReviewCleared ReviewStatus = "CLEARED"
+	// ReviewFraud confirms the flagged operation as fraudulent.
+	ReviewFraud ReviewStatus = "FRAUD"
+)
+
+// Valid reports whether s is one of the known statuses.
+func (s ReviewStatus) Valid() bool {
+	switch s {
+	case ReviewPending, ReviewCleared, ReviewFraud:
+		return true
+	}
+
+	return false
+}
+
+// RuleDecision records how the rules engine screened an operation.
+type RuleDecision struct {
+	Id uuid.UUID `json:"id"`
+	// TransactionId is the transaction the operation was saved as, or would have been if blocked.
+	TransactionId uuid.UUID  `json:"transactionId"`
+	WalletId      uuid.UUID  `json:"walletId"`
+	OperationType string     `json:"operationType"`
+	Amount        int        `json:"amount"`
+	Action        RuleAction `json:"action"`
+	// Rule is the name of the rule that decided, empty when no rule matched.
+	Rule   string `json:"rule,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Actor  string `json:"actor"`
+	// ReviewStatus is set for flagged operations only.
+	ReviewStatus  ReviewStatus `json:"reviewStatus,omitempty"`
+	ReviewedBy    string       `json:"reviewedBy,omitempty"`
+	ReviewComment string       `json:"reviewComment,omitempty"`
+	CreatedAt     time.Time    `json:"createdAt"`
+	ReviewedAt    *time.Time   `json:"reviewedAt,omitempty"`
+}
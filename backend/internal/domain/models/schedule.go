@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ScheduleDeposit  = "DEPOSIT"
+	ScheduleWithdraw = "WITHDRAW"
+	// ScheduleTransfer withdraws from WalletId and deposits to TargetWalletId in one transaction.
+	ScheduleTransfer = "TRANSFER"
+)
+
+type ScheduleStatus string
+
+const (
+	ScheduleActive    ScheduleStatus = "ACTIVE"
+	SchedulePaused    ScheduleStatus = "PAUSED"
+	ScheduleCompleted ScheduleStatus = "COMPLETED"
+	// ScheduleFailed means a run failed for good, e.g. the wallet is gone.
+	ScheduleFailed   ScheduleStatus = "FAILED"
+	ScheduleCanceled ScheduleStatus = "CANCELED"
+)
+
+// ScheduledOperation is a future-dated or recurring deposit, withdrawal or transfer.
+// A one-off operation has neither Cron nor Every and runs once at its first DueAt.
+type ScheduledOperation struct {
+	Id             uuid.UUID  `json:"id"`
+	OperationType  string     `json:"operationType"`
+	WalletId       uuid.UUID  `json:"walletId"`
+	TargetWalletId *uuid.UUID `json:"targetWalletId,omitempty"`
+	Amount         int        `json:"amount"`
+	Description    string     `json:"description,omitempty"`
+	// Cron is a five-field expression in UTC.
+	Cron string `json:"cron,omitempty"`
+	// Every is an interval such as "24h".
+	Every  string         `json:"every,omitempty"`
+	Status ScheduleStatus `json:"status"`
+	// DueAt is the occurrence to run, NextRunAt when to try it, later than DueAt while retrying.
+	DueAt     *time.Time `json:"dueAt,omitempty"`
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+	Runs      int        `json:"runs"`
+	// Attempts are the failed attempts of the current occurrence.
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError,omitempty"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type RunStatus string
+
+const (
+	RunSucceeded RunStatus = "SUCCEEDED"
+	RunFailed    RunStatus = "FAILED"
+)
+
+// ScheduledRun is a single attempt to run an occurrence of a scheduled operation.
+type ScheduledRun struct {
+	Id             uuid.UUID   `json:"id"`
+	ScheduleId     uuid.UUID   `json:"scheduleId"`
+	DueAt          time.Time   `json:"dueAt"`
+	Attempt        int         `json:"attempt"`
+	Status         RunStatus   `json:"status"`
+	TransactionIds []uuid.UUID `json:"transactionIds,omitempty"`
+	Error          string      `json:"error,omitempty"`
+	CreatedAt      time.Time   `json:"createdAt"`
+}
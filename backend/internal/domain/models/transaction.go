@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransactionDetails link a deposit or withdrawal to the caller's records.
+type TransactionDetails struct {
+	Description string `json:"description,omitempty"`
+	// Metadata is a free-form JSON object.
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// ExternalReference is unique per wallet, e.g. an order ID.
+	ExternalReference string `json:"externalReference,omitempty"`
+}
+
+// Transaction is an entry of the wallet ledger.
+type Transaction struct {
+	Id            uuid.UUID `json:"id"`
+	WalletId      uuid.UUID `json:"walletId"`
+	OperationType string    `json:"operationType"`
+	Amount        float64   `json:"amount"`
+	TransactionDetails
+	// Fee charged on a deposit or withdrawal, FeeFor links a FEE entry to it.
+	Fee       int        `json:"fee,omitempty"`
+	FeeFor    *uuid.UUID `json:"feeFor,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
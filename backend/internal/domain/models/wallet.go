@@ -6,10 +6,17 @@ import (
 	"github.com/google/uuid"
 )
 
+// DefaultCurrency is given to wallets opened without a currency, as the column default.
+const DefaultCurrency = "RUB"
+
 type Wallet struct {
-	Id        uuid.UUID `json:"id"`
-	UserId    uuid.UUID `json:"userId"`
-	Balance   float64   `json:"balance"`
+	Id      uuid.UUID `json:"id"`
+	UserId  uuid.UUID `json:"userId"`
+	Balance float64   `json:"balance"`
+	// Currency is an ISO 4217 code.
+	Currency string `json:"currency"`
+	// Version is incremented by every balance change.
+	Version   int64     `json:"version"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
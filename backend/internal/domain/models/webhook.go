@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryDead      DeliveryStatus = "dead"
+)
+
+type WebhookSubscription struct {
+	Id         uuid.UUID   `json:"id"`
+	URL        string      `json:"url"`
+	EventTypes []EventType `json:"eventTypes"`
+	Secret     string      `json:"-"`
+	Active     bool        `json:"active"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	// Owner is the operator of the caller that subscribed, see auth.Principal.Operator.
+	Owner string `json:"owner"`
+	// UserId limits the events to the wallets of the user, nil for all wallets.
+	UserId *uuid.UUID `json:"userId,omitempty"`
+	// WalletId limits the events to the wallet, nil for all wallets.
+	WalletId *uuid.UUID `json:"walletId,omitempty"`
+}
+
+type WebhookDelivery struct {
+	Id             uuid.UUID       `json:"id"`
+	SubscriptionId uuid.UUID       `json:"subscriptionId"`
+	EventId        uuid.UUID       `json:"eventId"`
+	AggregateId    uuid.UUID       `json:"aggregateId"`
+	EventType      EventType       `json:"eventType"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+	LastStatusCode *int            `json:"lastStatusCode,omitempty"`
+	LastError      *string         `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+
+	// Target of the delivery, filled for pending deliveries only.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
@@ -0,0 +1,105 @@
+package approve
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/adjustment"
+	walletService "coin-app/internal/services/wallet"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// Request is optional.
+type Request struct {
+	Comment string `json:"comment,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Adjustment models.AdjustmentRequest `json:"adjustment"`
+}
+
+type Approver interface {
+	Approve(ctx context.Context, requestId uuid.UUID, comment string) (models.AdjustmentRequest, error)
+}
+
+func New(log *slog.Logger, approver Approver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.adjustment.approve.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		requestId, err := uuid.Parse(chi.URLParam(r, "adjustmentId"))
+		if err != nil {
+			log.Error("invalid adjustmentId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid adjustmentId"))
+			return
+		}
+
+		var req Request
+
+		err = render.DecodeJSON(r.Body, &req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		approved, err := approver.Approve(r.Context(), requestId, req.Comment)
+		if errors.Is(err, adjustment.ErrRequestNotExists) {
+			render.JSON(w, r, resp.Error("adjustment not exists"))
+			return
+		}
+		if errors.Is(err, adjustment.ErrAlreadyDecided) {
+			render.JSON(w, r, resp.Error("adjustment is already decided"))
+			return
+		}
+		if errors.Is(err, adjustment.ErrNoOperator) {
+			render.JSON(w, r, resp.Error("caller is not bound to an operator, use a token or a key issued with one"))
+			return
+		}
+		if errors.Is(err, adjustment.ErrSelfApproval) {
+			render.JSON(w, r, resp.Error("adjustment must be approved by another operator"))
+			return
+		}
+		if errors.Is(err, walletService.ErrForbidden) || errors.Is(err, walletService.ErrUnauthenticated) ||
+			errors.Is(err, adjustment.ErrUnauthenticated) {
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if errors.Is(err, adjustment.ErrConflict) {
+			render.JSON(w, r, resp.Error("wallet is busy, try again"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to approve adjustment", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to approve adjustment"))
+			return
+		}
+
+		log.Info("adjustment approved",
+			slog.String("id", requestId.String()),
+			slog.String("transactionId", approved.TransactionId.String()),
+		)
+
+		render.JSON(w, r, Response{
+			Response:   resp.OK(),
+			Adjustment: approved,
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package list
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/adjustment"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	resp.Response
+	Adjustments []models.AdjustmentRequest `json:"adjustments"`
+}
+
+type Lister interface {
+	List(ctx context.Context, status models.AdjustmentStatus, limit int) ([]models.AdjustmentRequest, error)
+}
+
+// New returns adjustment requests filtered by the status and limit query parameters.
+func New(log *slog.Logger, lister Lister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.adjustment.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		limit := adjustment.DefaultLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > adjustment.MaxLimit {
+				log.Error("invalid limit", slog.String("limit", v))
+				render.JSON(w, r, resp.Error("invalid limit"))
+				return
+			}
+		}
+
+		status := models.AdjustmentStatus(r.URL.Query().Get("status"))
+
+		adjustments, err := lister.List(r.Context(), status, limit)
+		if errors.Is(err, adjustment.ErrInvalidStatus) {
+			render.JSON(w, r, resp.Error("invalid status"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to list adjustments", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to list adjustments"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response:    resp.OK(),
+			Adjustments: adjustments,
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package propose
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/adjustment"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Request struct {
+	WalletId uuid.UUID `json:"walletId"`
+	// Amount is signed, a positive amount credits the wallet.
+	Amount int    `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+type Response struct {
+	resp.Response
+	Adjustment models.AdjustmentRequest `json:"adjustment"`
+}
+
+type Proposer interface {
+	Propose(
+		ctx context.Context,
+		walletId uuid.UUID,
+		amount int,
+		reason string,
+	) (models.AdjustmentRequest, error)
+}
+
+func New(log *slog.Logger, proposer Proposer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.adjustment.propose.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, resp.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		proposed, err := proposer.Propose(r.Context(), req.WalletId, req.Amount, req.Reason)
+		if errors.Is(err, adjustment.ErrNoOperator) {
+			render.JSON(w, r, resp.Error("caller is not bound to an operator, use a token or a key issued with one"))
+
+			return
+		}
+		if errors.Is(err, adjustment.ErrInvalidAmount) {
+			render.JSON(w, r, resp.Error("amount must not be zero"))
+
+			return
+		}
+		if errors.Is(err, adjustment.ErrNoReason) {
+			render.JSON(w, r, resp.Error("reason is required"))
+
+			return
+		}
+		if errors.Is(err, adjustment.ErrWalletNotExists) {
+			log.Warn("wallet not exists", slog.String("walletId", req.WalletId.String()))
+
+			render.JSON(w, r, resp.Error("wallet not exists"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to propose adjustment", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to propose adjustment"))
+
+			return
+		}
+
+		log.Info("adjustment proposed", slog.String("id", proposed.Id.String()))
+
+		render.JSON(w, r, Response{
+			Response:   resp.OK(),
+			Adjustment: proposed,
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package issue
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/apikey"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Request struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresIn is a Go duration like "720h", empty for a key that does not expire.
+	ExpiresIn string `json:"expiresIn,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	APIKey models.APIKey `json:"apiKey"`
+	// Key is returned only once, on issue.
+	Key string `json:"key"`
+}
+
+type Issuer interface {
+	Issue(
+		ctx context.Context,
+		name string,
+		scopes []string,
+		expiresIn time.Duration,
+	) (models.APIKey, string, error)
+}
+
+func New(log *slog.Logger, issuer Issuer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikey.issue.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, resp.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		var expiresIn time.Duration
+		if req.ExpiresIn != "" {
+			expiresIn, err = time.ParseDuration(req.ExpiresIn)
+			if err != nil {
+				log.Warn("invalid expiresIn", sl.Err(err))
+
+				render.JSON(w, r, resp.Error("invalid expiresIn"))
+
+				return
+			}
+		}
+
+		key, secret, err := issuer.Issue(r.Context(), req.Name, req.Scopes, expiresIn)
+		if errors.Is(err, apikey.ErrInvalidName) {
+			render.JSON(w, r, resp.Error("invalid name"))
+
+			return
+		}
+		if errors.Is(err, apikey.ErrInvalidScope) {
+			log.Warn("invalid scope", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("invalid scope"))
+
+			return
+		}
+		if errors.Is(err, apikey.ErrInvalidExpiry) {
+			render.JSON(w, r, resp.Error("invalid expiresIn"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to issue api key", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to issue api key"))
+
+			return
+		}
+
+		log.Info("api key issued", slog.String("id", key.Id.String()))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			APIKey:   key,
+			Key:      secret,
+		})
+	}
+}
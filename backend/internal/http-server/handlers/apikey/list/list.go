@@ -0,0 +1,48 @@
+package list
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	resp.Response
+	APIKeys []models.APIKey `json:"apiKeys"`
+}
+
+type Lister interface {
+	List(ctx context.Context) ([]models.APIKey, error)
+}
+
+func New(log *slog.Logger, lister Lister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikey.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		keys, err := lister.List(r.Context())
+		if err != nil {
+			log.Error("failed to list api keys", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to list api keys"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			APIKeys:  keys,
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package revoke
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+
+	"log/slog"
+
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/apikey"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Revoker interface {
+	Revoke(ctx context.Context, keyId uuid.UUID) error
+}
+
+func New(log *slog.Logger, revoker Revoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikey.revoke.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		keyId, err := uuid.Parse(chi.URLParam(r, "keyId"))
+		if err != nil {
+			log.Error("invalid keyId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid keyId"))
+			return
+		}
+
+		err = revoker.Revoke(r.Context(), keyId)
+		if errors.Is(err, apikey.ErrKeyNotExists) {
+			log.Warn("api key not exists", slog.String("keyId", keyId.String()))
+			render.JSON(w, r, resp.Error("api key not exists"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to revoke api key", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to revoke api key"))
+			return
+		}
+
+		log.Info("api key revoked", slog.String("id", keyId.String()))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
@@ -0,0 +1,122 @@
+package rotate
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/apikey"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// Request is optional, an empty body rotates with the default grace period.
+type Request struct {
+	// Grace is how long the old key keeps working, a Go duration like "1h".
+	Grace string `json:"grace,omitempty"`
+	// ExpiresIn is the lifetime of the new key, empty for a key that does not expire.
+	ExpiresIn string `json:"expiresIn,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	APIKey models.APIKey `json:"apiKey"`
+	// Key is returned only once, on rotation.
+	Key string `json:"key"`
+}
+
+type Rotator interface {
+	Rotate(
+		ctx context.Context,
+		keyId uuid.UUID,
+		grace time.Duration,
+		expiresIn time.Duration,
+	) (models.APIKey, string, error)
+}
+
+func New(log *slog.Logger, rotator Rotator, defaultGrace time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.apikey.rotate.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		keyId, err := uuid.Parse(chi.URLParam(r, "keyId"))
+		if err != nil {
+			log.Error("invalid keyId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid keyId"))
+			return
+		}
+
+		var req Request
+
+		err = render.DecodeJSON(r.Body, &req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		grace := defaultGrace
+		if req.Grace != "" {
+			grace, err = time.ParseDuration(req.Grace)
+			if err != nil {
+				log.Warn("invalid grace", sl.Err(err))
+				render.JSON(w, r, resp.Error("invalid grace"))
+				return
+			}
+		}
+
+		var expiresIn time.Duration
+		if req.ExpiresIn != "" {
+			expiresIn, err = time.ParseDuration(req.ExpiresIn)
+			if err != nil {
+				log.Warn("invalid expiresIn", sl.Err(err))
+				render.JSON(w, r, resp.Error("invalid expiresIn"))
+				return
+			}
+		}
+
+		key, secret, err := rotator.Rotate(r.Context(), keyId, grace, expiresIn)
+		if errors.Is(err, apikey.ErrKeyNotExists) {
+			log.Warn("api key not exists", slog.String("keyId", keyId.String()))
+			render.JSON(w, r, resp.Error("api key not exists"))
+			return
+		}
+		if errors.Is(err, apikey.ErrKeyRevoked) {
+			log.Warn("api key is no longer valid", slog.String("keyId", keyId.String()))
+			render.JSON(w, r, resp.Error("api key revoked or expired"))
+			return
+		}
+		if errors.Is(err, apikey.ErrInvalidExpiry) {
+			render.JSON(w, r, resp.Error("invalid grace or expiresIn"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to rotate api key", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to rotate api key"))
+			return
+		}
+
+		log.Info("api key rotated", slog.String("id", keyId.String()), slog.String("newId", key.Id.String()))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			APIKey:   key,
+			Key:      secret,
+		})
+	}
+}
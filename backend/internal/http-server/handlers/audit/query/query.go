@@ -0,0 +1,84 @@
+package query
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"net/http"
+	"strconv"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/audit"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	resp.Response
+	Records []models.AuditRecord `json:"records"`
+	// NextAfterSeq is passed as afterSeq to get the next page, 0 on the last page.
+	NextAfterSeq int64 `json:"nextAfterSeq,omitempty"`
+}
+
+type RecordProvider interface {
+	List(ctx context.Context, filter models.AuditFilter) ([]models.AuditRecord, error)
+}
+
+// New returns audit records filtered by the actor, requestId, afterSeq and limit query parameters.
+func New(log *slog.Logger, recordProvider RecordProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.audit.query.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		q := r.URL.Query()
+		filter := models.AuditFilter{
+			Actor:     q.Get("actor"),
+			RequestId: q.Get("requestId"),
+			Limit:     audit.DefaultLimit,
+		}
+
+		var err error
+		if v := q.Get("afterSeq"); v != "" {
+			filter.AfterSeq, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || filter.AfterSeq < 0 {
+				log.Error("invalid afterSeq", slog.String("afterSeq", v))
+				render.JSON(w, r, resp.Error("invalid afterSeq"))
+				return
+			}
+		}
+		if v := q.Get("limit"); v != "" {
+			filter.Limit, err = strconv.Atoi(v)
+			if err != nil || filter.Limit <= 0 || filter.Limit > audit.MaxLimit {
+				log.Error("invalid limit", slog.String("limit", v))
+				render.JSON(w, r, resp.Error("invalid limit"))
+				return
+			}
+		}
+
+		records, err := recordProvider.List(r.Context(), filter)
+		if err != nil {
+			log.Error("failed to get audit records", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to get audit records"))
+			return
+		}
+
+		var next int64
+		if len(records) == filter.Limit {
+			next = records[len(records)-1].Seq
+		}
+
+		render.JSON(w, r, Response{
+			Response:     resp.OK(),
+			Records:      records,
+			NextAfterSeq: next,
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package execute
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/fx"
+	"coin-app/internal/services/wallet"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Response struct {
+	resp.Response
+	Quote models.FXQuote `json:"quote"`
+}
+
+type Executor interface {
+	Execute(ctx context.Context, quoteId uuid.UUID) (models.FXQuote, error)
+}
+
+// New converts at the quoted rate, the quote carries the ids of both transactions.
+func New(log *slog.Logger, executor Executor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.fx.execute.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		quoteId, err := uuid.Parse(chi.URLParam(r, "quoteId"))
+		if err != nil {
+			log.Error("invalid quoteId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid quoteId"))
+			return
+		}
+
+		q, err := executor.Execute(r.Context(), quoteId)
+		if errors.Is(err, fx.ErrQuoteNotExists) {
+			render.JSON(w, r, resp.Error("quote not exists"))
+			return
+		}
+		if errors.Is(err, fx.ErrQuoteExpired) {
+			render.JSON(w, r, resp.Error("quote expired"))
+			return
+		}
+		if errors.Is(err, fx.ErrAlreadyExecuted) {
+			render.JSON(w, r, resp.Error("quote is already executed"))
+			return
+		}
+		if errors.Is(err, fx.ErrWalletNotExists) {
+			render.JSON(w, r, resp.Error("wallet not exists"))
+			return
+		}
+		if errors.Is(err, fx.ErrForbidden) || errors.Is(err, fx.ErrUnauthenticated) {
+			log.Warn("quote execution denied", slog.String("quoteId", quoteId.String()))
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if errors.Is(err, wallet.ErrCurrencyMismatch) {
+			render.JSON(w, r, resp.Error("wallet currency changed since the quote"))
+			return
+		}
+		var limitErr *wallet.LimitError
+		if errors.As(err, &limitErr) {
+			log.Warn("conversion exceeds wallet limit", slog.String("limit", limitErr.Limit))
+			render.JSON(w, r, resp.Error("limit exceeded: "+limitErr.Limit))
+			return
+		}
+		if errors.Is(err, fx.ErrConflict) {
+			render.JSON(w, r, resp.Error("wallet is busy, try again"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to execute quote", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to execute quote"))
+			return
+		}
+
+		log.Info("quote executed", slog.String("id", quoteId.String()))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Quote:    q,
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package quote
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/fx"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Request struct {
+	FromWalletId uuid.UUID `json:"fromWalletId"`
+	ToWalletId   uuid.UUID `json:"toWalletId"`
+	// SellAmount is in the currency of the from wallet.
+	SellAmount int `json:"sellAmount"`
+}
+
+type Response struct {
+	resp.Response
+	Quote models.FXQuote `json:"quote"`
+}
+
+type Quoter interface {
+	Quote(ctx context.Context, fromWalletId, toWalletId uuid.UUID, sellAmount int) (models.FXQuote, error)
+}
+
+// New quotes a conversion between two wallets, the rate is locked until the quote expires.
+func New(log *slog.Logger, quoter Quoter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.fx.quote.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			render.JSON(w, r, resp.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		q, err := quoter.Quote(r.Context(), req.FromWalletId, req.ToWalletId, req.SellAmount)
+		if errors.Is(err, fx.ErrInvalidAmount) {
+			render.JSON(w, r, resp.Error("sellAmount is too small"))
+			return
+		}
+		if errors.Is(err, fx.ErrSameCurrency) {
+			render.JSON(w, r, resp.Error("wallets hold the same currency"))
+			return
+		}
+		if errors.Is(err, fx.ErrUnsupportedCurrency) {
+			render.JSON(w, r, resp.Error("currency is not convertible"))
+			return
+		}
+		if errors.Is(err, fx.ErrRateNotExists) {
+			log.Warn("no exchange rate", sl.Err(err))
+			render.JSON(w, r, resp.Error("no exchange rate for the currencies"))
+			return
+		}
+		if errors.Is(err, fx.ErrWalletNotExists) {
+			render.JSON(w, r, resp.Error("wallet not exists"))
+			return
+		}
+		if errors.Is(err, fx.ErrForbidden) || errors.Is(err, fx.ErrUnauthenticated) {
+			log.Warn("access to wallet denied")
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to quote conversion", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to quote conversion"))
+			return
+		}
+
+		log.Info("conversion quoted", slog.String("id", q.Id.String()))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Quote:    q,
+		})
+	}
+}
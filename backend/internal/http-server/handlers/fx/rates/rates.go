@@ -0,0 +1,49 @@
+package rates
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	resp.Response
+	Rates []models.FXRate `json:"rates"`
+}
+
+type RatesGetter interface {
+	Rates(ctx context.Context) ([]models.FXRate, error)
+}
+
+// New lists the rate effective now of every currency pair.
+func New(log *slog.Logger, getter RatesGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.fx.rates.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		rates, err := getter.Rates(r.Context())
+		if err != nil {
+			log.Error("failed to get exchange rates", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to get exchange rates"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Rates:    rates,
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package setrates
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/fx"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Request struct {
+	// EffectiveAt of a rate may be omitted to make it effective now.
+	Rates []models.FXRate `json:"rates"`
+}
+
+type Response struct {
+	resp.Response
+	Rates []models.FXRate `json:"rates"`
+}
+
+type RatesSetter interface {
+	SetRates(ctx context.Context, rates []models.FXRate) ([]models.FXRate, error)
+}
+
+// New stores the rates, all or none of them.
+func New(log *slog.Logger, setter RatesSetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.fx.setrates.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			render.JSON(w, r, resp.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+		if len(req.Rates) == 0 {
+			render.JSON(w, r, resp.Error("field rates is a required field"))
+			return
+		}
+
+		rates, err := setter.SetRates(r.Context(), req.Rates)
+		if errors.Is(err, fx.ErrInvalidRate) {
+			log.Warn("invalid exchange rate", sl.Err(err))
+			render.JSON(w, r, resp.Error(errors.Unwrap(err).Error()))
+			return
+		}
+		if errors.Is(err, fx.ErrUnauthenticated) {
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to set exchange rates", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to set exchange rates"))
+			return
+		}
+
+		log.Info("exchange rates set", slog.Int("count", len(rates)))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Rates:    rates,
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package liveness
+
+import (
+	"net/http"
+
+	resp "coin-app/internal/lib/api/response"
+
+	"github.com/go-chi/render"
+)
+
+// New reports that the process is up and serving HTTP.
+// It does not touch dependencies, so a database outage does not restart the container.
+func New() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, resp.OK())
+	}
+}
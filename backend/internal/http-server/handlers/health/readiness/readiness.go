@@ -0,0 +1,27 @@
+package readiness
+
+import (
+	"context"
+	"net/http"
+
+	"coin-app/internal/services/health"
+
+	"github.com/go-chi/render"
+)
+
+type ReadinessChecker interface {
+	Ready(ctx context.Context) health.Report
+}
+
+// New responds 200 when the instance may receive traffic and 503 otherwise,
+// so that nginx and compose health checks can rely on the status code.
+func New(checker ReadinessChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Ready(r.Context())
+		if !report.Ready {
+			render.Status(r, http.StatusServiceUnavailable)
+		}
+
+		render.JSON(w, r, report)
+	}
+}
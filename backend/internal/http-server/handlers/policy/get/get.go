@@ -0,0 +1,57 @@
+package get
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Response struct {
+	resp.Response
+	Policy models.WalletPolicy `json:"policy"`
+}
+
+type PolicyProvider interface {
+	Get(ctx context.Context, walletId uuid.UUID) (models.WalletPolicy, error)
+}
+
+func New(log *slog.Logger, provider PolicyProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.policy.get.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		walletId, err := uuid.Parse(chi.URLParam(r, "walletId"))
+		if err != nil {
+			log.Error("invalid walletId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid walletId"))
+			return
+		}
+
+		policy, err := provider.Get(r.Context(), walletId)
+		if err != nil {
+			log.Error("failed to get wallet policy", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to get wallet policy"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Policy:   policy,
+		})
+	}
+}
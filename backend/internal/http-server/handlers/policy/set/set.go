@@ -0,0 +1,90 @@
+package set
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/policy"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// Request replaces the tier and all overrides, an omitted tier means the default one.
+type Request struct {
+	Tier      string        `json:"tier,omitempty"`
+	Overrides models.Limits `json:"overrides"`
+}
+
+type Response struct {
+	resp.Response
+	Policy models.WalletPolicy `json:"policy"`
+}
+
+type PolicySetter interface {
+	Set(ctx context.Context, walletId uuid.UUID, tier string, overrides models.Limits) (models.WalletPolicy, error)
+}
+
+func New(log *slog.Logger, setter PolicySetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.policy.set.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		walletId, err := uuid.Parse(chi.URLParam(r, "walletId"))
+		if err != nil {
+			log.Error("invalid walletId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid walletId"))
+			return
+		}
+
+		var req Request
+
+		err = render.DecodeJSON(r.Body, &req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		saved, err := setter.Set(r.Context(), walletId, req.Tier, req.Overrides)
+		if errors.Is(err, policy.ErrUnknownTier) {
+			render.JSON(w, r, resp.Error("unknown tier"))
+			return
+		}
+		if errors.Is(err, policy.ErrInvalidLimits) {
+			log.Info("invalid limits", sl.Err(err))
+			render.JSON(w, r, resp.Error(errors.Unwrap(err).Error()))
+			return
+		}
+		if errors.Is(err, policy.ErrWalletNotExists) {
+			render.JSON(w, r, resp.Error("wallet not exists"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to set wallet policy", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to set wallet policy"))
+			return
+		}
+
+		log.Info("wallet policy set", slog.String("walletId", walletId.String()), slog.String("tier", saved.Tier))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Policy:   saved,
+		})
+	}
+}
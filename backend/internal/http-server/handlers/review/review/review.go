@@ -0,0 +1,92 @@
+package review
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/wallet/rules"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Request struct {
+	// Status is CLEARED or FRAUD.
+	Status  models.ReviewStatus `json:"status"`
+	Comment string              `json:"comment,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Review models.RuleDecision `json:"review"`
+}
+
+type Reviewer interface {
+	Review(ctx context.Context, decisionId uuid.UUID, status models.ReviewStatus, comment string) (models.RuleDecision, error)
+}
+
+func New(log *slog.Logger, reviewer Reviewer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.review.review.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		decisionId, err := uuid.Parse(chi.URLParam(r, "decisionId"))
+		if err != nil {
+			log.Error("invalid decisionId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid decisionId"))
+			return
+		}
+
+		var req Request
+
+		err = render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		reviewed, err := reviewer.Review(r.Context(), decisionId, req.Status, req.Comment)
+		if errors.Is(err, rules.ErrInvalidStatus) {
+			render.JSON(w, r, resp.Error("status must be CLEARED or FRAUD"))
+			return
+		}
+		if errors.Is(err, rules.ErrDecisionNotExists) {
+			render.JSON(w, r, resp.Error("flagged operation not exists"))
+			return
+		}
+		if errors.Is(err, rules.ErrAlreadyReviewed) {
+			render.JSON(w, r, resp.Error("flagged operation is already reviewed"))
+			return
+		}
+		if errors.Is(err, rules.ErrUnauthenticated) {
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to review operation", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to review operation"))
+			return
+		}
+
+		log.Info("flagged operation reviewed", slog.String("id", decisionId.String()), slog.String("status", string(reviewed.ReviewStatus)))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Review:   reviewed,
+		})
+	}
+}
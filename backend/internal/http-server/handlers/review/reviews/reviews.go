@@ -0,0 +1,69 @@
+package reviews
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/wallet/rules"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	resp.Response
+	Reviews []models.RuleDecision `json:"reviews"`
+}
+
+type Lister interface {
+	Reviews(ctx context.Context, status models.ReviewStatus, limit int) ([]models.RuleDecision, error)
+}
+
+// New returns flagged operations filtered by the status and limit query parameters.
+func New(log *slog.Logger, lister Lister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.review.reviews.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		limit := rules.DefaultLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > rules.MaxLimit {
+				log.Error("invalid limit", slog.String("limit", v))
+				render.JSON(w, r, resp.Error("invalid limit"))
+				return
+			}
+		}
+
+		status := models.ReviewStatus(r.URL.Query().Get("status"))
+
+		reviews, err := lister.Reviews(r.Context(), status, limit)
+		if errors.Is(err, rules.ErrInvalidStatus) {
+			render.JSON(w, r, resp.Error("invalid status"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to list reviews", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to list reviews"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Reviews:  reviews,
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package cancel
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/scheduler"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Response struct {
+	resp.Response
+	Schedule models.ScheduledOperation `json:"schedule"`
+}
+
+type Canceler interface {
+	Cancel(ctx context.Context, scheduleId uuid.UUID) (models.ScheduledOperation, error)
+}
+
+func New(log *slog.Logger, canceler Canceler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.schedule.cancel.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		scheduleId, err := uuid.Parse(chi.URLParam(r, "scheduleId"))
+		if err != nil {
+			log.Error("invalid scheduleId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid scheduleId"))
+			return
+		}
+
+		sched, err := canceler.Cancel(r.Context(), scheduleId)
+		if errors.Is(err, scheduler.ErrScheduleNotExists) || errors.Is(err, scheduler.ErrWalletNotExists) {
+			render.JSON(w, r, resp.Error("scheduled operation not exists"))
+			return
+		}
+		if errors.Is(err, scheduler.ErrFinished) {
+			render.JSON(w, r, resp.Error("scheduled operation is already finished"))
+			return
+		}
+		if errors.Is(err, scheduler.ErrForbidden) || errors.Is(err, scheduler.ErrUnauthenticated) {
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if errors.Is(err, scheduler.ErrConflict) {
+			render.JSON(w, r, resp.Error("scheduled operation is busy, try again"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to cancel scheduled operation", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to cancel scheduled operation"))
+			return
+		}
+
+		log.Info("scheduled operation canceled", slog.String("id", scheduleId.String()))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Schedule: sched,
+		})
+	}
+}
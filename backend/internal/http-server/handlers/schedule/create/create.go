@@ -0,0 +1,82 @@
+package create
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/scheduler"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	resp.Response
+	Schedule models.ScheduledOperation `json:"schedule"`
+}
+
+type Creator interface {
+	Create(ctx context.Context, spec scheduler.Spec) (models.ScheduledOperation, error)
+}
+
+// New schedules a one-off operation at runAt or a recurring one by cron or every.
+func New(log *slog.Logger, creator Creator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.schedule.create.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		var req scheduler.Spec
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			render.JSON(w, r, resp.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		sched, err := creator.Create(r.Context(), req)
+		if errors.Is(err, scheduler.ErrInvalidSchedule) {
+			log.Warn("invalid scheduled operation", sl.Err(err))
+			render.JSON(w, r, resp.Error(errors.Unwrap(err).Error()))
+			return
+		}
+		if errors.Is(err, scheduler.ErrWalletNotExists) {
+			render.JSON(w, r, resp.Error("wallet not exists"))
+			return
+		}
+		if errors.Is(err, scheduler.ErrForbidden) || errors.Is(err, scheduler.ErrUnauthenticated) {
+			log.Warn("access to wallet denied", slog.String("walletId", req.WalletId.String()))
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to schedule operation", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to schedule operation"))
+			return
+		}
+
+		log.Info("operation scheduled", slog.String("id", sched.Id.String()))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Schedule: sched,
+		})
+	}
+}
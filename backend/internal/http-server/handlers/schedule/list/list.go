@@ -0,0 +1,78 @@
+package list
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/scheduler"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Response struct {
+	resp.Response
+	Schedules []models.ScheduledOperation `json:"schedules"`
+}
+
+type Lister interface {
+	List(ctx context.Context, walletId uuid.UUID, limit int) ([]models.ScheduledOperation, error)
+}
+
+// New returns the operations scheduled on the wallet given by the walletId query parameter.
+func New(log *slog.Logger, lister Lister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.schedule.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		walletId, err := uuid.Parse(r.URL.Query().Get("walletId"))
+		if err != nil {
+			log.Error("invalid walletId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid walletId"))
+			return
+		}
+
+		limit := scheduler.DefaultLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > scheduler.MaxLimit {
+				log.Error("invalid limit", slog.String("limit", v))
+				render.JSON(w, r, resp.Error("invalid limit"))
+				return
+			}
+		}
+
+		schedules, err := lister.List(r.Context(), walletId, limit)
+		if errors.Is(err, scheduler.ErrWalletNotExists) {
+			render.JSON(w, r, resp.Error("wallet not exists"))
+			return
+		}
+		if errors.Is(err, scheduler.ErrForbidden) || errors.Is(err, scheduler.ErrUnauthenticated) {
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to list scheduled operations", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to list scheduled operations"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response:  resp.OK(),
+			Schedules: schedules,
+		})
+	}
+}
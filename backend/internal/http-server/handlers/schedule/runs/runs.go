@@ -0,0 +1,79 @@
+package runs
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/scheduler"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Response struct {
+	resp.Response
+	Runs []models.ScheduledRun `json:"runs"`
+}
+
+type Lister interface {
+	Runs(ctx context.Context, scheduleId uuid.UUID, limit int) ([]models.ScheduledRun, error)
+}
+
+// New returns the attempts to run the operation, failed ones with their error.
+func New(log *slog.Logger, lister Lister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.schedule.runs.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		scheduleId, err := uuid.Parse(chi.URLParam(r, "scheduleId"))
+		if err != nil {
+			log.Error("invalid scheduleId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid scheduleId"))
+			return
+		}
+
+		limit := scheduler.DefaultLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > scheduler.MaxLimit {
+				log.Error("invalid limit", slog.String("limit", v))
+				render.JSON(w, r, resp.Error("invalid limit"))
+				return
+			}
+		}
+
+		runs, err := lister.Runs(r.Context(), scheduleId, limit)
+		if errors.Is(err, scheduler.ErrScheduleNotExists) || errors.Is(err, scheduler.ErrWalletNotExists) {
+			render.JSON(w, r, resp.Error("scheduled operation not exists"))
+			return
+		}
+		if errors.Is(err, scheduler.ErrForbidden) || errors.Is(err, scheduler.ErrUnauthenticated) {
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to list scheduled runs", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to list scheduled runs"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Runs:     runs,
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package update
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/scheduler"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Response struct {
+	resp.Response
+	Schedule models.ScheduledOperation `json:"schedule"`
+}
+
+type Updater interface {
+	Update(ctx context.Context, scheduleId uuid.UUID, patch scheduler.Patch) (models.ScheduledOperation, error)
+}
+
+// New changes the amount or the description, or pauses and resumes with status PAUSED or ACTIVE.
+func New(log *slog.Logger, updater Updater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.schedule.update.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		scheduleId, err := uuid.Parse(chi.URLParam(r, "scheduleId"))
+		if err != nil {
+			log.Error("invalid scheduleId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid scheduleId"))
+			return
+		}
+
+		var req scheduler.Patch
+
+		err = render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		sched, err := updater.Update(r.Context(), scheduleId, req)
+		if errors.Is(err, scheduler.ErrInvalidSchedule) {
+			render.JSON(w, r, resp.Error(errors.Unwrap(err).Error()))
+			return
+		}
+		if errors.Is(err, scheduler.ErrScheduleNotExists) || errors.Is(err, scheduler.ErrWalletNotExists) {
+			render.JSON(w, r, resp.Error("scheduled operation not exists"))
+			return
+		}
+		if errors.Is(err, scheduler.ErrFinished) {
+			render.JSON(w, r, resp.Error("scheduled operation is already finished"))
+			return
+		}
+		if errors.Is(err, scheduler.ErrForbidden) || errors.Is(err, scheduler.ErrUnauthenticated) {
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if errors.Is(err, scheduler.ErrConflict) {
+			render.JSON(w, r, resp.Error("scheduled operation is busy, try again"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to update scheduled operation", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to update scheduled operation"))
+			return
+		}
+
+		log.Info("scheduled operation updated", slog.String("id", scheduleId.String()), slog.String("status", string(sched.Status)))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Schedule: sched,
+		})
+	}
+}
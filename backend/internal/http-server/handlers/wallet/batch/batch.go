@@ -0,0 +1,193 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/services/wallet"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Request struct {
+	// Mode is atomic or independent.
+	Mode       string             `json:"mode"`
+	Operations []wallet.BatchItem `json:"operations"`
+}
+
+type Response struct {
+	resp.Response
+	Mode string `json:"mode,omitempty"`
+	// Applied counts the operations posted by this request, duplicates excluded.
+	Applied int      `json:"applied"`
+	Results []Result `json:"results,omitempty"`
+}
+
+// Result is the outcome of operations[Index], the receipt is set if it was applied or is a duplicate.
+type Result struct {
+	Index int `json:"index"`
+	*models.Receipt
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type BatchPoster interface {
+	PostBatch(ctx context.Context, mode string, items []wallet.BatchItem) ([]wallet.BatchResult, error)
+}
+
+func New(log *slog.Logger, batchPoster BatchPoster, maxItems int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.wallet.batch.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, resp.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		log.Info("request body decoded", slog.String("mode", req.Mode), slog.Int("count", len(req.Operations)))
+
+		if len(req.Operations) > maxItems {
+			log.Warn("batch too large", slog.Int("count", len(req.Operations)))
+
+			render.JSON(w, r, resp.Error(fmt.Sprintf("at most %d operations per batch", maxItems)))
+
+			return
+		}
+
+		results, err := batchPoster.PostBatch(r.Context(), req.Mode, req.Operations)
+		if errors.Is(err, wallet.ErrBatchRolledBack) {
+			log.Warn("batch rolled back", sl.Err(err))
+
+			render.JSON(w, r, Response{
+				Response: resp.Error("batch rolled back"),
+				Mode:     req.Mode,
+				Results:  toResults(results),
+			})
+
+			return
+		}
+		if errors.Is(err, wallet.ErrInvalidBatch) {
+			log.Warn("invalid batch", sl.Err(err))
+
+			render.JSON(w, r, resp.Error(errors.Unwrap(err).Error()))
+
+			return
+		}
+		if errors.Is(err, wallet.ErrUnauthenticated) {
+			render.JSON(w, r, resp.Error("forbidden"))
+
+			return
+		}
+		if errors.Is(err, wallet.ErrConflict) {
+			log.Warn("batch conflicts with concurrent updates")
+
+			render.JSON(w, r, resp.Error("wallet is busy, try again"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to post batch", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to save transactions"))
+
+			return
+		}
+
+		applied := 0
+		for _, result := range results {
+			if result.Err == nil && !result.Duplicate {
+				applied++
+			}
+		}
+
+		log.Info("batch posted", slog.Int("applied", applied))
+
+		render.JSON(w, r, Response{
+			Response: resp.OK(),
+			Mode:     req.Mode,
+			Applied:  applied,
+			Results:  toResults(results),
+		})
+	}
+}
+
+func toResults(results []wallet.BatchResult) []Result {
+	out := make([]Result, len(results))
+	for i, result := range results {
+		out[i] = Result{Index: i, Duplicate: result.Duplicate}
+		if result.Err != nil {
+			out[i].Error = message(result.Err)
+			continue
+		}
+		receipt := result.Receipt
+		out[i].Receipt = &receipt
+	}
+
+	return out
+}
+
+// message is what the client sees for a failed operation, the same as POST /wallet answers.
+func message(err error) string {
+	var limitErr *wallet.LimitError
+	switch {
+	case errors.As(err, &limitErr):
+		return "limit exceeded: " + limitErr.Limit
+	case errors.Is(err, wallet.ErrNotApplied):
+		return "not applied"
+	case errors.Is(err, wallet.ErrInvalidOperation):
+		return "operationType must be DEPOSIT or WITHDRAW and amount positive"
+	case errors.Is(err, wallet.ErrReferenceConflict):
+		return "externalReference already used for another operation"
+	case errors.Is(err, wallet.ErrInvalidDetails):
+		return detail(err)
+	case errors.Is(err, wallet.ErrWalletNotExists):
+		return "wallet not exists"
+	case errors.Is(err, wallet.ErrForbidden), errors.Is(err, wallet.ErrUnauthenticated):
+		return "forbidden"
+	case errors.Is(err, wallet.ErrBlocked):
+		return "transaction blocked"
+	case errors.Is(err, wallet.ErrConflict):
+		return "wallet is busy, try again"
+	default:
+		return "failed to save transaction"
+	}
+}
+
+// detail returns the reason of an invalid details error, whether or not it is wrapped with the op.
+func detail(err error) string {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if errors.Unwrap(e) == wallet.ErrInvalidDetails {
+			return e.Error()
+		}
+	}
+
+	return wallet.ErrInvalidDetails.Error()
+}
@@ -20,6 +20,8 @@ import (
 type Request struct {
 	UserId uuid.UUID `json:"userId"`
 	Amount int       `json:"amount"`
+	// Currency is an ISO 4217 code, RUB if empty.
+	Currency string `json:"currency,omitempty"`
 }
 
 type Response struct {
@@ -32,6 +34,7 @@ type WalletSaver interface {
 		ctx context.Context,
 		UserId uuid.UUID,
 		amount int,
+		currency string,
 	) (walletId uuid.UUID, err error)
 }
 
@@ -42,6 +45,7 @@ func New(log *slog.Logger, walletSaver WalletSaver) http.HandlerFunc {
 		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
 		)
 
 		var req Request
@@ -64,7 +68,14 @@ func New(log *slog.Logger, walletSaver WalletSaver) http.HandlerFunc {
 
 		log.Info("request body decoded", slog.Any("request", req))
 
-		walletId, err := walletSaver.SaveWallet(r.Context(), req.UserId, req.Amount)
+		walletId, err := walletSaver.SaveWallet(r.Context(), req.UserId, req.Amount, req.Currency)
+		if errors.Is(err, wallet.ErrInvalidCurrency) {
+			log.Warn("invalid currency", slog.String("currency", req.Currency))
+
+			render.JSON(w, r, resp.Error("currency must be an ISO 4217 code"))
+
+			return
+		}
 		if errors.Is(err, wallet.ErrWalletExists) {
 			log.Warn("wallet already exists", slog.String("walletId", walletId.String()))
 
@@ -72,6 +83,13 @@ func New(log *slog.Logger, walletSaver WalletSaver) http.HandlerFunc {
 
 			return
 		}
+		if errors.Is(err, wallet.ErrForbidden) || errors.Is(err, wallet.ErrUnauthenticated) {
+			log.Warn("wallet for another user denied", slog.String("userId", req.UserId.String()))
+
+			render.JSON(w, r, resp.Error("forbidden"))
+
+			return
+		}
 		if err != nil {
 			log.Error("failed to save wallet", sl.Err(err))
 
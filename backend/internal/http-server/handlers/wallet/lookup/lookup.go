@@ -0,0 +1,79 @@
+package lookup
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	walletService "coin-app/internal/services/wallet"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Response struct {
+	resp.Response
+	Transaction models.Transaction `json:"transaction"`
+}
+
+type TransactionProvider interface {
+	TransactionByReference(ctx context.Context, walletId uuid.UUID, reference string) (models.Transaction, error)
+}
+
+// New finds the transaction of the wallet by the externalReference query parameter.
+func New(log *slog.Logger, provider TransactionProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.wallet.lookup.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		walletId, err := uuid.Parse(chi.URLParam(r, "walletId"))
+		if err != nil {
+			log.Error("invalid walletId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid walletId"))
+			return
+		}
+
+		reference := r.URL.Query().Get("externalReference")
+		if reference == "" {
+			render.JSON(w, r, resp.Error("externalReference is required"))
+			return
+		}
+
+		transaction, err := provider.TransactionByReference(r.Context(), walletId, reference)
+		if errors.Is(err, walletService.ErrWalletNotExists) {
+			render.JSON(w, r, resp.Error("wallet not exists"))
+			return
+		}
+		if errors.Is(err, walletService.ErrTransactionNotExists) {
+			render.JSON(w, r, resp.Error("transaction not exists"))
+			return
+		}
+		if errors.Is(err, walletService.ErrForbidden) || errors.Is(err, walletService.ErrUnauthenticated) {
+			log.Warn("access to wallet denied", slog.String("walletId", walletId.String()))
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to find transaction", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to find transaction"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response:    resp.OK(),
+			Transaction: transaction,
+		})
+	}
+}
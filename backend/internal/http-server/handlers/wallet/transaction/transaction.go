@@ -9,6 +9,7 @@ import (
 
 	"log/slog"
 
+	"coin-app/internal/domain/models"
 	resp "coin-app/internal/lib/api/response"
 	"coin-app/internal/services/wallet"
 
@@ -21,11 +22,16 @@ type Request struct {
 	WalletId      uuid.UUID `json:"walletId"`
 	OperationType string    `json:"operationType"`
 	Amount        int       `json:"amount"`
+	// Description, metadata and externalReference are optional.
+	models.TransactionDetails
 }
 
 type Response struct {
 	resp.Response
-	TransactionId uuid.UUID `json:"transactionId"`
+	// Gross is the requested amount, the fee is taken out of it and Net is credited or paid out.
+	models.Receipt
+	// Duplicate means the externalReference was posted before, TransactionId is the original one.
+	Duplicate bool `json:"duplicate,omitempty"`
 }
 
 // TODO(pogrammist): не используется
@@ -36,22 +42,24 @@ const (
 	Withdraw OperationType = "WITHDRAW"
 )
 
-type TransactionSaver interface {
-	SaveTransaction(
+type TransactionPoster interface {
+	Post(
 		ctx context.Context,
 		walletId uuid.UUID,
 		operationType string,
 		amount int,
-	) (transactionId uuid.UUID, err error)
+		details models.TransactionDetails,
+	) (receipt models.Receipt, err error)
 }
 
-func New(log *slog.Logger, transactionSaver TransactionSaver) http.HandlerFunc {
+func New(log *slog.Logger, transactionPoster TransactionPoster) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.wallet.transaction.New"
 
 		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
 		)
 
 		var req Request
@@ -74,7 +82,35 @@ func New(log *slog.Logger, transactionSaver TransactionSaver) http.HandlerFunc {
 
 		log.Info("request body decoded", slog.Any("request", req))
 
-		transactionId, err := transactionSaver.SaveTransaction(r.Context(), req.WalletId, req.OperationType, req.Amount)
+		receipt, err := transactionPoster.Post(r.Context(), req.WalletId, req.OperationType, req.Amount, req.TransactionDetails)
+		if errors.Is(err, wallet.ErrDuplicateReference) {
+			log.Info("reference already posted", slog.String("id", receipt.TransactionId.String()))
+
+			responseOK(w, r, receipt, true)
+
+			return
+		}
+		if errors.Is(err, wallet.ErrInvalidOperation) {
+			log.Warn("invalid operation", slog.String("operationType", req.OperationType), slog.Int("amount", req.Amount))
+
+			render.JSON(w, r, resp.Error("operationType must be DEPOSIT or WITHDRAW and amount positive"))
+
+			return
+		}
+		if errors.Is(err, wallet.ErrReferenceConflict) {
+			log.Warn("reference reused for another operation", slog.String("walletId", req.WalletId.String()))
+
+			render.JSON(w, r, resp.Error("externalReference already used for another operation"))
+
+			return
+		}
+		if errors.Is(err, wallet.ErrInvalidDetails) {
+			log.Warn("invalid transaction details", sl.Err(err))
+
+			render.JSON(w, r, resp.Error(errors.Unwrap(err).Error()))
+
+			return
+		}
 		if errors.Is(err, wallet.ErrWalletNotExists) {
 			log.Warn("wallet not exists", slog.String("walletId", req.WalletId.String()))
 
@@ -82,6 +118,35 @@ func New(log *slog.Logger, transactionSaver TransactionSaver) http.HandlerFunc {
 
 			return
 		}
+		if errors.Is(err, wallet.ErrForbidden) || errors.Is(err, wallet.ErrUnauthenticated) {
+			log.Warn("access to wallet denied", slog.String("walletId", req.WalletId.String()))
+
+			render.JSON(w, r, resp.Error("forbidden"))
+
+			return
+		}
+		var limitErr *wallet.LimitError
+		if errors.As(err, &limitErr) {
+			log.Warn("transaction exceeds wallet limit", slog.String("walletId", req.WalletId.String()), slog.String("limit", limitErr.Limit))
+
+			render.JSON(w, r, resp.Error("limit exceeded: "+limitErr.Limit))
+
+			return
+		}
+		if errors.Is(err, wallet.ErrBlocked) {
+			log.Warn("transaction blocked", slog.String("walletId", req.WalletId.String()))
+
+			render.JSON(w, r, resp.Error("transaction blocked"))
+
+			return
+		}
+		if errors.Is(err, wallet.ErrConflict) {
+			log.Warn("transaction conflicts with concurrent updates", slog.String("walletId", req.WalletId.String()))
+
+			render.JSON(w, r, resp.Error("wallet is busy, try again"))
+
+			return
+		}
 		if err != nil {
 			log.Error("failed to save user", sl.Err(err))
 
@@ -90,15 +155,16 @@ func New(log *slog.Logger, transactionSaver TransactionSaver) http.HandlerFunc {
 			return
 		}
 
-		log.Info("transaction added", slog.String("id", transactionId.String()))
+		log.Info("transaction added", slog.String("id", receipt.TransactionId.String()), slog.Int("fee", receipt.Fee))
 
-		responseOK(w, r, transactionId)
+		responseOK(w, r, receipt, false)
 	}
 }
 
-func responseOK(w http.ResponseWriter, r *http.Request, transactionId uuid.UUID) {
+func responseOK(w http.ResponseWriter, r *http.Request, receipt models.Receipt, duplicate bool) {
 	render.JSON(w, r, Response{
-		Response:      resp.OK(),
-		TransactionId: transactionId,
+		Response:  resp.OK(),
+		Receipt:   receipt,
+		Duplicate: duplicate,
 	})
 }
@@ -3,12 +3,14 @@ package wallet
 import (
 	"coin-app/internal/lib/logger/sl"
 	"context"
+	"errors"
 	"net/http"
 
 	"log/slog"
 
 	"coin-app/internal/domain/models"
 	resp "coin-app/internal/lib/api/response"
+	walletService "coin-app/internal/services/wallet"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/v5/middleware"
@@ -49,12 +51,18 @@ func New(log *slog.Logger, walletProvider WalletProvider) http.HandlerFunc {
 		log := log.With(
 			slog.String("op", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
 		)
 
 		log.Info("walletId extracted", slog.String("walletId", walletId.String()))
 
 		// Получение кошелька
 		wallet, err := walletProvider.GetWallet(r.Context(), walletId)
+		if errors.Is(err, walletService.ErrForbidden) || errors.Is(err, walletService.ErrUnauthenticated) {
+			log.Warn("access to wallet denied", slog.String("walletId", walletId.String()))
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
 		if err != nil {
 			log.Error("failed to get wallet", sl.Err(err))
 			render.JSON(w, r, resp.Error("failed to get wallet"))
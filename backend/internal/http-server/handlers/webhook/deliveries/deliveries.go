@@ -0,0 +1,84 @@
+package deliveries
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/webhook"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Response struct {
+	resp.Response
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+}
+
+type DeliveryProvider interface {
+	Deliveries(
+		ctx context.Context,
+		subscriptionId uuid.UUID,
+		limit int,
+	) ([]models.WebhookDelivery, error)
+}
+
+func New(log *slog.Logger, deliveryProvider DeliveryProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.deliveries.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		subscriptionId, err := uuid.Parse(chi.URLParam(r, "subscriptionId"))
+		if err != nil {
+			log.Error("invalid subscriptionId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid subscriptionId"))
+			return
+		}
+
+		limit := webhook.DefaultLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > webhook.MaxLimit {
+				log.Error("invalid limit", slog.String("limit", v))
+				render.JSON(w, r, resp.Error("invalid limit"))
+				return
+			}
+		}
+
+		deliveries, err := deliveryProvider.Deliveries(r.Context(), subscriptionId, limit)
+		if errors.Is(err, webhook.ErrSubscriptionNotExists) {
+			log.Warn("subscription not exists", slog.String("subscriptionId", subscriptionId.String()))
+			render.JSON(w, r, resp.Error("subscription not exists"))
+			return
+		}
+		if errors.Is(err, webhook.ErrForbidden) || errors.Is(err, webhook.ErrUnauthenticated) {
+			log.Warn("access to subscription denied", slog.String("subscriptionId", subscriptionId.String()))
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to get deliveries", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to get deliveries"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response:   resp.OK(),
+			Deliveries: deliveries,
+		})
+	}
+}
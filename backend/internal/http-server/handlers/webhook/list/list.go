@@ -0,0 +1,67 @@
+package list
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/webhook"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	resp.Response
+	Subscriptions []models.WebhookSubscription `json:"subscriptions"`
+}
+
+type Lister interface {
+	Subscriptions(ctx context.Context, limit int) ([]models.WebhookSubscription, error)
+}
+
+// New returns the webhook subscriptions of the caller.
+func New(log *slog.Logger, lister Lister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.list.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		limit := webhook.DefaultLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.Atoi(v)
+			if err != nil || limit <= 0 || limit > webhook.MaxLimit {
+				log.Error("invalid limit", slog.String("limit", v))
+				render.JSON(w, r, resp.Error("invalid limit"))
+				return
+			}
+		}
+
+		subs, err := lister.Subscriptions(r.Context(), limit)
+		if errors.Is(err, webhook.ErrForbidden) || errors.Is(err, webhook.ErrUnauthenticated) {
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to list webhook subscriptions", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to list webhook subscriptions"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response:      resp.OK(),
+			Subscriptions: subs,
+		})
+	}
+}
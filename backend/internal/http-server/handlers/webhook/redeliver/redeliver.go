@@ -0,0 +1,62 @@
+package redeliver
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+
+	"log/slog"
+
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/webhook"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Redeliverer interface {
+	Redeliver(ctx context.Context, deliveryId uuid.UUID) error
+}
+
+func New(log *slog.Logger, redeliverer Redeliverer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.redeliver.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		deliveryId, err := uuid.Parse(chi.URLParam(r, "deliveryId"))
+		if err != nil {
+			log.Error("invalid deliveryId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid deliveryId"))
+			return
+		}
+
+		err = redeliverer.Redeliver(r.Context(), deliveryId)
+		if errors.Is(err, webhook.ErrDeliveryNotExists) {
+			log.Warn("delivery not exists", slog.String("deliveryId", deliveryId.String()))
+			render.JSON(w, r, resp.Error("delivery not exists"))
+			return
+		}
+		if errors.Is(err, webhook.ErrForbidden) || errors.Is(err, webhook.ErrUnauthenticated) {
+			log.Warn("access to subscription denied", slog.String("deliveryId", deliveryId.String()))
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to redeliver", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to redeliver"))
+			return
+		}
+
+		log.Info("delivery requeued", slog.String("id", deliveryId.String()))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
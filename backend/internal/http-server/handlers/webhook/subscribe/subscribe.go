@@ -0,0 +1,117 @@
+package subscribe
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"log/slog"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/webhook"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Request struct {
+	URL        string             `json:"url"`
+	EventTypes []models.EventType `json:"eventTypes"`
+	// WalletId limits the events to one wallet of the caller.
+	WalletId *uuid.UUID `json:"walletId,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Subscription models.WebhookSubscription `json:"subscription"`
+	// Secret is returned only once, on subscription.
+	Secret string `json:"secret"`
+}
+
+type Subscriber interface {
+	Subscribe(
+		ctx context.Context,
+		url string,
+		eventTypes []models.EventType,
+		walletId *uuid.UUID,
+	) (models.WebhookSubscription, error)
+}
+
+func New(log *slog.Logger, subscriber Subscriber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.subscribe.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		var req Request
+
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.JSON(w, r, resp.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		sub, err := subscriber.Subscribe(r.Context(), req.URL, req.EventTypes, req.WalletId)
+		if errors.Is(err, webhook.ErrInvalidURL) {
+			log.Warn("invalid webhook url", sl.Err(err))
+
+			render.JSON(w, r, resp.Error(errors.Unwrap(err).Error()))
+
+			return
+		}
+		if errors.Is(err, webhook.ErrInvalidEventType) {
+			log.Warn("invalid event type", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("invalid event type"))
+
+			return
+		}
+		if errors.Is(err, webhook.ErrWalletNotExists) {
+			render.JSON(w, r, resp.Error("wallet not exists"))
+
+			return
+		}
+		if errors.Is(err, webhook.ErrForbidden) || errors.Is(err, webhook.ErrUnauthenticated) {
+			log.Warn("subscription denied", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("forbidden"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to subscribe", sl.Err(err))
+
+			render.JSON(w, r, resp.Error("failed to subscribe"))
+
+			return
+		}
+
+		log.Info("webhook subscription added", slog.String("id", sub.Id.String()))
+
+		render.JSON(w, r, Response{
+			Response:     resp.OK(),
+			Subscription: sub,
+			Secret:       sub.Secret,
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package unsubscribe
+
+import (
+	"coin-app/internal/lib/logger/sl"
+	"context"
+	"errors"
+	"net/http"
+
+	"log/slog"
+
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/services/webhook"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+type Unsubscriber interface {
+	Unsubscribe(ctx context.Context, subscriptionId uuid.UUID) error
+}
+
+func New(log *slog.Logger, unsubscriber Unsubscriber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.webhook.unsubscribe.New"
+
+		log := log.With(
+			slog.String("op", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			sl.TraceId(r.Context()),
+		)
+
+		subscriptionId, err := uuid.Parse(chi.URLParam(r, "subscriptionId"))
+		if err != nil {
+			log.Error("invalid subscriptionId", sl.Err(err))
+			render.JSON(w, r, resp.Error("invalid subscriptionId"))
+			return
+		}
+
+		err = unsubscriber.Unsubscribe(r.Context(), subscriptionId)
+		if errors.Is(err, webhook.ErrSubscriptionNotExists) {
+			log.Warn("subscription not exists", slog.String("subscriptionId", subscriptionId.String()))
+			render.JSON(w, r, resp.Error("subscription not exists"))
+			return
+		}
+		if errors.Is(err, webhook.ErrForbidden) || errors.Is(err, webhook.ErrUnauthenticated) {
+			log.Warn("access to subscription denied", slog.String("subscriptionId", subscriptionId.String()))
+			render.JSON(w, r, resp.Error("forbidden"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to unsubscribe", sl.Err(err))
+			render.JSON(w, r, resp.Error("failed to unsubscribe"))
+			return
+		}
+
+		log.Info("webhook subscription removed", slog.String("id", subscriptionId.String()))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/lib/logger/sl"
+	auditService "coin-app/internal/services/audit"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/go-chi/render"
+)
+
+// maxCapturedResponse bounds the part of the response kept to read the outcome.
+const maxCapturedResponse = 4 << 10
+
+// Recorder appends a record to the audit log.
+type Recorder interface {
+	Record(ctx context.Context, rec models.AuditRecord) (models.AuditRecord, error)
+}
+
+// New records every mutating request twice: before it is handled, with who made it,
+// from where, which route and the digest of the body, and after, with how it ended.
+// The request fails closed: if the first record cannot be written it is refused
+// with 503 and never reaches the handler, so no change is made without a record.
+// Must be mounted after the auth middleware to see the caller.
+// Safe methods pass through untouched.
+func New(log *slog.Logger, recorder Recorder) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/audit"),
+		)
+
+		log.Info("audit middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			// The handler still sees a read error, e.g. a body over the size limit
+			body, err := io.ReadAll(r.Body)
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+
+			rec := models.AuditRecord{
+				Actor:         actor(r),
+				ClientIP:      clientIP(r),
+				RequestId:     middleware.GetReqID(r.Context()),
+				Action:        r.Method + " " + routePattern(r),
+				Path:          r.URL.Path,
+				Outcome:       models.AuditStarted,
+				PayloadDigest: auditService.Digest(body),
+			}
+
+			if _, err := recorder.Record(r.Context(), rec); err != nil {
+				log.Error("mutating request refused, failed to audit it",
+					slog.String("action", rec.Action),
+					slog.String("request_id", rec.RequestId),
+					sl.Err(err),
+				)
+
+				render.Status(r, http.StatusServiceUnavailable)
+				render.JSON(w, r, resp.Error("service unavailable"))
+
+				return
+			}
+
+			captured := &capture{}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(captured)
+
+			next.ServeHTTP(ww, r)
+
+			rec.Status = ww.Status()
+			if rec.Status == 0 {
+				rec.Status = http.StatusOK
+			}
+			rec.Outcome = captured.outcome()
+
+			// The change is already made, so the record is written even if the client went away.
+			// If it is lost, the record of the start with the same request_id remains
+			if _, err := recorder.Record(context.WithoutCancel(r.Context()), rec); err != nil {
+				log.Error("outcome of mutating request was not audited",
+					slog.String("action", rec.Action),
+					slog.String("request_id", rec.RequestId),
+					sl.Err(err),
+				)
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func actor(r *http.Request) string {
+	principal, ok := auth.PrincipalFrom(r.Context())
+	if !ok {
+		return ""
+	}
+
+	return principal.Subject
+}
+
+// clientIP strips the port, middleware.RealIP already replaced the address
+// with the one from X-Forwarded-For or X-Real-IP if present.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+
+	return r.URL.Path
+}
+
+// capture keeps the beginning of the response.
+type capture struct {
+	buf bytes.Buffer
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	if room := maxCapturedResponse - c.buf.Len(); room > 0 {
+		c.buf.Write(p[:min(len(p), room)])
+	}
+
+	return len(p), nil
+}
+
+// outcome returns the status field of the API response, "" if it is not one.
+func (c *capture) outcome() string {
+	var resp struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(c.buf.Bytes(), &resp); err != nil {
+		return ""
+	}
+
+	return resp.Status
+}
+
+type errReader struct{ err error }
+
+func (e errReader) Read([]byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	return 0, io.EOF
+}
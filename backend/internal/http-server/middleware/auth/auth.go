@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/lib/logger/sl"
+
+	"github.com/go-chi/render"
+)
+
+// Verifier validates a bearer token and returns the caller.
+type Verifier interface {
+	Verify(ctx context.Context, token string) (auth.Principal, error)
+}
+
+// KeyAuthenticator validates an API key and returns the service caller.
+type KeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (auth.Principal, error)
+}
+
+// CertAuthenticator maps a verified client certificate to its API client.
+type CertAuthenticator interface {
+	AuthenticateCert(cert *x509.Certificate) (auth.Principal, bool)
+}
+
+// APIKeyHeader carries the API key of service callers.
+const APIKeyHeader = "X-API-Key"
+
+// New rejects requests without a known client certificate, a valid X-API-Key header
+// or "Authorization: Bearer" token with 401 and puts the caller into the request context.
+// They are tried in that order. Any of verifier, keys and certs may be nil to switch that method off.
+func New(log *slog.Logger, verifier Verifier, keys KeyAuthenticator, certs CertAuthenticator) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/auth"),
+		)
+
+		log.Info("auth middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			// Only chains verified against the client CAs during the handshake are trusted
+			if certs != nil && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+				if principal, ok := certs.AuthenticateCert(r.TLS.VerifiedChains[0][0]); ok {
+					next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+
+					return
+				}
+			}
+
+			if key := r.Header.Get(APIKeyHeader); key != "" && keys != nil {
+				principal, err := keys.Authenticate(r.Context(), key)
+				if err != nil {
+					log.Warn("invalid api key", slog.String("remote_addr", r.RemoteAddr), sl.Err(err))
+					unauthorized(w, r, "invalid api key")
+
+					return
+				}
+
+				next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+
+				return
+			}
+
+			token, ok := bearer(r)
+			if !ok || verifier == nil {
+				unauthorized(w, r, "missing credentials")
+
+				return
+			}
+
+			principal, err := verifier.Verify(r.Context(), token)
+			if err != nil {
+				log.Warn("invalid bearer token", slog.String("remote_addr", r.RemoteAddr), sl.Err(err))
+				unauthorized(w, r, "invalid token")
+
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Anonymous lets every request read and move money on any wallet, like a service without the admin scope.
+// It is installed instead of New when authentication is switched off, e.g. for local development.
+// The admin API stays closed: an unauthenticated caller must not issue keys, adjust balances or read the audit log.
+func Anonymous() func(next http.Handler) http.Handler {
+	principal := auth.Principal{
+		Subject: auth.AnonymousSubject,
+		Scopes:  []string{auth.ScopeRead, auth.ScopeDeposit, auth.ScopeWithdraw},
+		Service: true,
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireScope rejects callers holding none of scopes with 403.
+// Must be mounted after New or Anonymous.
+func RequireScope(scopes ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.PrincipalFrom(r.Context())
+			if !ok {
+				unauthorized(w, r, "not authenticated")
+
+				return
+			}
+			if !principal.HasAnyScope(scopes...) {
+				render.Status(r, http.StatusForbidden)
+				render.JSON(w, r, resp.Error("scope "+strings.Join(scopes, " or ")+" required"))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func bearer(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="coin"`)
+	render.Status(r, http.StatusUnauthorized)
+	render.JSON(w, r, resp.Error(msg))
+}
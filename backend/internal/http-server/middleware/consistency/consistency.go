@@ -0,0 +1,31 @@
+package consistency
+
+import (
+	"net/http"
+	"strings"
+
+	"coin-app/internal/storage"
+)
+
+const (
+	QueryParam = "consistency"
+	Header     = "X-Consistency"
+	Strong     = "strong"
+)
+
+// New forces reads from the primary database for requests that ask for it
+// with the "consistency=strong" query parameter or the "X-Consistency: strong" header.
+func New() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if strings.EqualFold(r.URL.Query().Get(QueryParam), Strong) ||
+				strings.EqualFold(r.Header.Get(Header), Strong) {
+				r = r.WithContext(storage.WithStrongConsistency(r.Context()))
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,45 @@
+package inflight
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// Tracker counts requests that are still being handled,
+// so that shutdown can wait for wallet operations before closing the storage.
+type Tracker struct {
+	wg sync.WaitGroup
+}
+
+func New() *Tracker {
+	return &Tracker{}
+}
+
+// Middleware registers every request passing through it.
+func (t *Tracker) Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		t.wg.Add(1)
+		defer t.wg.Done()
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// Wait blocks until all tracked requests are finished or ctx is done.
+func (t *Tracker) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,32 @@
+package limits
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// New caps the request body at maxBodyBytes and sets a deadline on the request context.
+// Unlike middleware.Timeout it does not answer 504 itself: storage calls and retries
+// see the deadline and the handler reports the error as usual.
+// A zero timeout leaves the context without a deadline.
+func New(maxBodyBytes int64, timeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+			}
+
+			if timeout > 0 {
+				ctx, cancel := context.WithTimeout(r.Context(), timeout)
+				defer cancel()
+
+				r = r.WithContext(ctx)
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
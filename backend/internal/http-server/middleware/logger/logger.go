@@ -6,6 +6,8 @@ import (
 
 	"log/slog"
 
+	"coin-app/internal/lib/logger/sl"
+
 	"github.com/go-chi/chi/v5/middleware"
 )
 
@@ -25,6 +27,7 @@ func New(log *slog.Logger) func(next http.Handler) http.Handler {
 				slog.String("remote_addr", r.RemoteAddr),
 				slog.String("user_agent", r.UserAgent()),
 				slog.String("request_id", middleware.GetReqID(r.Context())),
+				sl.TraceId(r.Context()),
 			)
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/lib/ratelimit"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// maxPeekBody bounds how much of a request body is read to find the walletId.
+const maxPeekBody = 64 << 10
+
+// Limiter takes one token from the bucket stored under key.
+// Implementations: ratelimit.Memory for a single instance, postgres.RateLimiter shared across replicas.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error)
+}
+
+// Options holds the bucket per client and per wallet.
+// A zero Rate disables the bucket.
+type Options struct {
+	Client ratelimit.Limit
+	Wallet ratelimit.Limit
+}
+
+// New throttles requests with a bucket per client and a bucket per client and walletId.
+// Clients are told apart by the authenticated subject, or by IP if there is none.
+// The walletId is taken from the route or from the JSON body before the service checks the caller
+// may access the wallet, so its bucket is the caller's own: no one can throttle a wallet for others.
+// The wallet bucket is checked first, a request it denies takes no token from the client bucket.
+// Must be mounted after middleware.RealIP, the auth middleware and inside the routes, so that URL params are known.
+// Limiter errors let the request through: a broken limiter must not take the API down.
+func New(log *slog.Logger, limiter Limiter, opts Options) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/ratelimit"),
+		)
+
+		log.Info("rate limit middleware enabled")
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			var results []ratelimit.Result
+
+			check := func(key string, limit ratelimit.Limit) bool {
+				if limit.Rate <= 0 {
+					return true
+				}
+
+				res, err := limiter.Allow(r.Context(), key, limit)
+				if err != nil {
+					log.Error("failed to check rate limit", slog.String("key", key), sl.Err(err))
+
+					return true
+				}
+				results = append(results, res)
+
+				return res.Allowed
+			}
+
+			client := clientKey(r)
+
+			allowed := true
+			if walletId := walletId(r); walletId != "" {
+				allowed = check("wallet:"+client+":"+walletId, opts.Wallet)
+			}
+			if allowed {
+				allowed = check("client:"+client, opts.Client)
+			}
+
+			if len(results) > 0 {
+				setHeaders(w, tightest(results))
+			}
+
+			if !allowed {
+				log.Warn("request throttled",
+					slog.String("remote_addr", r.RemoteAddr),
+					slog.String("path", r.URL.Path),
+				)
+
+				render.Status(r, http.StatusTooManyRequests)
+				render.JSON(w, r, resp.Error("too many requests"))
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// clientKey identifies the caller by its subject once authenticated, else by IP.
+func clientKey(r *http.Request) string {
+	if p, ok := auth.PrincipalFrom(r.Context()); ok && p.Subject != auth.AnonymousSubject {
+		return "sub:" + p.Subject
+	}
+
+	return "ip:" + clientIP(r)
+}
+
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+
+	return r.RemoteAddr
+}
+
+// walletId looks up the wallet in the route, then in the JSON body.
+// The body is put back so that the handler can read it again.
+// Anything but a UUID is ignored, the handler rejects it anyway.
+func walletId(r *http.Request) string {
+	if id := chi.URLParam(r, "walletId"); id != "" {
+		return canonical(id)
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+
+	peeked, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBody))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(peeked), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+
+	var body struct {
+		WalletId string `json:"walletId"`
+	}
+	if err := json.Unmarshal(peeked, &body); err != nil {
+		return ""
+	}
+
+	return canonical(body.WalletId)
+}
+
+// canonical returns the UUID in its canonical form, so that spellings of one wallet share a bucket,
+// or "" if it is not a UUID.
+func canonical(id string) string {
+	walletId, err := uuid.Parse(id)
+	if err != nil {
+		return ""
+	}
+
+	return walletId.String()
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// tightest picks the result to report: a denial, else the bucket closest to empty.
+func tightest(results []ratelimit.Result) ratelimit.Result {
+	res := results[0]
+	for _, r := range results[1:] {
+		if !r.Allowed || (res.Allowed && r.Remaining < res.Remaining) {
+			res = r
+		}
+	}
+
+	return res
+}
+
+func setHeaders(w http.ResponseWriter, res ratelimit.Result) {
+	w.Header().Set(HeaderLimit, strconv.Itoa(res.Limit))
+	w.Header().Set(HeaderRemaining, strconv.Itoa(res.Remaining))
+	w.Header().Set(HeaderReset, strconv.Itoa(ceilSeconds(res.Reset)))
+
+	if !res.Allowed {
+		w.Header().Set(HeaderRetryAfter, strconv.Itoa(max(ceilSeconds(res.RetryAfter), 1)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
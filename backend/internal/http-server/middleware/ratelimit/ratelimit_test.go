@@ -0,0 +1,203 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/lib/ratelimit"
+)
+
+// brokenLimiter fails every check.
+type brokenLimiter struct{}
+
+func (brokenLimiter) Allow(context.Context, string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+// newRouter mounts the middleware like the app does: after auth and in a group of routes.
+func newRouter(limiter Limiter, opts Options) http.Handler {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subject := r.Header.Get("X-Subject"); subject != "" {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{Subject: subject}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	// Middlewares of a group run once the route is matched, so its URL params are known
+	r.Group(func(r chi.Router) {
+		r.Use(New(slog.New(slog.NewTextHandler(io.Discard, nil)), limiter, opts))
+		r.Get("/wallet/{walletId}", ok)
+		r.Post("/wallet", ok)
+		r.Get("/ping", ok)
+	})
+
+	return r
+}
+
+// request is sent by subject to path, a non-empty body is posted.
+type request struct {
+	subject string
+	path    string
+	body    string
+}
+
+func (req request) do(h http.Handler) *httptest.ResponseRecorder {
+	method := http.MethodGet
+	if req.body != "" {
+		method = http.MethodPost
+	}
+
+	r := httptest.NewRequest(method, req.path, strings.NewReader(req.body))
+	r.Header.Set("X-Subject", req.subject)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestNew(t *testing.T) {
+	walletId := uuid.NewString()
+	wallet := "/wallet/" + walletId
+
+	tests := []struct {
+		name     string
+		opts     Options
+		requests []request
+		// want is the status of every request in turn
+		want []int
+	}{
+		{
+			name:     "client burst",
+			opts:     Options{Client: ratelimit.Limit{Rate: 0.01, Burst: 2}},
+			requests: []request{{subject: "a", path: "/ping"}, {subject: "a", path: "/ping"}, {subject: "a", path: "/ping"}},
+			want:     []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:     "clients counted apart",
+			opts:     Options{Client: ratelimit.Limit{Rate: 0.01, Burst: 1}},
+			requests: []request{{subject: "a", path: "/ping"}, {subject: "b", path: "/ping"}, {subject: "a", path: "/ping"}},
+			want:     []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name: "wallet from the route",
+			opts: Options{Client: ratelimit.Limit{Rate: 0.01, Burst: 10}, Wallet: ratelimit.Limit{Rate: 0.01, Burst: 1}},
+			requests: []request{
+				{subject: "a", path: wallet},
+				{subject: "a", path: wallet},
+				{subject: "a", path: "/wallet/" + uuid.NewString()},
+			},
+			want: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		{
+			name: "wallet from the body in any spelling",
+			opts: Options{Client: ratelimit.Limit{Rate: 0.01, Burst: 10}, Wallet: ratelimit.Limit{Rate: 0.01, Burst: 1}},
+			requests: []request{
+				{subject: "a", path: "/wallet", body: `{"walletId":"` + walletId + `"}`},
+				{subject: "a", path: "/wallet", body: `{"walletId":"` + strings.ToUpper(walletId) + `"}`},
+			},
+			want: []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name: "another client cannot throttle the wallet",
+			opts: Options{Client: ratelimit.Limit{Rate: 0.01, Burst: 10}, Wallet: ratelimit.Limit{Rate: 0.01, Burst: 1}},
+			requests: []request{
+				{subject: "intruder", path: wallet},
+				{subject: "intruder", path: wallet},
+				{subject: "owner", path: wallet},
+			},
+			want: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK},
+		},
+		{
+			name: "wallet denial takes no client token",
+			opts: Options{Client: ratelimit.Limit{Rate: 0.01, Burst: 2}, Wallet: ratelimit.Limit{Rate: 0.01, Burst: 1}},
+			requests: []request{
+				{subject: "a", path: wallet},
+				{subject: "a", path: wallet},
+				{subject: "a", path: "/ping"},
+				{subject: "a", path: "/ping"},
+			},
+			want: []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusTooManyRequests},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newRouter(ratelimit.NewMemory(time.Minute), tt.opts)
+
+			for i, req := range tt.requests {
+				if got := req.do(h).Code; got != tt.want[i] {
+					t.Fatalf("request %d to %s by %q: status = %d, want %d", i, req.path, req.subject, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewSetsHeaders(t *testing.T) {
+	h := newRouter(ratelimit.NewMemory(time.Minute), Options{
+		Client: ratelimit.Limit{Rate: 1, Burst: 5},
+		Wallet: ratelimit.Limit{Rate: 0.5, Burst: 2},
+	})
+	wallet := request{subject: "a", path: "/wallet/" + uuid.NewString()}
+
+	tests := []struct {
+		name       string
+		status     int
+		limit      string
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		// The wallet bucket is the tightest: 1 of 2 tokens left, the missing one comes in 2s
+		{name: "first", status: http.StatusOK, limit: "2", remaining: "1", reset: "2"},
+		{name: "last token", status: http.StatusOK, limit: "2", remaining: "0", reset: "4"},
+		{name: "denied", status: http.StatusTooManyRequests, limit: "2", remaining: "0", reset: "4", retryAfter: "2"},
+	}
+
+	for _, tt := range tests {
+		w := wallet.do(h)
+
+		if w.Code != tt.status {
+			t.Fatalf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		for header, want := range map[string]string{
+			HeaderLimit:      tt.limit,
+			HeaderRemaining:  tt.remaining,
+			HeaderReset:      tt.reset,
+			HeaderRetryAfter: tt.retryAfter,
+		} {
+			if got := w.Header().Get(header); got != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, header, got, want)
+			}
+		}
+	}
+}
+
+func TestNewLetsRequestsThroughOnLimiterErrors(t *testing.T) {
+	h := newRouter(brokenLimiter{}, Options{Client: ratelimit.Limit{Rate: 1, Burst: 1}})
+
+	for i := 0; i < 3; i++ {
+		w := request{subject: "a", path: "/ping"}.do(h)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want %d", i, w.Code, http.StatusOK)
+		}
+		if got := w.Header().Get(HeaderLimit); got != "" {
+			t.Errorf("request %d: %s = %q, want none", i, HeaderLimit, got)
+		}
+	}
+}
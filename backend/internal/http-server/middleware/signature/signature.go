@@ -0,0 +1,133 @@
+package signature
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/lib/signature"
+
+	"github.com/go-chi/render"
+)
+
+// maxNonceLength keeps clients from filling the nonce store with huge keys.
+const maxNonceLength = 128
+
+// NonceStore remembers nonces until they expire.
+type NonceStore interface {
+	Remember(ctx context.Context, clientId string, nonce string, ttl time.Duration) (bool, error)
+}
+
+type Options struct {
+	// Clients maps a client ID to its shared secret.
+	Clients map[string]string
+	// MaxSkew is how far the request timestamp may be from the server clock either way.
+	MaxSkew time.Duration
+}
+
+// New rejects requests without a valid HMAC signature with 401, see signature.Sign.
+// A nonce is accepted once per client while its timestamp is inside the skew window,
+// so it is remembered for twice the skew.
+// Mount it on the route groups that require signed requests.
+func New(log *slog.Logger, nonces NonceStore, opts Options) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/signature"),
+		)
+
+		log.Info("request signature middleware enabled", slog.Int("clients", len(opts.Clients)))
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			clientId := r.Header.Get(signature.HeaderClientId)
+			timestamp := r.Header.Get(signature.HeaderTimestamp)
+			nonce := r.Header.Get(signature.HeaderNonce)
+			sig := r.Header.Get(signature.HeaderSignature)
+
+			if clientId == "" || timestamp == "" || nonce == "" || sig == "" {
+				unauthorized(w, r, "missing signature headers")
+
+				return
+			}
+			if len(nonce) > maxNonceLength {
+				unauthorized(w, r, "nonce is too long")
+
+				return
+			}
+
+			secret, ok := opts.Clients[clientId]
+			if !ok {
+				log.Warn("unknown signing client", slog.String("client_id", clientId))
+				unauthorized(w, r, "invalid signature")
+
+				return
+			}
+
+			sec, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				unauthorized(w, r, "invalid timestamp")
+
+				return
+			}
+			if skew := time.Since(time.Unix(sec, 0)); skew > opts.MaxSkew || skew < -opts.MaxSkew {
+				log.Warn("request timestamp outside of skew window",
+					slog.String("client_id", clientId),
+					slog.Duration("skew", skew),
+				)
+				unauthorized(w, r, "timestamp outside of allowed window")
+
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				log.Warn("failed to read request body", sl.Err(err))
+				render.Status(r, http.StatusRequestEntityTooLarge)
+				render.JSON(w, r, resp.Error("failed to read request"))
+
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			if !signature.Valid(sig, secret, r.Method, r.URL.EscapedPath(), timestamp, nonce, body) {
+				log.Warn("invalid request signature", slog.String("client_id", clientId))
+				unauthorized(w, r, "invalid signature")
+
+				return
+			}
+
+			// Only a correctly signed request may use up a nonce
+			fresh, err := nonces.Remember(r.Context(), clientId, nonce, 2*opts.MaxSkew)
+			if err != nil {
+				log.Error("failed to check nonce", sl.Err(err))
+				render.Status(r, http.StatusServiceUnavailable)
+				render.JSON(w, r, resp.Error("service unavailable"))
+
+				return
+			}
+			if !fresh {
+				log.Warn("replayed request rejected",
+					slog.String("client_id", clientId),
+					slog.String("nonce", nonce),
+				)
+				unauthorized(w, r, "nonce already used")
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, msg string) {
+	render.Status(r, http.StatusUnauthorized)
+	render.JSON(w, r, resp.Error(msg))
+}
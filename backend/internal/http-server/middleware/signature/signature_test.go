@@ -0,0 +1,170 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"coin-app/internal/lib/signature"
+)
+
+const (
+	clientId = "billing"
+	secret   = "billing-secret"
+)
+
+// brokenNonces fails every check.
+type brokenNonces struct{}
+
+func (brokenNonces) Remember(context.Context, string, string, time.Duration) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func newHandler(nonces NonceStore) http.Handler {
+	mw := New(slog.New(slog.NewTextHandler(io.Discard, nil)), nonces, Options{
+		Clients: map[string]string{clientId: secret},
+		MaxSkew: time.Minute,
+	})
+
+	return mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The handler must still see the body the middleware has read
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}))
+}
+
+// signed is a request signed like a client does; the fields are sent as they are,
+// so a test may break one after signing.
+type signed struct {
+	method    string
+	path      string
+	body      string
+	clientId  string
+	timestamp string
+	nonce     string
+	signature string
+}
+
+func newSigned(body string, at time.Time, nonce string) signed {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+
+	return signed{
+		method:    http.MethodPost,
+		path:      "/wallet",
+		body:      body,
+		clientId:  clientId,
+		timestamp: timestamp,
+		nonce:     nonce,
+		signature: signature.Sign(secret, http.MethodPost, "/wallet", timestamp, nonce, []byte(body)),
+	}
+}
+
+func (s signed) do(h http.Handler) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(s.method, s.path, strings.NewReader(s.body))
+	for header, value := range map[string]string{
+		signature.HeaderClientId:  s.clientId,
+		signature.HeaderTimestamp: s.timestamp,
+		signature.HeaderNonce:     s.nonce,
+		signature.HeaderSignature: s.signature,
+	} {
+		if value != "" {
+			r.Header.Set(header, value)
+		}
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestNew(t *testing.T) {
+	const body = `{"walletId":"6f1b2d9e-0c4a-4c55-9a53-2f7a3c1d8e10","operationType":"DEPOSIT","amount":100}`
+	now := time.Now()
+
+	with := func(change func(s *signed)) signed {
+		s := newSigned(body, now, "n-1")
+		change(&s)
+		return s
+	}
+
+	tests := []struct {
+		name string
+		req  signed
+		want int
+	}{
+		{name: "valid", req: newSigned(body, now, "n-1"), want: http.StatusOK},
+		{name: "timestamp inside the window", req: newSigned(body, now.Add(-50*time.Second), "n-1"), want: http.StatusOK},
+		{name: "timestamp slightly ahead", req: newSigned(body, now.Add(50*time.Second), "n-1"), want: http.StatusOK},
+		{name: "timestamp too old", req: newSigned(body, now.Add(-2*time.Minute), "n-1"), want: http.StatusUnauthorized},
+		{name: "timestamp too far ahead", req: newSigned(body, now.Add(2*time.Minute), "n-1"), want: http.StatusUnauthorized},
+		{name: "timestamp not a number", req: with(func(s *signed) { s.timestamp = now.Format(time.RFC3339) }), want: http.StatusUnauthorized},
+		{name: "body changed", req: with(func(s *signed) { s.body = strings.Replace(s.body, "100", "1000", 1) }), want: http.StatusUnauthorized},
+		{name: "path changed", req: with(func(s *signed) { s.path = "/wallets" }), want: http.StatusUnauthorized},
+		{name: "method changed", req: with(func(s *signed) { s.method = http.MethodPut }), want: http.StatusUnauthorized},
+		{name: "nonce changed", req: with(func(s *signed) { s.nonce = "n-2" }), want: http.StatusUnauthorized},
+		{name: "timestamp changed", req: with(func(s *signed) { s.timestamp = strconv.FormatInt(now.Unix()-1, 10) }), want: http.StatusUnauthorized},
+		{name: "other secret", req: with(func(s *signed) {
+			s.signature = signature.Sign("other-secret", s.method, s.path, s.timestamp, s.nonce, []byte(s.body))
+		}), want: http.StatusUnauthorized},
+		{name: "signature not hex", req: with(func(s *signed) { s.signature = "zz" + s.signature[2:] }), want: http.StatusUnauthorized},
+		{name: "unknown client", req: with(func(s *signed) { s.clientId = "intruder" }), want: http.StatusUnauthorized},
+		{name: "no signature", req: with(func(s *signed) { s.signature = "" }), want: http.StatusUnauthorized},
+		{name: "no nonce", req: with(func(s *signed) { s.nonce = "" }), want: http.StatusUnauthorized},
+		{name: "nonce too long", req: newSigned(body, now, strings.Repeat("n", maxNonceLength+1)), want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.req.do(newHandler(signature.NewMemory()))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want == http.StatusOK && w.Body.String() != tt.req.body {
+				t.Errorf("handler got body %q, want %q", w.Body, tt.req.body)
+			}
+		})
+	}
+}
+
+func TestNewRejectsReplays(t *testing.T) {
+	h := newHandler(signature.NewMemory())
+	now := time.Now()
+
+	steps := []struct {
+		name string
+		req  signed
+		want int
+	}{
+		{name: "first use", req: newSigned(`{"amount":1}`, now, "n-1"), want: http.StatusOK},
+		{name: "replay", req: newSigned(`{"amount":1}`, now, "n-1"), want: http.StatusUnauthorized},
+		{name: "same nonce on another request", req: newSigned(`{"amount":2}`, now.Add(time.Second), "n-1"), want: http.StatusUnauthorized},
+		{name: "fresh nonce", req: newSigned(`{"amount":1}`, now, "n-2"), want: http.StatusOK},
+		// A forged request must not use up the nonce of the real one
+		{name: "forged", req: signed{method: http.MethodPost, path: "/wallet", body: "{}", clientId: clientId,
+			timestamp: strconv.FormatInt(now.Unix(), 10), nonce: "n-3", signature: strings.Repeat("0", 64)}, want: http.StatusUnauthorized},
+		{name: "real request after the forged one", req: newSigned(`{"amount":3}`, now, "n-3"), want: http.StatusOK},
+	}
+
+	for _, step := range steps {
+		if got := step.req.do(h).Code; got != step.want {
+			t.Fatalf("%s: status = %d, want %d", step.name, got, step.want)
+		}
+	}
+}
+
+func TestNewFailsClosedOnNonceStoreErrors(t *testing.T) {
+	w := newSigned("{}", time.Now(), "n-1").do(newHandler(brokenNonces{}))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ScopeRead lets the caller read wallets.
+	ScopeRead = "wallets:read"
+	// ScopeDeposit lets the caller create wallets and deposit money.
+	ScopeDeposit = "wallets:deposit"
+	// ScopeWithdraw lets the caller withdraw money.
+	ScopeWithdraw = "wallets:withdraw"
+	// ScopeAdmin lets the caller act on wallets of any user and implies every other scope.
+	ScopeAdmin = "wallets:admin"
+	// AnonymousSubject is the caller when authentication is switched off.
+	AnonymousSubject = "anonymous"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Principal is the authenticated caller.
+type Principal struct {
+	Subject string
+	// UserId is the subject as a user ID, zero for callers that are not users.
+	UserId uuid.UUID
+	// Operator is who is accountable for the caller whatever credential they use:
+	// the user of a token, the owner of an API key, the client of a certificate.
+	// Empty for callers bound to no one, e.g. the anonymous caller or a key without an owner.
+	Operator string
+	Scopes   []string
+	// Service is set for backend callers authenticated with an API key or a client certificate.
+	// They act on behalf of any user within their scopes.
+	Service bool
+}
+
+// ValidScope reports whether scope is one of the known scopes.
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeRead, ScopeDeposit, ScopeWithdraw, ScopeAdmin:
+		return true
+	}
+
+	return false
+}
+
+// HasScope reports whether the caller was granted scope.
+// Users acting on their own wallets hold the wallet scopes implicitly.
+func (p Principal) HasScope(scope string) bool {
+	if slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin) {
+		return true
+	}
+
+	return !p.Service && p.UserId != uuid.Nil && scope != ScopeAdmin
+}
+
+// HasAnyScope reports whether the caller holds at least one of scopes.
+func (p Principal) HasAnyScope(scopes ...string) bool {
+	return slices.ContainsFunc(scopes, p.HasScope)
+}
+
+func (p Principal) IsAdmin() bool {
+	return slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// CanAccess reports whether the caller may act on behalf of userId.
+func (p Principal) CanAccess(userId uuid.UUID) bool {
+	return p.IsAdmin() || p.Service || (p.UserId != uuid.Nil && p.UserId == userId)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the caller stored in ctx.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+
+	return p, ok
+}
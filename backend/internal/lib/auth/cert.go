@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+)
+
+// CertClients maps the identities of verified client certificates to API clients.
+// An identity is a URI SAN, a DNS SAN or the subject common name, checked in that order.
+type CertClients struct {
+	clients map[string][]string
+}
+
+// NewCertClients returns the API clients with their scopes keyed by certificate identity.
+func NewCertClients(clients map[string][]string) (*CertClients, error) {
+	for identity, scopes := range clients {
+		if len(scopes) == 0 {
+			return nil, fmt.Errorf("client %s: no scopes", identity)
+		}
+		for _, scope := range scopes {
+			if !ValidScope(scope) {
+				return nil, fmt.Errorf("client %s: unknown scope %q", identity, scope)
+			}
+		}
+	}
+
+	return &CertClients{clients: clients}, nil
+}
+
+// AuthenticateCert returns the service caller of the first identity of cert that is a known client.
+// cert must already be verified against the client CAs.
+func (c *CertClients) AuthenticateCert(cert *x509.Certificate) (Principal, bool) {
+	identities := make([]string, 0, len(cert.URIs)+len(cert.DNSNames)+1)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	identities = append(identities, cert.DNSNames...)
+	identities = append(identities, cert.Subject.CommonName)
+
+	for _, identity := range identities {
+		if scopes, ok := c.clients[identity]; ok && identity != "" {
+			subject := "mtls:" + identity
+
+			return Principal{Subject: subject, Operator: subject, Scopes: scopes, Service: true}, true
+		}
+	}
+
+	return Principal{}, false
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+)
+
+func TestAuthenticateCert(t *testing.T) {
+	clients, err := NewCertClients(map[string][]string{
+		"spiffe://coin/billing": {ScopeDeposit},
+		"payouts.internal":      {ScopeWithdraw},
+		"reports":               {ScopeRead},
+	})
+	if err != nil {
+		t.Fatalf("NewCertClients() error = %v", err)
+	}
+
+	spiffe, _ := url.Parse("spiffe://coin/billing")
+	other, _ := url.Parse("spiffe://coin/unknown")
+
+	tests := []struct {
+		name        string
+		cert        *x509.Certificate
+		wantSubject string
+		wantScope   string
+	}{
+		{name: "URI SAN", cert: &x509.Certificate{URIs: []*url.URL{spiffe}}, wantSubject: "mtls:spiffe://coin/billing", wantScope: ScopeDeposit},
+		{name: "DNS SAN", cert: &x509.Certificate{DNSNames: []string{"payouts.internal"}}, wantSubject: "mtls:payouts.internal", wantScope: ScopeWithdraw},
+		{name: "common name", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "reports"}}, wantSubject: "mtls:reports", wantScope: ScopeRead},
+		{
+			name:        "URI SAN before common name",
+			cert:        &x509.Certificate{URIs: []*url.URL{spiffe}, Subject: pkix.Name{CommonName: "reports"}},
+			wantSubject: "mtls:spiffe://coin/billing",
+			wantScope:   ScopeDeposit,
+		},
+		{
+			name:        "unknown URI SAN falls through",
+			cert:        &x509.Certificate{URIs: []*url.URL{other}, DNSNames: []string{"payouts.internal"}},
+			wantSubject: "mtls:payouts.internal",
+			wantScope:   ScopeWithdraw,
+		},
+		{name: "unknown", cert: &x509.Certificate{DNSNames: []string{"intruder"}, Subject: pkix.Name{CommonName: "intruder"}}},
+		{name: "no identity", cert: &x509.Certificate{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := clients.AuthenticateCert(tt.cert)
+			if ok != (tt.wantSubject != "") {
+				t.Fatalf("AuthenticateCert() ok = %v, want %v", ok, tt.wantSubject != "")
+			}
+			if !ok {
+				return
+			}
+
+			if p.Subject != tt.wantSubject || p.Operator != tt.wantSubject || !p.Service || len(p.Scopes) != 1 || p.Scopes[0] != tt.wantScope {
+				t.Errorf("AuthenticateCert() = %+v, want %s with %s", p, tt.wantSubject, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestNewCertClientsRejectsBadScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		clients map[string][]string
+	}{
+		{name: "no scopes", clients: map[string][]string{"billing": nil}},
+		{name: "unknown scope", clients: map[string][]string{"billing": {ScopeDeposit, "wallets:all"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewCertClients(tt.clients); err == nil {
+				t.Fatal("NewCertClients() error = nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+type JWTOptions struct {
+	// HMACSecret enables HS256 tokens.
+	HMACSecret string
+	// JWKSFile enables RS256 tokens signed by the keys in the file.
+	// The file is read again when a token names an unknown key, so keys can be rotated in place,
+	// and when it changed, at most every jwksRecheck, so retired keys stop working without a restart.
+	JWKSFile string
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+}
+
+// JWTVerifier validates bearer tokens. The subject must be a user ID,
+// scopes are taken from the space-separated "scope" claim.
+type JWTVerifier struct {
+	opts   JWTOptions
+	parser *jwt.Parser
+
+	mu       sync.RWMutex
+	keys     map[string]*rsa.PublicKey
+	modified time.Time
+	// checked is when the JWKS file was last looked at for changes, every recheck.
+	checked time.Time
+	recheck time.Duration
+}
+
+// jwksRecheck is how long a key removed from the JWKS file is still accepted.
+const jwksRecheck = 5 * time.Second
+
+type claims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope"`
+}
+
+func NewJWTVerifier(opts JWTOptions) (*JWTVerifier, error) {
+	const op = "lib.auth.NewJWTVerifier"
+
+	if opts.HMACSecret == "" && opts.JWKSFile == "" {
+		return nil, fmt.Errorf("%s: neither HMAC secret nor JWKS file is set", op)
+	}
+
+	var methods []string
+	if opts.HMACSecret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if opts.JWKSFile != "" {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(opts.Leeway),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+
+	v := &JWTVerifier{
+		opts:    opts,
+		parser:  jwt.NewParser(parserOpts...),
+		recheck: jwksRecheck,
+	}
+
+	if opts.JWKSFile != "" {
+		if err := v.loadKeys(); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return v, nil
+}
+
+// Verify checks the signature and the registered claims of token.
+func (v *JWTVerifier) Verify(_ context.Context, token string) (Principal, error) {
+	var c claims
+	if _, err := v.parser.ParseWithClaims(token, &c, v.key); err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	userId, err := uuid.Parse(c.Subject)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: subject is not a user id", ErrInvalidToken)
+	}
+
+	return Principal{
+		Subject:  c.Subject,
+		UserId:   userId,
+		Operator: c.Subject,
+		Scopes:   strings.Fields(c.Scope),
+	}, nil
+}
+
+// key picks the verification key by algorithm, so that an RSA public key
+// can never be used as an HMAC secret.
+func (v *JWTVerifier) key(t *jwt.Token) (any, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return []byte(v.opts.HMACSecret), nil
+	case *jwt.SigningMethodRSA:
+		kid, _ := t.Header["kid"].(string)
+
+		if v.recheckDue() {
+			// A file being replaced right now fails to read, the keys read last are kept until the next check
+			_ = v.loadKeys()
+		}
+
+		if key, ok := v.rsaKey(kid); ok {
+			return key, nil
+		}
+
+		if err := v.loadKeys(); err != nil {
+			return nil, err
+		}
+		if key, ok := v.rsaKey(kid); ok {
+			return key, nil
+		}
+
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+}
+
+// rsaKey finds the key by id. Tokens without a kid are accepted when the set holds a single key.
+func (v *JWTVerifier) rsaKey(kid string) (*rsa.PublicKey, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+
+	key, ok := v.keys[kid]
+
+	return key, ok
+}
+
+// recheckDue reports whether it is time to look at the JWKS file for changes.
+func (v *JWTVerifier) recheckDue() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if time.Since(v.checked) < v.recheck {
+		return false
+	}
+	v.checked = time.Now()
+
+	return true
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// loadKeys reads the RSA signing keys of the JWKS file if it changed since the last read.
+func (v *JWTVerifier) loadKeys() error {
+	info, err := os.Stat(v.opts.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.keys != nil && !info.ModTime().After(v.modified) {
+		return nil
+	}
+
+	b, err := os.ReadFile(v.opts.JWKSFile)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+
+	var set jwks
+	if err := json.Unmarshal(b, &set); err != nil {
+		return fmt.Errorf("failed to parse JWKS file: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return fmt.Errorf("key %q: invalid modulus: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return fmt.Errorf("key %q: invalid exponent: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("JWKS file has no RSA signing keys")
+	}
+
+	v.keys = keys
+	v.modified = info.ModTime()
+
+	return nil
+}
@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, c jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+
+	return s
+}
+
+// validClaims returns the claims of a token the verifier of TestVerifyHMAC accepts.
+func validClaims(subject string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   subject,
+		"iss":   "coin-auth",
+		"aud":   "coin-app",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "wallets:read wallets:admin",
+	}
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	return key
+}
+
+// writeJWKS writes the public keys by kid and moves the modification time forward,
+// so that the verifier sees a change even within the resolution of the file system clock.
+func writeJWKS(t *testing.T, path string, keys map[string]*rsa.PrivateKey) {
+	t.Helper()
+
+	var set jwks
+	for kid, key := range keys {
+		set.Keys = append(set.Keys, struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		}{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+
+	b, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	modified := time.Now()
+	if info, err := os.Stat(path); err == nil && info.ModTime().After(modified) {
+		modified = info.ModTime()
+	}
+	modified = modified.Add(time.Second)
+
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	userId := uuid.New()
+	rsaKey := newRSAKey(t)
+
+	v, err := NewJWTVerifier(JWTOptions{HMACSecret: testSecret, Issuer: "coin-auth", Audience: "coin-app", Leeway: 30 * time.Second})
+	if err != nil {
+		t.Fatalf("NewJWTVerifier() error = %v", err)
+	}
+
+	with := func(key string, value any) jwt.MapClaims {
+		c := validClaims(userId.String())
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{name: "valid", token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", validClaims(userId.String())), valid: true},
+		{name: "expired within leeway", token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", with("exp", time.Now().Add(-10*time.Second).Unix())), valid: true},
+		{name: "expired", token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", with("exp", time.Now().Add(-time.Minute).Unix()))},
+		{name: "without expiry", token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", with("exp", nil))},
+		{name: "other issuer", token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", with("iss", "someone"))},
+		{name: "other audience", token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", with("aud", "another-app"))},
+		{name: "subject is not a user id", token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "", with("sub", "admin"))},
+		{name: "other secret", token: sign(t, jwt.SigningMethodHS256, []byte("another secret of at least 32 bytes"), "", validClaims(userId.String()))},
+		{name: "RS256 when only HMAC is configured", token: sign(t, jwt.SigningMethodRS256, rsaKey, "", validClaims(userId.String()))},
+		{name: "unsigned", token: sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", validClaims(userId.String()))},
+		{name: "garbage", token: "not.a.token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := v.Verify(context.Background(), tt.token)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify() error = %v, want %v", err, ErrInvalidToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+
+			if p.UserId != userId || p.Subject != userId.String() || p.Operator != userId.String() || p.Service {
+				t.Errorf("Verify() = %+v, want the user %s", p, userId)
+			}
+			if !slices.Equal(p.Scopes, []string{ScopeRead, ScopeAdmin}) {
+				t.Errorf("scopes = %v, want %v", p.Scopes, []string{ScopeRead, ScopeAdmin})
+			}
+		})
+	}
+}
+
+func TestVerifyRotatesKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	oldKey, newKey, foreignKey := newRSAKey(t), newRSAKey(t), newRSAKey(t)
+	writeJWKS(t, path, map[string]*rsa.PrivateKey{"old": oldKey})
+
+	v, err := NewJWTVerifier(JWTOptions{JWKSFile: path})
+	if err != nil {
+		t.Fatalf("NewJWTVerifier() error = %v", err)
+	}
+	// Look at the file on every token instead of every few seconds
+	v.recheck = 0
+
+	claims := validClaims(uuid.NewString())
+	delete(claims, "iss")
+	delete(claims, "aud")
+	tokenOf := func(key *rsa.PrivateKey, kid string) string {
+		return sign(t, jwt.SigningMethodRS256, key, kid, claims)
+	}
+
+	steps := []struct {
+		name string
+		// keys replace the JWKS file before the step, nil keeps it
+		keys  map[string]*rsa.PrivateKey
+		token string
+		valid bool
+	}{
+		{name: "old key", token: tokenOf(oldKey, "old"), valid: true},
+		{name: "no kid with a single key", token: tokenOf(oldKey, ""), valid: true},
+		{name: "key not published yet", token: tokenOf(newKey, "new")},
+		{name: "new key published", keys: map[string]*rsa.PrivateKey{"old": oldKey, "new": newKey}, token: tokenOf(newKey, "new"), valid: true},
+		{name: "old key during rotation", token: tokenOf(oldKey, "old"), valid: true},
+		{name: "no kid with several keys", token: tokenOf(oldKey, "")},
+		{name: "old key retired", keys: map[string]*rsa.PrivateKey{"new": newKey}, token: tokenOf(oldKey, "old")},
+		{name: "new key after rotation", token: tokenOf(newKey, "new"), valid: true},
+		{name: "known kid with another key", token: tokenOf(foreignKey, "new")},
+		{name: "HS256 when only RSA is configured", token: sign(t, jwt.SigningMethodHS256, []byte(testSecret), "new", claims)},
+	}
+
+	for _, step := range steps {
+		if step.keys != nil {
+			writeJWKS(t, path, step.keys)
+		}
+
+		_, err := v.Verify(context.Background(), step.token)
+		if step.valid && err != nil {
+			t.Errorf("%s: Verify() error = %v", step.name, err)
+		}
+		if !step.valid && !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: Verify() error = %v, want %v", step.name, err, ErrInvalidToken)
+		}
+	}
+}
+
+func TestNewJWTVerifierRejectsBadKeySets(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		opts JWTOptions
+		jwks string
+	}{
+		{name: "no secret and no key set"},
+		{name: "missing file", opts: JWTOptions{JWKSFile: filepath.Join(dir, "missing.json")}},
+		{name: "not json", jwks: "keys"},
+		{name: "no RSA signing keys", jwks: `{"keys":[{"kty":"EC","kid":"a"},{"kty":"RSA","kid":"b","use":"enc","n":"AQAB","e":"AQAB"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.jwks != "" {
+				tt.opts.JWKSFile = filepath.Join(dir, "jwks.json")
+				if err := os.WriteFile(tt.opts.JWKSFile, []byte(tt.jwks), 0o600); err != nil {
+					t.Fatalf("WriteFile() error = %v", err)
+				}
+			}
+
+			if _, err := NewJWTVerifier(tt.opts); err == nil {
+				t.Fatal("NewJWTVerifier() error = nil")
+			}
+		})
+	}
+}
+
+func TestPrincipalAccess(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name      string
+		principal Principal
+		canAccess bool
+		withdraw  bool
+		admin     bool
+	}{
+		{name: "owner", principal: Principal{UserId: owner}, canAccess: true, withdraw: true},
+		{name: "another user", principal: Principal{UserId: other}, withdraw: true},
+		{name: "admin user", principal: Principal{UserId: other, Scopes: []string{ScopeAdmin}}, canAccess: true, withdraw: true, admin: true},
+		{name: "service with the scope", principal: Principal{Scopes: []string{ScopeWithdraw}, Service: true}, canAccess: true, withdraw: true},
+		{name: "service without the scope", principal: Principal{Scopes: []string{ScopeRead}, Service: true}, canAccess: true},
+		{name: "no one", principal: Principal{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.CanAccess(owner); got != tt.canAccess {
+				t.Errorf("CanAccess() = %v, want %v", got, tt.canAccess)
+			}
+			if got := tt.principal.HasScope(ScopeWithdraw); got != tt.withdraw {
+				t.Errorf("HasScope(%s) = %v, want %v", ScopeWithdraw, got, tt.withdraw)
+			}
+			if got := tt.principal.HasScope(ScopeAdmin); got != tt.admin {
+				t.Errorf("HasScope(%s) = %v, want %v", ScopeAdmin, got, tt.admin)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package backoff
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Jittered returns the delay before the next attempt after the given number of failed attempts:
+// min doubled per attempt up to max, with jitter in [delay/2, delay) so retries of many items spread out.
+func Jittered(attempts int, min, max time.Duration) time.Duration {
+	delay := min
+	for i := 0; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+
+	return time.Duration(half + rand.Int63n(half))
+}
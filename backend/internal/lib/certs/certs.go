@@ -0,0 +1,200 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile verifies client certificates, required unless ClientAuth is tls.NoClientCert.
+	ClientCAFile string
+	ClientAuth   tls.ClientAuthType
+	MinVersion   uint16
+	// CipherSuites apply to TLS 1.2 only, nil means the Go defaults.
+	CipherSuites []uint16
+}
+
+// Reloader serves the server certificate and the client CAs from files
+// and picks up new versions of them without a restart.
+type Reloader struct {
+	opts Options
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	// stamps are the modification time and size of the files as last loaded.
+	stamps map[string]stamp
+}
+
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// New loads the files for the first time.
+func New(opts Options) (*Reloader, error) {
+	if opts.ClientAuth != tls.NoClientCert && opts.ClientCAFile == "" {
+		return nil, errors.New("client certificates need a client CA file")
+	}
+
+	r := &Reloader{opts: opts}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload loads the files again if any of them has changed since the last load and reports whether it did.
+// On error the certificates loaded before stay in use.
+func (r *Reloader) Reload() (bool, error) {
+	files := []string{r.opts.CertFile, r.opts.KeyFile}
+	if r.opts.ClientCAFile != "" {
+		files = append(files, r.opts.ClientCAFile)
+	}
+
+	stamps := make(map[string]stamp, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false, err
+		}
+		stamps[file] = stamp{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	r.mu.RLock()
+	changed := false
+	for file, s := range stamps {
+		if r.stamps[file] != s {
+			changed = true
+		}
+	}
+	r.mu.RUnlock()
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	if cert.Leaf == nil {
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return false, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+	}
+
+	var clientCAs *x509.CertPool
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to read client CAs: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return false, errors.New("no certificates in the client CA file")
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCAs, r.stamps = &cert, clientCAs, stamps
+	r.mu.Unlock()
+
+	return true, nil
+}
+
+// NotAfter returns when the served certificate expires.
+func (r *Reloader) NotAfter() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert.Leaf.NotAfter
+}
+
+// Config returns the server TLS config, every handshake uses the certificates loaded last.
+func (r *Reloader) Config() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion:   r.opts.MinVersion,
+		CipherSuites: r.opts.CipherSuites,
+		ClientAuth:   r.opts.ClientAuth,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			return r.cert, nil
+		},
+	}
+
+	if r.opts.ClientAuth != tls.NoClientCert {
+		cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			c := cfg.Clone()
+			c.GetConfigForClient = nil
+			c.ClientCAs = r.clientCAs
+
+			return c, nil
+		}
+	}
+
+	return cfg
+}
+
+// ParseVersion parses "1.2" or "1.3".
+func ParseVersion(s string) (uint16, error) {
+	switch s {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version: %s", s)
+	}
+}
+
+// ParseCipherSuites maps names as in crypto/tls, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+// to their IDs. Only suites without known security issues are accepted.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite: %s", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// ParseClientAuth parses one of: none, optional, require.
+func ParseClientAuth(s string) (tls.ClientAuthType, error) {
+	switch s {
+	case "none", "":
+		return tls.NoClientCert, nil
+	case "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("unknown client auth mode: %s", s)
+	}
+}
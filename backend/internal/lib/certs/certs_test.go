@@ -0,0 +1,313 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// issued is a certificate with its key, signed by parent or by itself.
+type issued struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+var serial int64
+
+func issue(t *testing.T, name string, parent *issued, ca bool) *issued {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+
+	serial++
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+	}
+
+	signerCert, signerKey := tmpl, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+
+	return &issued{cert: cert, key: key, der: der}
+}
+
+func (c *issued) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der})
+}
+
+func (c *issued) keyPEM(t *testing.T) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() error = %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *issued) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key, Leaf: c.cert}
+}
+
+// write replaces the file and moves its modification time forward,
+// so that a rewrite within the resolution of the file system clock is seen as a change.
+func write(t *testing.T, path string, b []byte) {
+	t.Helper()
+
+	modified := time.Now()
+	if info, err := os.Stat(path); err == nil && info.ModTime().After(modified) {
+		modified = info.ModTime()
+	}
+	modified = modified.Add(time.Second)
+
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+}
+
+// files holds the paths of the files a Reloader reads.
+type files struct {
+	cert, key, clientCA string
+}
+
+func newFiles(t *testing.T) files {
+	dir := t.TempDir()
+
+	return files{
+		cert:     filepath.Join(dir, "server.crt"),
+		key:      filepath.Join(dir, "server.key"),
+		clientCA: filepath.Join(dir, "client-ca.crt"),
+	}
+}
+
+func (f files) writeServer(t *testing.T, c *issued) {
+	write(t, f.cert, c.certPEM())
+	write(t, f.key, c.keyPEM(t))
+}
+
+// serve accepts TLS connections with cfg until the test ends.
+func serve(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.(*tls.Conn).Handshake()
+				// Let the client finish reading the handshake
+				_, _ = conn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// handshake connects to addr and returns the certificate served, client may be nil.
+func handshake(addr string, roots *x509.CertPool, client *issued) (*x509.Certificate, error) {
+	cfg := &tls.Config{RootCAs: roots, ServerName: "coin-app", MinVersion: tls.VersionTLS12}
+	if client != nil {
+		cfg.Certificates = []tls.Certificate{client.tlsCert()}
+	}
+
+	conn, err := tls.Dial("tcp", addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// With TLS 1.3 the server checks the client certificate after the client handshake completes
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Write([]byte{0}); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+func TestReloadServesNewCertificate(t *testing.T) {
+	ca := issue(t, "ca", nil, true)
+	first, second := issue(t, "coin-app", ca, false), issue(t, "coin-app", ca, false)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	f := newFiles(t)
+	f.writeServer(t, first)
+
+	r, err := New(Options{CertFile: f.cert, KeyFile: f.key, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	addr := serve(t, r.Config())
+
+	steps := []struct {
+		name string
+		// change runs before the step, nil keeps the files
+		change      func()
+		wantChanged bool
+		wantErr     bool
+		wantServed  *issued
+	}{
+		{name: "first certificate", wantServed: first},
+		{name: "no change", wantServed: first},
+		{name: "new certificate", change: func() { f.writeServer(t, second) }, wantChanged: true, wantServed: second},
+		{name: "key of another certificate", change: func() { write(t, f.key, first.keyPEM(t)) }, wantErr: true, wantServed: second},
+		{name: "broken certificate", change: func() { write(t, f.cert, []byte("not a certificate")) }, wantErr: true, wantServed: second},
+		{name: "certificate removed", change: func() { os.Remove(f.cert) }, wantErr: true, wantServed: second},
+		{name: "certificate restored", change: func() { f.writeServer(t, first) }, wantChanged: true, wantServed: first},
+	}
+
+	for _, step := range steps {
+		if step.change != nil {
+			step.change()
+		}
+
+		changed, err := r.Reload()
+		if step.wantErr != (err != nil) {
+			t.Fatalf("%s: Reload() error = %v, want error %v", step.name, err, step.wantErr)
+		}
+		if changed != step.wantChanged {
+			t.Errorf("%s: Reload() = %v, want %v", step.name, changed, step.wantChanged)
+		}
+
+		served, err := handshake(addr, roots, nil)
+		if err != nil {
+			t.Fatalf("%s: handshake error = %v", step.name, err)
+		}
+		if served.SerialNumber.Cmp(step.wantServed.cert.SerialNumber) != 0 {
+			t.Errorf("%s: served certificate %s, want %s", step.name, served.SerialNumber, step.wantServed.cert.SerialNumber)
+		}
+		if !r.NotAfter().Equal(step.wantServed.cert.NotAfter) {
+			t.Errorf("%s: NotAfter() = %s, want %s", step.name, r.NotAfter(), step.wantServed.cert.NotAfter)
+		}
+	}
+}
+
+func TestReloadTrustsNewClientCAs(t *testing.T) {
+	serverCA, oldCA, newCA := issue(t, "server-ca", nil, true), issue(t, "old-ca", nil, true), issue(t, "new-ca", nil, true)
+	server := issue(t, "coin-app", serverCA, false)
+	oldClient, newClient := issue(t, "billing", oldCA, false), issue(t, "billing", newCA, false)
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCA.cert)
+
+	f := newFiles(t)
+	f.writeServer(t, server)
+	write(t, f.clientCA, oldCA.certPEM())
+
+	r, err := New(Options{CertFile: f.cert, KeyFile: f.key, ClientCAFile: f.clientCA, ClientAuth: tls.RequireAndVerifyClientCert, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	addr := serve(t, r.Config())
+
+	steps := []struct {
+		name   string
+		change func()
+		client *issued
+		valid  bool
+	}{
+		{name: "client of the old CA", client: oldClient, valid: true},
+		{name: "client of the new CA before reload", client: newClient},
+		{name: "no client certificate", client: nil},
+		{name: "client of the new CA after reload", change: func() { write(t, f.clientCA, newCA.certPEM()) }, client: newClient, valid: true},
+		{name: "client of the old CA after reload", client: oldClient},
+		{name: "both CAs", change: func() { write(t, f.clientCA, append(oldCA.certPEM(), newCA.certPEM()...)) }, client: oldClient, valid: true},
+		{name: "client CA file without certificates", change: func() { write(t, f.clientCA, []byte("empty")) }, client: newClient, valid: true},
+	}
+
+	for _, step := range steps {
+		if step.change != nil {
+			step.change()
+			// A broken file keeps the CAs loaded before, its error is checked by the next step
+			_, _ = r.Reload()
+		}
+
+		_, err := handshake(addr, roots, step.client)
+		if step.valid && err != nil {
+			t.Errorf("%s: handshake error = %v", step.name, err)
+		}
+		if !step.valid && err == nil {
+			t.Errorf("%s: handshake succeeded", step.name)
+		}
+	}
+
+	if _, err := r.Reload(); err == nil {
+		t.Errorf("Reload() of a client CA file without certificates error = nil")
+	}
+}
+
+func TestNewRejectsBadOptions(t *testing.T) {
+	ca := issue(t, "ca", nil, true)
+	f := newFiles(t)
+	f.writeServer(t, issue(t, "coin-app", ca, false))
+	write(t, f.clientCA, ca.certPEM())
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "client auth without CAs", opts: Options{CertFile: f.cert, KeyFile: f.key, ClientAuth: tls.RequireAndVerifyClientCert}},
+		{name: "missing certificate", opts: Options{CertFile: f.cert + ".missing", KeyFile: f.key}},
+		{name: "missing client CAs", opts: Options{CertFile: f.cert, KeyFile: f.key, ClientCAFile: f.clientCA + ".missing", ClientAuth: tls.VerifyClientCertIfGiven}},
+		{name: "key as certificate", opts: Options{CertFile: f.key, KeyFile: f.key}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opts); err == nil {
+				t.Fatal("New() error = nil")
+			}
+		})
+	}
+}
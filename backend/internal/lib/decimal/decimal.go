@@ -0,0 +1,60 @@
+package decimal
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+var ErrInvalid = errors.New("not a decimal number")
+
+// Parse parses a non-negative decimal in plain notation, e.g. "92.345".
+// Fractions like "1/3" and exponents are rejected.
+func Parse(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+
+	digits := strings.Replace(s, ".", "", 1)
+	if digits == "" || strings.HasPrefix(s, ".") || strings.HasSuffix(s, ".") ||
+		strings.Trim(digits, "0123456789") != "" {
+		return nil, ErrInvalid
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, ErrInvalid
+	}
+
+	return r, nil
+}
+
+// Round rounds r to places decimal places, halves to even.
+func Round(r *big.Rat, places int) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(scale))
+	quo, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+
+	// Compare twice the remainder with the denominator to find the nearest integer
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	switch twice.Cmp(scaled.Denom()) {
+	case 1:
+		quo.Add(quo, big.NewInt(int64(rem.Sign())))
+	case 0:
+		if quo.Bit(0) == 1 {
+			quo.Add(quo, big.NewInt(int64(rem.Sign())))
+		}
+	}
+
+	return new(big.Rat).SetFrac(quo, scale)
+}
+
+// String formats r rounded to places decimal places without trailing zeros, e.g. "92.3" for 92.30.
+func String(r *big.Rat, places int) string {
+	s := Round(r, places).FloatString(places)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+
+	return s
+}
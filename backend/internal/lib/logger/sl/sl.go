@@ -1,7 +1,10 @@
 package sl
 
 import (
+	"context"
 	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 func Err(err error) slog.Attr {
@@ -10,3 +13,17 @@ func Err(err error) slog.Attr {
 		Value: slog.StringValue(err.Error()),
 	}
 }
+
+// TraceId returns the id of the trace the span in ctx belongs to.
+// The value is empty if ctx carries no span.
+func TraceId(ctx context.Context) slog.Attr {
+	var traceId string
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		traceId = sc.TraceID().String()
+	}
+
+	return slog.Attr{
+		Key:   "trace_id",
+		Value: slog.StringValue(traceId),
+	}
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "coin"
+
+// Metrics holds the application collectors.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	HTTPRequests *prometheus.CounterVec
+	HTTPDuration *prometheus.HistogramVec
+
+	DBQueryDuration *prometheus.HistogramVec
+	DBQueryErrors   *prometheus.CounterVec
+	TxRetries       *prometheus.CounterVec
+	TxConflicts     *prometheus.CounterVec
+
+	Operations         *prometheus.CounterVec
+	OperationsAmount   *prometheus.CounterVec
+	RejectedOperations *prometheus.CounterVec
+	FeesAmount         *prometheus.CounterVec
+	WalletsCreated     prometheus.Counter
+}
+
+// New creates the collectors and registers them in a new registry
+// together with the Go runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+
+		HTTPRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by route pattern, method and status.",
+		}, []string{"route", "method", "status"}),
+		HTTPDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by route pattern, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+
+		DBQueryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "query_duration_seconds",
+			Help:      "Storage call latency by method.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"method"}),
+		DBQueryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "query_errors_total",
+			Help:      "Failed storage calls by method.",
+		}, []string{"method"}),
+
+		TxRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "tx_retries_total",
+			Help:      "Transactions run again after a conflict, by reason.",
+		}, []string{"reason"}),
+		TxConflicts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "db",
+			Name:      "tx_conflicts_total",
+			Help:      "Transactions that still conflicted after all retries, by reason.",
+		}, []string{"reason"}),
+
+		Operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "wallet",
+			Name:      "operations_total",
+			Help:      "Applied wallet operations by type.",
+		}, []string{"operation"}),
+		OperationsAmount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "wallet",
+			Name:      "operations_amount_total",
+			Help:      "Sum of applied wallet operation amounts by type.",
+		}, []string{"operation"}),
+		RejectedOperations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "wallet",
+			Name:      "rejected_operations_total",
+			Help:      "Rejected wallet operations by type and reason.",
+		}, []string{"operation", "reason"}),
+		FeesAmount: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "wallet",
+			Name:      "fees_amount_total",
+			Help:      "Sum of fees charged on wallet operations by type.",
+		}, []string{"operation"}),
+		WalletsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "wallet",
+			Name:      "created_total",
+			Help:      "Created wallets.",
+		}),
+	}
+
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.HTTPRequests,
+		m.HTTPDuration,
+		m.DBQueryDuration,
+		m.DBQueryErrors,
+		m.TxRetries,
+		m.TxConflicts,
+		m.Operations,
+		m.OperationsAmount,
+		m.RejectedOperations,
+		m.FeesAmount,
+		m.WalletsCreated,
+	)
+
+	return m
+}
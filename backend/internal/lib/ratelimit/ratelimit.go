@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit describes a token bucket: Rate tokens are added per second up to Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking one token from a bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is the time until the next token, zero if Allowed.
+	RetryAfter time.Duration
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+}
+
+// Take refills a bucket holding tokens after elapsed time and tries to take one token from it.
+// It returns the new number of tokens, so that limiters only have to store it.
+func Take(tokens float64, elapsed time.Duration, limit Limit) (float64, Result) {
+	burst := float64(limit.Burst)
+
+	tokens = math.Min(burst, tokens+elapsed.Seconds()*limit.Rate)
+
+	res := Result{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+
+	res.Remaining = int(tokens)
+	res.Reset = seconds((burst - tokens) / limit.Rate)
+
+	return tokens, res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Memory keeps buckets in process memory.
+// Each replica counts on its own, so the effective limit is multiplied by the number of replicas.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	idle      time.Duration
+	lastSweep time.Time
+}
+
+// NewMemory returns a limiter that forgets buckets untouched for idle.
+func NewMemory(idle time.Duration) *Memory {
+	return &Memory{
+		buckets:   make(map[string]*bucket),
+		idle:      idle,
+		lastSweep: time.Now(),
+	}
+}
+
+func (m *Memory) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		m.buckets[key] = b
+	}
+
+	var res Result
+	b.tokens, res = Take(b.tokens, now.Sub(b.last), limit)
+	b.last = now
+
+	return res, nil
+}
+
+// sweep drops idle buckets at most once per idle period.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < m.idle {
+		return
+	}
+	m.lastSweep = now
+
+	for key, b := range m.buckets {
+		if now.Sub(b.last) >= m.idle {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package signature
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers of a signed request.
+const (
+	HeaderClientId  = "X-Client-Id"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+// StringToSign joins the signed parts of a request, one per line:
+// method, path, unix timestamp in seconds, nonce and the hex SHA-256 of the body.
+func StringToSign(method string, path string, timestamp string, nonce string, body []byte) string {
+	digest := sha256.Sum256(body)
+
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		timestamp,
+		nonce,
+		hex.EncodeToString(digest[:]),
+	}, "\n")
+}
+
+// Sign returns the hex HMAC-SHA256 of the request with secret.
+func Sign(secret string, method string, path string, timestamp string, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(StringToSign(method, path, timestamp, nonce, body)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Valid compares signature with the expected one in constant time.
+func Valid(signature string, secret string, method string, path string, timestamp string, nonce string, body []byte) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	want, _ := hex.DecodeString(Sign(secret, method, path, timestamp, nonce, body))
+
+	return hmac.Equal(got, want)
+}
+
+// Memory is a nonce store for a single instance.
+type Memory struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		nonces:    make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// Remember stores the nonce of clientId for ttl and reports whether it was unseen.
+func (m *Memory) Remember(_ context.Context, clientId string, nonce string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	key := clientId + "\x00" + nonce
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now, ttl)
+
+	if expires, ok := m.nonces[key]; ok && now.Before(expires) {
+		return false, nil
+	}
+	m.nonces[key] = now.Add(ttl)
+
+	return true, nil
+}
+
+// sweep drops expired nonces at most once per ttl.
+func (m *Memory) sweep(now time.Time, ttl time.Duration) {
+	if now.Sub(m.lastSweep) < ttl {
+		return
+	}
+	m.lastSweep = now
+
+	for key, expires := range m.nonces {
+		if !now.Before(expires) {
+			delete(m.nonces, key)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package signature
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryRemember(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+
+	steps := []struct {
+		name     string
+		clientId string
+		nonce    string
+		ttl      time.Duration
+		// wait before the step
+		wait  time.Duration
+		fresh bool
+	}{
+		{name: "first use", clientId: "a", nonce: "n-1", ttl: 50 * time.Millisecond, fresh: true},
+		{name: "replay", clientId: "a", nonce: "n-1", ttl: 50 * time.Millisecond},
+		{name: "same nonce of another client", clientId: "b", nonce: "n-1", ttl: 50 * time.Millisecond, fresh: true},
+		{name: "client and nonce do not run together", clientId: "a\x00n", nonce: "-1", ttl: 50 * time.Millisecond, fresh: true},
+		{name: "after expiry", clientId: "a", nonce: "n-1", ttl: 50 * time.Millisecond, wait: 60 * time.Millisecond, fresh: true},
+		{name: "replay after reuse", clientId: "a", nonce: "n-1", ttl: 50 * time.Millisecond},
+	}
+
+	for _, step := range steps {
+		time.Sleep(step.wait)
+
+		fresh, err := m.Remember(ctx, step.clientId, step.nonce, step.ttl)
+		if err != nil {
+			t.Fatalf("%s: Remember() error = %v", step.name, err)
+		}
+		if fresh != step.fresh {
+			t.Errorf("%s: Remember() = %v, want %v", step.name, fresh, step.fresh)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	const secret = "secret"
+	sig := Sign(secret, "post", "/wallet", "1700000000", "n-1", []byte("{}"))
+
+	tests := []struct {
+		name      string
+		signature string
+		method    string
+		valid     bool
+	}{
+		{name: "valid", signature: sig, method: "POST", valid: true},
+		{name: "method in any case", signature: sig, method: "post", valid: true},
+		{name: "other method", signature: sig, method: "PUT"},
+		{name: "truncated", signature: sig[:len(sig)-2], method: "POST"},
+		{name: "not hex", signature: "x" + sig[1:], method: "POST"},
+		{name: "empty", method: "POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Valid(tt.signature, secret, tt.method, "/wallet", "1700000000", "n-1", []byte("{}")); got != tt.valid {
+				t.Errorf("Valid() = %v, want %v", got, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package adjustment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/storage"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Adjustment runs the maker-checker flow of manual balance adjustments:
+// one operator proposes, another one approves or rejects. Operators are compared
+// by auth.Principal.Operator, so one person with a token and an API key is still one operator.
+type Adjustment struct {
+	log       *slog.Logger
+	storage   RequestStorage
+	txManager TxManager
+	poster    Poster
+}
+
+type RequestStorage interface {
+	SaveAdjustmentRequest(ctx context.Context, req models.AdjustmentRequest) error
+	GetAdjustmentRequest(ctx context.Context, requestId uuid.UUID) (models.AdjustmentRequest, error)
+	LockAdjustmentRequest(ctx context.Context, requestId uuid.UUID) (models.AdjustmentRequest, error)
+	DecideAdjustmentRequest(ctx context.Context, req models.AdjustmentRequest) (models.AdjustmentRequest, error)
+	AdjustmentRequests(ctx context.Context, status models.AdjustmentStatus, limit int) ([]models.AdjustmentRequest, error)
+}
+
+// TxManager runs fn in a single database transaction.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Poster posts an approved adjustment to the wallet, see wallet.Wallet.Adjust.
+type Poster interface {
+	Adjust(ctx context.Context, walletId uuid.UUID, amount int, reason string) (uuid.UUID, error)
+}
+
+var (
+	ErrInvalidAmount    = errors.New("amount must not be zero")
+	ErrNoReason         = errors.New("reason is required")
+	ErrInvalidStatus    = errors.New("invalid status")
+	ErrRequestNotExists = errors.New("adjustment request not exists")
+	ErrAlreadyDecided   = errors.New("adjustment request is already decided")
+	// ErrSelfApproval means the proposer tried to approve their own request.
+	ErrSelfApproval    = errors.New("adjustment must be approved by another operator")
+	ErrWalletNotExists = errors.New("wallet not exists")
+	// ErrConflict means concurrent operations kept conflicting and the caller may try again.
+	ErrConflict = errors.New("decision conflicted with concurrent updates")
+	// ErrUnauthenticated means ctx carries no caller, see auth.WithPrincipal.
+	ErrUnauthenticated = errors.New("caller is not authenticated")
+	// ErrNoOperator means the caller is bound to no operator, e.g. an API key issued before owners were recorded.
+	ErrNoOperator = errors.New("caller is not bound to an operator")
+)
+
+// New returns a new instance of the Adjustment service.
+func New(log *slog.Logger, storage RequestStorage, txManager TxManager, poster Poster) *Adjustment {
+	return &Adjustment{
+		log:       log,
+		storage:   storage,
+		txManager: txManager,
+		poster:    poster,
+	}
+}
+
+// Propose records a pending adjustment of the signed amount on behalf of the caller.
+// Nothing is posted until another operator approves it.
+func (a *Adjustment) Propose(ctx context.Context, walletId uuid.UUID, amount int, reason string) (models.AdjustmentRequest, error) {
+	const op = "Adjustment.Propose"
+
+	log := a.log.With(
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("walletId", walletId.String()),
+		slog.Int("amount", amount),
+	)
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+	if principal.Operator == "" {
+		return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, ErrNoOperator)
+	}
+	if amount == 0 {
+		return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, ErrInvalidAmount)
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, ErrNoReason)
+	}
+
+	req := models.AdjustmentRequest{
+		Id:         uuid.New(),
+		WalletId:   walletId,
+		Amount:     amount,
+		Reason:     reason,
+		ProposedBy: principal.Subject,
+		Proposer:   principal.Operator,
+	}
+
+	if err := a.storage.SaveAdjustmentRequest(ctx, req); err != nil {
+		if errors.Is(err, storage.ErrWalletNotExists) {
+			return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, ErrWalletNotExists)
+		}
+		log.Error("failed to save adjustment request", sl.Err(err))
+
+		return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Read it back for the status and the timestamp set by the database
+	saved, err := a.storage.GetAdjustmentRequest(ctx, req.Id)
+	if err != nil {
+		return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("adjustment proposed",
+		slog.String("requestId", req.Id.String()),
+		slog.String("proposedBy", principal.Subject),
+		slog.String("proposer", principal.Operator),
+	)
+
+	return saved, nil
+}
+
+// Approve posts the adjustment through the wallet service and closes the request
+// in one transaction. The proposer cannot approve their own request.
+func (a *Adjustment) Approve(ctx context.Context, requestId uuid.UUID, comment string) (models.AdjustmentRequest, error) {
+	return a.decide(ctx, "Adjustment.Approve", requestId, models.AdjustmentApproved, comment)
+}
+
+// Reject closes the request without posting anything. The proposer may reject
+// their own request to withdraw it.
+func (a *Adjustment) Reject(ctx context.Context, requestId uuid.UUID, comment string) (models.AdjustmentRequest, error) {
+	return a.decide(ctx, "Adjustment.Reject", requestId, models.AdjustmentRejected, comment)
+}
+
+// List returns requests of the given status, all of them for an empty status, newest first.
+func (a *Adjustment) List(ctx context.Context, status models.AdjustmentStatus, limit int) ([]models.AdjustmentRequest, error) {
+	const op = "Adjustment.List"
+
+	if status != "" && !status.Valid() {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidStatus)
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	requests, err := a.storage.AdjustmentRequests(ctx, status, min(limit, MaxLimit))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return requests, nil
+}
+
+func (a *Adjustment) decide(
+	ctx context.Context,
+	op string,
+	requestId uuid.UUID,
+	status models.AdjustmentStatus,
+	comment string,
+) (models.AdjustmentRequest, error) {
+	log := a.log.With(
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("requestId", requestId.String()),
+	)
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+	if principal.Operator == "" {
+		return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, ErrNoOperator)
+	}
+
+	var decided models.AdjustmentRequest
+	err := a.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		req, err := a.storage.LockAdjustmentRequest(ctx, requestId)
+		if err != nil {
+			return err
+		}
+		if req.Status != models.AdjustmentPending {
+			return ErrAlreadyDecided
+		}
+
+		if status == models.AdjustmentApproved {
+			if req.Proposer == principal.Operator || req.ProposedBy == principal.Subject {
+				return ErrSelfApproval
+			}
+
+			// Posted last, so the balance commits together with the decision
+			transactionId, err := a.poster.Adjust(ctx, req.WalletId, req.Amount, req.Reason)
+			if err != nil {
+				return err
+			}
+			req.TransactionId = &transactionId
+		}
+
+		req.Status = status
+		req.DecidedBy = principal.Subject
+		req.Decider = principal.Operator
+		req.Comment = strings.TrimSpace(comment)
+
+		decided, err = a.storage.DecideAdjustmentRequest(ctx, req)
+
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrAdjustmentNotExists) {
+			return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, ErrRequestNotExists)
+		}
+		if errors.Is(err, ErrAlreadyDecided) || errors.Is(err, ErrSelfApproval) {
+			log.Warn("adjustment decision refused", slog.String("caller", principal.Subject), sl.Err(err))
+
+			return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if errors.Is(err, storage.ErrConflict) {
+			log.Warn("adjustment decision conflicts after retries", sl.Err(err))
+
+			return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, ErrConflict)
+		}
+		log.Error("failed to decide adjustment request", sl.Err(err))
+
+		return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("adjustment decided",
+		slog.String("status", string(status)),
+		slog.String("proposedBy", decided.ProposedBy),
+		slog.String("decidedBy", decided.DecidedBy),
+		slog.String("decider", decided.Decider),
+	)
+
+	return decided, nil
+}
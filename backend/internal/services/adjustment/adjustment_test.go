@@ -0,0 +1,220 @@
+package adjustment
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/storage"
+)
+
+// memRequests is an in-memory RequestStorage, its transactions run fn as is.
+type memRequests struct {
+	requests map[uuid.UUID]models.AdjustmentRequest
+}
+
+func (s *memRequests) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (s *memRequests) SaveAdjustmentRequest(_ context.Context, req models.AdjustmentRequest) error {
+	req.Status = models.AdjustmentPending
+	req.CreatedAt = time.Now()
+	s.requests[req.Id] = req
+
+	return nil
+}
+
+func (s *memRequests) GetAdjustmentRequest(_ context.Context, requestId uuid.UUID) (models.AdjustmentRequest, error) {
+	req, ok := s.requests[requestId]
+	if !ok {
+		return models.AdjustmentRequest{}, storage.ErrAdjustmentNotExists
+	}
+
+	return req, nil
+}
+
+func (s *memRequests) LockAdjustmentRequest(ctx context.Context, requestId uuid.UUID) (models.AdjustmentRequest, error) {
+	return s.GetAdjustmentRequest(ctx, requestId)
+}
+
+func (s *memRequests) DecideAdjustmentRequest(_ context.Context, req models.AdjustmentRequest) (models.AdjustmentRequest, error) {
+	if s.requests[req.Id].Status != models.AdjustmentPending {
+		return models.AdjustmentRequest{}, storage.ErrAdjustmentNotExists
+	}
+
+	now := time.Now()
+	req.DecidedAt = &now
+	s.requests[req.Id] = req
+
+	return req, nil
+}
+
+func (s *memRequests) AdjustmentRequests(context.Context, models.AdjustmentStatus, int) ([]models.AdjustmentRequest, error) {
+	return nil, nil
+}
+
+// memPoster counts the posted adjustments.
+type memPoster struct {
+	posted []int
+}
+
+func (p *memPoster) Adjust(_ context.Context, _ uuid.UUID, amount int, _ string) (uuid.UUID, error) {
+	p.posted = append(p.posted, amount)
+
+	return uuid.New(), nil
+}
+
+var (
+	alice       = auth.Principal{Subject: "alice-token", Operator: "alice", Scopes: []string{auth.ScopeAdmin}}
+	aliceAPIKey = auth.Principal{Subject: "apikey:1", Operator: "alice", Scopes: []string{auth.ScopeAdmin}, Service: true}
+	bob         = auth.Principal{Subject: "bob-token", Operator: "bob", Scopes: []string{auth.ScopeAdmin}}
+	// ownerless is an API key issued before owners were recorded
+	ownerless = auth.Principal{Subject: "apikey:2", Scopes: []string{auth.ScopeAdmin}, Service: true}
+)
+
+func newAdjustment(t *testing.T) (*Adjustment, *memPoster) {
+	t.Helper()
+
+	requests := &memRequests{requests: map[uuid.UUID]models.AdjustmentRequest{}}
+	poster := &memPoster{}
+
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), requests, requests, poster), poster
+}
+
+func propose(t *testing.T, a *Adjustment, proposer auth.Principal) models.AdjustmentRequest {
+	t.Helper()
+
+	req, err := a.Propose(auth.WithPrincipal(context.Background(), proposer), uuid.New(), 500, "lost deposit")
+	if err != nil {
+		t.Fatalf("Propose() error = %v", err)
+	}
+
+	return req
+}
+
+func TestApprove(t *testing.T) {
+	a, poster := newAdjustment(t)
+	req := propose(t, a, alice)
+
+	approved, err := a.Approve(auth.WithPrincipal(context.Background(), bob), req.Id, "checked")
+	if err != nil {
+		t.Fatalf("Approve() error = %v", err)
+	}
+
+	if approved.Status != models.AdjustmentApproved || approved.TransactionId == nil {
+		t.Errorf("approved = %s with transaction %v, want APPROVED with a transaction", approved.Status, approved.TransactionId)
+	}
+	if approved.Proposer != "alice" || approved.Decider != "bob" || approved.DecidedBy != bob.Subject {
+		t.Errorf("proposer, decider, decidedBy = %q, %q, %q, want alice, bob, %q",
+			approved.Proposer, approved.Decider, approved.DecidedBy, bob.Subject)
+	}
+	if len(poster.posted) != 1 || poster.posted[0] != 500 {
+		t.Errorf("posted = %v, want [500]", poster.posted)
+	}
+}
+
+func TestReject(t *testing.T) {
+	a, poster := newAdjustment(t)
+	req := propose(t, a, alice)
+
+	// The proposer may withdraw their own request
+	rejected, err := a.Reject(auth.WithPrincipal(context.Background(), aliceAPIKey), req.Id, "typo")
+	if err != nil {
+		t.Fatalf("Reject() error = %v", err)
+	}
+
+	if rejected.Status != models.AdjustmentRejected || rejected.TransactionId != nil {
+		t.Errorf("rejected = %s with transaction %v, want REJECTED without a transaction", rejected.Status, rejected.TransactionId)
+	}
+	if len(poster.posted) != 0 {
+		t.Errorf("posted = %v, want nothing", poster.posted)
+	}
+}
+
+func TestApproveRefusesSelfApproval(t *testing.T) {
+	tests := []struct {
+		name     string
+		proposer auth.Principal
+		approver auth.Principal
+	}{
+		{name: "same credential", proposer: alice, approver: alice},
+		{name: "token then api key of the same operator", proposer: alice, approver: aliceAPIKey},
+		{name: "api key then token of the same operator", proposer: aliceAPIKey, approver: alice},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, poster := newAdjustment(t)
+			req := propose(t, a, tt.proposer)
+
+			_, err := a.Approve(auth.WithPrincipal(context.Background(), tt.approver), req.Id, "")
+			if !errors.Is(err, ErrSelfApproval) {
+				t.Fatalf("Approve() error = %v, want %v", err, ErrSelfApproval)
+			}
+			if len(poster.posted) != 0 {
+				t.Errorf("posted = %v, want nothing", poster.posted)
+			}
+		})
+	}
+}
+
+func TestOwnerlessKeyCannotProposeOrDecide(t *testing.T) {
+	a, _ := newAdjustment(t)
+
+	_, err := a.Propose(auth.WithPrincipal(context.Background(), ownerless), uuid.New(), 500, "lost deposit")
+	if !errors.Is(err, ErrNoOperator) {
+		t.Errorf("Propose() error = %v, want %v", err, ErrNoOperator)
+	}
+
+	req := propose(t, a, alice)
+	if _, err := a.Approve(auth.WithPrincipal(context.Background(), ownerless), req.Id, ""); !errors.Is(err, ErrNoOperator) {
+		t.Errorf("Approve() error = %v, want %v", err, ErrNoOperator)
+	}
+}
+
+func TestDecideTwice(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  func(ctx context.Context, a *Adjustment, requestId uuid.UUID) (models.AdjustmentRequest, error)
+		second func(ctx context.Context, a *Adjustment, requestId uuid.UUID) (models.AdjustmentRequest, error)
+		posted int
+	}{
+		{name: "approve twice", first: approve, second: approve, posted: 1},
+		{name: "reject then approve", first: reject, second: approve, posted: 0},
+		{name: "approve then reject", first: approve, second: reject, posted: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, poster := newAdjustment(t)
+			req := propose(t, a, alice)
+			ctx := auth.WithPrincipal(context.Background(), bob)
+
+			if _, err := tt.first(ctx, a, req.Id); err != nil {
+				t.Fatalf("first decision error = %v", err)
+			}
+			if _, err := tt.second(ctx, a, req.Id); !errors.Is(err, ErrAlreadyDecided) {
+				t.Fatalf("second decision error = %v, want %v", err, ErrAlreadyDecided)
+			}
+			if len(poster.posted) != tt.posted {
+				t.Errorf("posted %d adjustments, want %d", len(poster.posted), tt.posted)
+			}
+		})
+	}
+}
+
+func approve(ctx context.Context, a *Adjustment, requestId uuid.UUID) (models.AdjustmentRequest, error) {
+	return a.Approve(ctx, requestId, "")
+}
+
+func reject(ctx context.Context, a *Adjustment, requestId uuid.UUID) (models.AdjustmentRequest, error) {
+	return a.Reject(ctx, requestId, "")
+}
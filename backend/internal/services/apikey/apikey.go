@@ -0,0 +1,325 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/storage"
+)
+
+const (
+	// keyPrefix marks the string as an API key of this service.
+	keyPrefix = "ck"
+	// DefaultRotationGrace is how long the old key keeps working after a rotation.
+	DefaultRotationGrace = 24 * time.Hour
+	// touchInterval limits how often last_used_at is written for a busy key.
+	touchInterval = time.Minute
+)
+
+type APIKey struct {
+	log     *slog.Logger
+	storage KeyStorage
+
+	mu      sync.Mutex
+	touched map[uuid.UUID]time.Time
+}
+
+type KeyStorage interface {
+	SaveAPIKey(ctx context.Context, key models.APIKey, expiresIn time.Duration) error
+	GetAPIKey(ctx context.Context, keyId uuid.UUID) (models.APIKey, error)
+	APIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error)
+	APIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyId uuid.UUID) error
+	ExpireAPIKey(ctx context.Context, keyId uuid.UUID, expiresIn time.Duration) error
+	TouchAPIKey(ctx context.Context, keyId uuid.UUID) error
+}
+
+var (
+	ErrInvalidName   = errors.New("invalid name")
+	ErrInvalidScope  = errors.New("invalid scope")
+	ErrKeyNotExists  = errors.New("api key not exists")
+	ErrKeyRevoked    = errors.New("api key revoked")
+	ErrInvalidExpiry = errors.New("invalid expiry")
+)
+
+// New returns a new instance of the APIKey service.
+func New(log *slog.Logger, storage KeyStorage) *APIKey {
+	return &APIKey{
+		log:     log,
+		storage: storage,
+		touched: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Issue creates a key with the given scopes. A zero expiresIn means the key does not expire.
+// The returned string is the only copy of the secret, only its hash is stored.
+func (a *APIKey) Issue(ctx context.Context, name string, scopes []string, expiresIn time.Duration) (models.APIKey, string, error) {
+	const op = "APIKey.Issue"
+
+	log := a.log.With(
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("name", name),
+	)
+
+	if strings.TrimSpace(name) == "" {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrInvalidName)
+	}
+	if len(scopes) == 0 {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrInvalidScope)
+	}
+	for _, scope := range scopes {
+		if !auth.ValidScope(scope) {
+			return models.APIKey{}, "", fmt.Errorf("%s: %w: %s", op, ErrInvalidScope, scope)
+		}
+	}
+	if expiresIn < 0 {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrInvalidExpiry)
+	}
+
+	// The key acts for whoever issued it, so maker-checker rules see one operator behind both
+	var owner string
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		owner = principal.Operator
+	}
+
+	key, secret, err := a.issue(ctx, models.APIKey{Name: name, Scopes: scopes, Owner: owner}, expiresIn)
+	if err != nil {
+		log.Error("failed to save api key", sl.Err(err))
+
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("api key issued", slog.String("keyId", key.Id.String()), slog.Any("scopes", scopes))
+
+	return key, secret, nil
+}
+
+// Rotate issues a new key with the scopes of keyId and lets the old one
+// expire after grace, so callers can switch over without downtime.
+// A zero expiresIn means the new key does not expire.
+func (a *APIKey) Rotate(ctx context.Context, keyId uuid.UUID, grace time.Duration, expiresIn time.Duration) (models.APIKey, string, error) {
+	const op = "APIKey.Rotate"
+
+	log := a.log.With(
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("keyId", keyId.String()),
+	)
+
+	if grace < 0 || expiresIn < 0 {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrInvalidExpiry)
+	}
+
+	old, err := a.storage.GetAPIKey(ctx, keyId)
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotExists) {
+			return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrKeyNotExists)
+		}
+
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	if old.RevokedAt != nil || old.Expired {
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, ErrKeyRevoked)
+	}
+
+	key, secret, err := a.issue(ctx, models.APIKey{Name: old.Name, Scopes: old.Scopes, RotatedFrom: &old.Id, Owner: old.Owner}, expiresIn)
+	if err != nil {
+		log.Error("failed to save rotated api key", sl.Err(err))
+
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.storage.ExpireAPIKey(ctx, keyId, grace); err != nil {
+		log.Error("failed to expire old api key", sl.Err(err))
+
+		return models.APIKey{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("api key rotated",
+		slog.String("newKeyId", key.Id.String()),
+		slog.Duration("grace", grace),
+	)
+
+	return key, secret, nil
+}
+
+// Revoke disables the key immediately.
+func (a *APIKey) Revoke(ctx context.Context, keyId uuid.UUID) error {
+	const op = "APIKey.Revoke"
+
+	if err := a.storage.RevokeAPIKey(ctx, keyId); err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotExists) {
+			return fmt.Errorf("%s: %w", op, ErrKeyNotExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.log.Info("api key revoked",
+		slog.String("op", op),
+		slog.String("keyId", keyId.String()),
+	)
+
+	return nil
+}
+
+// List returns all keys, including revoked and expired ones. Secrets are never returned.
+func (a *APIKey) List(ctx context.Context) ([]models.APIKey, error) {
+	const op = "APIKey.List"
+
+	keys, err := a.storage.APIKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// Authenticate checks the key and returns the service caller it belongs to.
+// Every failure is reported as auth.ErrInvalidToken so callers cannot probe which keys exist.
+func (a *APIKey) Authenticate(ctx context.Context, raw string) (auth.Principal, error) {
+	const op = "APIKey.Authenticate"
+
+	prefix, ok := parse(raw)
+	if !ok {
+		return auth.Principal{}, fmt.Errorf("%s: %w", op, auth.ErrInvalidToken)
+	}
+
+	key, err := a.storage.APIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotExists) {
+			return auth.Principal{}, fmt.Errorf("%s: %w", op, auth.ErrInvalidToken)
+		}
+
+		return auth.Principal{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if subtle.ConstantTimeCompare(hash(raw), key.Hash) != 1 {
+		return auth.Principal{}, fmt.Errorf("%s: %w", op, auth.ErrInvalidToken)
+	}
+	if key.RevokedAt != nil {
+		return auth.Principal{}, fmt.Errorf("%s: %w: revoked", op, auth.ErrInvalidToken)
+	}
+	if key.Expired {
+		return auth.Principal{}, fmt.Errorf("%s: %w: expired", op, auth.ErrInvalidToken)
+	}
+
+	a.touch(ctx, key.Id)
+
+	return principal(key), nil
+}
+
+// Principal returns the caller of a key as it is now, for work done later on its behalf,
+// e.g. a scheduled operation. A revoked or expired key is ErrKeyRevoked.
+func (a *APIKey) Principal(ctx context.Context, keyId uuid.UUID) (auth.Principal, error) {
+	const op = "APIKey.Principal"
+
+	key, err := a.storage.GetAPIKey(ctx, keyId)
+	if err != nil {
+		if errors.Is(err, storage.ErrAPIKeyNotExists) {
+			return auth.Principal{}, fmt.Errorf("%s: %w", op, ErrKeyNotExists)
+		}
+
+		return auth.Principal{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if key.RevokedAt != nil || key.Expired {
+		return auth.Principal{}, fmt.Errorf("%s: %w", op, ErrKeyRevoked)
+	}
+
+	return principal(key), nil
+}
+
+func principal(key models.APIKey) auth.Principal {
+	return auth.Principal{
+		Subject:  "apikey:" + key.Id.String(),
+		Operator: key.Owner,
+		Scopes:   key.Scopes,
+		Service:  true,
+	}
+}
+
+func (a *APIKey) issue(ctx context.Context, key models.APIKey, expiresIn time.Duration) (models.APIKey, string, error) {
+	prefix, secret, err := generate()
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	key.Id = uuid.New()
+	key.Prefix = prefix
+	key.Hash = hash(secret)
+
+	if err := a.storage.SaveAPIKey(ctx, key, expiresIn); err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	// Read it back for the timestamps, they come from the database clock
+	saved, err := a.storage.GetAPIKey(ctx, key.Id)
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	return saved, secret, nil
+}
+
+// touch records the use of the key at most once per touchInterval.
+// A failure only loses the timestamp, so it does not fail the request.
+func (a *APIKey) touch(ctx context.Context, keyId uuid.UUID) {
+	now := time.Now()
+
+	a.mu.Lock()
+	last, ok := a.touched[keyId]
+	if ok && now.Sub(last) < touchInterval {
+		a.mu.Unlock()
+
+		return
+	}
+	a.touched[keyId] = now
+	a.mu.Unlock()
+
+	if err := a.storage.TouchAPIKey(ctx, keyId); err != nil {
+		a.log.Warn("failed to record api key use", slog.String("keyId", keyId.String()), sl.Err(err))
+	}
+}
+
+// generate returns a key in the form ck_<prefix>_<secret>.
+// The prefix is stored in clear to find the key, the whole string only hashed.
+func generate() (prefix string, key string, err error) {
+	b := make([]byte, 4+32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	prefix = hex.EncodeToString(b[:4])
+
+	return prefix, keyPrefix + "_" + prefix + "_" + hex.EncodeToString(b[4:]), nil
+}
+
+func parse(raw string) (prefix string, ok bool) {
+	parts := strings.Split(raw, "_")
+	if len(parts) != 3 || parts[0] != keyPrefix || len(parts[1]) != 8 || parts[2] == "" {
+		return "", false
+	}
+
+	return parts[1], true
+}
+
+func hash(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+
+	return sum[:]
+}
@@ -0,0 +1,326 @@
+package apikey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/storage"
+)
+
+// memKeys keeps the keys by id and computes Expired like the database does.
+type memKeys struct {
+	keys map[uuid.UUID]models.APIKey
+}
+
+func newMemKeys() *memKeys {
+	return &memKeys{keys: make(map[uuid.UUID]models.APIKey)}
+}
+
+func (m *memKeys) SaveAPIKey(_ context.Context, key models.APIKey, expiresIn time.Duration) error {
+	key.CreatedAt = time.Now()
+	if expiresIn > 0 {
+		expiresAt := key.CreatedAt.Add(expiresIn)
+		key.ExpiresAt = &expiresAt
+	}
+	m.keys[key.Id] = key
+
+	return nil
+}
+
+func (m *memKeys) GetAPIKey(_ context.Context, keyId uuid.UUID) (models.APIKey, error) {
+	key, ok := m.keys[keyId]
+	if !ok {
+		return models.APIKey{}, storage.ErrAPIKeyNotExists
+	}
+	key.Expired = key.ExpiresAt != nil && !time.Now().Before(*key.ExpiresAt)
+
+	return key, nil
+}
+
+func (m *memKeys) APIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	for id, key := range m.keys {
+		if key.Prefix == prefix {
+			return m.GetAPIKey(ctx, id)
+		}
+	}
+
+	return models.APIKey{}, storage.ErrAPIKeyNotExists
+}
+
+func (m *memKeys) APIKeys(context.Context) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	for _, key := range m.keys {
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (m *memKeys) RevokeAPIKey(_ context.Context, keyId uuid.UUID) error {
+	key, ok := m.keys[keyId]
+	if !ok {
+		return storage.ErrAPIKeyNotExists
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+	}
+	m.keys[keyId] = key
+
+	return nil
+}
+
+func (m *memKeys) ExpireAPIKey(_ context.Context, keyId uuid.UUID, expiresIn time.Duration) error {
+	key, ok := m.keys[keyId]
+	if !ok {
+		return storage.ErrAPIKeyNotExists
+	}
+	expiresAt := time.Now().Add(expiresIn)
+	if key.ExpiresAt == nil || expiresAt.Before(*key.ExpiresAt) {
+		key.ExpiresAt = &expiresAt
+	}
+	m.keys[keyId] = key
+
+	return nil
+}
+
+func (m *memKeys) TouchAPIKey(_ context.Context, keyId uuid.UUID) error {
+	key := m.keys[keyId]
+	now := time.Now()
+	key.LastUsedAt = &now
+	m.keys[keyId] = key
+
+	return nil
+}
+
+func newService(storage KeyStorage) *APIKey {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), storage)
+}
+
+// operatorCtx returns a context of the admin issuing keys.
+func operatorCtx() context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{
+		Subject:  "admin-1",
+		Operator: "alice",
+		Scopes:   []string{auth.ScopeAdmin},
+	})
+}
+
+func TestIssueStoresOnlyTheHash(t *testing.T) {
+	keys := newMemKeys()
+	a := newService(keys)
+
+	key, secret, err := a.Issue(operatorCtx(), "billing", []string{auth.ScopeRead, auth.ScopeDeposit}, 0)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	if !strings.HasPrefix(secret, "ck_"+key.Prefix+"_") {
+		t.Errorf("secret %q does not start with ck_%s_", secret, key.Prefix)
+	}
+	stored := keys.keys[key.Id]
+	if !bytes.Equal(stored.Hash, hash(secret)) {
+		t.Errorf("stored hash is not the SHA-256 of the key")
+	}
+	if bytes.Contains(stored.Hash, []byte(secret)) || strings.Contains(stored.Prefix, secret) {
+		t.Errorf("the secret is stored in clear")
+	}
+	if stored.Owner != "alice" {
+		t.Errorf("owner = %q, want the operator of the issuer", stored.Owner)
+	}
+
+	_, other, err := a.Issue(operatorCtx(), "billing", []string{auth.ScopeRead}, 0)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if other == secret {
+		t.Errorf("two keys share a secret")
+	}
+}
+
+func TestIssueValidates(t *testing.T) {
+	tests := []struct {
+		name      string
+		keyName   string
+		scopes    []string
+		expiresIn time.Duration
+		wantErr   error
+	}{
+		{name: "empty name", keyName: " ", scopes: []string{auth.ScopeRead}, wantErr: ErrInvalidName},
+		{name: "no scopes", keyName: "billing", wantErr: ErrInvalidScope},
+		{name: "unknown scope", keyName: "billing", scopes: []string{auth.ScopeRead, "wallets:delete"}, wantErr: ErrInvalidScope},
+		{name: "negative expiry", keyName: "billing", scopes: []string{auth.ScopeRead}, expiresIn: -time.Hour, wantErr: ErrInvalidExpiry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := newMemKeys()
+			a := newService(keys)
+
+			_, _, err := a.Issue(operatorCtx(), tt.keyName, tt.scopes, tt.expiresIn)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Issue() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(keys.keys) != 0 {
+				t.Errorf("invalid key saved")
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name string
+		// raw turns the issued key into the presented one
+		raw func(secret string) string
+		// prepare runs against the issued key before it is presented
+		prepare func(t *testing.T, a *APIKey, keyId uuid.UUID)
+		valid   bool
+	}{
+		{name: "valid", raw: func(s string) string { return s }, valid: true},
+		{name: "wrong secret of a known prefix", raw: func(s string) string { return s[:len(s)-1] + flip(s[len(s)-1]) }},
+		{name: "unknown prefix", raw: func(s string) string { return "ck_00000000_" + strings.Split(s, "_")[2] }},
+		{name: "malformed", raw: func(s string) string { return strings.TrimPrefix(s, "ck_") }},
+		{name: "empty", raw: func(string) string { return "" }},
+		{
+			name: "revoked",
+			raw:  func(s string) string { return s },
+			prepare: func(t *testing.T, a *APIKey, keyId uuid.UUID) {
+				if err := a.Revoke(context.Background(), keyId); err != nil {
+					t.Fatalf("Revoke() error = %v", err)
+				}
+			},
+		},
+		{
+			name: "expired",
+			raw:  func(s string) string { return s },
+			prepare: func(t *testing.T, a *APIKey, keyId uuid.UUID) {
+				if err := a.storage.ExpireAPIKey(context.Background(), keyId, 0); err != nil {
+					t.Fatalf("ExpireAPIKey() error = %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newService(newMemKeys())
+			key, secret, err := a.Issue(operatorCtx(), "billing", []string{auth.ScopeDeposit}, 0)
+			if err != nil {
+				t.Fatalf("Issue() error = %v", err)
+			}
+			if tt.prepare != nil {
+				tt.prepare(t, a, key.Id)
+			}
+
+			p, err := a.Authenticate(context.Background(), tt.raw(secret))
+			if !tt.valid {
+				if !errors.Is(err, auth.ErrInvalidToken) {
+					t.Fatalf("Authenticate() error = %v, want %v", err, auth.ErrInvalidToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+
+			want := auth.Principal{Subject: "apikey:" + key.Id.String(), Operator: "alice", Scopes: []string{auth.ScopeDeposit}, Service: true}
+			if p.Subject != want.Subject || p.Operator != want.Operator || !p.Service || p.UserId != uuid.Nil ||
+				len(p.Scopes) != 1 || p.Scopes[0] != auth.ScopeDeposit {
+				t.Errorf("Authenticate() = %+v, want %+v", p, want)
+			}
+		})
+	}
+}
+
+func TestRotate(t *testing.T) {
+	tests := []struct {
+		name     string
+		grace    time.Duration
+		revoked  bool
+		wantErr  error
+		oldValid bool
+	}{
+		{name: "old key works during the grace", grace: time.Hour, oldValid: true},
+		{name: "no grace", grace: 0},
+		{name: "revoked key", grace: time.Hour, revoked: true, wantErr: ErrKeyRevoked},
+		{name: "negative grace", grace: -time.Hour, wantErr: ErrInvalidExpiry, oldValid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newService(newMemKeys())
+			old, oldSecret, err := a.Issue(operatorCtx(), "billing", []string{auth.ScopeRead}, 0)
+			if err != nil {
+				t.Fatalf("Issue() error = %v", err)
+			}
+			if tt.revoked {
+				if err := a.Revoke(context.Background(), old.Id); err != nil {
+					t.Fatalf("Revoke() error = %v", err)
+				}
+			}
+
+			key, secret, err := a.Rotate(context.Background(), old.Id, tt.grace, 0)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Rotate() error = %v, want %v", err, tt.wantErr)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Rotate() error = %v", err)
+				}
+				if key.RotatedFrom == nil || *key.RotatedFrom != old.Id || key.Owner != "alice" || key.Name != old.Name {
+					t.Errorf("rotated key = %+v, want a copy of %s", key, old.Id)
+				}
+				if _, err := a.Authenticate(context.Background(), secret); err != nil {
+					t.Errorf("Authenticate() with the new key error = %v", err)
+				}
+			}
+
+			_, err = a.Authenticate(context.Background(), oldSecret)
+			if tt.oldValid != (err == nil) {
+				t.Errorf("Authenticate() with the old key error = %v, want valid %v", err, tt.oldValid)
+			}
+		})
+	}
+}
+
+func TestPrincipalOfRevokedKey(t *testing.T) {
+	a := newService(newMemKeys())
+	key, _, err := a.Issue(operatorCtx(), "scheduler", []string{auth.ScopeWithdraw}, 0)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	if _, err := a.Principal(context.Background(), key.Id); err != nil {
+		t.Fatalf("Principal() error = %v", err)
+	}
+	if err := a.Revoke(context.Background(), key.Id); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if _, err := a.Principal(context.Background(), key.Id); !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("Principal() error = %v, want %v", err, ErrKeyRevoked)
+	}
+	if err := a.Revoke(context.Background(), uuid.New()); !errors.Is(err, ErrKeyNotExists) {
+		t.Fatalf("Revoke() of an unknown key error = %v, want %v", err, ErrKeyNotExists)
+	}
+}
+
+// flip returns another hex digit than c.
+func flip(c byte) string {
+	if c == '0' {
+		return "1"
+	}
+
+	return "0"
+}
@@ -0,0 +1,205 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/logger/sl"
+)
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// genesisHash is the previous hash of the first record.
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+type Audit struct {
+	log     *slog.Logger
+	storage AuditStorage
+}
+
+type AuditStorage interface {
+	// WithinReadCommittedTx runs fn in a READ COMMITTED transaction of its own.
+	WithinReadCommittedTx(ctx context.Context, fn func(ctx context.Context) error) error
+	LockAuditLog(ctx context.Context) (now time.Time, err error)
+	LastAuditRecord(ctx context.Context) (models.AuditRecord, error)
+	SaveAuditRecord(ctx context.Context, rec models.AuditRecord) error
+	AuditRecords(ctx context.Context, filter models.AuditFilter) ([]models.AuditRecord, error)
+}
+
+// ErrChainBroken means a record was changed, removed or inserted out of order.
+var ErrChainBroken = errors.New("audit chain is broken")
+
+// VerifyResult is the outcome of walking the whole chain.
+type VerifyResult struct {
+	Checked int64  `json:"checked"`
+	Head    string `json:"head"`
+	// BrokenAt is the seq of the first record that does not fit the chain.
+	BrokenAt int64  `json:"brokenAt,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// New returns a new instance of the Audit service.
+func New(log *slog.Logger, storage AuditStorage) *Audit {
+	return &Audit{
+		log:     log,
+		storage: storage,
+	}
+}
+
+// Record appends rec to the log. Seq, time and hashes are assigned here,
+// under a lock, so the chain has no forks or gaps.
+// The append commits on its own, even if ctx is bound to a transaction, and always reads
+// the head under READ COMMITTED: with a serializable snapshot taken before the lock,
+// concurrent appends would see the same head and conflict.
+func (a *Audit) Record(ctx context.Context, rec models.AuditRecord) (models.AuditRecord, error) {
+	const op = "Audit.Record"
+
+	err := a.storage.WithinReadCommittedTx(ctx, func(ctx context.Context) error {
+		now, err := a.storage.LockAuditLog(ctx)
+		if err != nil {
+			return err
+		}
+
+		last, err := a.storage.LastAuditRecord(ctx)
+		if err != nil {
+			return err
+		}
+
+		rec.Seq = last.Seq + 1
+		rec.CreatedAt = now
+		rec.PrevHash = last.Hash
+		if rec.PrevHash == "" {
+			rec.PrevHash = genesisHash
+		}
+		rec.Hash = hash(rec)
+
+		return a.storage.SaveAuditRecord(ctx, rec)
+	})
+	if err != nil {
+		a.log.Error("failed to write audit record",
+			slog.String("op", op),
+			sl.TraceId(ctx),
+			slog.String("action", rec.Action),
+			slog.String("request_id", rec.RequestId),
+			sl.Err(err),
+		)
+
+		return models.AuditRecord{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rec, nil
+}
+
+// List returns records matching filter in seq order.
+func (a *Audit) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditRecord, error) {
+	const op = "Audit.List"
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultLimit
+	}
+	filter.Limit = min(filter.Limit, MaxLimit)
+
+	records, err := a.storage.AuditRecords(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return records, nil
+}
+
+// Verify walks the whole log in batches and recomputes every hash.
+// It stops at the first record that does not fit and returns ErrChainBroken.
+func (a *Audit) Verify(ctx context.Context, batchSize int) (VerifyResult, error) {
+	const op = "Audit.Verify"
+
+	result := VerifyResult{Head: genesisHash}
+
+	for {
+		batch, err := a.storage.AuditRecords(ctx, models.AuditFilter{AfterSeq: result.Checked, Limit: batchSize})
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, rec := range batch {
+			switch {
+			case rec.Seq != result.Checked+1:
+				result.Reason = fmt.Sprintf("expected seq %d", result.Checked+1)
+			case rec.PrevHash != result.Head:
+				result.Reason = "previous hash does not match"
+			case rec.Hash != hash(rec):
+				result.Reason = "record hash does not match its content"
+			}
+			if result.Reason != "" {
+				result.BrokenAt = rec.Seq
+
+				a.log.Warn("audit chain is broken",
+					slog.String("op", op),
+					slog.Int64("seq", rec.Seq),
+					slog.String("reason", result.Reason),
+				)
+
+				return result, fmt.Errorf("%s: %w at seq %d: %s", op, ErrChainBroken, rec.Seq, result.Reason)
+			}
+
+			result.Checked = rec.Seq
+			result.Head = rec.Hash
+		}
+
+		if len(batch) < batchSize {
+			return result, nil
+		}
+	}
+}
+
+// hash returns the hex SHA-256 of the record content chained to the previous hash.
+// The field order is fixed by the struct below, changing it invalidates existing logs.
+func hash(rec models.AuditRecord) string {
+	content, _ := json.Marshal(struct {
+		Seq           int64  `json:"seq"`
+		CreatedAt     string `json:"createdAt"`
+		Actor         string `json:"actor"`
+		ClientIP      string `json:"clientIp"`
+		RequestId     string `json:"requestId"`
+		Action        string `json:"action"`
+		Path          string `json:"path"`
+		Status        int    `json:"status"`
+		Outcome       string `json:"outcome"`
+		PayloadDigest string `json:"payloadDigest"`
+		PrevHash      string `json:"prevHash"`
+	}{
+		Seq: rec.Seq,
+		// The column has microsecond precision and no time zone
+		CreatedAt:     rec.CreatedAt.Format("2006-01-02T15:04:05.000000"),
+		Actor:         rec.Actor,
+		ClientIP:      rec.ClientIP,
+		RequestId:     rec.RequestId,
+		Action:        rec.Action,
+		Path:          rec.Path,
+		Status:        rec.Status,
+		Outcome:       rec.Outcome,
+		PayloadDigest: rec.PayloadDigest,
+		PrevHash:      rec.PrevHash,
+	})
+
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Digest returns the hex SHA-256 of a request payload.
+func Digest(payload []byte) string {
+	sum := sha256.Sum256(payload)
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,264 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage/postgres"
+)
+
+// memLog is an in-memory AuditStorage, its transactions hold one lock in turn like the advisory lock.
+type memLog struct {
+	mu      sync.Mutex
+	records []models.AuditRecord
+}
+
+func (l *memLog) WithinReadCommittedTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return fn(ctx)
+}
+
+func (l *memLog) LockAuditLog(context.Context) (time.Time, error) {
+	return time.Now().UTC().Truncate(time.Microsecond), nil
+}
+
+func (l *memLog) LastAuditRecord(context.Context) (models.AuditRecord, error) {
+	if len(l.records) == 0 {
+		return models.AuditRecord{}, nil
+	}
+
+	return l.records[len(l.records)-1], nil
+}
+
+func (l *memLog) SaveAuditRecord(_ context.Context, rec models.AuditRecord) error {
+	l.records = append(l.records, rec)
+
+	return nil
+}
+
+func (l *memLog) AuditRecords(_ context.Context, filter models.AuditFilter) ([]models.AuditRecord, error) {
+	var records []models.AuditRecord
+	for _, rec := range l.records {
+		if rec.Seq > filter.AfterSeq && len(records) < filter.Limit {
+			records = append(records, rec)
+		}
+	}
+
+	return records, nil
+}
+
+func newLog() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func record(t *testing.T, a *Audit, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		_, err := a.Record(context.Background(), models.AuditRecord{
+			Actor:     "billing",
+			RequestId: fmt.Sprintf("req-%d", i),
+			Action:    "POST /wallet",
+			Path:      "/wallet",
+			Outcome:   models.AuditStarted,
+		})
+		if err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+}
+
+func TestRecordChainsRecords(t *testing.T) {
+	l := &memLog{}
+	a := New(newLog(), l)
+	record(t, a, 5)
+
+	prev := genesisHash
+	for i, rec := range l.records {
+		if rec.Seq != int64(i+1) {
+			t.Errorf("records[%d] seq = %d, want %d", i, rec.Seq, i+1)
+		}
+		if rec.PrevHash != prev {
+			t.Errorf("records[%d] prevHash = %s, want %s", i, rec.PrevHash, prev)
+		}
+		prev = rec.Hash
+	}
+
+	// Batches smaller than the log make Verify page through it
+	result, err := a.Verify(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if result.Checked != 5 || result.Head != prev {
+		t.Errorf("Verify() = %+v, want 5 records checked up to head %s", result, prev)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(records []models.AuditRecord) []models.AuditRecord
+		brokenAt int64
+		reason   string
+	}{
+		{
+			name: "changed field",
+			tamper: func(records []models.AuditRecord) []models.AuditRecord {
+				records[1].Actor = "someone else"
+				return records
+			},
+			brokenAt: 2,
+			reason:   "record hash does not match its content",
+		},
+		{
+			name: "changed record with its hash recomputed",
+			tamper: func(records []models.AuditRecord) []models.AuditRecord {
+				records[1].Status = 200
+				records[1].Hash = hash(records[1])
+				return records
+			},
+			brokenAt: 3,
+			reason:   "previous hash does not match",
+		},
+		{
+			name: "removed record",
+			tamper: func(records []models.AuditRecord) []models.AuditRecord {
+				return append(records[:2], records[3:]...)
+			},
+			brokenAt: 4,
+			reason:   "expected seq 3",
+		},
+		{
+			name: "removed head",
+			tamper: func(records []models.AuditRecord) []models.AuditRecord {
+				return records[1:]
+			},
+			brokenAt: 2,
+			reason:   "expected seq 1",
+		},
+		{
+			name: "inserted record",
+			tamper: func(records []models.AuditRecord) []models.AuditRecord {
+				forged := records[2]
+				forged.Seq = 4
+				forged.PrevHash = records[2].Hash
+				forged.Hash = hash(forged)
+				for i := 3; i < len(records); i++ {
+					records[i].Seq++
+				}
+				return append(records[:3], append([]models.AuditRecord{forged}, records[3:]...)...)
+			},
+			brokenAt: 5,
+			reason:   "previous hash does not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &memLog{}
+			a := New(newLog(), l)
+			record(t, a, 5)
+			l.records = tt.tamper(l.records)
+
+			result, err := a.Verify(context.Background(), 2)
+			if !errors.Is(err, ErrChainBroken) {
+				t.Fatalf("Verify() error = %v, want %v", err, ErrChainBroken)
+			}
+			if result.BrokenAt != tt.brokenAt || result.Reason != tt.reason {
+				t.Errorf("Verify() broken at %d: %q, want %d: %q", result.BrokenAt, result.Reason, tt.brokenAt, tt.reason)
+			}
+		})
+	}
+}
+
+// appendConcurrently makes n appends from several goroutines and fails on the first error.
+func appendConcurrently(t *testing.T, a *Audit, n int) {
+	t.Helper()
+
+	const workers = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < n; i += workers {
+				_, err := a.Record(context.Background(), models.AuditRecord{
+					Actor:     "billing",
+					RequestId: fmt.Sprintf("req-%d", i),
+					Action:    "POST /wallet",
+					Path:      "/wallet",
+					Outcome:   models.AuditStarted,
+				})
+				if err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("Record() error = %v", err)
+	}
+}
+
+func TestRecordConcurrently(t *testing.T) {
+	l := &memLog{}
+	a := New(newLog(), l)
+
+	appendConcurrently(t, a, 200)
+
+	result, err := a.Verify(context.Background(), 50)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if result.Checked != 200 {
+		t.Errorf("Verify() checked %d records, want 200", result.Checked)
+	}
+}
+
+// TestRecordConcurrentlyOnPostgres appends to the audit log of the migrated database in
+// TEST_POSTGRES_DSN with serializable isolation, the strictest tx.isolation allows: no append may
+// conflict and the chain stays gap-free.
+func TestRecordConcurrentlyOnPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	s, err := postgres.New(postgres.Options{DSN: dsn, MaxOpenConns: 8, Isolation: sql.LevelSerializable})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	a := New(newLog(), s)
+	before, err := a.Verify(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("Verify() before the appends error = %v", err)
+	}
+
+	appendConcurrently(t, a, 100)
+
+	after, err := a.Verify(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if after.Checked != before.Checked+100 {
+		t.Errorf("Verify() checked %d records, want %d", after.Checked, before.Checked+100)
+	}
+}
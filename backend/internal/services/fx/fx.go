@@ -0,0 +1,426 @@
+package fx
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/lib/decimal"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/services/wallet"
+	"coin-app/internal/storage"
+)
+
+const (
+	// RatePlaces is the scale rates are stored and applied with.
+	RatePlaces = 10
+	// SpreadPlaces is the scale of the spread fraction.
+	SpreadPlaces = 6
+)
+
+// FX keeps effective-dated exchange rates and converts money between wallets of different currencies:
+// a quote locks the rate for a while, executing it posts both legs in one transaction.
+type FX struct {
+	log       *slog.Logger
+	storage   RateStorage
+	txManager TxManager
+	wallets   Wallets
+	opts      Options
+}
+
+type RateStorage interface {
+	SaveRates(ctx context.Context, rates []models.FXRate) error
+	CurrentRate(ctx context.Context, base, quote string) (models.FXRate, error)
+	CurrentRates(ctx context.Context) ([]models.FXRate, error)
+	SaveQuote(ctx context.Context, q models.FXQuote) error
+	GetQuote(ctx context.Context, quoteId uuid.UUID) (models.FXQuote, error)
+	LockQuote(ctx context.Context, quoteId uuid.UUID) (models.FXQuote, error)
+	ExecuteQuote(ctx context.Context, quoteId, debitId, creditId uuid.UUID) (models.FXQuote, error)
+}
+
+// TxManager runs fn in a single database transaction.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Wallets checks access to wallets and posts conversions, see wallet.Wallet.
+type Wallets interface {
+	GetWallet(ctx context.Context, walletId uuid.UUID) (models.Wallet, error)
+	Convert(ctx context.Context, quote models.FXQuote) (debitId uuid.UUID, creditId uuid.UUID, err error)
+}
+
+type Options struct {
+	// Currencies maps the convertible currencies to their decimal places.
+	Currencies map[string]int
+	// SpreadBps is taken off the market rate, in basis points.
+	SpreadBps int
+	// QuoteTTL is how long a quote locks its rate.
+	QuoteTTL time.Duration
+}
+
+var (
+	// ErrInvalidRate comes with the reason, e.g. a malformed rate or an unknown currency.
+	ErrInvalidRate = errors.New("invalid exchange rate")
+	// ErrUnsupportedCurrency means a wallet holds a currency that is not convertible.
+	ErrUnsupportedCurrency = errors.New("currency is not convertible")
+	ErrSameCurrency        = errors.New("wallets hold the same currency")
+	ErrRateNotExists       = errors.New("no exchange rate for the currencies")
+	// ErrInvalidAmount means a sell amount that is not positive or buys less than the smallest unit.
+	ErrInvalidAmount   = errors.New("invalid amount")
+	ErrQuoteNotExists  = errors.New("quote not exists")
+	ErrQuoteExpired    = errors.New("quote expired")
+	ErrAlreadyExecuted = errors.New("quote is already executed")
+	ErrWalletNotExists = errors.New("wallet not exists")
+	// ErrForbidden means the caller may not act on a wallet or the quote was made by another caller.
+	ErrForbidden = errors.New("caller may not convert between the wallets")
+	// ErrConflict means concurrent operations kept conflicting and the caller may try again.
+	ErrConflict = errors.New("conversion conflicted with concurrent updates")
+	// ErrUnauthenticated means ctx carries no caller, see auth.WithPrincipal.
+	ErrUnauthenticated = errors.New("caller is not authenticated")
+)
+
+// New returns a new instance of the FX service.
+func New(log *slog.Logger, storage RateStorage, txManager TxManager, wallets Wallets, opts Options) *FX {
+	return &FX{
+		log:       log,
+		storage:   storage,
+		txManager: txManager,
+		wallets:   wallets,
+		opts:      opts,
+	}
+}
+
+// SetRates stores rates on behalf of the caller, a rate without an effective time is effective now.
+func (f *FX) SetRates(ctx context.Context, rates []models.FXRate) ([]models.FXRate, error) {
+	const op = "FX.SetRates"
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+
+	for i := range rates {
+		rates[i].Source = principal.Subject
+	}
+
+	saved, err := f.save(ctx, rates)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	f.log.Info("exchange rates set",
+		slog.String("op", op),
+		slog.Int("count", len(saved)),
+		slog.String("caller", principal.Subject),
+	)
+
+	return saved, nil
+}
+
+// LoadRates stores the rates of a CSV file with the header base,quote,rate,effective_at.
+// effective_at is RFC 3339 and may be empty, lines starting with # are skipped.
+func (f *FX) LoadRates(ctx context.Context, r io.Reader, source string) (int, error) {
+	const op = "FX.LoadRates"
+
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != "base,quote,rate,effective_at" {
+		return 0, fmt.Errorf("%s: want the header base,quote,rate,effective_at", op)
+	}
+
+	rates := make([]models.FXRate, 0, len(records)-1)
+	for i, record := range records[1:] {
+		rate := models.FXRate{Base: record[0], Quote: record[1], Rate: record[2], Source: source}
+		if record[3] != "" {
+			rate.EffectiveAt, err = time.Parse(time.RFC3339, record[3])
+			if err != nil {
+				return 0, fmt.Errorf("%s: line %d: %w", op, i+2, err)
+			}
+		}
+		rates = append(rates, rate)
+	}
+
+	saved, err := f.save(ctx, rates)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return len(saved), nil
+}
+
+// Rates returns the rate effective now of every pair.
+func (f *FX) Rates(ctx context.Context) ([]models.FXRate, error) {
+	const op = "FX.Rates"
+
+	rates, err := f.storage.CurrentRates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rates, nil
+}
+
+// Quote prices selling sellAmount from one wallet for the currency of the other one
+// and locks the price for the quote TTL. The caller needs access to both wallets.
+func (f *FX) Quote(ctx context.Context, fromWalletId, toWalletId uuid.UUID, sellAmount int) (models.FXQuote, error) {
+	const op = "FX.Quote"
+
+	log := f.log.With(
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("fromWalletId", fromWalletId.String()),
+		slog.String("toWalletId", toWalletId.String()),
+		slog.Int("sellAmount", sellAmount),
+	)
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+	if !principal.HasScope(auth.ScopeWithdraw) || !principal.HasScope(auth.ScopeDeposit) {
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+	if sellAmount <= 0 {
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, ErrInvalidAmount)
+	}
+
+	from, err := f.wallet(ctx, fromWalletId)
+	if err != nil {
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, err)
+	}
+	to, err := f.wallet(ctx, toWalletId)
+	if err != nil {
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if from.Currency == to.Currency {
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, ErrSameCurrency)
+	}
+	places, ok := f.opts.Currencies[to.Currency]
+	if _, known := f.opts.Currencies[from.Currency]; !ok || !known {
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, ErrUnsupportedCurrency)
+	}
+
+	rate, err := f.rate(ctx, from.Currency, to.Currency)
+	if err != nil {
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The spread is taken off the rate, the buy amount is rounded half to even in the buy currency
+	spread := big.NewRat(int64(f.opts.SpreadBps), 10000)
+	applied := decimal.Round(new(big.Rat).Mul(rate, new(big.Rat).Sub(big.NewRat(1, 1), spread)), RatePlaces)
+	buy := decimal.Round(new(big.Rat).Mul(big.NewRat(int64(sellAmount), 1), applied), places)
+	if buy.Sign() <= 0 {
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, ErrInvalidAmount)
+	}
+
+	q := models.FXQuote{
+		Id:           uuid.New(),
+		FromWalletId: fromWalletId,
+		ToWalletId:   toWalletId,
+		FromCurrency: from.Currency,
+		ToCurrency:   to.Currency,
+		SellAmount:   sellAmount,
+		BuyAmount:    decimal.String(buy, places),
+		Rate:         decimal.String(rate, RatePlaces),
+		Spread:       decimal.String(spread, SpreadPlaces),
+		AppliedRate:  decimal.String(applied, RatePlaces),
+		ExpiresAt:    time.Now().Add(f.opts.QuoteTTL).UTC(),
+		CreatedBy:    principal.Subject,
+	}
+
+	if err := f.storage.SaveQuote(ctx, q); err != nil {
+		if errors.Is(err, storage.ErrWalletNotExists) {
+			return models.FXQuote{}, fmt.Errorf("%s: %w", op, ErrWalletNotExists)
+		}
+		log.Error("failed to save quote", sl.Err(err))
+
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Read it back for the timestamp set by the database
+	saved, err := f.storage.GetQuote(ctx, q.Id)
+	if err != nil {
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("conversion quoted",
+		slog.String("quoteId", q.Id.String()),
+		slog.String("buyAmount", q.BuyAmount),
+		slog.String("appliedRate", q.AppliedRate),
+	)
+
+	return saved, nil
+}
+
+// Execute posts the conversion at the quoted rate, once and before the quote expires.
+// Only the caller who requested the quote, or an admin, may execute it.
+func (f *FX) Execute(ctx context.Context, quoteId uuid.UUID) (models.FXQuote, error) {
+	const op = "FX.Execute"
+
+	log := f.log.With(
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("quoteId", quoteId.String()),
+	)
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+
+	var executed models.FXQuote
+	err := f.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		q, err := f.storage.LockQuote(ctx, quoteId)
+		if err != nil {
+			return err
+		}
+
+		if q.CreatedBy != principal.Subject && !principal.IsAdmin() {
+			return ErrForbidden
+		}
+		if q.ExecutedAt != nil {
+			return ErrAlreadyExecuted
+		}
+		if !time.Now().Before(q.ExpiresAt) {
+			return ErrQuoteExpired
+		}
+
+		debitId, creditId, err := f.wallets.Convert(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		executed, err = f.storage.ExecuteQuote(ctx, q.Id, debitId, creditId)
+
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrQuoteNotExists):
+			return models.FXQuote{}, fmt.Errorf("%s: %w", op, ErrQuoteNotExists)
+		case errors.Is(err, wallet.ErrWalletNotExists):
+			return models.FXQuote{}, fmt.Errorf("%s: %w", op, ErrWalletNotExists)
+		case errors.Is(err, wallet.ErrForbidden), errors.Is(err, wallet.ErrUnauthenticated):
+			return models.FXQuote{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+		case errors.Is(err, storage.ErrConflict), errors.Is(err, wallet.ErrConflict):
+			log.Warn("conversion conflicts after retries", sl.Err(err))
+
+			return models.FXQuote{}, fmt.Errorf("%s: %w", op, ErrConflict)
+		case errors.Is(err, ErrForbidden), errors.Is(err, ErrAlreadyExecuted), errors.Is(err, ErrQuoteExpired),
+			errors.Is(err, wallet.ErrLimitExceeded), errors.Is(err, wallet.ErrCurrencyMismatch):
+			log.Warn("conversion refused", slog.String("caller", principal.Subject), sl.Err(err))
+
+			return models.FXQuote{}, fmt.Errorf("%s: %w", op, err)
+		}
+		log.Error("failed to execute quote", sl.Err(err))
+
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("conversion executed",
+		slog.String("debitTransactionId", executed.DebitTransactionId.String()),
+		slog.String("creditTransactionId", executed.CreditTransactionId.String()),
+	)
+
+	return executed, nil
+}
+
+// save validates the rates and stores them.
+func (f *FX) save(ctx context.Context, rates []models.FXRate) ([]models.FXRate, error) {
+	now := time.Now().UTC()
+
+	for i := range rates {
+		rate := &rates[i]
+		rate.Base = strings.ToUpper(strings.TrimSpace(rate.Base))
+		rate.Quote = strings.ToUpper(strings.TrimSpace(rate.Quote))
+
+		if _, ok := f.opts.Currencies[rate.Base]; !ok {
+			return nil, fmt.Errorf("%w: unknown currency %q", ErrInvalidRate, rate.Base)
+		}
+		if _, ok := f.opts.Currencies[rate.Quote]; !ok {
+			return nil, fmt.Errorf("%w: unknown currency %q", ErrInvalidRate, rate.Quote)
+		}
+		if rate.Base == rate.Quote {
+			return nil, fmt.Errorf("%w: %s is quoted in itself", ErrInvalidRate, rate.Base)
+		}
+
+		r, err := decimal.Parse(rate.Rate)
+		if err != nil || r.Sign() <= 0 {
+			return nil, fmt.Errorf("%w: rate %q of %s/%s must be a positive decimal", ErrInvalidRate, rate.Rate, rate.Base, rate.Quote)
+		}
+		rate.Rate = decimal.String(r, RatePlaces)
+		if rate.Rate == "0" {
+			return nil, fmt.Errorf("%w: rate of %s/%s has more than %d decimal places", ErrInvalidRate, rate.Base, rate.Quote, RatePlaces)
+		}
+
+		if rate.EffectiveAt.IsZero() {
+			rate.EffectiveAt = now
+		}
+	}
+
+	if err := f.storage.SaveRates(ctx, rates); err != nil {
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+// rate returns the market rate of one unit of base in quote,
+// the inverse of the quote/base rate if only that one is known.
+func (f *FX) rate(ctx context.Context, base, quote string) (*big.Rat, error) {
+	rate, err := f.storage.CurrentRate(ctx, base, quote)
+	inverse := false
+	if errors.Is(err, storage.ErrRateNotExists) {
+		rate, err = f.storage.CurrentRate(ctx, quote, base)
+		inverse = true
+	}
+	if errors.Is(err, storage.ErrRateNotExists) {
+		return nil, ErrRateNotExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := decimal.Parse(rate.Rate)
+	if err != nil {
+		return nil, fmt.Errorf("stored rate %s/%s: %w", rate.Base, rate.Quote, err)
+	}
+	if inverse {
+		r = decimal.Round(r.Inv(r), RatePlaces)
+	}
+
+	return r, nil
+}
+
+// wallet returns the wallet if the caller may access it.
+func (f *FX) wallet(ctx context.Context, walletId uuid.UUID) (models.Wallet, error) {
+	w, err := f.wallets.GetWallet(ctx, walletId)
+	switch {
+	case errors.Is(err, wallet.ErrWalletNotExists):
+		return models.Wallet{}, ErrWalletNotExists
+	case errors.Is(err, wallet.ErrForbidden), errors.Is(err, wallet.ErrUnauthenticated):
+		return models.Wallet{}, ErrForbidden
+	case err != nil:
+		return models.Wallet{}, err
+	}
+
+	return w, nil
+}
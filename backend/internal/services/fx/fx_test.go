@@ -0,0 +1,207 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/storage"
+)
+
+// memRates is an in-memory RateStorage, its transactions run fn as is.
+type memRates struct {
+	rates  map[string]string
+	quotes map[uuid.UUID]models.FXQuote
+}
+
+func (s *memRates) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (s *memRates) SaveRates(context.Context, []models.FXRate) error {
+	return nil
+}
+
+func (s *memRates) CurrentRate(_ context.Context, base, quote string) (models.FXRate, error) {
+	rate, ok := s.rates[base+"/"+quote]
+	if !ok {
+		return models.FXRate{}, storage.ErrRateNotExists
+	}
+
+	return models.FXRate{Base: base, Quote: quote, Rate: rate}, nil
+}
+
+func (s *memRates) CurrentRates(context.Context) ([]models.FXRate, error) {
+	return nil, nil
+}
+
+func (s *memRates) SaveQuote(_ context.Context, q models.FXQuote) error {
+	s.quotes[q.Id] = q
+
+	return nil
+}
+
+func (s *memRates) GetQuote(_ context.Context, quoteId uuid.UUID) (models.FXQuote, error) {
+	q, ok := s.quotes[quoteId]
+	if !ok {
+		return models.FXQuote{}, storage.ErrQuoteNotExists
+	}
+
+	return q, nil
+}
+
+func (s *memRates) LockQuote(ctx context.Context, quoteId uuid.UUID) (models.FXQuote, error) {
+	return s.GetQuote(ctx, quoteId)
+}
+
+func (s *memRates) ExecuteQuote(_ context.Context, quoteId, debitId, creditId uuid.UUID) (models.FXQuote, error) {
+	q := s.quotes[quoteId]
+	now := time.Now()
+	q.ExecutedAt, q.DebitTransactionId, q.CreditTransactionId = &now, &debitId, &creditId
+	s.quotes[quoteId] = q
+
+	return q, nil
+}
+
+// memWallets holds the wallets by id and counts conversions.
+type memWallets struct {
+	wallets   map[uuid.UUID]models.Wallet
+	converted int
+}
+
+func (w *memWallets) GetWallet(_ context.Context, walletId uuid.UUID) (models.Wallet, error) {
+	return w.wallets[walletId], nil
+}
+
+func (w *memWallets) Convert(context.Context, models.FXQuote) (uuid.UUID, uuid.UUID, error) {
+	w.converted++
+
+	return uuid.New(), uuid.New(), nil
+}
+
+var currencies = map[string]int{"RUB": 2, "USD": 2, "JPY": 0, "KWD": 3}
+
+func newFX(t *testing.T, spreadBps int, rate string, buyCurrency string) (*FX, *memRates, *memWallets, models.Wallet, models.Wallet) {
+	t.Helper()
+
+	from := models.Wallet{Id: uuid.New(), Currency: "RUB"}
+	to := models.Wallet{Id: uuid.New(), Currency: buyCurrency}
+
+	rates := &memRates{
+		rates:  map[string]string{"RUB/" + buyCurrency: rate},
+		quotes: map[uuid.UUID]models.FXQuote{},
+	}
+	wallets := &memWallets{wallets: map[uuid.UUID]models.Wallet{from.Id: from, to.Id: to}}
+
+	f := New(slog.New(slog.NewTextHandler(io.Discard, nil)), rates, rates, wallets, Options{
+		Currencies: currencies,
+		SpreadBps:  spreadBps,
+		QuoteTTL:   time.Minute,
+	})
+
+	return f, rates, wallets, from, to
+}
+
+func callerCtx() context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{
+		Subject: "billing",
+		Scopes:  []string{auth.ScopeDeposit, auth.ScopeWithdraw},
+		Service: true,
+	})
+}
+
+func TestQuoteRoundsHalfToEvenInBuyCurrency(t *testing.T) {
+	tests := []struct {
+		name      string
+		currency  string
+		rate      string
+		spreadBps int
+		sell      int
+		want      string
+		wantErr   error
+	}{
+		{name: "2 places, half rounds up to even", currency: "USD", rate: "0.015", sell: 1, want: "0.02"},
+		{name: "2 places, half stays even", currency: "USD", rate: "0.025", sell: 1, want: "0.02"},
+		{name: "2 places, above half", currency: "USD", rate: "0.0251", sell: 1, want: "0.03"},
+		{name: "2 places, below half", currency: "USD", rate: "0.0149", sell: 1, want: "0.01"},
+		{name: "2 places, half of a larger amount", currency: "USD", rate: "0.005", sell: 3, want: "0.02"},
+		{name: "0 places, half rounds down to even", currency: "JPY", rate: "2.5", sell: 1, want: "2"},
+		{name: "0 places, half rounds up to even", currency: "JPY", rate: "3.5", sell: 1, want: "4"},
+		{name: "0 places, spread taken before rounding", currency: "JPY", rate: "2", spreadBps: 50, sell: 5, want: "10"},
+		{name: "3 places, half rounds up to even", currency: "KWD", rate: "0.0015", sell: 1, want: "0.002"},
+		{name: "3 places, half stays even", currency: "KWD", rate: "0.0025", sell: 1, want: "0.002"},
+		{name: "3 places, rounds to nothing", currency: "KWD", rate: "0.0005", sell: 1, wantErr: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _, _, from, to := newFX(t, tt.spreadBps, tt.rate, tt.currency)
+
+			q, err := f.Quote(callerCtx(), from.Id, to.Id, tt.sell)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Quote() error = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("Quote() error = %v", err)
+			}
+			if q.BuyAmount != tt.want {
+				t.Errorf("buy amount of %d at %s = %s %s, want %s", tt.sell, tt.rate, q.BuyAmount, tt.currency, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteRefusesExpiredQuote(t *testing.T) {
+	f, rates, wallets, from, to := newFX(t, 0, "0.011", "USD")
+	ctx := callerCtx()
+
+	q, err := f.Quote(ctx, from.Id, to.Id, 1000)
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+	q.ExpiresAt = time.Now().Add(-time.Second)
+	rates.quotes[q.Id] = q
+
+	if _, err := f.Execute(ctx, q.Id); !errors.Is(err, ErrQuoteExpired) {
+		t.Fatalf("Execute() error = %v, want %v", err, ErrQuoteExpired)
+	}
+	if wallets.converted != 0 {
+		t.Errorf("converted %d times, want none", wallets.converted)
+	}
+}
+
+func TestExecuteTwice(t *testing.T) {
+	f, _, wallets, from, to := newFX(t, 0, "0.011", "USD")
+	ctx := callerCtx()
+
+	q, err := f.Quote(ctx, from.Id, to.Id, 1000)
+	if err != nil {
+		t.Fatalf("Quote() error = %v", err)
+	}
+
+	executed, err := f.Execute(ctx, q.Id)
+	if err != nil {
+		t.Fatalf("first Execute() error = %v", err)
+	}
+	if executed.ExecutedAt == nil || executed.DebitTransactionId == nil || executed.CreditTransactionId == nil {
+		t.Errorf("executed quote = %+v, want its time and transactions set", executed)
+	}
+
+	if _, err := f.Execute(ctx, q.Id); !errors.Is(err, ErrAlreadyExecuted) {
+		t.Fatalf("second Execute() error = %v, want %v", err, ErrAlreadyExecuted)
+	}
+	if wallets.converted != 1 {
+		t.Errorf("converted %d times, want once", wallets.converted)
+	}
+}
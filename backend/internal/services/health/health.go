@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	"coin-app/internal/lib/logger/sl"
+)
+
+const (
+	CheckOK       = "ok"
+	CheckDraining = "draining"
+)
+
+// Checker reports the state of the database.
+type Checker interface {
+	Ping(ctx context.Context) error
+	MigrationVersion(ctx context.Context) (version uint, dirty bool, err error)
+}
+
+// Health decides whether the instance may receive traffic.
+type Health struct {
+	log           *slog.Logger
+	checker       Checker
+	schemaVersion uint
+	draining      atomic.Bool
+}
+
+// Report is the outcome of a readiness check.
+// Checks holds "ok" or a failure reason per dependency.
+type Report struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
+
+// New returns a new instance of the Health service.
+// schemaVersion is the oldest migration the running code works with.
+func New(log *slog.Logger, checker Checker, schemaVersion uint) *Health {
+	return &Health{
+		log:           log,
+		checker:       checker,
+		schemaVersion: schemaVersion,
+	}
+}
+
+// Drain makes the instance report not ready, so that the balancer
+// stops sending new requests before the server shuts down.
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// Ready checks the database connection, the migration version and the draining state.
+func (h *Health) Ready(ctx context.Context) Report {
+	const op = "Health.Ready"
+
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
+	report := Report{
+		Ready: true,
+		Checks: map[string]string{
+			"database":   CheckOK,
+			"migrations": CheckOK,
+			"draining":   CheckOK,
+		},
+	}
+	fail := func(check string, reason string) {
+		report.Ready = false
+		report.Checks[check] = reason
+	}
+
+	if h.draining.Load() {
+		fail("draining", CheckDraining)
+	}
+
+	if err := h.checker.Ping(ctx); err != nil {
+		log.Warn("database is unreachable", sl.Err(err))
+		fail("database", "unreachable")
+		fail("migrations", "unknown")
+
+		return report
+	}
+
+	version, dirty, err := h.checker.MigrationVersion(ctx)
+	switch {
+	case err != nil:
+		log.Warn("failed to read migration version", sl.Err(err))
+		fail("migrations", "unknown")
+	case dirty:
+		fail("migrations", fmt.Sprintf("version %d is dirty", version))
+	case version < h.schemaVersion:
+		fail("migrations", fmt.Sprintf("version %d, expected %d", version, h.schemaVersion))
+	}
+
+	return report
+}
@@ -0,0 +1,22 @@
+package outbox
+
+import (
+	"context"
+
+	"coin-app/internal/domain/models"
+)
+
+// Fanout publishes every event to all of its publishers.
+// If one of them fails the whole event is retried,
+// so publishers that already succeeded will see it again.
+type Fanout []Publisher
+
+func (f Fanout) Publish(ctx context.Context, event models.Event) error {
+	for _, p := range f {
+		if err := p.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/backoff"
+	"coin-app/internal/lib/logger/sl"
+)
+
+// Publisher delivers events to downstream consumers.
+// Publish must be idempotent on the consumer side:
+// an event is published at least once and may be repeated after a failure.
+type Publisher interface {
+	Publish(ctx context.Context, event models.Event) error
+}
+
+type EventStorage interface {
+	ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]models.Event, error)
+	MarkEventPublished(ctx context.Context, eventId uuid.UUID) error
+	MarkEventFailed(ctx context.Context, eventId uuid.UUID, retryIn time.Duration, reason string) error
+}
+
+type Options struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// Lease is how long a claimed batch is reserved for publishing before another relay may take it over.
+	// It must be longer than publishing a batch takes.
+	Lease      time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Relay moves events from the outbox to the publisher.
+type Relay struct {
+	log       *slog.Logger
+	storage   EventStorage
+	publisher Publisher
+	opts      Options
+}
+
+// New returns a new instance of the outbox Relay.
+func New(
+	log *slog.Logger,
+	storage EventStorage,
+	publisher Publisher,
+	opts Options,
+) *Relay {
+	return &Relay{
+		log:       log,
+		storage:   storage,
+		publisher: publisher,
+		opts:      opts,
+	}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	const op = "Outbox.Run"
+
+	log := r.log.With(slog.String("op", op))
+
+	log.Info("outbox relay started")
+
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := r.relayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error("failed to relay events", sl.Err(err))
+		}
+
+		// A full batch means there may be more events waiting.
+		if err == nil && n == r.opts.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("outbox relay stopped")
+
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// relayBatch publishes one batch of pending events and returns its size.
+// The events are claimed first, so no transaction or row lock is held while publishing.
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	const op = "Outbox.relayBatch"
+
+	events, err := r.storage.ClaimEvents(ctx, r.opts.BatchSize, r.opts.Lease)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, event := range events {
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			retryIn := backoff.Jittered(event.Attempts, r.opts.MinBackoff, r.opts.MaxBackoff)
+
+			r.log.Warn("failed to publish event",
+				slog.String("op", op),
+				slog.String("eventId", event.Id.String()),
+				slog.String("eventType", string(event.Type)),
+				slog.Int("attempts", event.Attempts+1),
+				slog.String("retryIn", retryIn.String()),
+				sl.Err(err),
+			)
+
+			if err := r.storage.MarkEventFailed(ctx, event.Id, retryIn, err.Error()); err != nil {
+				return 0, fmt.Errorf("%s: %w", op, err)
+			}
+
+			continue
+		}
+
+		if err := r.storage.MarkEventPublished(ctx, event.Id); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return len(events), nil
+}
@@ -0,0 +1,216 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+)
+
+// memEvent is an outbox row.
+type memEvent struct {
+	event         models.Event
+	nextAttemptAt time.Time
+	published     bool
+	lastError     string
+}
+
+// memOutbox leases events like the database does, on a clock moved by the test.
+type memOutbox struct {
+	now    time.Time
+	events []*memEvent
+}
+
+func newMemOutbox() *memOutbox {
+	return &memOutbox{now: time.Now()}
+}
+
+func (m *memOutbox) add(aggregateId uuid.UUID) uuid.UUID {
+	id := uuid.New()
+	m.events = append(m.events, &memEvent{
+		event:         models.Event{Id: id, AggregateId: aggregateId, Type: models.EventFundsDeposited},
+		nextAttemptAt: m.now,
+	})
+
+	return id
+}
+
+func (m *memOutbox) find(eventId uuid.UUID) *memEvent {
+	for _, e := range m.events {
+		if e.event.Id == eventId {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func (m *memOutbox) ClaimEvents(_ context.Context, limit int, lease time.Duration) ([]models.Event, error) {
+	var claimed []models.Event
+	blocked := make(map[uuid.UUID]bool)
+	for _, e := range m.events {
+		if e.published {
+			continue
+		}
+		// Only the oldest unpublished event of an aggregate may go
+		first := !blocked[e.event.AggregateId]
+		blocked[e.event.AggregateId] = true
+		if !first || m.now.Before(e.nextAttemptAt) || len(claimed) == limit {
+			continue
+		}
+
+		e.nextAttemptAt = m.now.Add(lease)
+		claimed = append(claimed, e.event)
+	}
+
+	return claimed, nil
+}
+
+func (m *memOutbox) MarkEventPublished(_ context.Context, eventId uuid.UUID) error {
+	m.find(eventId).published = true
+
+	return nil
+}
+
+func (m *memOutbox) MarkEventFailed(_ context.Context, eventId uuid.UUID, retryIn time.Duration, reason string) error {
+	e := m.find(eventId)
+	e.event.Attempts++
+	e.nextAttemptAt = m.now.Add(retryIn)
+	e.lastError = reason
+
+	return nil
+}
+
+// memPublisher records the events published and fails those told to.
+type memPublisher struct {
+	published []uuid.UUID
+	failing   map[uuid.UUID]bool
+}
+
+func (p *memPublisher) Publish(_ context.Context, event models.Event) error {
+	if p.failing[event.Id] {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, event.Id)
+
+	return nil
+}
+
+var testOptions = Options{
+	PollInterval: time.Second,
+	BatchSize:    10,
+	Lease:        time.Minute,
+	MinBackoff:   time.Second,
+	MaxBackoff:   time.Minute,
+}
+
+func newRelay(storage EventStorage, publisher Publisher) *Relay {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), storage, publisher, testOptions)
+}
+
+func TestRelayBatchReclaimsExpiredLeases(t *testing.T) {
+	outbox := newMemOutbox()
+	first, second := outbox.add(uuid.New()), outbox.add(uuid.New())
+
+	// A relay claims the events and dies before publishing them
+	if _, err := outbox.ClaimEvents(context.Background(), testOptions.BatchSize, testOptions.Lease); err != nil {
+		t.Fatalf("ClaimEvents() error = %v", err)
+	}
+
+	publisher := &memPublisher{}
+	r := newRelay(outbox, publisher)
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		want    []uuid.UUID
+	}{
+		{name: "lease held", advance: testOptions.Lease / 2},
+		{name: "lease about to expire", advance: testOptions.Lease/2 - time.Millisecond},
+		{name: "lease expired", advance: time.Millisecond, want: []uuid.UUID{first, second}},
+		{name: "published events are not claimed again", advance: 2 * testOptions.Lease},
+	}
+
+	for _, step := range steps {
+		outbox.now = outbox.now.Add(step.advance)
+		publisher.published = nil
+
+		n, err := r.relayBatch(context.Background())
+		if err != nil {
+			t.Fatalf("%s: relayBatch() error = %v", step.name, err)
+		}
+		if n != len(step.want) || !slices.Equal(publisher.published, step.want) {
+			t.Errorf("%s: relayBatch() = %d, published %v, want %v", step.name, n, publisher.published, step.want)
+		}
+	}
+}
+
+func TestRelayBatchRetriesFailures(t *testing.T) {
+	outbox := newMemOutbox()
+	wallet, other := uuid.New(), uuid.New()
+	failing, next, unrelated := outbox.add(wallet), outbox.add(wallet), outbox.add(other)
+
+	publisher := &memPublisher{failing: map[uuid.UUID]bool{failing: true}}
+	r := newRelay(outbox, publisher)
+
+	steps := []struct {
+		name string
+		// heal lets the failing event through before the step
+		heal    bool
+		advance time.Duration
+		want    []uuid.UUID
+	}{
+		// The later event of the wallet waits for the failing one, other wallets do not
+		{name: "first attempt", want: []uuid.UUID{unrelated}},
+		{name: "backoff not over", advance: testOptions.MinBackoff / 4},
+		{name: "second attempt", advance: 2 * testOptions.MinBackoff},
+		{name: "recovered", heal: true, advance: testOptions.MaxBackoff, want: []uuid.UUID{failing}},
+		{name: "next event of the wallet", want: []uuid.UUID{next}},
+	}
+
+	for _, step := range steps {
+		if step.heal {
+			publisher.failing = nil
+		}
+		outbox.now = outbox.now.Add(step.advance)
+		publisher.published = nil
+
+		if _, err := r.relayBatch(context.Background()); err != nil {
+			t.Fatalf("%s: relayBatch() error = %v", step.name, err)
+		}
+		if !slices.Equal(publisher.published, step.want) {
+			t.Errorf("%s: published %v, want %v", step.name, publisher.published, step.want)
+		}
+	}
+
+	e := outbox.find(failing)
+	if !e.published || e.event.Attempts != 2 || e.lastError != "broker unavailable" {
+		t.Errorf("failing event = %+v, want published after 2 failed attempts", e)
+	}
+}
+
+func TestRelayBatchLimitsBatch(t *testing.T) {
+	outbox := newMemOutbox()
+	for i := 0; i < testOptions.BatchSize+3; i++ {
+		outbox.add(uuid.New())
+	}
+
+	r := newRelay(outbox, &memPublisher{})
+
+	for _, want := range []int{testOptions.BatchSize, 3, 0} {
+		n, err := r.relayBatch(context.Background())
+		if err != nil {
+			t.Fatalf("relayBatch() error = %v", err)
+		}
+		if n != want {
+			t.Errorf("relayBatch() = %d, want %d", n, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package writer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"coin-app/internal/domain/models"
+)
+
+// Publisher writes events as JSON lines. Intended for local use.
+type Publisher struct {
+	mu     sync.Mutex
+	out    io.Writer
+	closer io.Closer
+}
+
+func New(out io.Writer) *Publisher {
+	return &Publisher{out: out}
+}
+
+// NewStdout returns a publisher that prints events to stdout.
+func NewStdout() *Publisher {
+	return New(os.Stdout)
+}
+
+// NewFile returns a publisher that appends events to the file at path.
+func NewFile(path string) (*Publisher, error) {
+	const op = "outbox.publishers.writer.NewFile"
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Publisher{out: f, closer: f}, nil
+}
+
+// Close closes the underlying file, if any.
+func (p *Publisher) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+
+	return p.closer.Close()
+}
+
+func (p *Publisher) Publish(_ context.Context, event models.Event) error {
+	const op = "outbox.publishers.writer.Publish"
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err := p.out.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/services/wallet"
+	"coin-app/internal/storage"
+)
+
+// Policy resolves the limits of a wallet from its tier and overrides and enforces them.
+type Policy struct {
+	log         *slog.Logger
+	storage     PolicyStorage
+	tiers       map[string]models.Limits
+	defaultTier string
+}
+
+type PolicyStorage interface {
+	GetWalletPolicy(ctx context.Context, walletId uuid.UUID) (models.WalletPolicy, error)
+	SaveWalletPolicy(ctx context.Context, p models.WalletPolicy) error
+	WithdrawalTotals(ctx context.Context, walletId uuid.UUID) (daily int, monthly int, err error)
+}
+
+var (
+	ErrUnknownTier     = errors.New("unknown policy tier")
+	ErrInvalidLimits   = errors.New("invalid limits")
+	ErrWalletNotExists = errors.New("wallet not exists")
+)
+
+// New returns a new instance of the Policy service.
+// Wallets without an assigned tier get defaultTier, an unknown defaultTier enforces nothing.
+func New(log *slog.Logger, storage PolicyStorage, tiers map[string]models.Limits, defaultTier string) *Policy {
+	return &Policy{
+		log:         log,
+		storage:     storage,
+		tiers:       tiers,
+		defaultTier: defaultTier,
+	}
+}
+
+// Get returns the tier, the overrides and the effective limits of the wallet.
+func (p *Policy) Get(ctx context.Context, walletId uuid.UUID) (models.WalletPolicy, error) {
+	const op = "Policy.Get"
+
+	wp, err := p.storage.GetWalletPolicy(ctx, walletId)
+	if err != nil {
+		if !errors.Is(err, storage.ErrPolicyNotExists) {
+			return models.WalletPolicy{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		wp = models.WalletPolicy{WalletId: walletId, Tier: p.defaultTier}
+	}
+
+	// A tier removed from the config enforces nothing until the wallet is reassigned
+	wp.Effective = p.tiers[wp.Tier].Override(wp.Overrides)
+
+	return wp, nil
+}
+
+// Set assigns the tier to the wallet and replaces its overrides.
+func (p *Policy) Set(ctx context.Context, walletId uuid.UUID, tier string, overrides models.Limits) (models.WalletPolicy, error) {
+	const op = "Policy.Set"
+
+	if tier == "" {
+		tier = p.defaultTier
+	}
+	if _, ok := p.tiers[tier]; !ok {
+		return models.WalletPolicy{}, fmt.Errorf("%s: %w: %s", op, ErrUnknownTier, tier)
+	}
+	if err := Validate(p.tiers[tier].Override(overrides)); err != nil {
+		return models.WalletPolicy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err := p.storage.SaveWalletPolicy(ctx, models.WalletPolicy{WalletId: walletId, Tier: tier, Overrides: overrides})
+	if err != nil {
+		if errors.Is(err, storage.ErrWalletNotExists) {
+			return models.WalletPolicy{}, fmt.Errorf("%s: %w", op, ErrWalletNotExists)
+		}
+
+		return models.WalletPolicy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p.log.Info("wallet policy changed",
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("walletId", walletId.String()),
+		slog.String("tier", tier),
+	)
+
+	return p.Get(ctx, walletId)
+}
+
+// CheckLimits implements wallet.LimitChecker. wallet carries the balance after the operation.
+func (p *Policy) CheckLimits(ctx context.Context, w models.Wallet, operationType string, amount int) error {
+	const op = "Policy.CheckLimits"
+
+	wp, err := p.Get(ctx, w.Id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	limits := wp.Effective
+
+	if limits.MinAmount != nil && amount < *limits.MinAmount {
+		return &wallet.LimitError{Limit: wallet.LimitMinAmount, Allowed: *limits.MinAmount, Actual: float64(amount)}
+	}
+	if limits.MaxAmount != nil && amount > *limits.MaxAmount {
+		return &wallet.LimitError{Limit: wallet.LimitMaxAmount, Allowed: *limits.MaxAmount, Actual: float64(amount)}
+	}
+
+	switch operationType {
+	case "DEPOSIT":
+		if limits.MaxBalance != nil && w.Balance > float64(*limits.MaxBalance) {
+			return &wallet.LimitError{Limit: wallet.LimitMaxBalance, Allowed: *limits.MaxBalance, Actual: w.Balance}
+		}
+	case "WITHDRAW":
+		if limits.DailyWithdrawal == nil && limits.MonthlyWithdrawal == nil {
+			return nil
+		}
+
+		// The totals include this withdrawal, it is already saved in the transaction
+		daily, monthly, err := p.storage.WithdrawalTotals(ctx, w.Id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if limits.DailyWithdrawal != nil && daily > *limits.DailyWithdrawal {
+			return &wallet.LimitError{Limit: wallet.LimitDailyWithdrawal, Allowed: *limits.DailyWithdrawal, Actual: float64(daily)}
+		}
+		if limits.MonthlyWithdrawal != nil && monthly > *limits.MonthlyWithdrawal {
+			return &wallet.LimitError{Limit: wallet.LimitMonthlyWithdrawal, Allowed: *limits.MonthlyWithdrawal, Actual: float64(monthly)}
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that limits are not negative and the amount bounds are ordered.
+func Validate(l models.Limits) error {
+	for name, v := range map[string]*int{
+		wallet.LimitMaxBalance:        l.MaxBalance,
+		wallet.LimitMinAmount:         l.MinAmount,
+		wallet.LimitMaxAmount:         l.MaxAmount,
+		wallet.LimitDailyWithdrawal:   l.DailyWithdrawal,
+		wallet.LimitMonthlyWithdrawal: l.MonthlyWithdrawal,
+	} {
+		if v != nil && *v < 0 {
+			return fmt.Errorf("%w: %s must not be negative", ErrInvalidLimits, name)
+		}
+	}
+
+	if l.MinAmount != nil && l.MaxAmount != nil && *l.MinAmount > *l.MaxAmount {
+		return fmt.Errorf("%w: min_amount exceeds max_amount", ErrInvalidLimits)
+	}
+
+	return nil
+}
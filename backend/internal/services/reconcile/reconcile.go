@@ -0,0 +1,192 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/logger/sl"
+)
+
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusMismatch Status = "mismatch"
+	StatusFixed    Status = "fixed"
+	// StatusUnverifiable means the opening balance of the wallet is unknown.
+	StatusUnverifiable Status = "unverifiable"
+)
+
+type LedgerStorage interface {
+	LedgerBalances(ctx context.Context, afterId uuid.UUID, limit int) ([]models.LedgerBalance, error)
+	SaveLedgerAdjustment(ctx context.Context, transactionId uuid.UUID, walletId uuid.UUID, reason string) (*models.Adjustment, error)
+}
+
+type Options struct {
+	BatchSize int
+	// Pause between batches to limit the load on a live database.
+	Pause time.Duration
+	// Fix posts adjusting entries for mismatched wallets.
+	Fix    bool
+	Reason string
+}
+
+type Row struct {
+	models.LedgerBalance
+	Status     Status             `json:"status"`
+	Adjustment *models.Adjustment `json:"adjustment,omitempty"`
+}
+
+type Summary struct {
+	StartedAt    time.Time `json:"startedAt"`
+	FinishedAt   time.Time `json:"finishedAt"`
+	Checked      int       `json:"checked"`
+	Mismatched   int       `json:"mismatched"`
+	Fixed        int       `json:"fixed"`
+	Unverifiable int       `json:"unverifiable"`
+}
+
+type Reconciler struct {
+	log     *slog.Logger
+	storage LedgerStorage
+}
+
+var ErrNoReason = errors.New("fix mode requires a reason")
+
+// New returns a new instance of the Reconciler.
+func New(log *slog.Logger, storage LedgerStorage) *Reconciler {
+	return &Reconciler{
+		log:     log,
+		storage: storage,
+	}
+}
+
+// Run checks every wallet in batches ordered by id and passes each row to emit.
+func (r *Reconciler) Run(ctx context.Context, opts Options, emit func(Row) error) (Summary, error) {
+	const op = "Reconciler.Run"
+
+	log := r.log.With(
+		slog.String("op", op),
+		slog.Bool("fix", opts.Fix),
+	)
+
+	if opts.Fix && opts.Reason == "" {
+		return Summary{}, fmt.Errorf("%s: %w", op, ErrNoReason)
+	}
+
+	summary := Summary{StartedAt: time.Now().UTC()}
+
+	var after uuid.UUID
+	for {
+		batch, err := r.storage.LedgerBalances(ctx, after, opts.BatchSize)
+		if err != nil {
+			return summary, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, balance := range batch {
+			row, err := r.check(ctx, balance, opts)
+			if err != nil {
+				log.Error("failed to adjust wallet", slog.String("walletId", balance.WalletId.String()), sl.Err(err))
+
+				return summary, fmt.Errorf("%s: %w", op, err)
+			}
+
+			summary.Checked++
+			switch row.Status {
+			case StatusMismatch:
+				summary.Mismatched++
+			case StatusFixed:
+				summary.Mismatched++
+				summary.Fixed++
+			case StatusUnverifiable:
+				summary.Unverifiable++
+			}
+
+			if err := emit(row); err != nil {
+				return summary, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		log.Debug("batch reconciled", slog.Int("size", len(batch)), slog.Int("checked", summary.Checked))
+
+		if len(batch) < opts.BatchSize {
+			break
+		}
+		after = batch[len(batch)-1].WalletId
+
+		if opts.Pause > 0 {
+			select {
+			case <-ctx.Done():
+				return summary, fmt.Errorf("%s: %w", op, ctx.Err())
+			case <-time.After(opts.Pause):
+			}
+		}
+	}
+
+	summary.FinishedAt = time.Now().UTC()
+
+	log.Info("reconciliation finished",
+		slog.Int("checked", summary.Checked),
+		slog.Int("mismatched", summary.Mismatched),
+		slog.Int("fixed", summary.Fixed),
+		slog.Int("unverifiable", summary.Unverifiable),
+	)
+
+	return summary, nil
+}
+
+func (r *Reconciler) check(ctx context.Context, balance models.LedgerBalance, opts Options) (Row, error) {
+	row := Row{LedgerBalance: balance, Status: StatusOK}
+
+	switch {
+	case balance.Difference == nil:
+		row.Status = StatusUnverifiable
+	case !isZero(*balance.Difference):
+		row.Status = StatusMismatch
+	}
+
+	if row.Status != StatusMismatch || !opts.Fix {
+		return row, nil
+	}
+
+	// The balance is what clients were told, so the ledger is brought in line with it.
+	adjustment, err := r.storage.SaveLedgerAdjustment(ctx, uuid.New(), balance.WalletId, opts.Reason)
+	if err != nil {
+		return Row{}, err
+	}
+
+	// nil means the wallet was reconciled concurrently, e.g. by another run.
+	if adjustment == nil {
+		row.Status = StatusOK
+	} else {
+		row.Status = StatusFixed
+		row.Adjustment = adjustment
+
+		r.log.Info("ledger adjusted",
+			slog.String("walletId", balance.WalletId.String()),
+			slog.String("transactionId", adjustment.TransactionId.String()),
+			slog.String("amount", adjustment.Amount),
+		)
+	}
+
+	return row, nil
+}
+
+// isZero reports whether decimal string d is zero, e.g. "0", "0.00" or "-0.00".
+func isZero(d string) bool {
+	for _, c := range d {
+		switch c {
+		case '0', '.', '-', '+':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
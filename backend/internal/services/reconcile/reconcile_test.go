@@ -0,0 +1,247 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+)
+
+// memLedger pages through balances ordered by wallet id and adjusts the wallets told to.
+type memLedger struct {
+	balances []models.LedgerBalance
+	// concurrent are the wallets some other run has already adjusted.
+	concurrent map[uuid.UUID]bool
+	adjustErr  error
+	adjusted   []uuid.UUID
+	pages      int
+}
+
+func (m *memLedger) LedgerBalances(_ context.Context, afterId uuid.UUID, limit int) ([]models.LedgerBalance, error) {
+	m.pages++
+
+	var page []models.LedgerBalance
+	for _, b := range m.balances {
+		if afterId.String() < b.WalletId.String() && len(page) < limit {
+			page = append(page, b)
+		}
+	}
+
+	return page, nil
+}
+
+func (m *memLedger) SaveLedgerAdjustment(_ context.Context, transactionId uuid.UUID, walletId uuid.UUID, reason string) (*models.Adjustment, error) {
+	if m.adjustErr != nil {
+		return nil, m.adjustErr
+	}
+	if m.concurrent[walletId] {
+		return nil, nil
+	}
+	m.adjusted = append(m.adjusted, walletId)
+
+	for _, b := range m.balances {
+		if b.WalletId == walletId {
+			return &models.Adjustment{TransactionId: transactionId, WalletId: walletId, Amount: *b.Difference, Reason: reason}, nil
+		}
+	}
+
+	return nil, errors.New("wallet not exists")
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+// ids returns n wallet ids in ascending order.
+func ids(n int) []uuid.UUID {
+	ids := make([]uuid.UUID, n)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+	slices.SortFunc(ids, func(a, b uuid.UUID) int {
+		return slices.Compare(a[:], b[:])
+	})
+
+	return ids
+}
+
+func newReconciler(ledger LedgerStorage) *Reconciler {
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), ledger)
+}
+
+func TestRun(t *testing.T) {
+	w := ids(5)
+	balances := []models.LedgerBalance{
+		{WalletId: w[0], OpeningBalance: ptr("0.00"), Balance: "100.00", LedgerSum: "100.00", Expected: ptr("100.00"), Difference: ptr("0.00")},
+		{WalletId: w[1], OpeningBalance: ptr("0.00"), Balance: "150.00", LedgerSum: "100.00", Expected: ptr("100.00"), Difference: ptr("50.00")},
+		{WalletId: w[2], Balance: "70.00", LedgerSum: "20.00"},
+		{WalletId: w[3], OpeningBalance: ptr("10.00"), Balance: "5.00", LedgerSum: "0.00", Expected: ptr("10.00"), Difference: ptr("-5.00")},
+		{WalletId: w[4], OpeningBalance: ptr("0.00"), Balance: "0.00", LedgerSum: "0.00", Expected: ptr("0.00"), Difference: ptr("-0.00")},
+	}
+
+	tests := []struct {
+		name       string
+		opts       Options
+		concurrent map[uuid.UUID]bool
+		// wantStatuses are the statuses of the wallets in order
+		wantStatuses []Status
+		wantSummary  Summary
+		wantAdjusted []uuid.UUID
+	}{
+		{
+			name:         "report drift",
+			opts:         Options{BatchSize: 2},
+			wantStatuses: []Status{StatusOK, StatusMismatch, StatusUnverifiable, StatusMismatch, StatusOK},
+			wantSummary:  Summary{Checked: 5, Mismatched: 2, Unverifiable: 1},
+		},
+		{
+			name:         "fix drift",
+			opts:         Options{BatchSize: 2, Fix: true, Reason: "INC-42"},
+			wantStatuses: []Status{StatusOK, StatusFixed, StatusUnverifiable, StatusFixed, StatusOK},
+			wantSummary:  Summary{Checked: 5, Mismatched: 2, Fixed: 2, Unverifiable: 1},
+			wantAdjusted: []uuid.UUID{w[1], w[3]},
+		},
+		{
+			name:         "wallet fixed by another run",
+			opts:         Options{BatchSize: 10, Fix: true, Reason: "INC-42"},
+			concurrent:   map[uuid.UUID]bool{w[1]: true},
+			wantStatuses: []Status{StatusOK, StatusOK, StatusUnverifiable, StatusFixed, StatusOK},
+			wantSummary:  Summary{Checked: 5, Mismatched: 1, Fixed: 1, Unverifiable: 1},
+			wantAdjusted: []uuid.UUID{w[3]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := &memLedger{balances: balances, concurrent: tt.concurrent}
+			r := newReconciler(ledger)
+
+			var rows []Row
+			summary, err := r.Run(context.Background(), tt.opts, func(row Row) error {
+				rows = append(rows, row)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			var statuses []Status
+			for i, row := range rows {
+				statuses = append(statuses, row.Status)
+				if row.WalletId != w[i] {
+					t.Errorf("row %d is of wallet %s, want %s", i, row.WalletId, w[i])
+				}
+				if (row.Status == StatusFixed) != (row.Adjustment != nil) {
+					t.Errorf("row %d with status %s has adjustment %+v", i, row.Status, row.Adjustment)
+				}
+				if row.Adjustment != nil && (row.Adjustment.Amount != *row.Difference || row.Adjustment.Reason != tt.opts.Reason) {
+					t.Errorf("row %d adjustment = %+v, want %s for %q", i, row.Adjustment, *row.Difference, tt.opts.Reason)
+				}
+			}
+			if !slices.Equal(statuses, tt.wantStatuses) {
+				t.Errorf("statuses = %v, want %v", statuses, tt.wantStatuses)
+			}
+
+			summary.StartedAt, summary.FinishedAt = tt.wantSummary.StartedAt, tt.wantSummary.FinishedAt
+			if summary != tt.wantSummary {
+				t.Errorf("Run() = %+v, want %+v", summary, tt.wantSummary)
+			}
+			if !slices.Equal(ledger.adjusted, tt.wantAdjusted) {
+				t.Errorf("adjusted = %v, want %v", ledger.adjusted, tt.wantAdjusted)
+			}
+		})
+	}
+}
+
+func TestRunPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		wallets   int
+		batchSize int
+		wantPages int
+	}{
+		{name: "no wallets", wallets: 0, batchSize: 2, wantPages: 1},
+		{name: "partial last page", wallets: 5, batchSize: 2, wantPages: 3},
+		{name: "full last page", wallets: 4, batchSize: 2, wantPages: 3},
+		{name: "single page", wallets: 4, batchSize: 10, wantPages: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := &memLedger{}
+			for _, id := range ids(tt.wallets) {
+				ledger.balances = append(ledger.balances, models.LedgerBalance{WalletId: id, Difference: ptr("0")})
+			}
+
+			var checked []uuid.UUID
+			summary, err := newReconciler(ledger).Run(context.Background(), Options{BatchSize: tt.batchSize}, func(row Row) error {
+				checked = append(checked, row.WalletId)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if summary.Checked != tt.wallets || len(checked) != tt.wallets {
+				t.Errorf("checked %d wallets, want %d", summary.Checked, tt.wallets)
+			}
+			for i, b := range ledger.balances {
+				if checked[i] != b.WalletId {
+					t.Fatalf("wallet %d is %s, want %s", i, checked[i], b.WalletId)
+				}
+			}
+			if ledger.pages != tt.wantPages {
+				t.Errorf("pages read = %d, want %d", ledger.pages, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestRunStops(t *testing.T) {
+	w := ids(2)
+	balances := []models.LedgerBalance{
+		{WalletId: w[0], OpeningBalance: ptr("0.00"), Difference: ptr("1.00")},
+		{WalletId: w[1], OpeningBalance: ptr("0.00"), Difference: ptr("0.00")},
+	}
+	errAdjust, errEmit := errors.New("serialization failure"), errors.New("broken pipe")
+
+	tests := []struct {
+		name      string
+		opts      Options
+		adjustErr error
+		emitErr   error
+		wantErr   error
+		wantRows  int
+	}{
+		{name: "fix without a reason", opts: Options{BatchSize: 10, Fix: true}, wantErr: ErrNoReason},
+		{name: "adjustment fails", opts: Options{BatchSize: 10, Fix: true, Reason: "INC-42"}, adjustErr: errAdjust, wantErr: errAdjust},
+		{name: "emit fails", opts: Options{BatchSize: 10}, emitErr: errEmit, wantErr: errEmit, wantRows: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := &memLedger{balances: balances, adjustErr: tt.adjustErr}
+
+			rows := 0
+			_, err := newReconciler(ledger).Run(context.Background(), tt.opts, func(Row) error {
+				rows++
+				return tt.emitErr
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			if rows != tt.wantRows {
+				t.Errorf("rows emitted = %d, want %d", rows, tt.wantRows)
+			}
+			if len(ledger.adjusted) != 0 {
+				t.Errorf("adjusted = %v, want none", ledger.adjusted)
+			}
+		})
+	}
+}
@@ -0,0 +1,864 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	resp "coin-app/internal/lib/api/response"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/lib/backoff"
+	"coin-app/internal/lib/cron"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/services/apikey"
+	auditService "coin-app/internal/services/audit"
+	"coin-app/internal/services/wallet"
+	"coin-app/internal/storage"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Scheduler keeps future-dated and recurring operations and runs them when they are due.
+// Every run posts ordinary transactions through the wallet service.
+type Scheduler struct {
+	log       *slog.Logger
+	storage   ScheduleStorage
+	txManager TxManager
+	wallets   Wallets
+	recorder  Recorder
+	keys      APIKeys
+	opts      Options
+}
+
+type ScheduleStorage interface {
+	SaveSchedule(ctx context.Context, sched models.ScheduledOperation) error
+	GetSchedule(ctx context.Context, scheduleId uuid.UUID) (models.ScheduledOperation, error)
+	LockSchedule(ctx context.Context, scheduleId uuid.UUID) (models.ScheduledOperation, error)
+	LockDueSchedule(ctx context.Context) (models.ScheduledOperation, error)
+	Schedules(ctx context.Context, walletId uuid.UUID, limit int) ([]models.ScheduledOperation, error)
+	UpdateSchedule(ctx context.Context, sched models.ScheduledOperation) (models.ScheduledOperation, error)
+	SaveScheduledRun(ctx context.Context, run models.ScheduledRun) error
+	ScheduledRuns(ctx context.Context, scheduleId uuid.UUID, limit int) ([]models.ScheduledRun, error)
+}
+
+// TxManager runs fn in a single database transaction.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Wallets checks access to wallets and posts the operations, see wallet.Wallet.
+type Wallets interface {
+	GetWallet(ctx context.Context, walletId uuid.UUID) (models.Wallet, error)
+	SaveTransaction(
+		ctx context.Context,
+		walletId uuid.UUID,
+		operationType string,
+		amount int,
+		details models.TransactionDetails,
+	) (uuid.UUID, error)
+}
+
+// Recorder appends a record to the audit log, see audit.Audit.
+type Recorder interface {
+	Record(ctx context.Context, rec models.AuditRecord) (models.AuditRecord, error)
+}
+
+// APIKeys resolves an API key to its caller as it is now, see apikey.APIKey.Principal.
+// A revoked or expired key is an error.
+type APIKeys interface {
+	Principal(ctx context.Context, keyId uuid.UUID) (auth.Principal, error)
+}
+
+type Options struct {
+	PollInterval time.Duration
+	// BatchSize is how many due operations one poll runs at most.
+	BatchSize int
+	// MaxAttempts of an occurrence. Then a one-off operation fails
+	// and a recurring one goes on with its next occurrence.
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// MinEvery is the shortest interval of a recurring operation.
+	MinEvery time.Duration
+}
+
+// Spec describes a new scheduled operation. A one-off operation runs once at RunAt.
+// A recurring one has either Cron or Every, RunAt is then optional and delays its start.
+type Spec struct {
+	OperationType  string     `json:"operationType"`
+	WalletId       uuid.UUID  `json:"walletId"`
+	TargetWalletId *uuid.UUID `json:"targetWalletId,omitempty"`
+	Amount         int        `json:"amount"`
+	Description    string     `json:"description,omitempty"`
+	RunAt          *time.Time `json:"runAt,omitempty"`
+	Cron           string     `json:"cron,omitempty"`
+	Every          string     `json:"every,omitempty"`
+}
+
+// Patch changes an operation that has not finished, nil fields are kept.
+// Status may only switch between ACTIVE and PAUSED.
+type Patch struct {
+	Amount      *int                   `json:"amount,omitempty"`
+	Description *string                `json:"description,omitempty"`
+	Status      *models.ScheduleStatus `json:"status,omitempty"`
+}
+
+var (
+	// ErrInvalidSchedule comes with the reason, e.g. an unknown operation type or a malformed cron expression.
+	ErrInvalidSchedule   = errors.New("invalid scheduled operation")
+	ErrScheduleNotExists = errors.New("scheduled operation not exists")
+	// ErrFinished means the operation is completed, failed or canceled and cannot be changed.
+	ErrFinished        = errors.New("scheduled operation is finished")
+	ErrWalletNotExists = errors.New("wallet not exists")
+	// ErrForbidden means the caller may not act on the wallet or lacks the scope for the operation.
+	ErrForbidden = errors.New("caller may not schedule operations on the wallet")
+	// ErrConflict means concurrent operations kept conflicting and the caller may try again.
+	ErrConflict = errors.New("scheduled operation conflicted with concurrent updates")
+	// ErrUnauthenticated means ctx carries no caller, see auth.WithPrincipal.
+	ErrUnauthenticated = errors.New("caller is not authenticated")
+	// ErrCreatorRevoked means the API key that created the operation is revoked or expired.
+	ErrCreatorRevoked = errors.New("creator of scheduled operation is revoked")
+)
+
+// New returns a new instance of the Scheduler service.
+func New(
+	log *slog.Logger,
+	storage ScheduleStorage,
+	txManager TxManager,
+	wallets Wallets,
+	recorder Recorder,
+	keys APIKeys,
+	opts Options,
+) *Scheduler {
+	return &Scheduler{
+		log:       log,
+		storage:   storage,
+		txManager: txManager,
+		wallets:   wallets,
+		recorder:  recorder,
+		keys:      keys,
+		opts:      opts,
+	}
+}
+
+// Create schedules an operation on a wallet the caller may access with the scope of the operation.
+// A transfer needs the withdraw scope on its source wallet, the target may belong to anyone.
+func (s *Scheduler) Create(ctx context.Context, spec Spec) (models.ScheduledOperation, error) {
+	const op = "Scheduler.Create"
+
+	log := s.log.With(
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("walletId", spec.WalletId.String()),
+		slog.String("operationType", spec.OperationType),
+		slog.Int("amount", spec.Amount),
+	)
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+
+	dueAt, err := s.validate(&spec, time.Now())
+	if err != nil {
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	w, err := s.checkAccess(ctx, principal, spec.WalletId, spec.OperationType)
+	if err != nil {
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// A user's runs are posted as the user alone, see creator: the admin scope of the token
+	// is not on record, so a schedule on someone else's wallet could never run.
+	if principal.UserId != uuid.Nil && !principal.Service && w.UserId != principal.UserId {
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w: users schedule operations on their own wallets only", op, ErrForbidden)
+	}
+
+	sched := models.ScheduledOperation{
+		Id:             uuid.New(),
+		OperationType:  spec.OperationType,
+		WalletId:       spec.WalletId,
+		TargetWalletId: spec.TargetWalletId,
+		Amount:         spec.Amount,
+		Description:    spec.Description,
+		Cron:           spec.Cron,
+		Every:          spec.Every,
+		Status:         models.ScheduleActive,
+		DueAt:          &dueAt,
+		CreatedBy:      principal.Subject,
+	}
+
+	if err := s.storage.SaveSchedule(ctx, sched); err != nil {
+		if errors.Is(err, storage.ErrWalletNotExists) {
+			return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, ErrWalletNotExists)
+		}
+		log.Error("failed to save scheduled operation", sl.Err(err))
+
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Read it back for the timestamps set by the database
+	saved, err := s.storage.GetSchedule(ctx, sched.Id)
+	if err != nil {
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("operation scheduled",
+		slog.String("scheduleId", sched.Id.String()),
+		slog.String("createdBy", principal.Subject),
+		slog.Time("dueAt", dueAt),
+	)
+
+	return saved, nil
+}
+
+// Get returns the operation if the caller may access its wallet.
+func (s *Scheduler) Get(ctx context.Context, scheduleId uuid.UUID) (models.ScheduledOperation, error) {
+	const op = "Scheduler.Get"
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+
+	sched, err := s.storage.GetSchedule(ctx, scheduleId)
+	if err != nil {
+		if errors.Is(err, storage.ErrScheduleNotExists) {
+			return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, ErrScheduleNotExists)
+		}
+
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := s.checkAccess(ctx, principal, sched.WalletId, ""); err != nil {
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sched, nil
+}
+
+// List returns the operations scheduled on the wallet, newest first.
+func (s *Scheduler) List(ctx context.Context, walletId uuid.UUID, limit int) ([]models.ScheduledOperation, error) {
+	const op = "Scheduler.List"
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+	if _, err := s.checkAccess(ctx, principal, walletId, ""); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	schedules, err := s.storage.Schedules(ctx, walletId, min(limit, MaxLimit))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return schedules, nil
+}
+
+// Runs returns the attempts to run the operation, newest first.
+func (s *Scheduler) Runs(ctx context.Context, scheduleId uuid.UUID, limit int) ([]models.ScheduledRun, error) {
+	const op = "Scheduler.Runs"
+
+	if _, err := s.Get(ctx, scheduleId); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	runs, err := s.storage.ScheduledRuns(ctx, scheduleId, min(limit, MaxLimit))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return runs, nil
+}
+
+// Update changes the amount or the description, pauses or resumes the operation.
+// A run in progress is waited for. A resumed operation runs its missed occurrence once.
+func (s *Scheduler) Update(ctx context.Context, scheduleId uuid.UUID, patch Patch) (models.ScheduledOperation, error) {
+	const op = "Scheduler.Update"
+
+	if patch.Amount != nil && *patch.Amount <= 0 {
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, fmt.Errorf("%w: amount must be positive", ErrInvalidSchedule))
+	}
+	if patch.Description != nil {
+		description := strings.TrimSpace(*patch.Description)
+		if utf8.RuneCountInString(description) > wallet.MaxDescriptionLength {
+			return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, fmt.Errorf("%w: description is longer than %d characters", ErrInvalidSchedule, wallet.MaxDescriptionLength))
+		}
+		patch.Description = &description
+	}
+	if patch.Status != nil && *patch.Status != models.ScheduleActive && *patch.Status != models.SchedulePaused {
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, fmt.Errorf("%w: status must be ACTIVE or PAUSED", ErrInvalidSchedule))
+	}
+
+	return s.change(ctx, op, scheduleId, func(sched *models.ScheduledOperation) {
+		if patch.Amount != nil {
+			sched.Amount = *patch.Amount
+		}
+		if patch.Description != nil {
+			sched.Description = *patch.Description
+		}
+		if patch.Status != nil {
+			sched.Status = *patch.Status
+		}
+	})
+}
+
+// Cancel stops the operation for good, a run in progress is waited for.
+func (s *Scheduler) Cancel(ctx context.Context, scheduleId uuid.UUID) (models.ScheduledOperation, error) {
+	const op = "Scheduler.Cancel"
+
+	return s.change(ctx, op, scheduleId, func(sched *models.ScheduledOperation) {
+		sched.Status = models.ScheduleCanceled
+		sched.NextRunAt = nil
+	})
+}
+
+func (s *Scheduler) change(
+	ctx context.Context,
+	op string,
+	scheduleId uuid.UUID,
+	apply func(sched *models.ScheduledOperation),
+) (models.ScheduledOperation, error) {
+	log := s.log.With(
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("scheduleId", scheduleId.String()),
+	)
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+
+	var changed models.ScheduledOperation
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		sched, err := s.storage.LockSchedule(ctx, scheduleId)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.checkAccess(ctx, principal, sched.WalletId, sched.OperationType); err != nil {
+			return err
+		}
+		if sched.Status != models.ScheduleActive && sched.Status != models.SchedulePaused {
+			return ErrFinished
+		}
+
+		apply(&sched)
+
+		changed, err = s.storage.UpdateSchedule(ctx, sched)
+
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrScheduleNotExists) {
+			return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, ErrScheduleNotExists)
+		}
+		if errors.Is(err, ErrFinished) || errors.Is(err, ErrForbidden) ||
+			errors.Is(err, ErrWalletNotExists) || errors.Is(err, ErrUnauthenticated) {
+			return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if errors.Is(err, storage.ErrConflict) {
+			log.Warn("scheduled operation change conflicts after retries", sl.Err(err))
+
+			return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, ErrConflict)
+		}
+		log.Error("failed to change scheduled operation", sl.Err(err))
+
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("scheduled operation changed",
+		slog.String("status", string(changed.Status)),
+		slog.String("caller", principal.Subject),
+	)
+
+	return changed, nil
+}
+
+// Run runs due operations until ctx is cancelled.
+// Replicas may run it side by side, every operation is claimed by one of them.
+func (s *Scheduler) Run(ctx context.Context) {
+	const op = "Scheduler.Run"
+
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("scheduler started")
+
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.RunDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error("failed to run scheduled operations", sl.Err(err))
+		}
+
+		if err == nil && n == s.opts.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("scheduler stopped")
+
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue runs up to a batch of due operations and returns how many it has claimed.
+// Each one runs in its own transaction, a failed run is recorded and retried later.
+func (s *Scheduler) RunDue(ctx context.Context) (int, error) {
+	const op = "Scheduler.RunDue"
+
+	for n := 0; n < s.opts.BatchSize; n++ {
+		ok, err := s.runNext(ctx)
+		if err != nil {
+			return n, fmt.Errorf("%s: %w", op, err)
+		}
+		if !ok {
+			return n, nil
+		}
+	}
+
+	return s.opts.BatchSize, nil
+}
+
+// runNext claims the earliest due operation, posts it and moves it on to its next occurrence
+// in one transaction, so a crash in between leaves the occurrence due.
+func (s *Scheduler) runNext(ctx context.Context) (bool, error) {
+	const op = "Scheduler.runNext"
+
+	var sched models.ScheduledOperation
+	var run models.ScheduledRun
+	// audited is set once the start of the run is recorded, a retried transaction records it again
+	var audited bool
+	err := s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+
+		sched, err = s.storage.LockDueSchedule(ctx)
+		if err != nil {
+			return err
+		}
+
+		run = models.ScheduledRun{
+			Id:         uuid.New(),
+			ScheduleId: sched.Id,
+			DueAt:      *sched.DueAt,
+			Attempt:    sched.Attempts + 1,
+			Status:     models.RunSucceeded,
+		}
+
+		run.TransactionIds, err = s.post(ctx, sched)
+		if err != nil {
+			return err
+		}
+
+		// Runs never pass the audit middleware. Like a request, a run is recorded before it commits
+		// and is not posted if the record cannot be written, its outcome is recorded after
+		if err := s.audit(ctx, sched, run, 0, models.AuditStarted); err != nil {
+			return err
+		}
+		audited = true
+
+		sched.Runs++
+		sched.Attempts = 0
+		sched.LastError = ""
+		s.advance(&sched, time.Now())
+
+		if _, err := s.storage.UpdateSchedule(ctx, sched); err != nil {
+			return err
+		}
+
+		return s.storage.SaveScheduledRun(ctx, run)
+	})
+	if errors.Is(err, storage.ErrScheduleNotExists) {
+		return false, nil
+	}
+	if err != nil {
+		if sched.Id == uuid.Nil || ctx.Err() != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		if err := s.fail(ctx, sched, run, err); err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		if audited {
+			s.auditOutcome(ctx, sched, run, http.StatusInternalServerError, resp.StatusError)
+		}
+
+		return true, nil
+	}
+	s.auditOutcome(ctx, sched, run, http.StatusOK, resp.StatusOK)
+
+	s.log.Info("scheduled operation run",
+		slog.String("op", op),
+		slog.String("scheduleId", sched.Id.String()),
+		slog.Time("dueAt", run.DueAt),
+		slog.String("status", string(sched.Status)),
+	)
+
+	return true, nil
+}
+
+// post runs the occurrence and returns the IDs of the posted transactions.
+// The operation on the wallet of the schedule is posted on behalf of its creator as they are now,
+// so a revoked key or a wallet the creator no longer may act on fails the run. Only the credit
+// of a transfer, which may go to anyone, is posted on behalf of the scheduler.
+// Within the transaction of the claim, a transfer withdraws and deposits all or nothing.
+func (s *Scheduler) post(ctx context.Context, sched models.ScheduledOperation) ([]uuid.UUID, error) {
+	creator, err := s.creator(ctx, sched)
+	if err != nil {
+		return nil, err
+	}
+	scheduler := auth.Principal{
+		Subject: "scheduler:" + sched.Id.String(),
+		Scopes:  []string{auth.ScopeDeposit},
+		Service: true,
+	}
+
+	// The reference of the occurrence keeps it from being posted twice
+	details := models.TransactionDetails{
+		Description:       sched.Description,
+		Metadata:          []byte(fmt.Sprintf(`{"scheduleId":%q}`, sched.Id)),
+		ExternalReference: fmt.Sprintf("schedule:%s:%d", sched.Id, sched.DueAt.Unix()),
+	}
+
+	type posting struct {
+		walletId      uuid.UUID
+		operationType string
+		caller        auth.Principal
+	}
+
+	var postings []posting
+	switch sched.OperationType {
+	case models.ScheduleDeposit:
+		postings = []posting{{sched.WalletId, "DEPOSIT", creator}}
+	case models.ScheduleWithdraw:
+		postings = []posting{{sched.WalletId, "WITHDRAW", creator}}
+	case models.ScheduleTransfer:
+		postings = []posting{{sched.WalletId, "WITHDRAW", creator}, {*sched.TargetWalletId, "DEPOSIT", scheduler}}
+	}
+
+	ids := make([]uuid.UUID, 0, len(postings))
+	for _, p := range postings {
+		id, err := s.wallets.SaveTransaction(auth.WithPrincipal(ctx, p.caller), p.walletId, p.operationType, sched.Amount, details)
+		if err != nil && !errors.Is(err, wallet.ErrDuplicateReference) {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// creator returns the caller who created sched as they are at run time.
+// A user keeps the wallets they own and the scopes users hold on them, the wallet service
+// checks the ownership again. An API key keeps the scopes it has now.
+// Callers that are configuration rather than records, client certificates and the anonymous
+// caller, keep the scope of the operation.
+func (s *Scheduler) creator(ctx context.Context, sched models.ScheduledOperation) (auth.Principal, error) {
+	if userId, err := uuid.Parse(sched.CreatedBy); err == nil {
+		return auth.Principal{Subject: sched.CreatedBy, UserId: userId, Operator: sched.CreatedBy}, nil
+	}
+
+	if id, ok := strings.CutPrefix(sched.CreatedBy, "apikey:"); ok {
+		keyId, err := uuid.Parse(id)
+		if err != nil {
+			return auth.Principal{}, fmt.Errorf("%w: %s", ErrCreatorRevoked, sched.CreatedBy)
+		}
+
+		principal, err := s.keys.Principal(ctx, keyId)
+		if err != nil {
+			if errors.Is(err, apikey.ErrKeyRevoked) || errors.Is(err, apikey.ErrKeyNotExists) {
+				return auth.Principal{}, fmt.Errorf("%w: %s", ErrCreatorRevoked, sched.CreatedBy)
+			}
+
+			return auth.Principal{}, err
+		}
+
+		return principal, nil
+	}
+
+	scope := auth.ScopeWithdraw
+	if sched.OperationType == models.ScheduleDeposit {
+		scope = auth.ScopeDeposit
+	}
+
+	return auth.Principal{Subject: sched.CreatedBy, Scopes: []string{scope}, Service: true}, nil
+}
+
+// audit records a run in the audit log on behalf of the scheduler.
+// The record commits on its own, see audit.Audit.Record.
+func (s *Scheduler) audit(ctx context.Context, sched models.ScheduledOperation, run models.ScheduledRun, status int, outcome string) error {
+	payload, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.recorder.Record(ctx, models.AuditRecord{
+		Actor:         "scheduler:" + sched.Id.String(),
+		RequestId:     run.Id.String(),
+		Action:        "RUN " + sched.OperationType,
+		Path:          "/schedules/" + sched.Id.String(),
+		Status:        status,
+		Outcome:       outcome,
+		PayloadDigest: auditService.Digest(payload),
+	})
+
+	return err
+}
+
+// auditOutcome records how a run ended. The run is already committed or failed,
+// so a lost record is only logged, the one of its start with the same request id remains.
+func (s *Scheduler) auditOutcome(ctx context.Context, sched models.ScheduledOperation, run models.ScheduledRun, status int, outcome string) {
+	if err := s.audit(context.WithoutCancel(ctx), sched, run, status, outcome); err != nil {
+		s.log.Error("outcome of scheduled run was not audited",
+			slog.String("scheduleId", sched.Id.String()),
+			slog.String("runId", run.Id.String()),
+			sl.Err(err),
+		)
+	}
+}
+
+// fail records the failed run in a new transaction and schedules a retry with backoff.
+// Permanent errors and exhausted attempts fail a one-off operation,
+// a recurring one skips to its next occurrence unless the error is permanent.
+func (s *Scheduler) fail(ctx context.Context, claimed models.ScheduledOperation, run models.ScheduledRun, cause error) error {
+	const op = "Scheduler.fail"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("scheduleId", claimed.Id.String()),
+		slog.Time("dueAt", run.DueAt),
+		slog.Int("attempt", run.Attempt),
+		sl.Err(cause),
+	)
+
+	return s.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		sched, err := s.storage.LockSchedule(ctx, claimed.Id)
+		if err != nil {
+			return err
+		}
+
+		// Changed since the claim was rolled back, e.g. paused or run by another replica
+		if sched.Status != models.ScheduleActive || !sameTime(sched.NextRunAt, claimed.NextRunAt) {
+			return nil
+		}
+
+		now := time.Now()
+		sched.Attempts = run.Attempt
+		sched.LastError = cause.Error()
+
+		switch {
+		case permanent(cause):
+			log.Error("scheduled operation failed permanently")
+
+			sched.Status = models.ScheduleFailed
+			sched.NextRunAt = nil
+		case sched.Attempts < s.opts.MaxAttempts:
+			retryAt := now.Add(backoff.Jittered(sched.Attempts-1, s.opts.MinBackoff, s.opts.MaxBackoff))
+			log.Warn("scheduled operation run failed", slog.Time("retryAt", retryAt))
+
+			sched.NextRunAt = &retryAt
+		case recurring(sched):
+			log.Error("scheduled occurrence skipped after max attempts")
+
+			sched.Attempts = 0
+			s.advance(&sched, now)
+		default:
+			log.Error("scheduled operation failed after max attempts")
+
+			sched.Status = models.ScheduleFailed
+			sched.NextRunAt = nil
+		}
+
+		if _, err := s.storage.UpdateSchedule(ctx, sched); err != nil {
+			return err
+		}
+
+		run.Status = models.RunFailed
+		run.TransactionIds = nil
+		run.Error = cause.Error()
+
+		return s.storage.SaveScheduledRun(ctx, run)
+	})
+}
+
+// advance moves the operation to its next occurrence after now, missed ones are skipped.
+// A one-off operation, or a cron expression that never fires again, completes.
+func (s *Scheduler) advance(sched *models.ScheduledOperation, now time.Time) {
+	var next time.Time
+	switch {
+	case sched.Cron != "":
+		if expr, err := cron.Parse(sched.Cron); err == nil {
+			next = expr.Next(sched.DueAt.UTC())
+			if !next.IsZero() && !next.After(now) {
+				next = expr.Next(now.UTC())
+			}
+		}
+	case sched.Every != "":
+		if every, err := time.ParseDuration(sched.Every); err == nil && every > 0 {
+			next = sched.DueAt.Add(every)
+			if !next.After(now) {
+				next = next.Add(every * (now.Sub(next)/every + 1))
+			}
+		}
+	}
+
+	if next.IsZero() {
+		sched.Status = models.ScheduleCompleted
+		sched.NextRunAt = nil
+
+		return
+	}
+
+	sched.DueAt = &next
+	sched.NextRunAt = &next
+}
+
+// validate normalizes spec and returns its first occurrence.
+func (s *Scheduler) validate(spec *Spec, now time.Time) (time.Time, error) {
+	spec.Description = strings.TrimSpace(spec.Description)
+	spec.Cron = strings.TrimSpace(spec.Cron)
+	spec.Every = strings.TrimSpace(spec.Every)
+
+	switch spec.OperationType {
+	case models.ScheduleDeposit, models.ScheduleWithdraw:
+		if spec.TargetWalletId != nil {
+			return time.Time{}, fmt.Errorf("%w: targetWalletId is only for transfers", ErrInvalidSchedule)
+		}
+	case models.ScheduleTransfer:
+		if spec.TargetWalletId == nil || *spec.TargetWalletId == spec.WalletId {
+			return time.Time{}, fmt.Errorf("%w: a transfer needs another targetWalletId", ErrInvalidSchedule)
+		}
+	default:
+		return time.Time{}, fmt.Errorf("%w: operationType must be DEPOSIT, WITHDRAW or TRANSFER", ErrInvalidSchedule)
+	}
+
+	if spec.Amount <= 0 {
+		return time.Time{}, fmt.Errorf("%w: amount must be positive", ErrInvalidSchedule)
+	}
+	if utf8.RuneCountInString(spec.Description) > wallet.MaxDescriptionLength {
+		return time.Time{}, fmt.Errorf("%w: description is longer than %d characters", ErrInvalidSchedule, wallet.MaxDescriptionLength)
+	}
+
+	start := now
+	if spec.RunAt != nil {
+		if !spec.RunAt.After(now) {
+			return time.Time{}, fmt.Errorf("%w: runAt must be in the future", ErrInvalidSchedule)
+		}
+		start = *spec.RunAt
+	}
+
+	switch {
+	case spec.Cron != "" && spec.Every != "":
+		return time.Time{}, fmt.Errorf("%w: cron and every are mutually exclusive", ErrInvalidSchedule)
+	case spec.Cron != "":
+		expr, err := cron.Parse(spec.Cron)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+		}
+		// The first occurrence at or after start
+		first := expr.Next(start.UTC().Add(-time.Nanosecond))
+		if first.IsZero() {
+			return time.Time{}, fmt.Errorf("%w: cron %q never fires", ErrInvalidSchedule, spec.Cron)
+		}
+
+		return first, nil
+	case spec.Every != "":
+		every, err := time.ParseDuration(spec.Every)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: every must be a duration like 24h", ErrInvalidSchedule)
+		}
+		if every < s.opts.MinEvery {
+			return time.Time{}, fmt.Errorf("%w: every must be at least %s", ErrInvalidSchedule, s.opts.MinEvery)
+		}
+		if spec.RunAt != nil {
+			return start.UTC(), nil
+		}
+
+		return now.Add(every).UTC(), nil
+	default:
+		if spec.RunAt == nil {
+			return time.Time{}, fmt.Errorf("%w: a one-off operation needs runAt", ErrInvalidSchedule)
+		}
+
+		return spec.RunAt.UTC(), nil
+	}
+}
+
+// checkAccess checks the caller may access the wallet and, for an operation type,
+// holds the scope it needs. It returns the wallet.
+func (s *Scheduler) checkAccess(ctx context.Context, principal auth.Principal, walletId uuid.UUID, operationType string) (models.Wallet, error) {
+	w, err := s.wallets.GetWallet(ctx, walletId)
+	if err != nil {
+		switch {
+		case errors.Is(err, wallet.ErrWalletNotExists):
+			return models.Wallet{}, ErrWalletNotExists
+		case errors.Is(err, wallet.ErrForbidden):
+			return models.Wallet{}, ErrForbidden
+		case errors.Is(err, wallet.ErrUnauthenticated):
+			return models.Wallet{}, ErrUnauthenticated
+		default:
+			return models.Wallet{}, err
+		}
+	}
+
+	switch operationType {
+	case models.ScheduleDeposit:
+		if !principal.HasScope(auth.ScopeDeposit) {
+			return models.Wallet{}, ErrForbidden
+		}
+	case models.ScheduleWithdraw, models.ScheduleTransfer:
+		if !principal.HasScope(auth.ScopeWithdraw) {
+			return models.Wallet{}, ErrForbidden
+		}
+	}
+
+	return w, nil
+}
+
+// permanent reports whether running the occurrence again cannot succeed.
+func permanent(err error) bool {
+	return errors.Is(err, ErrCreatorRevoked) ||
+		errors.Is(err, wallet.ErrWalletNotExists) ||
+		errors.Is(err, wallet.ErrForbidden) ||
+		errors.Is(err, wallet.ErrReferenceConflict) ||
+		errors.Is(err, wallet.ErrInvalidDetails)
+}
+
+func recurring(sched models.ScheduledOperation) bool {
+	return sched.Cron != "" || sched.Every != ""
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
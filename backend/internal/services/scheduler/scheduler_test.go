@@ -0,0 +1,206 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/services/apikey"
+	"coin-app/internal/services/wallet"
+)
+
+// memWallets checks access to its wallets like wallet.Wallet.GetWallet, it posts nothing.
+type memWallets map[uuid.UUID]models.Wallet
+
+func (m memWallets) GetWallet(ctx context.Context, walletId uuid.UUID) (models.Wallet, error) {
+	w, ok := m[walletId]
+	if !ok {
+		return models.Wallet{}, wallet.ErrWalletNotExists
+	}
+
+	principal, _ := auth.PrincipalFrom(ctx)
+	if !principal.CanAccess(w.UserId) {
+		return models.Wallet{}, wallet.ErrForbidden
+	}
+
+	return w, nil
+}
+
+func (m memWallets) SaveTransaction(context.Context, uuid.UUID, string, int, models.TransactionDetails) (uuid.UUID, error) {
+	return uuid.Nil, errors.New("not implemented")
+}
+
+// memSchedules saves and reads back schedules, the other storage methods are not used.
+type memSchedules struct {
+	ScheduleStorage
+	saved map[uuid.UUID]models.ScheduledOperation
+}
+
+func (m *memSchedules) SaveSchedule(_ context.Context, sched models.ScheduledOperation) error {
+	m.saved[sched.Id] = sched
+	return nil
+}
+
+func (m *memSchedules) GetSchedule(_ context.Context, scheduleId uuid.UUID) (models.ScheduledOperation, error) {
+	return m.saved[scheduleId], nil
+}
+
+// memKeys knows one active key, every other one is revoked.
+type memKeys struct {
+	active uuid.UUID
+}
+
+func (k memKeys) Principal(_ context.Context, keyId uuid.UUID) (auth.Principal, error) {
+	if keyId != k.active {
+		return auth.Principal{}, apikey.ErrKeyRevoked
+	}
+
+	return auth.Principal{Subject: "apikey:" + keyId.String(), Scopes: []string{auth.ScopeRead}, Service: true}, nil
+}
+
+func TestCreatorIsResolvedAtRunTime(t *testing.T) {
+	userId := uuid.New()
+	activeKey := uuid.New()
+
+	tests := []struct {
+		name      string
+		createdBy string
+		operation string
+		wantErr   error
+		check     func(t *testing.T, p auth.Principal)
+	}{
+		{
+			name:      "user keeps only own wallets",
+			createdBy: userId.String(),
+			operation: models.ScheduleWithdraw,
+			check: func(t *testing.T, p auth.Principal) {
+				if p.Service || p.UserId != userId || p.IsAdmin() {
+					t.Errorf("principal = %+v, want user %s without the admin scope", p, userId)
+				}
+			},
+		},
+		{
+			name:      "active key keeps its current scopes",
+			createdBy: "apikey:" + activeKey.String(),
+			operation: models.ScheduleWithdraw,
+			check: func(t *testing.T, p auth.Principal) {
+				if p.HasScope(auth.ScopeWithdraw) {
+					t.Errorf("principal = %+v, want the scopes the key has now", p)
+				}
+			},
+		},
+		{
+			name:      "revoked key",
+			createdBy: "apikey:" + uuid.NewString(),
+			operation: models.ScheduleTransfer,
+			wantErr:   ErrCreatorRevoked,
+		},
+		{
+			name:      "client certificate keeps the scope of the operation",
+			createdBy: "mtls:billing",
+			operation: models.ScheduleDeposit,
+			check: func(t *testing.T, p auth.Principal) {
+				if !slices.Equal(p.Scopes, []string{auth.ScopeDeposit}) {
+					t.Errorf("scopes = %v, want [%s]", p.Scopes, auth.ScopeDeposit)
+				}
+			},
+		},
+	}
+
+	s := New(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, nil, nil, nil, memKeys{active: activeKey}, Options{})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := s.creator(context.Background(), models.ScheduledOperation{
+				Id:            uuid.New(),
+				OperationType: tt.operation,
+				CreatedBy:     tt.createdBy,
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("creator() error = %v, want %v", err, tt.wantErr)
+				}
+				if !permanent(err) {
+					t.Errorf("permanent(%v) = false, want the run to fail for good", err)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("creator() error = %v", err)
+			}
+			if p.Subject != tt.createdBy {
+				t.Errorf("subject = %q, want %q", p.Subject, tt.createdBy)
+			}
+			tt.check(t, p)
+		})
+	}
+}
+
+func TestCreateChecksWalletOwner(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	walletId := uuid.New()
+	wallets := memWallets{walletId: {Id: walletId, UserId: owner, Currency: "RUB"}}
+
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		wantErr   error
+	}{
+		{
+			name:      "owner",
+			principal: auth.Principal{Subject: owner.String(), UserId: owner},
+		},
+		{
+			name:      "service",
+			principal: auth.Principal{Subject: "billing", Scopes: []string{auth.ScopeWithdraw}, Service: true},
+		},
+		{
+			name:      "api key",
+			principal: auth.Principal{Subject: "apikey:" + uuid.NewString(), Scopes: []string{auth.ScopeAdmin}, Service: true},
+		},
+		{
+			// Its runs would be posted without the admin scope and fail
+			name:      "admin user on another user's wallet",
+			principal: auth.Principal{Subject: other.String(), UserId: other, Scopes: []string{auth.ScopeAdmin}},
+			wantErr:   ErrForbidden,
+		},
+		{
+			name:      "another user",
+			principal: auth.Principal{Subject: other.String(), UserId: other},
+			wantErr:   ErrForbidden,
+		},
+		{
+			name:      "service without the scope",
+			principal: auth.Principal{Subject: "billing", Scopes: []string{auth.ScopeDeposit}, Service: true},
+			wantErr:   ErrForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedules := &memSchedules{saved: make(map[uuid.UUID]models.ScheduledOperation)}
+			s := New(slog.New(slog.NewTextHandler(io.Discard, nil)), schedules, nil, wallets, nil, nil, Options{})
+
+			ctx := auth.WithPrincipal(context.Background(), tt.principal)
+			_, err := s.Create(ctx, Spec{OperationType: models.ScheduleWithdraw, WalletId: walletId, Amount: 100, Every: "1h"})
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if saved := len(schedules.saved); (tt.wantErr == nil) != (saved == 1) {
+				t.Errorf("Create() saved %d schedules", saved)
+			}
+		})
+	}
+}
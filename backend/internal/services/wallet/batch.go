@@ -0,0 +1,564 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/storage"
+)
+
+// Batch modes.
+const (
+	// BatchAtomic applies every operation of the batch or none of them.
+	BatchAtomic = "atomic"
+	// BatchIndependent applies every operation on its own, a failing one does not affect the others.
+	BatchIndependent = "independent"
+)
+
+// BatchItem is a deposit or withdrawal of a batch.
+type BatchItem struct {
+	WalletId      uuid.UUID `json:"walletId"`
+	OperationType string    `json:"operationType"`
+	Amount        int       `json:"amount"`
+	models.TransactionDetails
+}
+
+// BatchResult is the outcome of one operation of a batch, Err is why it was not applied.
+type BatchResult struct {
+	models.Receipt
+	// Duplicate means the externalReference was posted before, the receipt is the original one.
+	Duplicate bool
+	Err       error
+}
+
+var (
+	// ErrInvalidBatch comes with the reason, e.g. an unknown mode.
+	ErrInvalidBatch = errors.New("invalid batch")
+	// ErrBatchRolledBack means an operation of an atomic batch failed and nothing was applied.
+	ErrBatchRolledBack = errors.New("batch rolled back")
+	// ErrNotApplied is the result of the operations rolled back with an atomic batch because of another one.
+	ErrNotApplied = errors.New("not applied, another operation of the batch failed")
+)
+
+// itemError fails an atomic batch because of the operation at index.
+type itemError struct {
+	index int
+	err   error
+}
+
+func (e *itemError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.index, e.err)
+}
+
+func (e *itemError) Unwrap() error {
+	return e.err
+}
+
+// batchOp is a validated operation of an atomic batch.
+type batchOp struct {
+	BatchItem
+	transactionId uuid.UUID
+	eventType     models.EventType
+	delta         int
+}
+
+// PostBatch posts deposits and withdrawals, results[i] is the outcome of items[i].
+// In BatchAtomic mode the operations are applied in one transaction with bulk statements,
+// the first failing one rolls all of them back and ErrBatchRolledBack is returned with the results.
+// In BatchIndependent mode every operation is posted on its own, see Post.
+func (w *Wallet) PostBatch(ctx context.Context, mode string, items []BatchItem) ([]BatchResult, error) {
+	const op = "Wallet.PostBatch"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	log := w.log.With(
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("mode", mode),
+		slog.Int("count", len(items)),
+	)
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		log.Warn("caller is not authenticated")
+
+		return nil, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s: %w", op, fmt.Errorf("%w: no operations", ErrInvalidBatch))
+	}
+
+	switch mode {
+	case BatchIndependent:
+		results := w.postIndependent(ctx, items)
+
+		log.Info("batch posted", slog.Int("applied", applied(results)))
+
+		return results, nil
+	case BatchAtomic:
+		results, err := w.postAtomic(ctx, log, principal, items)
+		if err != nil {
+			if !errors.Is(err, ErrBatchRolledBack) {
+				failSpan(span, err)
+			}
+
+			return results, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Info("batch posted", slog.Int("applied", applied(results)))
+
+		return results, nil
+	default:
+		return nil, fmt.Errorf("%s: %w", op, fmt.Errorf("%w: mode must be %s or %s", ErrInvalidBatch, BatchAtomic, BatchIndependent))
+	}
+}
+
+func (w *Wallet) postIndependent(ctx context.Context, items []BatchItem) []BatchResult {
+	results := make([]BatchResult, len(items))
+	for i, item := range items {
+		if err := checkItem(item); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		receipt, err := w.Post(ctx, item.WalletId, item.OperationType, item.Amount, item.TransactionDetails)
+		if errors.Is(err, ErrDuplicateReference) {
+			results[i] = BatchResult{Receipt: receipt, Duplicate: true}
+			continue
+		}
+		results[i] = BatchResult{Receipt: receipt, Err: err}
+	}
+
+	return results
+}
+
+func (w *Wallet) postAtomic(ctx context.Context, log *slog.Logger, principal auth.Principal, items []BatchItem) ([]BatchResult, error) {
+	results := make([]BatchResult, len(items))
+
+	// Everything that does not need the database is checked up front
+	ops := make([]batchOp, len(items))
+	references := make(map[string]int)
+	invalid := false
+	for i, item := range items {
+		o, err := w.prepareOp(principal, item)
+		if err == nil && o.ExternalReference != "" {
+			key := o.WalletId.String() + "/" + o.ExternalReference
+			if j, ok := references[key]; ok {
+				err = fmt.Errorf("%w: externalReference repeats operation %d", ErrInvalidDetails, j)
+			}
+			references[key] = i
+		}
+		if err != nil {
+			results[i].Err = err
+			invalid = true
+		}
+		ops[i] = o
+	}
+	if invalid {
+		return notApplied(results), ErrBatchRolledBack
+	}
+
+	var applied batchApplied
+	var err error
+	// A concurrent request may commit one of the references first, the second attempt sees it as a duplicate
+	for attempt := 0; attempt < 2; attempt++ {
+		err = w.txManager.WithinTx(ctx, func(ctx context.Context) error {
+			var err error
+			applied, err = w.applyAtomic(ctx, principal, ops)
+
+			return err
+		})
+		if !errors.Is(err, storage.ErrTransactionExists) {
+			break
+		}
+	}
+
+	var failed *itemError
+	if errors.As(err, &failed) {
+		err := failed.err
+		switch {
+		case errors.Is(err, storage.ErrWalletNotExists):
+			err = ErrWalletNotExists
+		case errors.Is(err, ErrBlocked):
+			// The transaction has been rolled back, the decision is kept on its own
+			if rerr := w.ruleEngine.Record(w.txManager.WithoutTx(ctx), applied.blocked); rerr != nil {
+				log.Error("failed to record rule decision", sl.Err(rerr))
+			}
+			err = fmt.Errorf("%w: %s", ErrBlocked, applied.blocked.Rule)
+		}
+		results[failed.index].Err = err
+
+		log.Warn("batch rolled back", slog.Int("index", failed.index), sl.Err(err))
+
+		return notApplied(results), ErrBatchRolledBack
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrConflict) {
+			log.Warn("batch conflicts after retries", sl.Err(err))
+
+			return nil, ErrConflict
+		}
+		log.Error("failed to post batch", sl.Err(err))
+
+		return nil, err
+	}
+
+	changed := make([]models.Wallet, 0, len(applied.wallets))
+	for _, wallet := range applied.wallets {
+		changed = append(changed, wallet)
+	}
+	w.walletsChanged(ctx, changed...)
+	for _, decision := range applied.decisions {
+		if decision.Action == models.RuleFlag {
+			log.Warn("transaction flagged for review", slog.String("transactionId", decision.TransactionId.String()), slog.String("rule", decision.Rule))
+		}
+	}
+
+	for i := range results {
+		results[i] = BatchResult{Receipt: applied.receipts[i], Duplicate: applied.duplicate[i]}
+	}
+
+	return results, nil
+}
+
+// batchApplied is the state of an atomic batch within its transaction.
+type batchApplied struct {
+	receipts  []models.Receipt
+	duplicate []bool
+	// wallets changed by the batch, the revenue wallets only accrue fees
+	wallets   map[uuid.UUID]models.Wallet
+	decisions []models.RuleDecision
+	blocked   models.RuleDecision
+}
+
+// applyAtomic posts the operations within the current transaction. The ledger entries, balances
+// and events are written with one statement each, limits, rules and fees are applied per operation.
+// It is run again from scratch if the transaction is retried.
+func (w *Wallet) applyAtomic(ctx context.Context, principal auth.Principal, ops []batchOp) (batchApplied, error) {
+	a := batchApplied{
+		receipts:  make([]models.Receipt, len(ops)),
+		duplicate: make([]bool, len(ops)),
+	}
+
+	var refWallets []uuid.UUID
+	var refs []string
+	for _, o := range ops {
+		if o.ExternalReference != "" {
+			refWallets = append(refWallets, o.WalletId)
+			refs = append(refs, o.ExternalReference)
+		}
+	}
+	if len(refs) > 0 {
+		originals, err := w.transactionSaver.TransactionsByReferences(ctx, refWallets, refs)
+		if err != nil {
+			return a, err
+		}
+		posted := make(map[string]models.Transaction, len(originals))
+		for _, t := range originals {
+			posted[t.WalletId.String()+"/"+t.ExternalReference] = t
+		}
+
+		for i, o := range ops {
+			t, ok := posted[o.WalletId.String()+"/"+o.ExternalReference]
+			if o.ExternalReference == "" || !ok {
+				continue
+			}
+			if t.OperationType != o.OperationType || t.Amount != float64(o.Amount) {
+				return a, &itemError{index: i, err: ErrReferenceConflict}
+			}
+			a.duplicate[i] = true
+			a.receipts[i] = models.Receipt{TransactionId: t.Id, Gross: o.Amount, Fee: t.Fee, Net: o.Amount - t.Fee}
+		}
+	}
+
+	deltas := make(map[uuid.UUID]int)
+	for i, o := range ops {
+		if !a.duplicate[i] {
+			deltas[o.WalletId] += o.delta
+		}
+	}
+
+	a.wallets = make(map[uuid.UUID]models.Wallet)
+	if len(deltas) > 0 {
+		wallets, err := w.walletSaver.UpdateBalances(ctx, deltas)
+		if err != nil {
+			return a, fmt.Errorf("failed to update wallet balances: %w", err)
+		}
+		a.wallets = wallets
+	}
+
+	// Duplicates change nothing, but the caller still needs access to their wallets
+	for i, o := range ops {
+		wallet, ok := a.wallets[o.WalletId]
+		if !ok && a.duplicate[i] {
+			var err error
+			wallet, err = w.walletSaver.GetWallet(ctx, o.WalletId)
+			if err != nil {
+				return a, err
+			}
+			ok = true
+		}
+		if !ok {
+			return a, &itemError{index: i, err: ErrWalletNotExists}
+		}
+		if !principal.CanAccess(wallet.UserId) {
+			return a, &itemError{index: i, err: ErrForbidden}
+		}
+	}
+
+	var rows []models.Transaction
+	for i, o := range ops {
+		if a.duplicate[i] {
+			continue
+		}
+		rows = append(rows, models.Transaction{
+			Id:                 o.transactionId,
+			WalletId:           o.WalletId,
+			OperationType:      o.OperationType,
+			Amount:             float64(o.Amount),
+			TransactionDetails: o.TransactionDetails,
+		})
+		a.receipts[i] = models.Receipt{TransactionId: o.transactionId, Gross: o.Amount, Net: o.Amount}
+	}
+	if len(rows) == 0 {
+		return a, nil
+	}
+	if err := w.transactionSaver.SaveTransactions(ctx, rows); err != nil {
+		return a, err
+	}
+
+	if err := w.chargeFees(ctx, ops, &a); err != nil {
+		return a, err
+	}
+
+	// Limits and rules see every wallet as a single Post would right after the operation,
+	// so a deposit taken back by a later withdrawal of the batch still may not exceed max_balance
+	states := runningWallets(ops, &a)
+
+	if w.limitChecker != nil {
+		for i, o := range ops {
+			if a.duplicate[i] {
+				continue
+			}
+			if err := w.limitChecker.CheckLimits(ctx, states[i], o.OperationType, o.Amount); err != nil {
+				return a, &itemError{index: i, err: err}
+			}
+		}
+	}
+
+	if w.ruleEngine != nil {
+		for i, o := range ops {
+			if a.duplicate[i] {
+				continue
+			}
+			decision, err := w.ruleEngine.Evaluate(ctx, states[i], o.transactionId, o.OperationType, o.Amount)
+			if err != nil {
+				return a, fmt.Errorf("failed to evaluate rules: %w", err)
+			}
+			if decision.Action == models.RuleBlock {
+				a.blocked = decision
+
+				return a, &itemError{index: i, err: ErrBlocked}
+			}
+			a.decisions = append(a.decisions, decision)
+		}
+
+		// Allowed and flagged decisions commit together with the operations
+		for _, decision := range a.decisions {
+			if err := w.ruleEngine.Record(ctx, decision); err != nil {
+				return a, fmt.Errorf("failed to record rule decision: %w", err)
+			}
+		}
+	}
+
+	events := make([]models.Event, 0, len(rows))
+	for i, o := range ops {
+		if a.duplicate[i] {
+			continue
+		}
+		event, err := models.NewEvent(o.WalletId, o.eventType, models.FundsMovedPayload{
+			TransactionId:      o.transactionId,
+			WalletId:           o.WalletId,
+			OperationType:      o.OperationType,
+			Amount:             o.Amount,
+			TransactionDetails: o.TransactionDetails,
+			Fee:                a.receipts[i].Fee,
+			FeeTransactionId:   a.receipts[i].FeeTransactionId,
+		})
+		if err != nil {
+			return a, fmt.Errorf("failed to build %s event: %w", o.eventType, err)
+		}
+		events = append(events, event)
+	}
+	if err := w.eventSaver.SaveEvents(ctx, events); err != nil {
+		return a, fmt.Errorf("failed to save events: %w", err)
+	}
+
+	return a, nil
+}
+
+// runningWallets returns the wallet of every operation as it is right after the operation and its fee,
+// replaying the batch in order from the balances it started with.
+func runningWallets(ops []batchOp, a *batchApplied) []models.Wallet {
+	balances := make(map[uuid.UUID]float64, len(a.wallets))
+	for id, wallet := range a.wallets {
+		balances[id] = wallet.Balance
+	}
+	for i, o := range ops {
+		if !a.duplicate[i] {
+			balances[o.WalletId] -= float64(o.delta - depositFee(o, a.receipts[i]))
+		}
+	}
+
+	states := make([]models.Wallet, len(ops))
+	for i, o := range ops {
+		states[i] = a.wallets[o.WalletId]
+		if a.duplicate[i] {
+			continue
+		}
+		balances[o.WalletId] += float64(o.delta - depositFee(o, a.receipts[i]))
+		states[i].Balance = balances[o.WalletId]
+	}
+
+	return states
+}
+
+// depositFee is the fee debited from the wallet on top of the operation, a withdrawal's fee is part of its amount.
+func depositFee(o batchOp, receipt models.Receipt) int {
+	if o.OperationType == "DEPOSIT" {
+		return receipt.Fee
+	}
+
+	return 0
+}
+
+// chargeFees posts the fees of the batch like chargeFee does for a single operation,
+// with one insert, one accrual and one balance update for all of them.
+func (w *Wallet) chargeFees(ctx context.Context, ops []batchOp, a *batchApplied) error {
+	if w.feeCalculator == nil {
+		return nil
+	}
+
+	var debits, accruals []models.Transaction
+	deltas := make(map[uuid.UUID]int)
+	for i, o := range ops {
+		if a.duplicate[i] {
+			continue
+		}
+
+		fee, err := w.feeCalculator.Fee(ctx, a.wallets[o.WalletId], o.OperationType, o.Amount)
+		if err != nil {
+			return &itemError{index: i, err: fmt.Errorf("failed to price fee: %w", err)}
+		}
+		if fee.Amount == 0 {
+			continue
+		}
+
+		transactionId := o.transactionId
+		if o.OperationType == "DEPOSIT" {
+			debits = append(debits, models.Transaction{Id: uuid.New(), WalletId: o.WalletId, OperationType: "FEE", Amount: float64(-fee.Amount), FeeFor: &transactionId})
+			deltas[o.WalletId] -= fee.Amount
+		}
+		feeId := uuid.New()
+		accruals = append(accruals, models.Transaction{Id: feeId, WalletId: fee.RevenueWalletId, OperationType: "FEE", Amount: float64(fee.Amount), FeeFor: &transactionId})
+
+		a.receipts[i].FeeTransactionId = &feeId
+		a.receipts[i].Fee = fee.Amount
+		a.receipts[i].Net = o.Amount - fee.Amount
+	}
+	if len(accruals) == 0 {
+		return nil
+	}
+
+	if len(debits) > 0 {
+		if err := w.transactionSaver.SaveTransactions(ctx, debits); err != nil {
+			return fmt.Errorf("failed to save fees: %w", err)
+		}
+
+		wallets, err := w.walletSaver.UpdateBalances(ctx, deltas)
+		if err != nil {
+			return fmt.Errorf("failed to update wallet balances: %w", err)
+		}
+		for id, wallet := range wallets {
+			a.wallets[id] = wallet
+		}
+	}
+
+	// A missing revenue wallet is a configuration error, not the caller's
+	if err := w.transactionSaver.AccrueFees(ctx, accruals); err != nil {
+		if errors.Is(err, storage.ErrWalletNotExists) {
+			return errors.New("revenue wallet not exists")
+		}
+
+		return fmt.Errorf("failed to accrue fees: %w", err)
+	}
+
+	return nil
+}
+
+// prepareOp validates an operation of an atomic batch and the caller's scope for it.
+func (w *Wallet) prepareOp(principal auth.Principal, item BatchItem) (batchOp, error) {
+	if err := checkItem(item); err != nil {
+		return batchOp{}, err
+	}
+
+	details, err := normalizeDetails(item.TransactionDetails)
+	if err != nil {
+		return batchOp{}, err
+	}
+	item.TransactionDetails = details
+
+	o := batchOp{BatchItem: item, transactionId: uuid.New()}
+	scope := auth.ScopeDeposit
+	switch item.OperationType {
+	case "DEPOSIT":
+		o.eventType, o.delta = models.EventFundsDeposited, item.Amount
+	case "WITHDRAW":
+		o.eventType, o.delta, scope = models.EventFundsWithdrawn, -item.Amount, auth.ScopeWithdraw
+	}
+	if !principal.HasScope(scope) {
+		return batchOp{}, ErrForbidden
+	}
+
+	return o, nil
+}
+
+func checkItem(item BatchItem) error {
+	if (item.OperationType != "DEPOSIT" && item.OperationType != "WITHDRAW") || item.Amount <= 0 {
+		return ErrInvalidOperation
+	}
+
+	return nil
+}
+
+// notApplied marks every operation without an error as rolled back because of another one.
+func notApplied(results []BatchResult) []BatchResult {
+	for i := range results {
+		results[i].Receipt = models.Receipt{}
+		results[i].Duplicate = false
+		if results[i].Err == nil {
+			results[i].Err = ErrNotApplied
+		}
+	}
+
+	return results
+}
+
+func applied(results []BatchResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Err == nil && !r.Duplicate {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,476 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/storage"
+)
+
+// memStorage keeps wallets, ledger and outbox in memory. A failing transaction restores them,
+// nested ones are part of the outermost, which runs the queued AfterCommit functions.
+type memStorage struct {
+	wallets      map[uuid.UUID]models.Wallet
+	transactions []models.Transaction
+	events       []models.Event
+	depth        int
+	afterCommit  []func()
+}
+
+func (s *memStorage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	wallets, transactions, events := maps.Clone(s.wallets), len(s.transactions), len(s.events)
+
+	s.depth++
+	err := fn(ctx)
+	s.depth--
+
+	if err != nil {
+		s.wallets, s.transactions, s.events = wallets, s.transactions[:transactions], s.events[:events]
+		if s.depth == 0 {
+			s.afterCommit = nil
+		}
+
+		return err
+	}
+
+	if s.depth == 0 {
+		queued := s.afterCommit
+		s.afterCommit = nil
+		for _, fn := range queued {
+			fn()
+		}
+	}
+
+	return nil
+}
+
+func (s *memStorage) AfterCommit(_ context.Context, fn func()) {
+	if s.depth > 0 {
+		s.afterCommit = append(s.afterCommit, fn)
+
+		return
+	}
+
+	fn()
+}
+
+func (s *memStorage) WithoutTx(ctx context.Context) context.Context {
+	return ctx
+}
+
+func (s *memStorage) SaveWallet(_ context.Context, walletId, userId uuid.UUID, balance int, currency string) (uuid.UUID, error) {
+	s.wallets[walletId] = models.Wallet{Id: walletId, UserId: userId, Balance: float64(balance), Currency: currency}
+
+	return walletId, nil
+}
+
+func (s *memStorage) UpdateBalance(_ context.Context, walletId uuid.UUID, amount int) (models.Wallet, error) {
+	w, ok := s.wallets[walletId]
+	if !ok {
+		return models.Wallet{}, storage.ErrWalletNotExists
+	}
+	w.Balance += float64(amount)
+	s.wallets[walletId] = w
+
+	return w, nil
+}
+
+func (s *memStorage) UpdateBalanceDecimal(context.Context, uuid.UUID, string) (models.Wallet, error) {
+	return models.Wallet{}, errors.New("not supported")
+}
+
+func (s *memStorage) GetWallet(_ context.Context, walletId uuid.UUID) (models.Wallet, error) {
+	w, ok := s.wallets[walletId]
+	if !ok {
+		return models.Wallet{}, storage.ErrWalletNotExists
+	}
+
+	return w, nil
+}
+
+func (s *memStorage) LockWallets(context.Context, ...uuid.UUID) error {
+	return nil
+}
+
+func (s *memStorage) UpdateBalances(_ context.Context, deltas map[uuid.UUID]int) (map[uuid.UUID]models.Wallet, error) {
+	wallets := make(map[uuid.UUID]models.Wallet, len(deltas))
+	for id, delta := range deltas {
+		w, ok := s.wallets[id]
+		if !ok {
+			continue
+		}
+		w.Balance += float64(delta)
+		s.wallets[id] = w
+		wallets[id] = w
+	}
+
+	return wallets, nil
+}
+
+// save appends the entries like the foreign key and the unique reference index would let them in.
+func (s *memStorage) save(transactions ...models.Transaction) error {
+	for _, t := range transactions {
+		if _, ok := s.wallets[t.WalletId]; !ok {
+			return storage.ErrWalletNotExists
+		}
+		if t.ExternalReference != "" {
+			if _, err := s.TransactionByReference(context.Background(), t.WalletId, t.ExternalReference); err == nil {
+				return storage.ErrTransactionExists
+			}
+		}
+		s.transactions = append(s.transactions, t)
+	}
+
+	return nil
+}
+
+func (s *memStorage) SaveTransaction(
+	_ context.Context,
+	transactionId uuid.UUID,
+	walletId uuid.UUID,
+	operationType string,
+	amount int,
+	details models.TransactionDetails,
+) (uuid.UUID, error) {
+	return transactionId, s.save(models.Transaction{
+		Id:                 transactionId,
+		WalletId:           walletId,
+		OperationType:      operationType,
+		Amount:             float64(amount),
+		TransactionDetails: details,
+	})
+}
+
+func (s *memStorage) TransactionByReference(_ context.Context, walletId uuid.UUID, reference string) (models.Transaction, error) {
+	for _, t := range s.transactions {
+		if t.WalletId == walletId && t.ExternalReference == reference {
+			return t, nil
+		}
+	}
+
+	return models.Transaction{}, storage.ErrTransactionNotExists
+}
+
+func (s *memStorage) SaveAdjustment(_ context.Context, transactionId, walletId uuid.UUID, amount int, _ string) (uuid.UUID, error) {
+	return transactionId, s.save(models.Transaction{Id: transactionId, WalletId: walletId, OperationType: "ADJUSTMENT", Amount: float64(amount)})
+}
+
+func (s *memStorage) SaveConversionTransaction(context.Context, uuid.UUID, uuid.UUID, string, string, models.FXQuote) (uuid.UUID, error) {
+	return uuid.UUID{}, errors.New("not supported")
+}
+
+func (s *memStorage) SaveFee(_ context.Context, transactionId, walletId uuid.UUID, amount int, feeFor uuid.UUID) (uuid.UUID, error) {
+	return transactionId, s.save(models.Transaction{Id: transactionId, WalletId: walletId, OperationType: "FEE", Amount: float64(amount), FeeFor: &feeFor})
+}
+
+func (s *memStorage) SaveTransactions(_ context.Context, transactions []models.Transaction) error {
+	return s.save(transactions...)
+}
+
+func (s *memStorage) AccrueFees(_ context.Context, fees []models.Transaction) error {
+	return s.save(fees...)
+}
+
+func (s *memStorage) TransactionsByReferences(ctx context.Context, walletIds []uuid.UUID, refs []string) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	for i, ref := range refs {
+		if t, err := s.TransactionByReference(ctx, walletIds[i], ref); err == nil {
+			transactions = append(transactions, t)
+		}
+	}
+
+	return transactions, nil
+}
+
+func (s *memStorage) SaveEvent(_ context.Context, event models.Event) error {
+	s.events = append(s.events, event)
+
+	return nil
+}
+
+func (s *memStorage) SaveEvents(_ context.Context, events []models.Event) error {
+	s.events = append(s.events, events...)
+
+	return nil
+}
+
+// maxBalance is a LimitChecker with only the max_balance limit.
+type maxBalance int
+
+func (m maxBalance) CheckLimits(_ context.Context, wallet models.Wallet, operationType string, _ int) error {
+	if operationType == "DEPOSIT" && wallet.Balance > float64(m) {
+		return &LimitError{Limit: LimitMaxBalance, Allowed: int(m), Actual: wallet.Balance}
+	}
+
+	return nil
+}
+
+// newBatchWallet returns the service over wallets with the balances, limits may be nil.
+func newBatchWallet(t *testing.T, limits LimitChecker, balances ...int) (*Wallet, *memStorage, []uuid.UUID) {
+	t.Helper()
+
+	s := &memStorage{wallets: map[uuid.UUID]models.Wallet{}}
+	ids := make([]uuid.UUID, len(balances))
+	for i, balance := range balances {
+		ids[i] = uuid.New()
+		s.wallets[ids[i]] = models.Wallet{Id: ids[i], Balance: float64(balance), Currency: models.DefaultCurrency}
+	}
+
+	w := New(slog.New(slog.NewTextHandler(io.Discard, nil)), s, s, s, s, nil, nil, limits, nil, nil)
+
+	return w, s, ids
+}
+
+func billingCtx() context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{
+		Subject: "billing",
+		Scopes:  []string{auth.ScopeDeposit, auth.ScopeWithdraw},
+		Service: true,
+	})
+}
+
+func deposit(walletId uuid.UUID, amount int, reference string) BatchItem {
+	return BatchItem{WalletId: walletId, OperationType: "DEPOSIT", Amount: amount, TransactionDetails: models.TransactionDetails{ExternalReference: reference}}
+}
+
+func withdraw(walletId uuid.UUID, amount int, reference string) BatchItem {
+	return BatchItem{WalletId: walletId, OperationType: "WITHDRAW", Amount: amount, TransactionDetails: models.TransactionDetails{ExternalReference: reference}}
+}
+
+// checkResults compares the errors of the results, a nil want is an applied operation.
+func checkResults(t *testing.T, results []BatchResult, want []error) {
+	t.Helper()
+
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, r := range results {
+		if want[i] == nil && r.Err != nil {
+			t.Errorf("results[%d] error = %v, want applied", i, r.Err)
+		}
+		if !errors.Is(r.Err, want[i]) {
+			t.Errorf("results[%d] error = %v, want %v", i, r.Err, want[i])
+		}
+	}
+}
+
+func checkBalances(t *testing.T, s *memStorage, ids []uuid.UUID, want ...float64) {
+	t.Helper()
+
+	for i, id := range ids {
+		if got := s.wallets[id].Balance; got != want[i] {
+			t.Errorf("balance of wallet %d = %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+func TestPostBatchAtomicRollsBack(t *testing.T) {
+	missing := uuid.New()
+
+	tests := []struct {
+		name  string
+		items func(ids []uuid.UUID) []BatchItem
+		want  []error
+	}{
+		{
+			name: "missing wallet",
+			items: func(ids []uuid.UUID) []BatchItem {
+				return []BatchItem{deposit(ids[0], 50, ""), withdraw(ids[1], 30, ""), deposit(missing, 10, "")}
+			},
+			want: []error{ErrNotApplied, ErrNotApplied, ErrWalletNotExists},
+		},
+		{
+			name: "limit of a later operation",
+			items: func(ids []uuid.UUID) []BatchItem {
+				return []BatchItem{withdraw(ids[1], 30, ""), deposit(ids[0], 950, "")}
+			},
+			want: []error{ErrNotApplied, ErrLimitExceeded},
+		},
+		{
+			name: "invalid operation",
+			items: func(ids []uuid.UUID) []BatchItem {
+				return []BatchItem{deposit(ids[0], 50, ""), withdraw(ids[1], 0, "")}
+			},
+			want: []error{ErrNotApplied, ErrInvalidOperation},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, s, ids := newBatchWallet(t, maxBalance(1000), 100, 100)
+
+			results, err := w.PostBatch(billingCtx(), BatchAtomic, tt.items(ids))
+			if !errors.Is(err, ErrBatchRolledBack) {
+				t.Fatalf("PostBatch() error = %v, want %v", err, ErrBatchRolledBack)
+			}
+			checkResults(t, results, tt.want)
+			checkBalances(t, s, ids, 100, 100)
+			if len(s.transactions) != 0 || len(s.events) != 0 {
+				t.Errorf("kept %d transactions and %d events, want none", len(s.transactions), len(s.events))
+			}
+		})
+	}
+}
+
+func TestPostBatchAtomicChecksLimitsOnRunningBalances(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   func(id uuid.UUID) []BatchItem
+		want    []error
+		balance float64
+	}{
+		{
+			name:    "deposit taken back by a later withdrawal",
+			items:   func(id uuid.UUID) []BatchItem { return []BatchItem{deposit(id, 200, ""), withdraw(id, 200, "")} },
+			want:    []error{ErrLimitExceeded, ErrNotApplied},
+			balance: 900,
+		},
+		{
+			name:    "withdrawal before the deposit",
+			items:   func(id uuid.UUID) []BatchItem { return []BatchItem{withdraw(id, 200, ""), deposit(id, 200, "")} },
+			want:    []error{nil, nil},
+			balance: 900,
+		},
+		{
+			name:    "deposits over the limit only together",
+			items:   func(id uuid.UUID) []BatchItem { return []BatchItem{deposit(id, 50, ""), deposit(id, 100, "")} },
+			want:    []error{ErrNotApplied, ErrLimitExceeded},
+			balance: 900,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, s, ids := newBatchWallet(t, maxBalance(1000), 900)
+
+			results, _ := w.PostBatch(billingCtx(), BatchAtomic, tt.items(ids[0]))
+			checkResults(t, results, tt.want)
+			checkBalances(t, s, ids, tt.balance)
+		})
+	}
+}
+
+func TestPostBatchIndependent(t *testing.T) {
+	w, s, ids := newBatchWallet(t, maxBalance(1000), 100, 100)
+
+	results, err := w.PostBatch(billingCtx(), BatchIndependent, []BatchItem{
+		deposit(ids[0], 50, ""),
+		deposit(uuid.New(), 10, ""),
+		withdraw(ids[1], 0, ""),
+		deposit(ids[1], 950, ""),
+		withdraw(ids[1], 30, ""),
+	})
+	if err != nil {
+		t.Fatalf("PostBatch() error = %v", err)
+	}
+
+	checkResults(t, results, []error{nil, ErrWalletNotExists, ErrInvalidOperation, ErrLimitExceeded, nil})
+	checkBalances(t, s, ids, 150, 70)
+	if len(s.transactions) != 2 || len(s.events) != 2 {
+		t.Errorf("kept %d transactions and %d events, want 2 of each", len(s.transactions), len(s.events))
+	}
+}
+
+func TestPostBatchDuplicateReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		// before is posted as a separate batch first
+		before    func(ids []uuid.UUID) []BatchItem
+		items     func(ids []uuid.UUID) []BatchItem
+		want      []error
+		duplicate []bool
+		balances  []float64
+	}{
+		{
+			name: "atomic, reference repeated in the batch",
+			mode: BatchAtomic,
+			items: func(ids []uuid.UUID) []BatchItem {
+				return []BatchItem{deposit(ids[0], 10, "inv-1"), deposit(ids[0], 10, "inv-1")}
+			},
+			want:     []error{ErrNotApplied, ErrInvalidDetails},
+			balances: []float64{100, 100},
+		},
+		{
+			name: "atomic, same reference on two wallets",
+			mode: BatchAtomic,
+			items: func(ids []uuid.UUID) []BatchItem {
+				return []BatchItem{deposit(ids[0], 10, "inv-1"), deposit(ids[1], 10, "inv-1")}
+			},
+			want:     []error{nil, nil},
+			balances: []float64{110, 110},
+		},
+		{
+			name:   "atomic, reference posted before",
+			mode:   BatchAtomic,
+			before: func(ids []uuid.UUID) []BatchItem { return []BatchItem{deposit(ids[0], 10, "inv-1")} },
+			items: func(ids []uuid.UUID) []BatchItem {
+				return []BatchItem{deposit(ids[0], 10, "inv-1"), deposit(ids[1], 10, "inv-2")}
+			},
+			want:      []error{nil, nil},
+			duplicate: []bool{true, false},
+			balances:  []float64{110, 110},
+		},
+		{
+			name:   "atomic, reference posted before for another amount",
+			mode:   BatchAtomic,
+			before: func(ids []uuid.UUID) []BatchItem { return []BatchItem{deposit(ids[0], 10, "inv-1")} },
+			items: func(ids []uuid.UUID) []BatchItem {
+				return []BatchItem{deposit(ids[1], 10, "inv-2"), deposit(ids[0], 20, "inv-1")}
+			},
+			want:     []error{ErrNotApplied, ErrReferenceConflict},
+			balances: []float64{110, 100},
+		},
+		{
+			name: "independent, reference repeated in the batch",
+			mode: BatchIndependent,
+			items: func(ids []uuid.UUID) []BatchItem {
+				return []BatchItem{deposit(ids[0], 10, "inv-1"), deposit(ids[0], 10, "inv-1")}
+			},
+			want:      []error{nil, nil},
+			duplicate: []bool{false, true},
+			balances:  []float64{110, 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, s, ids := newBatchWallet(t, nil, 100, 100)
+			ctx := billingCtx()
+
+			var original uuid.UUID
+			if tt.before != nil {
+				results, err := w.PostBatch(ctx, BatchAtomic, tt.before(ids))
+				if err != nil {
+					t.Fatalf("first PostBatch() error = %v", err)
+				}
+				original = results[0].TransactionId
+			}
+
+			results, _ := w.PostBatch(ctx, tt.mode, tt.items(ids))
+			checkResults(t, results, tt.want)
+			checkBalances(t, s, ids, tt.balances...)
+
+			for i, r := range results {
+				duplicate := tt.duplicate != nil && tt.duplicate[i]
+				if r.Duplicate != duplicate {
+					t.Errorf("results[%d] duplicate = %v, want %v", i, r.Duplicate, duplicate)
+				}
+				if duplicate && original != uuid.Nil && r.TransactionId != original {
+					t.Errorf("results[%d] transaction = %s, want the original %s", i, r.TransactionId, original)
+				}
+			}
+			if tt.mode == BatchIndependent && results[1].TransactionId != results[0].TransactionId {
+				t.Errorf("repeated reference posted as %s, want the first transaction %s", results[1].TransactionId, results[0].TransactionId)
+			}
+		})
+	}
+}
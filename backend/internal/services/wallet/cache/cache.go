@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+)
+
+type WalletProvider interface {
+	GetWallet(
+		ctx context.Context,
+		walletId uuid.UUID,
+	) (wallet models.Wallet, err error)
+}
+
+// Cache keeps recently read wallets in memory.
+//
+// Besides cached wallets it remembers the version of the latest write
+// acknowledged by this instance for every wallet (the watermark).
+// A wallet older than its watermark is never cached nor returned:
+// such a read is repeated against the primary database.
+type Cache struct {
+	mu         sync.Mutex
+	wallets    *lru[uuid.UUID, models.Wallet]
+	watermarks *lru[uuid.UUID, int64]
+
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+	evictions  atomic.Uint64
+	staleReads atomic.Uint64
+}
+
+type Stats struct {
+	Hits       uint64
+	Misses     uint64
+	Evictions  uint64
+	StaleReads uint64
+	Size       int
+}
+
+// HitRatio returns the share of reads served from the cache.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(total)
+}
+
+// New returns an empty cache holding up to size wallets for ttl.
+// Watermarks are kept longer than wallets: they must outlive the replication lag.
+func New(size int, ttl time.Duration, watermarkTTL time.Duration) *Cache {
+	return &Cache{
+		wallets:    newLRU[uuid.UUID, models.Wallet](size, ttl),
+		watermarks: newLRU[uuid.UUID, int64](size*4, watermarkTTL),
+	}
+}
+
+// WalletChanged implements wallet.WalletObserver.
+// It is called with the committed state of the wallet before the write is acknowledged.
+func (c *Cache) WalletChanged(wallet models.Wallet) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if v, ok := c.watermarks.get(wallet.Id, now); !ok || v < wallet.Version {
+		c.watermarks.set(wallet.Id, wallet.Version, now)
+	}
+
+	c.storeLocked(wallet, now)
+}
+
+// Wrap returns a WalletProvider that serves reads from the cache and falls back to next.
+func (c *Cache) Wrap(next WalletProvider) WalletProvider {
+	return &provider{cache: c, next: next}
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	size := c.wallets.len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+		Evictions:  c.evictions.Load(),
+		StaleReads: c.staleReads.Load(),
+		Size:       size,
+	}
+}
+
+// ReportStats logs cache statistics every interval until ctx is cancelled.
+func (c *Cache) ReportStats(ctx context.Context, log *slog.Logger, interval time.Duration) {
+	const op = "Cache.ReportStats"
+
+	log = log.With(slog.String("op", op))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := c.Stats()
+			log.Info("wallet cache stats",
+				slog.Uint64("hits", stats.Hits),
+				slog.Uint64("misses", stats.Misses),
+				slog.Uint64("evictions", stats.Evictions),
+				slog.Uint64("staleReads", stats.StaleReads),
+				slog.Int("size", stats.Size),
+				slog.Float64("hitRatio", stats.HitRatio()),
+			)
+		}
+	}
+}
+
+func (c *Cache) lookup(walletId uuid.UUID) (models.Wallet, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.wallets.get(walletId, time.Now())
+}
+
+func (c *Cache) watermark(walletId uuid.UUID) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.watermarks.get(walletId, time.Now())
+}
+
+func (c *Cache) store(wallet models.Wallet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.storeLocked(wallet, time.Now())
+}
+
+// storeLocked caches wallet unless a newer version is already known.
+func (c *Cache) storeLocked(wallet models.Wallet, now time.Time) {
+	if v, ok := c.watermarks.get(wallet.Id, now); ok && wallet.Version < v {
+		return
+	}
+
+	if cached, ok := c.wallets.get(wallet.Id, now); ok && cached.Version > wallet.Version {
+		return
+	}
+
+	if c.wallets.set(wallet.Id, wallet, now) {
+		c.evictions.Add(1)
+	}
+}
+
+type provider struct {
+	cache *Cache
+	next  WalletProvider
+}
+
+// GetWallet serves the wallet from the cache.
+// Requests for strong consistency bypass the cache, as other instances may have changed the wallet.
+func (p *provider) GetWallet(ctx context.Context, walletId uuid.UUID) (models.Wallet, error) {
+	if !storage.IsStrongConsistency(ctx) {
+		if wallet, ok := p.cache.lookup(walletId); ok {
+			p.cache.hits.Add(1)
+
+			return wallet, nil
+		}
+	}
+
+	p.cache.misses.Add(1)
+
+	wallet, err := p.next.GetWallet(ctx, walletId)
+	if err != nil {
+		return models.Wallet{}, err
+	}
+
+	// A replica may not have caught up with a write we have already acknowledged.
+	if v, ok := p.cache.watermark(walletId); ok && wallet.Version < v {
+		p.cache.staleReads.Add(1)
+
+		wallet, err = p.next.GetWallet(storage.WithStrongConsistency(ctx), walletId)
+		if err != nil {
+			return models.Wallet{}, err
+		}
+	}
+
+	p.cache.store(wallet)
+
+	return wallet, nil
+}
@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+)
+
+// memReplicas serves strongly consistent reads from the primary and the others from a replica
+// that catches up only when told to.
+type memReplicas struct {
+	primary map[uuid.UUID]models.Wallet
+	replica map[uuid.UUID]models.Wallet
+	// reads counts the reads by whether they went to the primary.
+	reads map[bool]int
+}
+
+func newMemReplicas() *memReplicas {
+	return &memReplicas{
+		primary: make(map[uuid.UUID]models.Wallet),
+		replica: make(map[uuid.UUID]models.Wallet),
+		reads:   make(map[bool]int),
+	}
+}
+
+func (m *memReplicas) GetWallet(ctx context.Context, walletId uuid.UUID) (models.Wallet, error) {
+	strong := storage.IsStrongConsistency(ctx)
+	m.reads[strong]++
+
+	source := m.replica
+	if strong {
+		source = m.primary
+	}
+	wallet, ok := source[walletId]
+	if !ok {
+		return models.Wallet{}, storage.ErrWalletNotExists
+	}
+
+	return wallet, nil
+}
+
+// write commits the wallet to the primary only, like a write the replica has not replayed yet.
+func (m *memReplicas) write(wallet models.Wallet) {
+	m.primary[wallet.Id] = wallet
+}
+
+func (m *memReplicas) replicate() {
+	for id, wallet := range m.primary {
+		m.replica[id] = wallet
+	}
+}
+
+func wallet(id uuid.UUID, version int64, balance float64) models.Wallet {
+	return models.Wallet{Id: id, Version: version, Balance: balance}
+}
+
+func TestGetWalletNeverReturnsAcknowledgedWritesStale(t *testing.T) {
+	id := uuid.New()
+
+	tests := []struct {
+		name string
+		// prepare runs against a cache of the wallet at version 1 everywhere
+		prepare     func(c *Cache, db *memReplicas)
+		ctx         context.Context
+		wantVersion int64
+		wantStale   uint64
+		// wantReads are the reads sent to the replica and to the primary
+		wantReplica, wantPrimary int
+	}{
+		{
+			name: "cached after the write",
+			prepare: func(c *Cache, db *memReplicas) {
+				db.write(wallet(id, 2, 150))
+				c.WalletChanged(wallet(id, 2, 150))
+			},
+			ctx:         context.Background(),
+			wantVersion: 2,
+		},
+		{
+			name: "evicted wallet re-read from the primary",
+			prepare: func(c *Cache, db *memReplicas) {
+				db.write(wallet(id, 2, 150))
+				c.WalletChanged(wallet(id, 2, 150))
+				c.wallets = newLRU[uuid.UUID, models.Wallet](1, time.Minute)
+			},
+			ctx:         context.Background(),
+			wantVersion: 2,
+			wantStale:   1,
+			wantReplica: 1,
+			wantPrimary: 1,
+		},
+		{
+			name: "expired wallet re-read from the primary",
+			prepare: func(c *Cache, db *memReplicas) {
+				db.write(wallet(id, 2, 150))
+				c.WalletChanged(wallet(id, 2, 150))
+				c.wallets.ttl = -time.Second
+				c.wallets.set(id, wallet(id, 2, 150), time.Now())
+			},
+			ctx:         context.Background(),
+			wantVersion: 2,
+			wantStale:   1,
+			wantReplica: 1,
+			wantPrimary: 1,
+		},
+		{
+			name: "replica caught up",
+			prepare: func(c *Cache, db *memReplicas) {
+				db.write(wallet(id, 2, 150))
+				c.WalletChanged(wallet(id, 2, 150))
+				db.replicate()
+				c.wallets = newLRU[uuid.UUID, models.Wallet](1, time.Minute)
+			},
+			ctx:         context.Background(),
+			wantVersion: 2,
+			wantReplica: 1,
+		},
+		{
+			name: "write of another instance",
+			prepare: func(c *Cache, db *memReplicas) {
+				db.write(wallet(id, 2, 150))
+			},
+			ctx:         context.Background(),
+			wantVersion: 1,
+		},
+		{
+			name: "write of another instance with strong consistency",
+			prepare: func(c *Cache, db *memReplicas) {
+				db.write(wallet(id, 2, 150))
+			},
+			ctx:         storage.WithStrongConsistency(context.Background()),
+			wantVersion: 2,
+			wantPrimary: 1,
+		},
+		{
+			name: "late notification of an older write",
+			prepare: func(c *Cache, db *memReplicas) {
+				db.write(wallet(id, 3, 175))
+				c.WalletChanged(wallet(id, 3, 175))
+				c.WalletChanged(wallet(id, 2, 150))
+			},
+			ctx:         context.Background(),
+			wantVersion: 3,
+		},
+		{
+			name: "watermark outlived by the replication lag",
+			prepare: func(c *Cache, db *memReplicas) {
+				db.write(wallet(id, 2, 150))
+				c.watermarks.ttl = -time.Second
+				c.WalletChanged(wallet(id, 2, 150))
+				c.wallets = newLRU[uuid.UUID, models.Wallet](1, time.Minute)
+			},
+			ctx:         context.Background(),
+			wantVersion: 1,
+			wantReplica: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newMemReplicas()
+			db.write(wallet(id, 1, 100))
+			db.replicate()
+
+			c := New(10, time.Minute, time.Minute)
+			p := c.Wrap(db)
+			if _, err := p.GetWallet(context.Background(), id); err != nil {
+				t.Fatalf("GetWallet() error = %v", err)
+			}
+			db.reads = make(map[bool]int)
+
+			tt.prepare(c, db)
+
+			got, err := p.GetWallet(tt.ctx, id)
+			if err != nil {
+				t.Fatalf("GetWallet() error = %v", err)
+			}
+			if got.Version != tt.wantVersion {
+				t.Errorf("GetWallet() version = %d, want %d", got.Version, tt.wantVersion)
+			}
+			if stale := c.Stats().StaleReads; stale != tt.wantStale {
+				t.Errorf("stale reads = %d, want %d", stale, tt.wantStale)
+			}
+			if db.reads[false] != tt.wantReplica || db.reads[true] != tt.wantPrimary {
+				t.Errorf("reads = %d from the replica and %d from the primary, want %d and %d",
+					db.reads[false], db.reads[true], tt.wantReplica, tt.wantPrimary)
+			}
+
+			// The wallet returned is the one served next from the cache
+			again, err := p.GetWallet(context.Background(), id)
+			if err != nil {
+				t.Fatalf("GetWallet() error = %v", err)
+			}
+			if again.Version < got.Version {
+				t.Errorf("cached version = %d, older than %d read before", again.Version, got.Version)
+			}
+		})
+	}
+}
+
+func TestStoreKeepsNewerVersion(t *testing.T) {
+	id := uuid.New()
+	c := New(10, time.Minute, time.Minute)
+
+	c.store(wallet(id, 5, 500))
+	c.store(wallet(id, 4, 400))
+
+	got, ok := c.lookup(id)
+	if !ok || got.Version != 5 {
+		t.Fatalf("lookup() = %+v, %v, want version 5", got, ok)
+	}
+}
+
+func TestStats(t *testing.T) {
+	db := newMemReplicas()
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	for _, id := range ids {
+		db.write(wallet(id, 1, 100))
+	}
+	db.replicate()
+
+	c := New(2, time.Minute, time.Minute)
+	p := c.Wrap(db)
+
+	for _, id := range []uuid.UUID{ids[0], ids[0], ids[1], ids[2], ids[0]} {
+		if _, err := p.GetWallet(context.Background(), id); err != nil {
+			t.Fatalf("GetWallet() error = %v", err)
+		}
+	}
+	if _, err := p.GetWallet(context.Background(), uuid.New()); !errors.Is(err, storage.ErrWalletNotExists) {
+		t.Fatalf("GetWallet() of an unknown wallet error = %v, want %v", err, storage.ErrWalletNotExists)
+	}
+
+	want := Stats{Hits: 1, Misses: 5, Evictions: 2, Size: 2}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if ratio := c.Stats().HitRatio(); ratio != 1.0/6 {
+		t.Errorf("HitRatio() = %v, want %v", ratio, 1.0/6)
+	}
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+)
+
+// lru is a size bounded map with per entry expiration.
+// It is not safe for concurrent use.
+type lru[K comparable, V any] struct {
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[K]*list.Element
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+func newLRU[K comparable, V any](size int, ttl time.Duration) *lru[K, V] {
+	return &lru[K, V]{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[K]*list.Element, size),
+	}
+}
+
+// get returns the value stored for key unless it is missing or expired.
+func (c *lru[K, V]) get(key K, now time.Time) (V, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	e := el.Value.(*entry[K, V])
+	if now.After(e.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+
+		var zero V
+		return zero, false
+	}
+
+	c.ll.MoveToFront(el)
+
+	return e.value, true
+}
+
+// set stores value for key and reports whether an entry was evicted to make room.
+func (c *lru[K, V]) set(key K, value V, now time.Time) (evicted bool) {
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value = value
+		e.expiresAt = now.Add(c.ttl)
+		c.ll.MoveToFront(el)
+
+		return false
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[K, V]{
+		key:       key,
+		value:     value,
+		expiresAt: now.Add(c.ttl),
+	})
+
+	if c.ll.Len() <= c.size {
+		return false
+	}
+
+	oldest := c.ll.Back()
+	c.ll.Remove(oldest)
+	delete(c.items, oldest.Value.(*entry[K, V]).key)
+
+	return true
+}
+
+func (c *lru[K, V]) len() int {
+	return c.ll.Len()
+}
@@ -0,0 +1,142 @@
+package fees
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+)
+
+// Engine prices deposits and withdrawals by the fee schedule of the operation type and wallet tier.
+// A schedule of the exact tier wins over one matching every tier, an operation no schedule matches is free.
+type Engine struct {
+	log       *slog.Logger
+	tiers     TierResolver
+	schedules []models.FeeSchedule
+	revenue   map[string]uuid.UUID
+}
+
+// TierResolver returns the policy of a wallet, see policy.Policy.
+type TierResolver interface {
+	Get(ctx context.Context, walletId uuid.UUID) (models.WalletPolicy, error)
+}
+
+// WalletGetter reads a wallet, see CheckRevenueWallets.
+type WalletGetter interface {
+	GetWallet(ctx context.Context, walletId uuid.UUID) (models.Wallet, error)
+}
+
+var (
+	// ErrNoRevenueWallet means a fee is due in a currency without a revenue wallet.
+	ErrNoRevenueWallet = errors.New("no revenue wallet for the currency")
+	// ErrRevenueCurrency means a revenue wallet holds another currency than it collects fees in.
+	ErrRevenueCurrency = errors.New("revenue wallet holds another currency")
+)
+
+// New returns a new instance of the fees Engine. revenue maps a currency to the wallet its fees are credited to.
+func New(log *slog.Logger, tiers TierResolver, schedules []models.FeeSchedule, revenue map[string]uuid.UUID) *Engine {
+	return &Engine{
+		log:       log,
+		tiers:     tiers,
+		schedules: schedules,
+		revenue:   revenue,
+	}
+}
+
+// Fee implements wallet.FeeCalculator. Operations on the revenue wallets themselves are free.
+func (e *Engine) Fee(ctx context.Context, w models.Wallet, operationType string, amount int) (models.Fee, error) {
+	const op = "fees.Engine.Fee"
+
+	revenueWalletId, hasRevenue := e.revenue[w.Currency]
+	if hasRevenue && revenueWalletId == w.Id {
+		return models.Fee{}, nil
+	}
+
+	schedule, ok, err := e.schedule(ctx, w.Id, operationType)
+	if err != nil {
+		return models.Fee{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return models.Fee{}, nil
+	}
+
+	fee := schedule.Fee(amount)
+	if fee == 0 {
+		return models.Fee{}, nil
+	}
+	if !hasRevenue {
+		return models.Fee{}, fmt.Errorf("%s: %w: %s", op, ErrNoRevenueWallet, w.Currency)
+	}
+
+	e.log.Debug("fee priced",
+		slog.String("op", op),
+		slog.String("walletId", w.Id.String()),
+		slog.String("schedule", schedule.Name),
+		slog.Int("amount", amount),
+		slog.Int("fee", fee),
+	)
+
+	return models.Fee{
+		Amount:          fee,
+		Schedule:        schedule.Name,
+		RevenueWalletId: revenueWalletId,
+	}, nil
+}
+
+// CheckRevenueWallets makes sure every revenue wallet exists and holds the currency it collects fees in.
+// Fees are accrued without reading the revenue wallet, so it is checked once at startup.
+func (e *Engine) CheckRevenueWallets(ctx context.Context, wallets WalletGetter) error {
+	const op = "fees.Engine.CheckRevenueWallets"
+
+	for currency, walletId := range e.revenue {
+		w, err := wallets.GetWallet(ctx, walletId)
+		if err != nil {
+			return fmt.Errorf("%s: revenue wallet %s for %s: %w", op, walletId, currency, err)
+		}
+		if w.Currency != currency {
+			return fmt.Errorf("%s: %w: %s holds %s, collects %s", op, ErrRevenueCurrency, walletId, w.Currency, currency)
+		}
+	}
+
+	return nil
+}
+
+// schedule picks the schedule of the operation, the tier is only resolved if some schedule depends on it.
+func (e *Engine) schedule(ctx context.Context, walletId uuid.UUID, operationType string) (models.FeeSchedule, bool, error) {
+	var fallback *models.FeeSchedule
+	var tiered []models.FeeSchedule
+	for i, s := range e.schedules {
+		if s.Operation != operationType {
+			continue
+		}
+		if s.Tier == "" {
+			if fallback == nil {
+				fallback = &e.schedules[i]
+			}
+			continue
+		}
+		tiered = append(tiered, s)
+	}
+
+	if len(tiered) > 0 {
+		wp, err := e.tiers.Get(ctx, walletId)
+		if err != nil {
+			return models.FeeSchedule{}, false, err
+		}
+		for _, s := range tiered {
+			if s.Tier == wp.Tier {
+				return s, true, nil
+			}
+		}
+	}
+
+	if fallback == nil {
+		return models.FeeSchedule{}, false, nil
+	}
+
+	return *fallback, true, nil
+}
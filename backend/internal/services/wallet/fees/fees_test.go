@@ -0,0 +1,132 @@
+package fees
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+)
+
+// memWallets holds the wallets by id.
+type memWallets map[uuid.UUID]models.Wallet
+
+func (m memWallets) GetWallet(_ context.Context, walletId uuid.UUID) (models.Wallet, error) {
+	w, ok := m[walletId]
+	if !ok {
+		return models.Wallet{}, storage.ErrWalletNotExists
+	}
+
+	return w, nil
+}
+
+// memAccruals settles the accrued fees in batches, its transactions run fn as is.
+type memAccruals struct {
+	wallets  map[uuid.UUID]models.Wallet
+	accruals []models.Transaction
+}
+
+func (s *memAccruals) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (s *memAccruals) SettleFees(_ context.Context, limit int) (int, []models.Wallet, error) {
+	n := min(limit, len(s.accruals))
+	credited := make(map[uuid.UUID]bool)
+	for _, a := range s.accruals[:n] {
+		w := s.wallets[a.WalletId]
+		w.Balance += a.Amount
+		s.wallets[a.WalletId] = w
+		credited[a.WalletId] = true
+	}
+	s.accruals = s.accruals[n:]
+
+	var wallets []models.Wallet
+	for id := range credited {
+		wallets = append(wallets, s.wallets[id])
+	}
+
+	return n, wallets, nil
+}
+
+// memObserver remembers the last seen state of every wallet.
+type memObserver map[uuid.UUID]models.Wallet
+
+func (m memObserver) WalletChanged(wallet models.Wallet) {
+	m[wallet.Id] = wallet
+}
+
+func newLog() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestCheckRevenueWallets(t *testing.T) {
+	rub, usd := uuid.New(), uuid.New()
+	wallets := memWallets{
+		rub: {Id: rub, Currency: "RUB"},
+		usd: {Id: usd, Currency: "USD"},
+	}
+
+	tests := []struct {
+		name    string
+		revenue map[string]uuid.UUID
+		wantErr error
+	}{
+		{name: "every currency in its own wallet", revenue: map[string]uuid.UUID{"RUB": rub, "USD": usd}},
+		{name: "wallet of another currency", revenue: map[string]uuid.UUID{"RUB": rub, "EUR": usd}, wantErr: ErrRevenueCurrency},
+		{name: "missing wallet", revenue: map[string]uuid.UUID{"RUB": uuid.New()}, wantErr: storage.ErrWalletNotExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(newLog(), nil, nil, tt.revenue)
+
+			err := e.CheckRevenueWallets(context.Background(), wallets)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("CheckRevenueWallets() error = %v", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckRevenueWallets() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSettle(t *testing.T) {
+	rub, usd := uuid.New(), uuid.New()
+	s := &memAccruals{
+		wallets: map[uuid.UUID]models.Wallet{
+			rub: {Id: rub, Currency: "RUB", Balance: 100},
+			usd: {Id: usd, Currency: "USD"},
+		},
+		accruals: []models.Transaction{
+			{WalletId: rub, Amount: 10},
+			{WalletId: rub, Amount: 5},
+			{WalletId: usd, Amount: 1},
+		},
+	}
+	observer := memObserver{}
+	settler := NewSettler(newLog(), s, observer, SettlerOptions{BatchSize: 2})
+
+	for _, want := range []int{2, 1, 0} {
+		n, err := settler.Settle(context.Background())
+		if err != nil {
+			t.Fatalf("Settle() error = %v", err)
+		}
+		if n != want {
+			t.Fatalf("Settle() settled %d accruals, want %d", n, want)
+		}
+	}
+
+	if got := observer[rub].Balance; got != 115 {
+		t.Errorf("observed RUB revenue balance = %v, want 115", got)
+	}
+	if got := observer[usd].Balance; got != 1 {
+		t.Errorf("observed USD revenue balance = %v, want 1", got)
+	}
+}
@@ -0,0 +1,105 @@
+package fees
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/logger/sl"
+)
+
+// SettleStorage credits the accrued fees to the revenue wallets.
+type SettleStorage interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// SettleFees returns the number of settled accruals and the credited wallets.
+	SettleFees(ctx context.Context, limit int) (int, []models.Wallet, error)
+}
+
+// WalletObserver is notified about the settled revenue wallets, see wallet.WalletObserver.
+type WalletObserver interface {
+	WalletChanged(wallet models.Wallet)
+}
+
+type SettlerOptions struct {
+	Interval  time.Duration
+	BatchSize int
+}
+
+// Settler moves the fees accrued by deposits and withdrawals into the revenue wallet balances.
+// The operations only insert the accruals, so the revenue wallet rows are locked here alone,
+// once per batch instead of once per fee.
+type Settler struct {
+	log      *slog.Logger
+	storage  SettleStorage
+	observer WalletObserver
+	opts     SettlerOptions
+}
+
+// NewSettler returns a new instance of the fee Settler, observer may be nil.
+func NewSettler(log *slog.Logger, storage SettleStorage, observer WalletObserver, opts SettlerOptions) *Settler {
+	return &Settler{
+		log:      log,
+		storage:  storage,
+		observer: observer,
+		opts:     opts,
+	}
+}
+
+// Run settles the accrued fees until ctx is cancelled.
+func (s *Settler) Run(ctx context.Context) {
+	const op = "fees.Settler.Run"
+
+	log := s.log.With(slog.String("op", op))
+
+	log.Info("fee settler started")
+
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.Settle(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error("failed to settle fees", sl.Err(err))
+		}
+
+		// A full batch means there may be more accruals waiting.
+		if err == nil && n == s.opts.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("fee settler stopped")
+
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Settle credits one batch of accruals and returns its size.
+func (s *Settler) Settle(ctx context.Context) (int, error) {
+	const op = "fees.Settler.Settle"
+
+	var settled int
+	var wallets []models.Wallet
+	err := s.storage.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		settled, wallets, err = s.storage.SettleFees(ctx, s.opts.BatchSize)
+
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if s.observer != nil {
+		for _, wallet := range wallets {
+			s.observer.WalletChanged(wallet)
+		}
+	}
+
+	return settled, nil
+}
@@ -0,0 +1,182 @@
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/metrics"
+	"coin-app/internal/services/wallet"
+
+	"github.com/google/uuid"
+)
+
+const (
+	operationCreate     = "CREATE"
+	operationAdjustment = "ADJUSTMENT"
+	operationConversion = "CONVERSION"
+	operationBatch      = "BATCH"
+)
+
+// Wallet decorates the wallet service with business counters.
+// Methods that are not overridden here are passed through untouched.
+type Wallet struct {
+	*wallet.Wallet
+	metrics *metrics.Metrics
+}
+
+func New(w *wallet.Wallet, m *metrics.Metrics) *Wallet {
+	return &Wallet{Wallet: w, metrics: m}
+}
+
+func (w *Wallet) SaveWallet(ctx context.Context, userId uuid.UUID, balance int, currency string) (uuid.UUID, error) {
+	id, err := w.Wallet.SaveWallet(ctx, userId, balance, currency)
+	if err != nil {
+		w.metrics.RejectedOperations.WithLabelValues(operationCreate, reason(err)).Inc()
+
+		return id, err
+	}
+
+	w.metrics.WalletsCreated.Inc()
+
+	return id, nil
+}
+
+func (w *Wallet) SaveTransaction(
+	ctx context.Context,
+	walletId uuid.UUID,
+	operationType string,
+	amount int,
+	details models.TransactionDetails,
+) (uuid.UUID, error) {
+	receipt, err := w.Post(ctx, walletId, operationType, amount, details)
+
+	return receipt.TransactionId, err
+}
+
+func (w *Wallet) Post(
+	ctx context.Context,
+	walletId uuid.UUID,
+	operationType string,
+	amount int,
+	details models.TransactionDetails,
+) (models.Receipt, error) {
+	receipt, err := w.Wallet.Post(ctx, walletId, operationType, amount, details)
+	if err != nil {
+		w.metrics.RejectedOperations.WithLabelValues(operation(operationType), reason(err)).Inc()
+
+		return receipt, err
+	}
+
+	w.metrics.Operations.WithLabelValues(operation(operationType)).Inc()
+	w.metrics.OperationsAmount.WithLabelValues(operation(operationType)).Add(float64(amount))
+	if receipt.Fee > 0 {
+		w.metrics.FeesAmount.WithLabelValues(operation(operationType)).Add(float64(receipt.Fee))
+	}
+
+	return receipt, nil
+}
+
+// PostBatch counts every operation of the batch like Post does.
+// A rolled back atomic batch rejects the failed operation for its own reason and the others as "batch".
+func (w *Wallet) PostBatch(ctx context.Context, mode string, items []wallet.BatchItem) ([]wallet.BatchResult, error) {
+	results, err := w.Wallet.PostBatch(ctx, mode, items)
+	if err != nil && results == nil {
+		w.metrics.RejectedOperations.WithLabelValues(operationBatch, reason(err)).Inc()
+
+		return results, err
+	}
+
+	for i, result := range results {
+		operationType := operation(items[i].OperationType)
+		switch {
+		case errors.Is(result.Err, wallet.ErrNotApplied):
+			w.metrics.RejectedOperations.WithLabelValues(operationType, "batch").Inc()
+		case result.Err != nil:
+			w.metrics.RejectedOperations.WithLabelValues(operationType, reason(result.Err)).Inc()
+		case result.Duplicate:
+			w.metrics.RejectedOperations.WithLabelValues(operationType, "duplicate").Inc()
+		default:
+			w.metrics.Operations.WithLabelValues(operationType).Inc()
+			w.metrics.OperationsAmount.WithLabelValues(operationType).Add(float64(items[i].Amount))
+			if result.Fee > 0 {
+				w.metrics.FeesAmount.WithLabelValues(operationType).Add(float64(result.Fee))
+			}
+		}
+	}
+
+	return results, err
+}
+
+func (w *Wallet) Adjust(ctx context.Context, walletId uuid.UUID, amount int, note string) (uuid.UUID, error) {
+	id, err := w.Wallet.Adjust(ctx, walletId, amount, note)
+	if err != nil {
+		w.metrics.RejectedOperations.WithLabelValues(operationAdjustment, reason(err)).Inc()
+
+		return id, err
+	}
+
+	// Adjustments are signed, the amount counter sums their absolute values
+	w.metrics.Operations.WithLabelValues(operationAdjustment).Inc()
+	w.metrics.OperationsAmount.WithLabelValues(operationAdjustment).Add(math.Abs(float64(amount)))
+
+	return id, nil
+}
+
+func (w *Wallet) Convert(ctx context.Context, quote models.FXQuote) (uuid.UUID, uuid.UUID, error) {
+	debitId, creditId, err := w.Wallet.Convert(ctx, quote)
+	if err != nil {
+		w.metrics.RejectedOperations.WithLabelValues(operationConversion, reason(err)).Inc()
+
+		return debitId, creditId, err
+	}
+
+	// Amounts of different currencies are not summed up, the sell amount is counted once
+	w.metrics.Operations.WithLabelValues(operationConversion).Inc()
+	w.metrics.OperationsAmount.WithLabelValues(operationConversion).Add(float64(quote.SellAmount))
+
+	return debitId, creditId, nil
+}
+
+// operation guards the label against arbitrary client input.
+func operation(operationType string) string {
+	switch operationType {
+	case "DEPOSIT", "WITHDRAW":
+		return operationType
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// reason maps a service error to a low-cardinality label value.
+func reason(err error) string {
+	switch {
+	case errors.Is(err, wallet.ErrWalletNotExists):
+		return "wallet_not_exists"
+	case errors.Is(err, wallet.ErrWalletExists):
+		return "wallet_exists"
+	case errors.Is(err, wallet.ErrLimitExceeded):
+		return "limit_exceeded"
+	case errors.Is(err, wallet.ErrBlocked):
+		return "blocked"
+	case errors.Is(err, wallet.ErrDuplicateReference):
+		return "duplicate"
+	case errors.Is(err, wallet.ErrReferenceConflict):
+		return "reference_conflict"
+	case errors.Is(err, wallet.ErrInvalidDetails):
+		return "invalid"
+	case errors.Is(err, wallet.ErrInvalidAdjustment), errors.Is(err, wallet.ErrInvalidBatch), errors.Is(err, wallet.ErrInvalidOperation):
+		return "invalid"
+	case errors.Is(err, wallet.ErrInvalidCurrency), errors.Is(err, wallet.ErrCurrencyMismatch):
+		return "currency"
+	case errors.Is(err, wallet.ErrConflict):
+		return "conflict"
+	case errors.Is(err, wallet.ErrForbidden), errors.Is(err, wallet.ErrUnauthenticated):
+		return "forbidden"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,86 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// History is what the built-in rules read about past operations of a wallet.
+type History interface {
+	CountTransactions(ctx context.Context, walletId uuid.UUID, operationType string, window time.Duration) (int, error)
+	WalletAge(ctx context.Context, walletId uuid.UUID) (time.Duration, error)
+	AverageAmount(ctx context.Context, walletId uuid.UUID, operationType string, excludeId uuid.UUID) (float64, int, error)
+}
+
+// Velocity triggers on more than MaxCount operations of the type within Window, this one included.
+type Velocity struct {
+	MaxCount int
+	Window   time.Duration
+	History  History
+}
+
+func (r Velocity) Match(ctx context.Context, op Operation) (bool, string, error) {
+	count, err := r.History.CountTransactions(ctx, op.Wallet.Id, op.Type, r.Window)
+	if err != nil {
+		return false, "", err
+	}
+	if count <= r.MaxCount {
+		return false, "", nil
+	}
+
+	return true, fmt.Sprintf("%d operations within %s, at most %d allowed", count, r.Window, r.MaxCount), nil
+}
+
+// NewWallet triggers on operations on a wallet created less than MinAge ago.
+type NewWallet struct {
+	MinAge  time.Duration
+	History History
+}
+
+func (r NewWallet) Match(ctx context.Context, op Operation) (bool, string, error) {
+	age, err := r.History.WalletAge(ctx, op.Wallet.Id)
+	if err != nil {
+		return false, "", err
+	}
+	if age >= r.MinAge {
+		return false, "", nil
+	}
+
+	return true, fmt.Sprintf("wallet created %s ago", age.Round(time.Second)), nil
+}
+
+// AmountSpike triggers on an amount more than Factor times the average of the earlier
+// operations of the type. Wallets with fewer than MinHistory of them are not judged.
+type AmountSpike struct {
+	Factor     float64
+	MinHistory int
+	History    History
+}
+
+func (r AmountSpike) Match(ctx context.Context, op Operation) (bool, string, error) {
+	avg, count, err := r.History.AverageAmount(ctx, op.Wallet.Id, op.Type, op.TransactionId)
+	if err != nil {
+		return false, "", err
+	}
+	if count == 0 || count < r.MinHistory || float64(op.Amount) <= r.Factor*avg {
+		return false, "", nil
+	}
+
+	return true, fmt.Sprintf("amount %d is %.1f times the average %.2f", op.Amount, float64(op.Amount)/avg, avg), nil
+}
+
+// SmallAmount triggers on amounts up to Max, e.g. to allow them before the rules that follow.
+type SmallAmount struct {
+	Max int
+}
+
+func (r SmallAmount) Match(ctx context.Context, op Operation) (bool, string, error) {
+	if op.Amount > r.Max {
+		return false, "", nil
+	}
+
+	return true, fmt.Sprintf("amount %d is at most %d", op.Amount, r.Max), nil
+}
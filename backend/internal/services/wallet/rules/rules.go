@@ -0,0 +1,217 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/storage"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Operation is a deposit or withdrawal being screened. It is already saved
+// within the current transaction, Wallet carries the balance after it.
+type Operation struct {
+	TransactionId uuid.UUID
+	Wallet        models.Wallet
+	Type          string
+	Amount        int
+}
+
+// Rule is a single check of an operation. Custom rules plug in by implementing it.
+type Rule interface {
+	// Match reports whether the rule triggers on the operation and why.
+	Match(ctx context.Context, op Operation) (bool, string, error)
+}
+
+// Configured binds a rule to its name and the action taken when it triggers.
+type Configured struct {
+	Name   string
+	Action models.RuleAction
+	// Operation restricts the rule to DEPOSIT or WITHDRAW, empty means both.
+	Operation string
+	Rule      Rule
+}
+
+// Engine screens operations with rules in their configured order: the first
+// rule that triggers decides, an operation no rule triggers on is allowed.
+type Engine struct {
+	log     *slog.Logger
+	storage DecisionStorage
+	rules   []Configured
+}
+
+type DecisionStorage interface {
+	SaveRuleDecision(ctx context.Context, d models.RuleDecision) error
+	GetRuleDecision(ctx context.Context, decisionId uuid.UUID) (models.RuleDecision, error)
+	ReviewRuleDecision(ctx context.Context, d models.RuleDecision) (models.RuleDecision, error)
+	RuleDecisions(ctx context.Context, status models.ReviewStatus, limit int) ([]models.RuleDecision, error)
+}
+
+var (
+	ErrInvalidStatus     = errors.New("invalid review status")
+	ErrDecisionNotExists = errors.New("flagged operation not exists")
+	ErrAlreadyReviewed   = errors.New("flagged operation is already reviewed")
+	// ErrUnauthenticated means ctx carries no caller, see auth.WithPrincipal.
+	ErrUnauthenticated = errors.New("caller is not authenticated")
+)
+
+// New returns a new instance of the rules Engine.
+func New(log *slog.Logger, storage DecisionStorage, rules []Configured) *Engine {
+	return &Engine{
+		log:     log,
+		storage: storage,
+		rules:   rules,
+	}
+}
+
+// Evaluate implements wallet.RuleEngine. It decides on the operation but does not record the decision.
+func (e *Engine) Evaluate(
+	ctx context.Context,
+	wallet models.Wallet,
+	transactionId uuid.UUID,
+	operationType string,
+	amount int,
+) (models.RuleDecision, error) {
+	const op = "Engine.Evaluate"
+
+	decision := models.RuleDecision{
+		Id:            uuid.New(),
+		TransactionId: transactionId,
+		WalletId:      wallet.Id,
+		OperationType: operationType,
+		Amount:        amount,
+		Action:        models.RuleAllow,
+	}
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		decision.Actor = principal.Subject
+	}
+
+	operation := Operation{TransactionId: transactionId, Wallet: wallet, Type: operationType, Amount: amount}
+	for _, rule := range e.rules {
+		if rule.Operation != "" && rule.Operation != operationType {
+			continue
+		}
+
+		matched, reason, err := rule.Rule.Match(ctx, operation)
+		if err != nil {
+			return models.RuleDecision{}, fmt.Errorf("%s: rule %s: %w", op, rule.Name, err)
+		}
+		if !matched {
+			continue
+		}
+
+		decision.Action, decision.Rule, decision.Reason = rule.Action, rule.Name, reason
+		if decision.Action == models.RuleFlag {
+			decision.ReviewStatus = models.ReviewPending
+		}
+
+		break
+	}
+
+	return decision, nil
+}
+
+// Record implements wallet.RuleEngine.
+func (e *Engine) Record(ctx context.Context, decision models.RuleDecision) error {
+	const op = "Engine.Record"
+
+	if err := e.storage.SaveRuleDecision(ctx, decision); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if decision.Rule != "" {
+		e.log.Info("operation matched rule",
+			slog.String("op", op),
+			sl.TraceId(ctx),
+			slog.String("transactionId", decision.TransactionId.String()),
+			slog.String("walletId", decision.WalletId.String()),
+			slog.String("rule", decision.Rule),
+			slog.String("action", string(decision.Action)),
+			slog.String("reason", decision.Reason),
+		)
+	}
+
+	return nil
+}
+
+// Reviews returns flagged operations of the given review status, all of them for an empty status, oldest first.
+func (e *Engine) Reviews(ctx context.Context, status models.ReviewStatus, limit int) ([]models.RuleDecision, error) {
+	const op = "Engine.Reviews"
+
+	if status != "" && !status.Valid() {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidStatus)
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	decisions, err := e.storage.RuleDecisions(ctx, status, min(limit, MaxLimit))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return decisions, nil
+}
+
+// Review closes the review of a flagged operation as cleared or fraud on behalf of the caller.
+func (e *Engine) Review(ctx context.Context, decisionId uuid.UUID, status models.ReviewStatus, comment string) (models.RuleDecision, error) {
+	const op = "Engine.Review"
+
+	log := e.log.With(
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("decisionId", decisionId.String()),
+	)
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return models.RuleDecision{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+	if status != models.ReviewCleared && status != models.ReviewFraud {
+		return models.RuleDecision{}, fmt.Errorf("%s: %w", op, ErrInvalidStatus)
+	}
+
+	reviewed, err := e.storage.ReviewRuleDecision(ctx, models.RuleDecision{
+		Id:            decisionId,
+		ReviewStatus:  status,
+		ReviewedBy:    principal.Subject,
+		ReviewComment: strings.TrimSpace(comment),
+	})
+	if errors.Is(err, storage.ErrRuleDecisionNotExists) {
+		// Either there is no such flagged operation or it is no longer pending
+		d, err := e.storage.GetRuleDecision(ctx, decisionId)
+		if err == nil && d.ReviewStatus != "" {
+			return models.RuleDecision{}, fmt.Errorf("%s: %w", op, ErrAlreadyReviewed)
+		}
+		if err != nil && !errors.Is(err, storage.ErrRuleDecisionNotExists) {
+			return models.RuleDecision{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return models.RuleDecision{}, fmt.Errorf("%s: %w", op, ErrDecisionNotExists)
+	}
+	if err != nil {
+		log.Error("failed to review flagged operation", sl.Err(err))
+
+		return models.RuleDecision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("flagged operation reviewed",
+		slog.String("status", string(status)),
+		slog.String("reviewedBy", principal.Subject),
+		slog.String("rule", reviewed.Rule),
+	)
+
+	return reviewed, nil
+}
@@ -0,0 +1,298 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/storage"
+)
+
+// stubRule triggers as told and records it was asked in calls.
+type stubRule struct {
+	name    string
+	matched bool
+	err     error
+	calls   *[]string
+}
+
+func (r stubRule) Match(context.Context, Operation) (bool, string, error) {
+	*r.calls = append(*r.calls, r.name)
+
+	return r.matched, r.name + " matched", r.err
+}
+
+// memHistory answers the built-in rules with fixed figures.
+type memHistory struct {
+	count   int
+	age     time.Duration
+	average float64
+	earlier int
+}
+
+func (h memHistory) CountTransactions(context.Context, uuid.UUID, string, time.Duration) (int, error) {
+	return h.count, nil
+}
+
+func (h memHistory) WalletAge(context.Context, uuid.UUID) (time.Duration, error) {
+	return h.age, nil
+}
+
+func (h memHistory) AverageAmount(context.Context, uuid.UUID, string, uuid.UUID) (float64, int, error) {
+	return h.average, h.earlier, nil
+}
+
+// memDecisions keeps the decisions by id.
+type memDecisions struct {
+	decisions map[uuid.UUID]models.RuleDecision
+}
+
+func (m *memDecisions) SaveRuleDecision(_ context.Context, d models.RuleDecision) error {
+	m.decisions[d.Id] = d
+
+	return nil
+}
+
+func (m *memDecisions) GetRuleDecision(_ context.Context, decisionId uuid.UUID) (models.RuleDecision, error) {
+	d, ok := m.decisions[decisionId]
+	if !ok {
+		return models.RuleDecision{}, storage.ErrRuleDecisionNotExists
+	}
+
+	return d, nil
+}
+
+func (m *memDecisions) ReviewRuleDecision(_ context.Context, review models.RuleDecision) (models.RuleDecision, error) {
+	d, ok := m.decisions[review.Id]
+	if !ok || d.ReviewStatus != models.ReviewPending {
+		return models.RuleDecision{}, storage.ErrRuleDecisionNotExists
+	}
+	now := time.Now()
+	d.ReviewStatus, d.ReviewedBy, d.ReviewComment, d.ReviewedAt = review.ReviewStatus, review.ReviewedBy, review.ReviewComment, &now
+	m.decisions[d.Id] = d
+
+	return d, nil
+}
+
+func (m *memDecisions) RuleDecisions(context.Context, models.ReviewStatus, int) ([]models.RuleDecision, error) {
+	return nil, nil
+}
+
+func newEngine(rules []Configured) (*Engine, *memDecisions) {
+	decisions := &memDecisions{decisions: make(map[uuid.UUID]models.RuleDecision)}
+
+	return New(slog.New(slog.NewTextHandler(io.Discard, nil)), decisions, rules), decisions
+}
+
+func TestEvaluate(t *testing.T) {
+	type rule struct {
+		name      string
+		action    models.RuleAction
+		operation string
+		matched   bool
+		err       error
+	}
+
+	tests := []struct {
+		name          string
+		rules         []rule
+		operationType string
+		wantAction    models.RuleAction
+		wantRule      string
+		wantStatus    models.ReviewStatus
+		// wantCalls are the rules asked, in order
+		wantCalls []string
+		wantErr   bool
+	}{
+		{
+			name:          "no rules",
+			operationType: "DEPOSIT",
+			wantAction:    models.RuleAllow,
+		},
+		{
+			name:          "no rule triggers",
+			rules:         []rule{{name: "a", action: models.RuleBlock}, {name: "b", action: models.RuleFlag}},
+			operationType: "DEPOSIT",
+			wantAction:    models.RuleAllow,
+			wantCalls:     []string{"a", "b"},
+		},
+		{
+			name:          "first trigger decides",
+			rules:         []rule{{name: "a", action: models.RuleFlag, matched: true}, {name: "b", action: models.RuleBlock, matched: true}},
+			operationType: "DEPOSIT",
+			wantAction:    models.RuleFlag,
+			wantRule:      "a",
+			wantStatus:    models.ReviewPending,
+			wantCalls:     []string{"a"},
+		},
+		{
+			name:          "allow before block",
+			rules:         []rule{{name: "small", action: models.RuleAllow, matched: true}, {name: "block", action: models.RuleBlock, matched: true}},
+			operationType: "WITHDRAW",
+			wantAction:    models.RuleAllow,
+			wantRule:      "small",
+			wantCalls:     []string{"small"},
+		},
+		{
+			name:          "later rule triggers",
+			rules:         []rule{{name: "a", action: models.RuleFlag}, {name: "b", action: models.RuleBlock, matched: true}, {name: "c", action: models.RuleFlag, matched: true}},
+			operationType: "WITHDRAW",
+			wantAction:    models.RuleBlock,
+			wantRule:      "b",
+			wantCalls:     []string{"a", "b"},
+		},
+		{
+			name:          "rule of another operation skipped",
+			rules:         []rule{{name: "deposits", action: models.RuleBlock, operation: "DEPOSIT", matched: true}, {name: "any", action: models.RuleFlag, matched: true}},
+			operationType: "WITHDRAW",
+			wantAction:    models.RuleFlag,
+			wantRule:      "any",
+			wantStatus:    models.ReviewPending,
+			wantCalls:     []string{"any"},
+		},
+		{
+			name:          "rule error stops the evaluation",
+			rules:         []rule{{name: "a", action: models.RuleFlag, err: errors.New("connection refused")}, {name: "b", action: models.RuleBlock, matched: true}},
+			operationType: "DEPOSIT",
+			wantCalls:     []string{"a"},
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			var configured []Configured
+			for _, r := range tt.rules {
+				configured = append(configured, Configured{
+					Name:      r.name,
+					Action:    r.action,
+					Operation: r.operation,
+					Rule:      stubRule{name: r.name, matched: r.matched, err: r.err, calls: &calls},
+				})
+			}
+			e, decisions := newEngine(configured)
+
+			wallet := models.Wallet{Id: uuid.New(), Balance: 1000}
+			transactionId := uuid.New()
+			ctx := auth.WithPrincipal(context.Background(), auth.Principal{Subject: "apikey:billing", Service: true})
+
+			d, err := e.Evaluate(ctx, wallet, transactionId, tt.operationType, 100)
+			if !slices.Equal(calls, tt.wantCalls) {
+				t.Errorf("rules asked = %v, want %v", calls, tt.wantCalls)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Evaluate() error = nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if d.Action != tt.wantAction || d.Rule != tt.wantRule || d.ReviewStatus != tt.wantStatus {
+				t.Errorf("Evaluate() = %s by %q with review %q, want %s by %q with review %q",
+					d.Action, d.Rule, d.ReviewStatus, tt.wantAction, tt.wantRule, tt.wantStatus)
+			}
+			if d.TransactionId != transactionId || d.WalletId != wallet.Id || d.Amount != 100 || d.Actor != "apikey:billing" {
+				t.Errorf("Evaluate() = %+v, want the operation and its caller", d)
+			}
+			if len(decisions.decisions) != 0 {
+				t.Errorf("Evaluate() recorded the decision")
+			}
+		})
+	}
+}
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		amount  int
+		matched bool
+	}{
+		{name: "velocity at the limit", rule: Velocity{MaxCount: 3, Window: time.Minute, History: memHistory{count: 3}}},
+		{name: "velocity over the limit", rule: Velocity{MaxCount: 3, Window: time.Minute, History: memHistory{count: 4}}, matched: true},
+		{name: "new wallet", rule: NewWallet{MinAge: time.Hour, History: memHistory{age: time.Minute}}, matched: true},
+		{name: "old wallet", rule: NewWallet{MinAge: time.Hour, History: memHistory{age: time.Hour}}},
+		{name: "spike", rule: AmountSpike{Factor: 5, MinHistory: 3, History: memHistory{average: 100, earlier: 3}}, amount: 501, matched: true},
+		{name: "spike at the factor", rule: AmountSpike{Factor: 5, MinHistory: 3, History: memHistory{average: 100, earlier: 3}}, amount: 500},
+		{name: "spike with short history", rule: AmountSpike{Factor: 5, MinHistory: 3, History: memHistory{average: 100, earlier: 2}}, amount: 10000},
+		{name: "spike without history", rule: AmountSpike{Factor: 5, History: memHistory{}}, amount: 10000},
+		{name: "small amount", rule: SmallAmount{Max: 100}, amount: 100, matched: true},
+		{name: "large amount", rule: SmallAmount{Max: 100}, amount: 101},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, reason, err := tt.rule.Match(context.Background(), Operation{Wallet: models.Wallet{Id: uuid.New()}, Type: "DEPOSIT", Amount: tt.amount})
+			if err != nil {
+				t.Fatalf("Match() error = %v", err)
+			}
+			if matched != tt.matched {
+				t.Errorf("Match() = %v, want %v", matched, tt.matched)
+			}
+			if matched && reason == "" {
+				t.Errorf("Match() gives no reason")
+			}
+		})
+	}
+}
+
+func TestReview(t *testing.T) {
+	reviewer := auth.WithPrincipal(context.Background(), auth.Principal{Subject: "admin-1", Scopes: []string{auth.ScopeAdmin}})
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		stored  models.ReviewStatus
+		missing bool
+		status  models.ReviewStatus
+		wantErr error
+	}{
+		{name: "cleared", ctx: reviewer, stored: models.ReviewPending, status: models.ReviewCleared},
+		{name: "fraud", ctx: reviewer, stored: models.ReviewPending, status: models.ReviewFraud},
+		{name: "back to pending", ctx: reviewer, stored: models.ReviewPending, status: models.ReviewPending, wantErr: ErrInvalidStatus},
+		{name: "already reviewed", ctx: reviewer, stored: models.ReviewCleared, status: models.ReviewFraud, wantErr: ErrAlreadyReviewed},
+		{name: "allowed operation", ctx: reviewer, status: models.ReviewCleared, wantErr: ErrDecisionNotExists},
+		{name: "unknown", ctx: reviewer, missing: true, status: models.ReviewCleared, wantErr: ErrDecisionNotExists},
+		{name: "anonymous", ctx: context.Background(), stored: models.ReviewPending, status: models.ReviewCleared, wantErr: ErrUnauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, decisions := newEngine(nil)
+			d := models.RuleDecision{Id: uuid.New(), Action: models.RuleFlag, Rule: "velocity", ReviewStatus: tt.stored}
+			if !tt.missing {
+				decisions.decisions[d.Id] = d
+			}
+
+			reviewed, err := e.Review(tt.ctx, d.Id, tt.status, "  checked with the customer ")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Review() error = %v, want %v", err, tt.wantErr)
+				}
+				if !tt.missing && decisions.decisions[d.Id].ReviewStatus != tt.stored {
+					t.Errorf("review status changed to %q", decisions.decisions[d.Id].ReviewStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Review() error = %v", err)
+			}
+
+			if reviewed.ReviewStatus != tt.status || reviewed.ReviewedBy != "admin-1" || reviewed.ReviewComment != "checked with the customer" {
+				t.Errorf("Review() = %+v, want %s by admin-1", reviewed, tt.status)
+			}
+		})
+	}
+}
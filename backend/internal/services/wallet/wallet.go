@@ -1,14 +1,24 @@
 package wallet
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
 	"coin-app/internal/lib/logger/sl"
 	"coin-app/internal/storage"
 )
@@ -17,6 +27,13 @@ type Wallet struct {
 	log              *slog.Logger
 	walletSaver      WalletSaver
 	transactionSaver TransactionSaver
+	txManager        TxManager
+	eventSaver       EventSaver
+	walletObserver   WalletObserver
+	walletReader     WalletReader
+	limitChecker     LimitChecker
+	ruleEngine       RuleEngine
+	feeCalculator    FeeCalculator
 }
 
 type WalletSaver interface {
@@ -25,16 +42,29 @@ type WalletSaver interface {
 		walletId uuid.UUID,
 		userId uuid.UUID,
 		balance int,
+		currency string,
 	) (id uuid.UUID, err error)
 	UpdateBalance(
 		ctx context.Context,
 		walletId uuid.UUID,
 		amount int,
-	) error
+	) (wallet models.Wallet, err error)
+	UpdateBalanceDecimal(
+		ctx context.Context,
+		walletId uuid.UUID,
+		amount string,
+	) (wallet models.Wallet, err error)
 	GetWallet(
 		ctx context.Context,
 		walletId uuid.UUID,
 	) (wallet models.Wallet, err error)
+	// LockWallets locks the wallets in the order of their ids, missing ones are skipped.
+	LockWallets(ctx context.Context, walletIds ...uuid.UUID) error
+	// UpdateBalances changes several balances at once, missing wallets are left out of the result.
+	UpdateBalances(
+		ctx context.Context,
+		deltas map[uuid.UUID]int,
+	) (wallets map[uuid.UUID]models.Wallet, err error)
 }
 
 type TransactionSaver interface {
@@ -44,34 +74,209 @@ type TransactionSaver interface {
 		walletId uuid.UUID,
 		operationType string,
 		amount int,
+		details models.TransactionDetails,
 	) (id uuid.UUID, err error)
+	TransactionByReference(
+		ctx context.Context,
+		walletId uuid.UUID,
+		reference string,
+	) (transaction models.Transaction, err error)
+	SaveAdjustment(
+		ctx context.Context,
+		transactionId uuid.UUID,
+		walletId uuid.UUID,
+		amount int,
+		reason string,
+	) (id uuid.UUID, err error)
+	SaveConversionTransaction(
+		ctx context.Context,
+		transactionId uuid.UUID,
+		walletId uuid.UUID,
+		operationType string,
+		amount string,
+		quote models.FXQuote,
+	) (id uuid.UUID, err error)
+	SaveFee(
+		ctx context.Context,
+		transactionId uuid.UUID,
+		walletId uuid.UUID,
+		amount int,
+		feeFor uuid.UUID,
+	) (id uuid.UUID, err error)
+	SaveTransactions(ctx context.Context, transactions []models.Transaction) error
+	// AccrueFees posts FEE entries crediting revenue wallets without updating their balances,
+	// the accruals are settled into the balances later, see fees.Settler.
+	AccrueFees(ctx context.Context, fees []models.Transaction) error
+	TransactionsByReferences(
+		ctx context.Context,
+		walletIds []uuid.UUID,
+		refs []string,
+	) (transactions []models.Transaction, err error)
+}
+
+// TxManager runs fn in a single database transaction.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// WithoutTx detaches ctx from its transaction, so a write survives its rollback.
+	WithoutTx(ctx context.Context) context.Context
+	// AfterCommit runs fn once the outermost transaction of ctx commits, right away outside of one.
+	AfterCommit(ctx context.Context, fn func())
+}
+
+// EventSaver writes domain events to the outbox.
+type EventSaver interface {
+	SaveEvent(ctx context.Context, event models.Event) error
+	SaveEvents(ctx context.Context, events []models.Event) error
+}
+
+// WalletReader serves balance reads, e.g. through a cache.
+type WalletReader interface {
+	GetWallet(ctx context.Context, walletId uuid.UUID) (models.Wallet, error)
+}
+
+// WalletObserver is notified about committed balance changes,
+// e.g. to keep a read cache up to date. When an operation runs within a caller's transaction,
+// it is only notified after that transaction commits, see TxManager.AfterCommit.
+type WalletObserver interface {
+	WalletChanged(wallet models.Wallet)
+}
+
+// LimitChecker enforces the product limits of a wallet. It is called within the transaction,
+// after the balance has been changed and the wallet row locked.
+// A violation is reported as a *LimitError.
+type LimitChecker interface {
+	CheckLimits(ctx context.Context, wallet models.Wallet, operationType string, amount int) error
 }
 
+// RuleEngine screens an operation for fraud within the transaction, after the limits.
+// A blocked operation is rolled back and only its decision is recorded.
+type RuleEngine interface {
+	Evaluate(
+		ctx context.Context,
+		wallet models.Wallet,
+		transactionId uuid.UUID,
+		operationType string,
+		amount int,
+	) (models.RuleDecision, error)
+	Record(ctx context.Context, decision models.RuleDecision) error
+}
+
+// FeeCalculator prices a deposit or withdrawal within the transaction, after the balance has been changed.
+// A zero fee is not posted.
+type FeeCalculator interface {
+	Fee(ctx context.Context, wallet models.Wallet, operationType string, amount int) (models.Fee, error)
+}
+
+var tracer = otel.Tracer("coin-app/internal/services/wallet")
+
 var (
 	ErrWalletExists    = errors.New("wallet already exists")
 	ErrWalletNotExists = errors.New("wallet not exists")
+	// ErrConflict means concurrent operations kept conflicting and the caller may try again.
+	ErrConflict = errors.New("operation conflicted with concurrent updates")
+	// ErrInvalidOperation means an operation type other than DEPOSIT or WITHDRAW or an amount that is not positive.
+	ErrInvalidOperation = errors.New("operation must be DEPOSIT or WITHDRAW with a positive amount")
+	// ErrUnauthenticated means ctx carries no caller, see auth.WithPrincipal.
+	ErrUnauthenticated = errors.New("caller is not authenticated")
+	// ErrForbidden means the wallet belongs to another user and the caller is not an admin,
+	// or the caller lacks the scope for the operation.
+	ErrForbidden = errors.New("caller may not act on the wallet")
+	// ErrInvalidAdjustment means a zero amount or an empty reason.
+	ErrInvalidAdjustment = errors.New("adjustment needs a non-zero amount and a reason")
+	// ErrLimitExceeded means the operation breaks a wallet limit, see LimitError for which one.
+	ErrLimitExceeded = errors.New("wallet limit exceeded")
+	// ErrBlocked means a fraud rule blocked the operation.
+	ErrBlocked = errors.New("operation blocked by rule")
+	// ErrInvalidDetails means a description, metadata or reference over the limits or malformed.
+	ErrInvalidDetails = errors.New("invalid transaction details")
+	// ErrDuplicateReference comes with the ID of the transaction posted earlier with the same reference,
+	// nothing has been posted again.
+	ErrDuplicateReference = errors.New("transaction with this reference already posted")
+	// ErrReferenceConflict means the reference was used for a different operation or amount.
+	ErrReferenceConflict    = errors.New("reference already used for another operation")
+	ErrTransactionNotExists = errors.New("transaction not exists")
+	// ErrInvalidCurrency means a currency that is not a three-letter ISO 4217 code.
+	ErrInvalidCurrency = errors.New("invalid currency")
+	// ErrCurrencyMismatch means a conversion quoted for other currencies than the wallets hold.
+	ErrCurrencyMismatch = errors.New("wallet currency does not match the quote")
+)
+
+// Limits of the transaction details.
+const (
+	MaxDescriptionLength = 500
+	MaxMetadataBytes     = 4096
+	MaxReferenceLength   = 128
+)
+
+// Limit names reported in LimitError.
+const (
+	LimitMaxBalance        = "max_balance"
+	LimitMinAmount         = "min_amount"
+	LimitMaxAmount         = "max_amount"
+	LimitDailyWithdrawal   = "daily_withdrawal"
+	LimitMonthlyWithdrawal = "monthly_withdrawal"
 )
 
+// LimitError says which limit an operation has hit. It matches ErrLimitExceeded.
+type LimitError struct {
+	Limit string
+	// Allowed is the limit value, Actual what the operation would have made of it.
+	Allowed int
+	Actual  float64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s is %d, operation makes it %v", ErrLimitExceeded, e.Limit, e.Allowed, e.Actual)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
 // New returns a new instance of the Wallet service.
+// walletObserver, limitChecker, ruleEngine and feeCalculator may be nil, walletReader defaults to walletSaver.
 func New(
 	log *slog.Logger,
 	walletSaver WalletSaver,
 	transactionSaver TransactionSaver,
+	txManager TxManager,
+	eventSaver EventSaver,
+	walletObserver WalletObserver,
+	walletReader WalletReader,
+	limitChecker LimitChecker,
+	ruleEngine RuleEngine,
+	feeCalculator FeeCalculator,
 ) *Wallet {
+	if walletReader == nil {
+		walletReader = walletSaver
+	}
+
 	return &Wallet{
 		log:              log,
 		walletSaver:      walletSaver,
 		transactionSaver: transactionSaver,
+		txManager:        txManager,
+		eventSaver:       eventSaver,
+		walletObserver:   walletObserver,
+		walletReader:     walletReader,
+		limitChecker:     limitChecker,
+		ruleEngine:       ruleEngine,
+		feeCalculator:    feeCalculator,
 	}
 }
 
-func (w *Wallet) SaveWallet(ctx context.Context, userId uuid.UUID, balance int) (uuid.UUID, error) {
+// SaveWallet opens a wallet in the currency, models.DefaultCurrency if it is empty.
+func (w *Wallet) SaveWallet(ctx context.Context, userId uuid.UUID, balance int, currency string) (uuid.UUID, error) {
 	const op = "Wallet.SaveWallet"
 
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
 	walletId := uuid.New()
 
 	log := w.log.With(
 		slog.String("op", op),
+		sl.TraceId(ctx),
 		slog.String("walletId", walletId.String()),
 		slog.String("userId", userId.String()),
 		slog.Int("balance", balance),
@@ -79,14 +284,55 @@ func (w *Wallet) SaveWallet(ctx context.Context, userId uuid.UUID, balance int)
 
 	log.Info("creating new wallet")
 
-	id, err := w.walletSaver.SaveWallet(ctx, walletId, userId, balance)
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	if !validCurrency(currency) {
+		return uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrInvalidCurrency)
+	}
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		log.Warn("caller is not authenticated")
+
+		return uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+	if !principal.CanAccess(userId) {
+		log.Warn("wallet for another user denied", slog.String("caller", principal.Subject))
+
+		return uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	var id uuid.UUID
+	err := w.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+
+		id, err = w.walletSaver.SaveWallet(ctx, walletId, userId, balance, currency)
+		if err != nil {
+			return err
+		}
+
+		return w.saveEvent(ctx, walletId, models.EventWalletCreated, models.WalletCreatedPayload{
+			WalletId: walletId,
+			UserId:   userId,
+			Balance:  balance,
+			Currency: currency,
+		})
+	})
 	if err != nil {
 		if errors.Is(err, storage.ErrWalletExists) {
 			log.Warn("wallet already exists", sl.Err(err))
 
 			return uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrWalletExists)
 		}
+		if errors.Is(err, storage.ErrConflict) {
+			log.Warn("wallet creation conflicts after retries", sl.Err(err))
+
+			return uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrConflict)
+		}
 		log.Error("failed to save wallet", sl.Err(err))
+		failSpan(span, err)
 
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
 	}
@@ -95,15 +341,39 @@ func (w *Wallet) SaveWallet(ctx context.Context, userId uuid.UUID, balance int)
 	return id, nil
 }
 
-// SaveTransaction adds deposit or withdraw in the wallet.
+// SaveTransaction adds deposit or withdraw in the wallet, see Post.
+func (w *Wallet) SaveTransaction(
+	ctx context.Context,
+	walletId uuid.UUID,
+	operationType string,
+	amount int,
+	details models.TransactionDetails,
+) (uuid.UUID, error) {
+	receipt, err := w.Post(ctx, walletId, operationType, amount, details)
+
+	return receipt.TransactionId, err
+}
+
+// Post adds deposit or withdraw in the wallet and charges its fee.
 // If wallet with given uuid not exists, returns error.
-func (w *Wallet) SaveTransaction(ctx context.Context, walletId uuid.UUID, operationType string, amount int) (uuid.UUID, error) {
-	const op = "Wallet.SaveTransaction"
+// A reference already posted on the wallet returns the receipt of the original transaction with ErrDuplicateReference.
+func (w *Wallet) Post(
+	ctx context.Context,
+	walletId uuid.UUID,
+	operationType string,
+	amount int,
+	details models.TransactionDetails,
+) (models.Receipt, error) {
+	const op = "Wallet.Post"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
 
 	transactionId := uuid.New()
 
 	log := w.log.With(
 		slog.String("op", op),
+		sl.TraceId(ctx),
 		slog.String("transactionId", transactionId.String()),
 		slog.String("walletId", walletId.String()),
 		slog.String("operationType", string(operationType)),
@@ -112,46 +382,450 @@ func (w *Wallet) SaveTransaction(ctx context.Context, walletId uuid.UUID, operat
 
 	log.Info("depositing money")
 
-	id, err := w.transactionSaver.SaveTransaction(ctx, transactionId, walletId, operationType, amount)
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		log.Warn("caller is not authenticated")
+
+		return models.Receipt{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+
+	// The scope and limits only hold for the operation they are checked for,
+	// a negative deposit would be a withdrawal and a negative withdrawal would lower the withdrawal totals
+	if (operationType != "DEPOSIT" && operationType != "WITHDRAW") || amount <= 0 {
+		log.Warn("invalid operation")
+
+		return models.Receipt{}, fmt.Errorf("%s: %w", op, ErrInvalidOperation)
+	}
+
+	details, err := normalizeDetails(details)
+	if err != nil {
+		return models.Receipt{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if details.ExternalReference != "" {
+		log = log.With(slog.String("externalReference", details.ExternalReference))
+	}
+
+	var eventType models.EventType
+	var delta int
+	var scope string
+	switch operationType {
+	case "DEPOSIT":
+		eventType, delta, scope = models.EventFundsDeposited, amount, auth.ScopeDeposit
+	case "WITHDRAW":
+		eventType, delta, scope = models.EventFundsWithdrawn, -amount, auth.ScopeWithdraw
+	}
+
+	// The route admits both scopes, the operation needs its own one
+	if !principal.HasScope(scope) {
+		log.Warn("operation outside of caller scopes", slog.String("caller", principal.Subject))
+
+		return models.Receipt{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	var id uuid.UUID
+	var wallet models.Wallet
+	var fee models.Fee
+	var feeId *uuid.UUID
+	var decision models.RuleDecision
+	var original models.Transaction
+	err = w.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+
+		if details.ExternalReference != "" {
+			original, err = w.transactionSaver.TransactionByReference(ctx, walletId, details.ExternalReference)
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, storage.ErrTransactionNotExists) {
+				return err
+			}
+		}
+
+		id, err = w.transactionSaver.SaveTransaction(ctx, transactionId, walletId, operationType, amount, details)
+		if err != nil {
+			return err
+		}
+
+		wallet, err = w.walletSaver.UpdateBalance(ctx, walletId, delta)
+		if err != nil {
+			return fmt.Errorf("failed to update wallet balance: %w", err)
+		}
+
+		// The owner comes with the updated row; a foreign wallet rolls the whole transaction back
+		if !principal.CanAccess(wallet.UserId) {
+			return ErrForbidden
+		}
+
+		if w.feeCalculator != nil {
+			fee, err = w.feeCalculator.Fee(ctx, wallet, operationType, amount)
+			if err != nil {
+				return fmt.Errorf("failed to price fee: %w", err)
+			}
+			if fee.Amount > 0 {
+				wallet, feeId, err = w.chargeFee(ctx, wallet, operationType, transactionId, fee)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// The row stays locked until commit, so concurrent operations see each other's totals
+		if w.limitChecker != nil {
+			if err := w.limitChecker.CheckLimits(ctx, wallet, operationType, amount); err != nil {
+				return err
+			}
+		}
+
+		if w.ruleEngine != nil {
+			decision, err = w.ruleEngine.Evaluate(ctx, wallet, transactionId, operationType, amount)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate rules: %w", err)
+			}
+			if decision.Action == models.RuleBlock {
+				return ErrBlocked
+			}
+
+			// Allowed and flagged decisions commit together with the operation
+			if err := w.ruleEngine.Record(ctx, decision); err != nil {
+				return fmt.Errorf("failed to record rule decision: %w", err)
+			}
+		}
+
+		return w.saveEvent(ctx, walletId, eventType, models.FundsMovedPayload{
+			TransactionId:      transactionId,
+			WalletId:           walletId,
+			OperationType:      operationType,
+			Amount:             amount,
+			TransactionDetails: details,
+			Fee:                fee.Amount,
+			FeeTransactionId:   feeId,
+		})
+	})
+	// The original is read with the caller's transaction unless the insert has failed
+	dupCtx := ctx
+	if errors.Is(err, storage.ErrTransactionExists) {
+		// A concurrent request with the same reference has committed first. Within a caller's
+		// transaction, e.g. a scheduled run, that transaction is aborted by the failed insert,
+		// so the committed original is read outside of it
+		dupCtx = w.txManager.WithoutTx(ctx)
+		original, err = w.transactionSaver.TransactionByReference(dupCtx, walletId, details.ExternalReference)
+	}
 	if err != nil {
 		if errors.Is(err, storage.ErrWalletNotExists) {
 			log.Warn("wallet not exists", sl.Err(err))
 
-			return uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrWalletNotExists)
+			return models.Receipt{}, fmt.Errorf("%s: %w", op, ErrWalletNotExists)
+		}
+		if errors.Is(err, ErrForbidden) {
+			log.Warn("transaction on another user's wallet denied", slog.String("caller", principal.Subject))
+
+			return models.Receipt{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+		}
+		if errors.Is(err, ErrLimitExceeded) {
+			log.Warn("transaction exceeds wallet limit", sl.Err(err))
+
+			return models.Receipt{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if errors.Is(err, ErrBlocked) {
+			log.Warn("transaction blocked by rule", slog.String("rule", decision.Rule), slog.String("reason", decision.Reason))
+
+			// The transaction has been rolled back, the decision is kept on its own,
+			// even when Post ran within a caller's transaction such as a scheduled run
+			if err := w.ruleEngine.Record(w.txManager.WithoutTx(ctx), decision); err != nil {
+				log.Error("failed to record rule decision", sl.Err(err))
+			}
+
+			return models.Receipt{}, fmt.Errorf("%s: %w: %s", op, ErrBlocked, decision.Rule)
+		}
+		if errors.Is(err, storage.ErrConflict) {
+			log.Warn("transaction conflicts after retries", sl.Err(err))
+
+			return models.Receipt{}, fmt.Errorf("%s: %w", op, ErrConflict)
 		}
 		log.Error("failed to save transaction", sl.Err(err))
+		failSpan(span, err)
 
-		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
+		return models.Receipt{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if operationType == "DEPOSIT" {
-		err = w.walletSaver.UpdateBalance(ctx, walletId, amount)
-	} else if operationType == "WITHDRAW" {
-		err = w.walletSaver.UpdateBalance(ctx, walletId, -amount)
+	if original.Id != uuid.Nil {
+		return w.duplicate(dupCtx, log, principal, original, operationType, amount)
+	}
+
+	w.walletsChanged(ctx, wallet)
+
+	if decision.Action == models.RuleFlag {
+		log.Warn("transaction flagged for review", slog.String("rule", decision.Rule), slog.String("reason", decision.Reason))
+	}
+	if feeId != nil {
+		log = log.With(slog.Int("fee", fee.Amount), slog.String("feeSchedule", fee.Schedule))
+	}
+
+	log.Info("transaction saved successfully")
+	return models.Receipt{
+		TransactionId:    id,
+		FeeTransactionId: feeId,
+		Gross:            amount,
+		Fee:              fee.Amount,
+		Net:              amount - fee.Amount,
+	}, nil
+}
+
+// Adjust posts a manual ADJUSTMENT of the signed amount with its reason and changes the balance.
+// Only admins may adjust, the maker-checker approval is up to the caller.
+// Within an outer transaction the adjustment commits or rolls back with it.
+func (w *Wallet) Adjust(ctx context.Context, walletId uuid.UUID, amount int, reason string) (uuid.UUID, error) {
+	const op = "Wallet.Adjust"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	transactionId := uuid.New()
+
+	log := w.log.With(
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("transactionId", transactionId.String()),
+		slog.String("walletId", walletId.String()),
+		slog.Int("amount", amount),
+	)
+
+	log.Info("adjusting balance")
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		log.Warn("caller is not authenticated")
+
+		return uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+	if !principal.IsAdmin() {
+		log.Warn("adjustment by non-admin denied", slog.String("caller", principal.Subject))
+
+		return uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrForbidden)
 	}
+	if amount == 0 || reason == "" {
+		return uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrInvalidAdjustment)
+	}
+
+	var id uuid.UUID
+	var wallet models.Wallet
+	err := w.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+
+		id, err = w.transactionSaver.SaveAdjustment(ctx, transactionId, walletId, amount, reason)
+		if err != nil {
+			return err
+		}
+
+		wallet, err = w.walletSaver.UpdateBalance(ctx, walletId, amount)
+		if err != nil {
+			return fmt.Errorf("failed to update wallet balance: %w", err)
+		}
 
+		return w.saveEvent(ctx, walletId, models.EventFundsAdjusted, models.FundsAdjustedPayload{
+			TransactionId: transactionId,
+			WalletId:      walletId,
+			Amount:        amount,
+			Reason:        reason,
+		})
+	})
 	if err != nil {
-		log.Error("failed to update wallet balance", sl.Err(err))
+		if errors.Is(err, storage.ErrWalletNotExists) {
+			log.Warn("wallet not exists", sl.Err(err))
+
+			return uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrWalletNotExists)
+		}
+		if errors.Is(err, storage.ErrConflict) {
+			log.Warn("adjustment conflicts after retries", sl.Err(err))
+
+			return uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrConflict)
+		}
+		log.Error("failed to save adjustment", sl.Err(err))
+		failSpan(span, err)
+
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	log.Info("transaction saved successfully")
+	w.walletsChanged(ctx, wallet)
+
+	log.Info("balance adjusted")
 	return id, nil
 }
 
+// Convert posts an executed quote: the sell amount is withdrawn from one wallet and the buy amount
+// deposited to the other, both legs carry the quote, its rate and spread. The caller needs access
+// to both wallets with the withdraw and deposit scopes, the limits of both wallets apply.
+// Within an outer transaction the conversion commits or rolls back with it.
+func (w *Wallet) Convert(ctx context.Context, quote models.FXQuote) (uuid.UUID, uuid.UUID, error) {
+	const op = "Wallet.Convert"
+
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
+	debitId, creditId := uuid.New(), uuid.New()
+
+	log := w.log.With(
+		slog.String("op", op),
+		sl.TraceId(ctx),
+		slog.String("quoteId", quote.Id.String()),
+		slog.String("fromWalletId", quote.FromWalletId.String()),
+		slog.String("toWalletId", quote.ToWalletId.String()),
+		slog.Int("sellAmount", quote.SellAmount),
+		slog.String("buyAmount", quote.BuyAmount),
+	)
+
+	log.Info("converting money")
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		log.Warn("caller is not authenticated")
+
+		return uuid.UUID{}, uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+	if !principal.HasScope(auth.ScopeWithdraw) || !principal.HasScope(auth.ScopeDeposit) {
+		log.Warn("conversion outside of caller scopes", slog.String("caller", principal.Subject))
+
+		return uuid.UUID{}, uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	// Per-operation limits are whole units, a fractional buy amount counts as the next one
+	buyAmount, err := strconv.ParseFloat(quote.BuyAmount, 64)
+	if err != nil {
+		return uuid.UUID{}, uuid.UUID{}, fmt.Errorf("%s: invalid buy amount: %w", op, err)
+	}
+
+	var from, to models.Wallet
+	err = w.txManager.WithinTx(ctx, func(ctx context.Context) error {
+		// Converting A to B and B to A at once would otherwise lock the wallets in opposite orders
+		if err := w.walletSaver.LockWallets(ctx, quote.FromWalletId, quote.ToWalletId); err != nil {
+			return err
+		}
+
+		var err error
+
+		if _, err := w.transactionSaver.SaveConversionTransaction(ctx, debitId, quote.FromWalletId, "WITHDRAW", strconv.Itoa(quote.SellAmount), quote); err != nil {
+			return err
+		}
+
+		from, err = w.walletSaver.UpdateBalance(ctx, quote.FromWalletId, -quote.SellAmount)
+		if err != nil {
+			return fmt.Errorf("failed to update wallet balance: %w", err)
+		}
+		if err := w.checkLeg(ctx, principal, from, quote.FromCurrency, "WITHDRAW", quote.SellAmount); err != nil {
+			return err
+		}
+
+		if _, err := w.transactionSaver.SaveConversionTransaction(ctx, creditId, quote.ToWalletId, "DEPOSIT", quote.BuyAmount, quote); err != nil {
+			return err
+		}
+
+		to, err = w.walletSaver.UpdateBalanceDecimal(ctx, quote.ToWalletId, quote.BuyAmount)
+		if err != nil {
+			return fmt.Errorf("failed to update wallet balance: %w", err)
+		}
+		if err := w.checkLeg(ctx, principal, to, quote.ToCurrency, "DEPOSIT", int(math.Ceil(buyAmount))); err != nil {
+			return err
+		}
+
+		payload := models.FundsConvertedPayload{
+			QuoteId:             quote.Id,
+			FromWalletId:        quote.FromWalletId,
+			ToWalletId:          quote.ToWalletId,
+			DebitTransactionId:  debitId,
+			CreditTransactionId: creditId,
+			SellAmount:          quote.SellAmount,
+			SellCurrency:        quote.FromCurrency,
+			BuyAmount:           quote.BuyAmount,
+			BuyCurrency:         quote.ToCurrency,
+			Rate:                quote.AppliedRate,
+			Spread:              quote.Spread,
+		}
+		if err := w.saveEvent(ctx, quote.FromWalletId, models.EventFundsConverted, payload); err != nil {
+			return err
+		}
+
+		return w.saveEvent(ctx, quote.ToWalletId, models.EventFundsConverted, payload)
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrWalletNotExists) {
+			log.Warn("wallet not exists", sl.Err(err))
+
+			return uuid.UUID{}, uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrWalletNotExists)
+		}
+		if errors.Is(err, ErrForbidden) {
+			log.Warn("conversion on another user's wallet denied", slog.String("caller", principal.Subject))
+
+			return uuid.UUID{}, uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+		}
+		if errors.Is(err, ErrCurrencyMismatch) || errors.Is(err, ErrLimitExceeded) {
+			log.Warn("conversion refused", sl.Err(err))
+
+			return uuid.UUID{}, uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if errors.Is(err, storage.ErrConflict) {
+			log.Warn("conversion conflicts after retries", sl.Err(err))
+
+			return uuid.UUID{}, uuid.UUID{}, fmt.Errorf("%s: %w", op, ErrConflict)
+		}
+		log.Error("failed to convert", sl.Err(err))
+		failSpan(span, err)
+
+		return uuid.UUID{}, uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	w.walletsChanged(ctx, from, to)
+
+	log.Info("money converted")
+	return debitId, creditId, nil
+}
+
+// checkLeg checks a leg of a conversion against the wallet it has changed and locked.
+func (w *Wallet) checkLeg(
+	ctx context.Context,
+	principal auth.Principal,
+	wallet models.Wallet,
+	currency string,
+	operationType string,
+	amount int,
+) error {
+	if !principal.CanAccess(wallet.UserId) {
+		return ErrForbidden
+	}
+	if wallet.Currency != currency {
+		return fmt.Errorf("%w: wallet %s holds %s", ErrCurrencyMismatch, wallet.Id, wallet.Currency)
+	}
+
+	if w.limitChecker != nil {
+		return w.limitChecker.CheckLimits(ctx, wallet, operationType, amount)
+	}
+
+	return nil
+}
+
 // GetWallet retrieves a wallet by its ID.
 // If wallet with given uuid not exists, returns error.
 func (w *Wallet) GetWallet(ctx context.Context, walletId uuid.UUID) (models.Wallet, error) {
 	const op = "Wallet.GetWallet"
 
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+
 	log := w.log.With(
 		slog.String("op", op),
+		sl.TraceId(ctx),
 		slog.String("walletId", walletId.String()),
 	)
 
 	log.Info("retrieving wallet")
 
-	wallet, err := w.walletSaver.GetWallet(ctx, walletId)
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		log.Warn("caller is not authenticated")
+
+		return models.Wallet{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+
+	wallet, err := w.walletReader.GetWallet(ctx, walletId)
 	if err != nil {
 		if errors.Is(err, storage.ErrWalletNotExists) {
 			log.Warn("wallet not exists", sl.Err(err))
@@ -159,9 +833,200 @@ func (w *Wallet) GetWallet(ctx context.Context, walletId uuid.UUID) (models.Wall
 			return models.Wallet{}, fmt.Errorf("%s: %w", op, ErrWalletNotExists)
 		}
 		log.Error("failed to get wallet", sl.Err(err))
+		failSpan(span, err)
 		return models.Wallet{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	if !principal.CanAccess(wallet.UserId) {
+		log.Warn("read of another user's wallet denied", slog.String("caller", principal.Subject))
+
+		return models.Wallet{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
 	log.Info("wallet retrieved successfully")
 	return wallet, nil
 }
+
+// TransactionByReference returns the transaction posted on the wallet with the external reference.
+func (w *Wallet) TransactionByReference(ctx context.Context, walletId uuid.UUID, reference string) (models.Transaction, error) {
+	const op = "Wallet.TransactionByReference"
+
+	// Checks the caller may read the wallet
+	if _, err := w.GetWallet(ctx, walletId); err != nil {
+		return models.Transaction{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	transaction, err := w.transactionSaver.TransactionByReference(ctx, walletId, reference)
+	if err != nil {
+		if errors.Is(err, storage.ErrTransactionNotExists) {
+			return models.Transaction{}, fmt.Errorf("%s: %w", op, ErrTransactionNotExists)
+		}
+
+		return models.Transaction{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return transaction, nil
+}
+
+// duplicate answers a repeated reference with the original transaction, if it is the same operation.
+func (w *Wallet) duplicate(
+	ctx context.Context,
+	log *slog.Logger,
+	principal auth.Principal,
+	original models.Transaction,
+	operationType string,
+	amount int,
+) (models.Receipt, error) {
+	const op = "Wallet.Post"
+
+	wallet, err := w.walletSaver.GetWallet(ctx, original.WalletId)
+	if err != nil {
+		return models.Receipt{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !principal.CanAccess(wallet.UserId) {
+		log.Warn("transaction on another user's wallet denied", slog.String("caller", principal.Subject))
+
+		return models.Receipt{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	if original.OperationType != operationType || original.Amount != float64(amount) {
+		log.Warn("reference reused for another operation", slog.String("originalId", original.Id.String()))
+
+		return models.Receipt{}, fmt.Errorf("%s: %w", op, ErrReferenceConflict)
+	}
+
+	log.Info("reference already posted", slog.String("originalId", original.Id.String()))
+
+	receipt := models.Receipt{
+		TransactionId: original.Id,
+		Gross:         amount,
+		Fee:           original.Fee,
+		Net:           amount - original.Fee,
+	}
+
+	return receipt, fmt.Errorf("%s: %w", op, ErrDuplicateReference)
+}
+
+// chargeFee accrues the fee to the revenue wallet and links it to the operation.
+// A deposit also debits the fee from the wallet, a withdrawal has debited the gross amount already.
+// The revenue wallet row is not locked, its balance grows once the accrual is settled.
+// It returns the wallet after the fee.
+func (w *Wallet) chargeFee(
+	ctx context.Context,
+	wallet models.Wallet,
+	operationType string,
+	transactionId uuid.UUID,
+	fee models.Fee,
+) (models.Wallet, *uuid.UUID, error) {
+	var err error
+
+	if operationType == "DEPOSIT" {
+		if _, err := w.transactionSaver.SaveFee(ctx, uuid.New(), wallet.Id, -fee.Amount, transactionId); err != nil {
+			return models.Wallet{}, nil, fmt.Errorf("failed to save fee: %w", err)
+		}
+
+		wallet, err = w.walletSaver.UpdateBalance(ctx, wallet.Id, -fee.Amount)
+		if err != nil {
+			return models.Wallet{}, nil, fmt.Errorf("failed to update wallet balance: %w", err)
+		}
+	}
+
+	// A missing revenue wallet is a configuration error, not the caller's.
+	// Its currency is checked at startup, see fees.Engine.CheckRevenueWallets.
+	feeId := uuid.New()
+	err = w.transactionSaver.AccrueFees(ctx, []models.Transaction{
+		{Id: feeId, WalletId: fee.RevenueWalletId, OperationType: "FEE", Amount: float64(fee.Amount), FeeFor: &transactionId},
+	})
+	if errors.Is(err, storage.ErrWalletNotExists) {
+		return models.Wallet{}, nil, fmt.Errorf("revenue wallet %s not exists", fee.RevenueWalletId)
+	}
+	if err != nil {
+		return models.Wallet{}, nil, fmt.Errorf("failed to accrue fee: %w", err)
+	}
+
+	return wallet, &feeId, nil
+}
+
+// validCurrency reports whether code looks like an ISO 4217 code, e.g. USD.
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// normalizeDetails trims the texts, compacts the metadata and checks them against the limits.
+func normalizeDetails(d models.TransactionDetails) (models.TransactionDetails, error) {
+	d.Description = strings.TrimSpace(d.Description)
+	d.ExternalReference = strings.TrimSpace(d.ExternalReference)
+
+	if utf8.RuneCountInString(d.Description) > MaxDescriptionLength {
+		return d, fmt.Errorf("%w: description is longer than %d characters", ErrInvalidDetails, MaxDescriptionLength)
+	}
+	if utf8.RuneCountInString(d.ExternalReference) > MaxReferenceLength {
+		return d, fmt.Errorf("%w: externalReference is longer than %d characters", ErrInvalidDetails, MaxReferenceLength)
+	}
+
+	if len(d.Metadata) == 0 || string(d.Metadata) == "null" {
+		d.Metadata = nil
+
+		return d, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(d.Metadata, &object); err != nil {
+		return d, fmt.Errorf("%w: metadata must be a JSON object", ErrInvalidDetails)
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, d.Metadata); err != nil {
+		return d, fmt.Errorf("%w: metadata must be a JSON object", ErrInvalidDetails)
+	}
+	if buf.Len() > MaxMetadataBytes {
+		return d, fmt.Errorf("%w: metadata is larger than %d bytes", ErrInvalidDetails, MaxMetadataBytes)
+	}
+	d.Metadata = buf.Bytes()
+
+	return d, nil
+}
+
+// saveEvent writes event to the outbox within the current transaction.
+func (w *Wallet) saveEvent(ctx context.Context, walletId uuid.UUID, eventType models.EventType, payload any) error {
+	event, err := models.NewEvent(walletId, eventType, payload)
+	if err != nil {
+		return fmt.Errorf("failed to build %s event: %w", eventType, err)
+	}
+
+	if err := w.eventSaver.SaveEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to save %s event: %w", eventType, err)
+	}
+
+	return nil
+}
+
+// walletsChanged notifies the observer about the wallets once the transaction of ctx commits.
+// A nested operation has not committed when its WithinTx returns, the caller's transaction
+// may still roll back or be retried.
+func (w *Wallet) walletsChanged(ctx context.Context, wallets ...models.Wallet) {
+	if w.walletObserver == nil {
+		return
+	}
+
+	w.txManager.AfterCommit(ctx, func() {
+		for _, wallet := range wallets {
+			w.walletObserver.WalletChanged(wallet)
+		}
+	})
+}
+
+// failSpan marks span as failed with err.
+func failSpan(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
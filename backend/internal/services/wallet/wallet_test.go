@@ -0,0 +1,93 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+)
+
+func TestPostRejectsInvalidOperations(t *testing.T) {
+	tests := []struct {
+		name          string
+		scopes        []string
+		operationType string
+		amount        int
+	}{
+		{name: "negative deposit with the deposit scope", scopes: []string{auth.ScopeDeposit}, operationType: "DEPOSIT", amount: -100},
+		{name: "negative withdrawal", scopes: []string{auth.ScopeWithdraw}, operationType: "WITHDRAW", amount: -100},
+		{name: "zero deposit", scopes: []string{auth.ScopeDeposit}, operationType: "DEPOSIT", amount: 0},
+		{name: "unknown type", scopes: []string{auth.ScopeDeposit, auth.ScopeWithdraw}, operationType: "REFUND", amount: 100},
+		{name: "empty type", scopes: []string{auth.ScopeDeposit, auth.ScopeWithdraw}, operationType: "", amount: 100},
+		{name: "lowercase type", scopes: []string{auth.ScopeDeposit}, operationType: "deposit", amount: 100},
+	}
+
+	// Nothing is stored: an invalid operation is refused before the wallet is touched
+	w := New(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := auth.WithPrincipal(context.Background(), auth.Principal{Subject: "billing", Scopes: tt.scopes, Service: true})
+
+			_, err := w.Post(ctx, uuid.New(), tt.operationType, tt.amount, models.TransactionDetails{})
+			if !errors.Is(err, ErrInvalidOperation) {
+				t.Errorf("Post(%q, %d) error = %v, want %v", tt.operationType, tt.amount, err, ErrInvalidOperation)
+			}
+		})
+	}
+}
+
+// memObserver remembers the last seen state of every wallet.
+type memObserver map[uuid.UUID]models.Wallet
+
+func (m memObserver) WalletChanged(wallet models.Wallet) {
+	m[wallet.Id] = wallet
+}
+
+func TestObserverSeesOnlyCommittedChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		outerErr error
+		want     bool
+	}{
+		{name: "outer transaction commits", want: true},
+		{name: "outer transaction rolls back", outerErr: errors.New("run failed later")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, s, ids := newBatchWallet(t, nil, 100)
+			observer := memObserver{}
+			w := New(slog.New(slog.NewTextHandler(io.Discard, nil)), s, s, s, s, observer, nil, nil, nil, nil)
+
+			// Like a scheduled run, the operation is posted within the caller's transaction
+			err := s.WithinTx(billingCtx(), func(ctx context.Context) error {
+				if _, err := w.Post(ctx, ids[0], "DEPOSIT", 50, models.TransactionDetails{}); err != nil {
+					return err
+				}
+				if len(observer) != 0 {
+					t.Errorf("observer notified before the outer transaction committed")
+				}
+
+				return tt.outerErr
+			})
+			if !errors.Is(err, tt.outerErr) {
+				t.Fatalf("WithinTx() error = %v, want %v", err, tt.outerErr)
+			}
+
+			wallet, notified := observer[ids[0]]
+			if notified != tt.want {
+				t.Fatalf("observer notified = %v, want %v", notified, tt.want)
+			}
+			if notified && wallet.Balance != 150 {
+				t.Errorf("observed balance = %v, want 150", wallet.Balance)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/backoff"
+	"coin-app/internal/lib/logger/sl"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+type DeliveryStorage interface {
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	MarkDeliverySucceeded(ctx context.Context, deliveryId uuid.UUID, statusCode int) error
+	MarkDeliveryFailed(ctx context.Context, deliveryId uuid.UUID, statusCode int, reason string, retryIn time.Duration, dead bool) error
+}
+
+type DispatcherOptions struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	// Lease is how long a claimed batch is reserved for sending before another dispatcher may take it over.
+	// It must be longer than sending a batch takes.
+	Lease      time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Dispatcher sends queued deliveries to subscriber endpoints.
+type Dispatcher struct {
+	log     *slog.Logger
+	storage DeliveryStorage
+	client  *http.Client
+	opts    DispatcherOptions
+}
+
+// NewDispatcher returns a new instance of the webhook Dispatcher.
+func NewDispatcher(
+	log *slog.Logger,
+	storage DeliveryStorage,
+	client *http.Client,
+	opts DispatcherOptions,
+) *Dispatcher {
+	return &Dispatcher{
+		log:     log,
+		storage: storage,
+		client:  client,
+		opts:    opts,
+	}
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>".
+// Receivers recompute it with their secret and compare with the signature header
+// in constant time, rejecting stale timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run delivers pending webhooks until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	const op = "Dispatcher.Run"
+
+	log := d.log.With(slog.String("op", op))
+
+	log.Info("webhook dispatcher started")
+
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := d.dispatchBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error("failed to dispatch webhooks", sl.Err(err))
+		}
+
+		if err == nil && n == d.opts.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Info("webhook dispatcher stopped")
+
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchBatch sends one batch of due deliveries and returns its size.
+// The deliveries are claimed first, so no transaction or row lock is held while waiting for receivers.
+func (d *Dispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	const op = "Dispatcher.dispatchBatch"
+
+	deliveries, err := d.storage.ClaimDeliveries(ctx, d.opts.BatchSize, d.opts.Lease)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, delivery := range deliveries {
+		statusCode, err := d.deliver(ctx, delivery)
+		if err == nil {
+			if err := d.storage.MarkDeliverySucceeded(ctx, delivery.Id, statusCode); err != nil {
+				return 0, fmt.Errorf("%s: %w", op, err)
+			}
+
+			continue
+		}
+
+		attempts := delivery.Attempts + 1
+		dead := attempts >= d.opts.MaxAttempts
+		retryIn := backoff.Jittered(delivery.Attempts, d.opts.MinBackoff, d.opts.MaxBackoff)
+
+		log := d.log.With(
+			slog.String("op", op),
+			slog.String("deliveryId", delivery.Id.String()),
+			slog.String("url", delivery.URL),
+			slog.Int("attempts", attempts),
+			sl.Err(err),
+		)
+		if dead {
+			log.Error("webhook delivery moved to dead letter")
+		} else {
+			log.Warn("webhook delivery failed", slog.String("retryIn", retryIn.String()))
+		}
+
+		if err := d.storage.MarkDeliveryFailed(ctx, delivery.Id, statusCode, err.Error(), retryIn, dead); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// deliver posts the event to the subscriber and returns the response status code.
+func (d *Dispatcher) deliver(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(models.Event{
+		Id:          delivery.EventId,
+		AggregateId: delivery.AggregateId,
+		Type:        delivery.EventType,
+		Payload:     delivery.Payload,
+		CreatedAt:   delivery.CreatedAt,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(delivery.EventType))
+	req.Header.Set(HeaderDelivery, delivery.Id.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, "sha256="+Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
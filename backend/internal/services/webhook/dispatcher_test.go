@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+)
+
+// memDeliveries is an in-memory DeliveryStorage. A failed delivery is due again right away.
+type memDeliveries struct {
+	mu         sync.Mutex
+	deliveries []*models.WebhookDelivery
+	codes      map[uuid.UUID]int
+}
+
+func (s *memDeliveries) ClaimDeliveries(_ context.Context, limit int, _ time.Duration) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []models.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.Status == models.DeliveryPending && len(out) < limit {
+			out = append(out, *d)
+		}
+	}
+
+	return out, nil
+}
+
+func (s *memDeliveries) MarkDeliverySucceeded(_ context.Context, deliveryId uuid.UUID, statusCode int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.get(deliveryId)
+	d.Status = models.DeliveryDelivered
+	d.Attempts++
+	s.codes[deliveryId] = statusCode
+
+	return nil
+}
+
+func (s *memDeliveries) MarkDeliveryFailed(_ context.Context, deliveryId uuid.UUID, statusCode int, _ string, _ time.Duration, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.get(deliveryId)
+	d.Attempts++
+	if dead {
+		d.Status = models.DeliveryDead
+	}
+	s.codes[deliveryId] = statusCode
+
+	return nil
+}
+
+func (s *memDeliveries) get(deliveryId uuid.UUID) *models.WebhookDelivery {
+	for _, d := range s.deliveries {
+		if d.Id == deliveryId {
+			return d
+		}
+	}
+	panic("unknown delivery " + deliveryId.String())
+}
+
+// receiver is a subscriber endpoint that checks signatures and answers with the queued status codes.
+type receiver struct {
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	statuses []int
+	calls    int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rc.t.Errorf("read body: %v", err)
+	}
+
+	timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		rc.t.Errorf("bad %s header: %v", HeaderTimestamp, err)
+	}
+	want := "sha256=" + Sign(rc.secret, timestamp, body)
+	if got := r.Header.Get(HeaderSignature); !hmac.Equal([]byte(got), []byte(want)) {
+		rc.t.Errorf("signature = %q, want %q", got, want)
+	}
+
+	var event models.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		rc.t.Errorf("body is not an event: %v", err)
+	}
+	if got := r.Header.Get(HeaderEvent); got != string(event.Type) {
+		rc.t.Errorf("%s = %q, want %q", HeaderEvent, got, event.Type)
+	}
+
+	rc.mu.Lock()
+	status := http.StatusOK
+	if rc.calls < len(rc.statuses) {
+		status = rc.statuses[rc.calls]
+	}
+	rc.calls++
+	rc.mu.Unlock()
+
+	w.WriteHeader(status)
+}
+
+func newDispatcher(t *testing.T, statuses []int, maxAttempts int) (*Dispatcher, *memDeliveries, *receiver) {
+	t.Helper()
+
+	rc := &receiver{t: t, secret: "whsec_test", statuses: statuses}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	storage := &memDeliveries{
+		codes: map[uuid.UUID]int{},
+		deliveries: []*models.WebhookDelivery{{
+			Id:          uuid.New(),
+			EventId:     uuid.New(),
+			AggregateId: uuid.New(),
+			EventType:   models.EventFundsDeposited,
+			Payload:     json.RawMessage(`{"amount":100}`),
+			Status:      models.DeliveryPending,
+			URL:         srv.URL,
+			Secret:      rc.secret,
+		}},
+	}
+
+	d := NewDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), storage, srv.Client(), DispatcherOptions{
+		BatchSize:   10,
+		MaxAttempts: maxAttempts,
+		Lease:       time.Minute,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+	})
+
+	return d, storage, rc
+}
+
+func TestDispatcherSignsDelivery(t *testing.T) {
+	d, storage, rc := newDispatcher(t, nil, 3)
+
+	n, err := d.dispatchBatch(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("dispatchBatch() = %d, %v, want 1, nil", n, err)
+	}
+
+	delivery := storage.deliveries[0]
+	if delivery.Status != models.DeliveryDelivered || delivery.Attempts != 1 {
+		t.Errorf("delivery = %s after %d attempts, want delivered after 1", delivery.Status, delivery.Attempts)
+	}
+	if code := storage.codes[delivery.Id]; code != http.StatusOK {
+		t.Errorf("status code = %d, want 200", code)
+	}
+	if rc.calls != 1 {
+		t.Errorf("receiver called %d times, want 1", rc.calls)
+	}
+}
+
+func TestDispatcherRetriesServerErrors(t *testing.T) {
+	d, storage, rc := newDispatcher(t, []int{http.StatusInternalServerError, http.StatusBadGateway}, 5)
+
+	for i := 0; i < 3; i++ {
+		if _, err := d.dispatchBatch(context.Background()); err != nil {
+			t.Fatalf("dispatchBatch() #%d: %v", i+1, err)
+		}
+
+		delivery := storage.deliveries[0]
+		want := models.DeliveryPending
+		if i == 2 {
+			want = models.DeliveryDelivered
+		}
+		if delivery.Status != want || delivery.Attempts != i+1 {
+			t.Fatalf("after run #%d delivery = %s after %d attempts, want %s after %d", i+1, delivery.Status, delivery.Attempts, want, i+1)
+		}
+	}
+
+	if rc.calls != 3 {
+		t.Errorf("receiver called %d times, want 3", rc.calls)
+	}
+}
+
+func TestDispatcherDeadLettersAfterMaxAttempts(t *testing.T) {
+	const maxAttempts = 3
+	d, storage, rc := newDispatcher(t, []int{500, 500, 500, 500}, maxAttempts)
+
+	for i := 0; i < maxAttempts+1; i++ {
+		if _, err := d.dispatchBatch(context.Background()); err != nil {
+			t.Fatalf("dispatchBatch() #%d: %v", i+1, err)
+		}
+	}
+
+	delivery := storage.deliveries[0]
+	if delivery.Status != models.DeliveryDead || delivery.Attempts != maxAttempts {
+		t.Errorf("delivery = %s after %d attempts, want dead after %d", delivery.Status, delivery.Attempts, maxAttempts)
+	}
+	if code := storage.codes[delivery.Id]; code != http.StatusInternalServerError {
+		t.Errorf("last status code = %d, want 500", code)
+	}
+	// A dead delivery is not claimed again
+	if rc.calls != maxAttempts {
+		t.Errorf("receiver called %d times, want %d", rc.calls, maxAttempts)
+	}
+}
+
+func TestNewClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := NewClient(time.Second, false).Get(srv.URL)
+	if err == nil || !strings.Contains(err.Error(), ErrPrivateAddress.Error()) {
+		t.Errorf("Get(%s) error = %v, want %v", srv.URL, err, ErrPrivateAddress)
+	}
+
+	resp, err := NewClient(time.Second, true).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get(%s) with private networks allowed: %v", srv.URL, err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress means a webhook host is not a public address, see publicAddr.
+var ErrPrivateAddress = errors.New("address is not public")
+
+// cgnat is the shared address space of carrier-grade NAT, RFC 6598.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr reports whether ip is a public unicast address. Loopback, private, link-local
+// (including the 169.254.169.254 cloud metadata endpoint), unspecified and multicast addresses
+// reach the service's own network and are never webhook targets.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+
+	return ip.IsValid() &&
+		ip.IsGlobalUnicast() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!cgnat.Contains(ip)
+}
+
+// checkHost resolves the host of a webhook url and rejects it unless every address is public.
+func checkHost(ctx context.Context, resolver *net.Resolver, host string) error {
+	if ip, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		if !publicAddr(ip) {
+			return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+		}
+
+		return nil
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+
+	ips, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		if !publicAddr(ip) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateAddress, host, ip)
+		}
+	}
+
+	return nil
+}
+
+// NewClient returns the http client of the dispatcher. Unless allowPrivate is set it refuses
+// to connect to addresses that are not public, so a host re-pointed after subscribing
+// cannot reach the service's own network either.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, addrPort.Addr())
+			}
+
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// A redirect would bypass the checks made on subscribe
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,319 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/lib/logger/sl"
+	"coin-app/internal/services/wallet"
+	"coin-app/internal/storage"
+)
+
+type Webhook struct {
+	log     *slog.Logger
+	storage SubscriptionStorage
+	wallets WalletGetter
+	// allowPrivate lets subscriptions target loopback and private networks, e.g. in local development.
+	allowPrivate bool
+}
+
+type SubscriptionStorage interface {
+	SaveSubscription(ctx context.Context, sub models.WebhookSubscription) error
+	GetSubscription(ctx context.Context, subscriptionId uuid.UUID) (models.WebhookSubscription, error)
+	Subscriptions(ctx context.Context, owner string, limit int) ([]models.WebhookSubscription, error)
+	DeactivateSubscription(ctx context.Context, subscriptionId uuid.UUID) error
+	EnqueueDeliveries(ctx context.Context, event models.Event) error
+	Deliveries(ctx context.Context, subscriptionId uuid.UUID, limit int) ([]models.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, deliveryId uuid.UUID) (models.WebhookDelivery, error)
+	ResetDelivery(ctx context.Context, deliveryId uuid.UUID) error
+}
+
+// WalletGetter returns a wallet the caller may access, see wallet.Wallet.GetWallet.
+type WalletGetter interface {
+	GetWallet(ctx context.Context, walletId uuid.UUID) (models.Wallet, error)
+}
+
+var (
+	ErrInvalidURL            = errors.New("invalid url")
+	ErrInvalidEventType      = errors.New("invalid event type")
+	ErrSubscriptionNotExists = errors.New("subscription not exists")
+	ErrDeliveryNotExists     = errors.New("delivery not exists")
+	ErrWalletNotExists       = errors.New("wallet not exists")
+	// ErrForbidden is returned when the subscription belongs to someone else
+	// or the caller may not access its wallet.
+	ErrForbidden = errors.New("caller may not access the subscription")
+	// ErrUnauthenticated is returned when the context carries no principal.
+	ErrUnauthenticated = errors.New("caller is not authenticated")
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// New returns a new instance of the Webhook service.
+// Unless allowPrivate is set, subscription urls must resolve to public addresses only.
+func New(log *slog.Logger, storage SubscriptionStorage, wallets WalletGetter, allowPrivate bool) *Webhook {
+	return &Webhook{
+		log:          log,
+		storage:      storage,
+		wallets:      wallets,
+		allowPrivate: allowPrivate,
+	}
+}
+
+// Subscribe registers url for the given event types of the wallets the caller may access:
+// a user gets the events of their own wallets, an admin or a service those of every wallet.
+// A non-nil walletId narrows the events down to that wallet.
+// The returned subscription carries the generated signing secret,
+// it is not shown again afterwards.
+func (w *Webhook) Subscribe(ctx context.Context, rawURL string, eventTypes []models.EventType, walletId *uuid.UUID) (models.WebhookSubscription, error) {
+	const op = "Webhook.Subscribe"
+
+	log := w.log.With(
+		slog.String("op", op),
+		slog.String("url", rawURL),
+	)
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+	owner := ownerOf(principal)
+	if owner == "" {
+		return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+	}
+
+	var userId *uuid.UUID
+	if !principal.IsAdmin() && !principal.Service {
+		if principal.UserId == uuid.Nil {
+			return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+		}
+		userId = &principal.UserId
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, ErrInvalidURL)
+	}
+	if !w.allowPrivate {
+		if err := checkHost(ctx, net.DefaultResolver, u.Hostname()); err != nil {
+			log.Warn("webhook host rejected", sl.Err(err))
+
+			return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, fmt.Errorf("%w: %w", ErrInvalidURL, err))
+		}
+	}
+
+	if len(eventTypes) == 0 {
+		return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, ErrInvalidEventType)
+	}
+	for _, t := range eventTypes {
+		if !t.Valid() {
+			return models.WebhookSubscription{}, fmt.Errorf("%s: %w: %s", op, ErrInvalidEventType, t)
+		}
+	}
+
+	if walletId != nil {
+		if _, err := w.wallets.GetWallet(ctx, *walletId); err != nil {
+			switch {
+			case errors.Is(err, wallet.ErrWalletNotExists):
+				return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, ErrWalletNotExists)
+			case errors.Is(err, wallet.ErrForbidden), errors.Is(err, wallet.ErrUnauthenticated):
+				return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, ErrForbidden)
+			default:
+				return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	sub := models.WebhookSubscription{
+		Id:         uuid.New(),
+		URL:        rawURL,
+		EventTypes: eventTypes,
+		Secret:     secret,
+		Active:     true,
+		Owner:      owner,
+		UserId:     userId,
+		WalletId:   walletId,
+	}
+
+	if err := w.storage.SaveSubscription(ctx, sub); err != nil {
+		if errors.Is(err, storage.ErrWalletNotExists) {
+			return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, ErrWalletNotExists)
+		}
+		log.Error("failed to save subscription", sl.Err(err))
+
+		return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("webhook subscription created",
+		slog.String("subscriptionId", sub.Id.String()),
+		slog.String("owner", owner),
+	)
+
+	return sub, nil
+}
+
+// Subscriptions returns the subscriptions of the caller, newest first. An admin gets all of them.
+func (w *Webhook) Subscriptions(ctx context.Context, limit int) ([]models.WebhookSubscription, error) {
+	const op = "Webhook.Subscriptions"
+
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, ErrUnauthenticated)
+	}
+
+	owner := ""
+	if !principal.IsAdmin() {
+		if owner = ownerOf(principal); owner == "" {
+			return nil, fmt.Errorf("%s: %w", op, ErrForbidden)
+		}
+	}
+
+	subs, err := w.storage.Subscriptions(ctx, owner, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subs, nil
+}
+
+// Unsubscribe deactivates the subscription. Already queued deliveries are still attempted.
+func (w *Webhook) Unsubscribe(ctx context.Context, subscriptionId uuid.UUID) error {
+	const op = "Webhook.Unsubscribe"
+
+	if _, err := w.subscription(ctx, subscriptionId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := w.storage.DeactivateSubscription(ctx, subscriptionId); err != nil {
+		if errors.Is(err, storage.ErrSubscriptionNotExists) {
+			return fmt.Errorf("%s: %w", op, ErrSubscriptionNotExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	w.log.Info("webhook subscription deactivated",
+		slog.String("op", op),
+		slog.String("subscriptionId", subscriptionId.String()),
+	)
+
+	return nil
+}
+
+// Deliveries returns the delivery log of the subscription.
+func (w *Webhook) Deliveries(ctx context.Context, subscriptionId uuid.UUID, limit int) ([]models.WebhookDelivery, error) {
+	const op = "Webhook.Deliveries"
+
+	if _, err := w.subscription(ctx, subscriptionId); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deliveries, err := w.storage.Deliveries(ctx, subscriptionId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver puts the delivery back to the queue, including dead ones.
+func (w *Webhook) Redeliver(ctx context.Context, deliveryId uuid.UUID) error {
+	const op = "Webhook.Redeliver"
+
+	delivery, err := w.storage.GetDelivery(ctx, deliveryId)
+	if err != nil {
+		if errors.Is(err, storage.ErrDeliveryNotExists) {
+			return fmt.Errorf("%s: %w", op, ErrDeliveryNotExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := w.subscription(ctx, delivery.SubscriptionId); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := w.storage.ResetDelivery(ctx, deliveryId); err != nil {
+		if errors.Is(err, storage.ErrDeliveryNotExists) {
+			return fmt.Errorf("%s: %w", op, ErrDeliveryNotExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	w.log.Info("webhook delivery requeued",
+		slog.String("op", op),
+		slog.String("deliveryId", deliveryId.String()),
+	)
+
+	return nil
+}
+
+// Publish implements outbox.Publisher: it queues event for every matching subscription.
+func (w *Webhook) Publish(ctx context.Context, event models.Event) error {
+	const op = "Webhook.Publish"
+
+	if err := w.storage.EnqueueDeliveries(ctx, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// subscription returns the subscription if it belongs to the caller or the caller is an admin.
+func (w *Webhook) subscription(ctx context.Context, subscriptionId uuid.UUID) (models.WebhookSubscription, error) {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return models.WebhookSubscription{}, ErrUnauthenticated
+	}
+
+	sub, err := w.storage.GetSubscription(ctx, subscriptionId)
+	if err != nil {
+		if errors.Is(err, storage.ErrSubscriptionNotExists) {
+			return models.WebhookSubscription{}, ErrSubscriptionNotExists
+		}
+
+		return models.WebhookSubscription{}, err
+	}
+
+	if !principal.IsAdmin() && (sub.Owner == "" || sub.Owner != ownerOf(principal)) {
+		return models.WebhookSubscription{}, ErrForbidden
+	}
+
+	return sub, nil
+}
+
+// ownerOf returns who the subscriptions of the caller belong to: its operator,
+// so they survive a key rotation, or the caller itself when it is bound to no one.
+func ownerOf(principal auth.Principal) string {
+	if principal.Operator != "" {
+		return principal.Operator
+	}
+
+	return principal.Subject
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(b), nil
+}
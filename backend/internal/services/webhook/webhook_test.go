@@ -0,0 +1,309 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/auth"
+	"coin-app/internal/services/wallet"
+	"coin-app/internal/storage"
+)
+
+type memSubscriptions struct {
+	SubscriptionStorage
+	saved []models.WebhookSubscription
+	// delivery belongs to the first saved subscription
+	delivery models.WebhookDelivery
+	reset    []uuid.UUID
+}
+
+func (s *memSubscriptions) SaveSubscription(_ context.Context, sub models.WebhookSubscription) error {
+	s.saved = append(s.saved, sub)
+
+	return nil
+}
+
+func (s *memSubscriptions) GetSubscription(_ context.Context, subscriptionId uuid.UUID) (models.WebhookSubscription, error) {
+	for _, sub := range s.saved {
+		if sub.Id == subscriptionId {
+			return sub, nil
+		}
+	}
+
+	return models.WebhookSubscription{}, storage.ErrSubscriptionNotExists
+}
+
+func (s *memSubscriptions) Subscriptions(_ context.Context, owner string, limit int) ([]models.WebhookSubscription, error) {
+	var subs []models.WebhookSubscription
+	for _, sub := range s.saved {
+		if (owner == "" || sub.Owner == owner) && len(subs) < limit {
+			subs = append(subs, sub)
+		}
+	}
+
+	return subs, nil
+}
+
+func (s *memSubscriptions) Deliveries(_ context.Context, subscriptionId uuid.UUID, _ int) ([]models.WebhookDelivery, error) {
+	if subscriptionId != s.delivery.SubscriptionId {
+		return []models.WebhookDelivery{}, nil
+	}
+
+	return []models.WebhookDelivery{s.delivery}, nil
+}
+
+func (s *memSubscriptions) GetDelivery(_ context.Context, deliveryId uuid.UUID) (models.WebhookDelivery, error) {
+	if deliveryId != s.delivery.Id {
+		return models.WebhookDelivery{}, storage.ErrDeliveryNotExists
+	}
+
+	return s.delivery, nil
+}
+
+func (s *memSubscriptions) ResetDelivery(_ context.Context, deliveryId uuid.UUID) error {
+	s.reset = append(s.reset, deliveryId)
+
+	return nil
+}
+
+// memWallets checks access to its wallets like wallet.Wallet.GetWallet.
+type memWallets map[uuid.UUID]models.Wallet
+
+func (m memWallets) GetWallet(ctx context.Context, walletId uuid.UUID) (models.Wallet, error) {
+	w, ok := m[walletId]
+	if !ok {
+		return models.Wallet{}, wallet.ErrWalletNotExists
+	}
+
+	principal, _ := auth.PrincipalFrom(ctx)
+	if !principal.CanAccess(w.UserId) {
+		return models.Wallet{}, wallet.ErrForbidden
+	}
+
+	return w, nil
+}
+
+func newLog() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// serviceCtx returns a context of a backend service subscribing to events of all wallets.
+func serviceCtx() context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{
+		Subject:  "apikey:" + uuid.NewString(),
+		Operator: "billing",
+		Scopes:   []string{auth.ScopeRead},
+		Service:  true,
+	})
+}
+
+func TestSubscribeRejectsPrivateHosts(t *testing.T) {
+	tests := []struct {
+		url     string
+		private bool
+	}{
+		{url: "http://127.0.0.1:8080/hook", private: true},
+		{url: "http://localhost/hook", private: true},
+		{url: "http://api.localhost/hook", private: true},
+		{url: "http://[::1]/hook", private: true},
+		{url: "http://10.1.2.3/hook", private: true},
+		{url: "http://172.16.0.1/hook", private: true},
+		{url: "http://192.168.1.1/hook", private: true},
+		{url: "http://100.64.0.1/hook", private: true},
+		{url: "http://169.254.169.254/latest/meta-data", private: true},
+		{url: "http://[fe80::1]/hook", private: true},
+		{url: "http://[fd00::1]/hook", private: true},
+		{url: "http://[::ffff:127.0.0.1]/hook", private: true},
+		{url: "http://0.0.0.0/hook", private: true},
+		{url: "https://93.184.216.34/hook", private: false},
+		{url: "https://[2606:4700::1111]/hook", private: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			storage := &memSubscriptions{}
+			w := New(newLog(), storage, nil, false)
+
+			_, err := w.Subscribe(serviceCtx(), tt.url, []models.EventType{models.EventFundsDeposited}, nil)
+			if tt.private {
+				if !errors.Is(err, ErrInvalidURL) || !errors.Is(err, ErrPrivateAddress) {
+					t.Fatalf("Subscribe() error = %v, want %v and %v", err, ErrInvalidURL, ErrPrivateAddress)
+				}
+				if len(storage.saved) != 0 {
+					t.Errorf("subscription saved for a private host")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("Subscribe() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestSubscribeAllowsPrivateHostsWhenConfigured(t *testing.T) {
+	storage := &memSubscriptions{}
+	w := New(newLog(), storage, nil, true)
+
+	sub, err := w.Subscribe(serviceCtx(), "http://127.0.0.1:9000/hook", []models.EventType{models.EventFundsDeposited}, nil)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if sub.Id == uuid.Nil || len(storage.saved) != 1 {
+		t.Errorf("subscription not saved")
+	}
+}
+
+func TestSubscribeScopesEventsToCaller(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	ownWallet, otherWallet := uuid.New(), uuid.New()
+	wallets := memWallets{
+		ownWallet:   {Id: ownWallet, UserId: owner},
+		otherWallet: {Id: otherWallet, UserId: other},
+	}
+
+	user := auth.Principal{Subject: owner.String(), UserId: owner, Operator: owner.String()}
+	admin := auth.Principal{Subject: other.String(), UserId: other, Operator: other.String(), Scopes: []string{auth.ScopeAdmin}}
+	service := auth.Principal{Subject: "apikey:" + uuid.NewString(), Operator: "billing", Scopes: []string{auth.ScopeRead}, Service: true}
+
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		walletId  *uuid.UUID
+		wantOwner string
+		wantUser  *uuid.UUID
+		wantErr   error
+	}{
+		{name: "user gets their wallets", principal: user, wantOwner: owner.String(), wantUser: &owner},
+		{name: "user narrows to own wallet", principal: user, walletId: &ownWallet, wantOwner: owner.String(), wantUser: &owner},
+		{name: "user on another user's wallet", principal: user, walletId: &otherWallet, wantErr: ErrForbidden},
+		{name: "missing wallet", principal: user, walletId: func() *uuid.UUID { id := uuid.New(); return &id }(), wantErr: ErrWalletNotExists},
+		{name: "admin gets all wallets", principal: admin, wantOwner: other.String()},
+		{name: "admin narrows to any wallet", principal: admin, walletId: &ownWallet, wantOwner: other.String()},
+		{name: "service gets all wallets", principal: service, wantOwner: "billing"},
+		{name: "caller bound to no one", principal: auth.Principal{Scopes: []string{auth.ScopeRead}, Service: true}, wantErr: ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &memSubscriptions{}
+			w := New(newLog(), storage, wallets, true)
+
+			ctx := auth.WithPrincipal(context.Background(), tt.principal)
+			sub, err := w.Subscribe(ctx, "http://127.0.0.1:9000/hook", []models.EventType{models.EventFundsDeposited}, tt.walletId)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Subscribe() error = %v, want %v", err, tt.wantErr)
+				}
+				if len(storage.saved) != 0 {
+					t.Errorf("subscription saved")
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("Subscribe() error = %v", err)
+			}
+
+			if sub.Owner != tt.wantOwner {
+				t.Errorf("owner = %q, want %q", sub.Owner, tt.wantOwner)
+			}
+			if !sameId(sub.UserId, tt.wantUser) {
+				t.Errorf("userId = %v, want %v", sub.UserId, tt.wantUser)
+			}
+			if !sameId(sub.WalletId, tt.walletId) {
+				t.Errorf("walletId = %v, want %v", sub.WalletId, tt.walletId)
+			}
+		})
+	}
+}
+
+func TestSubscriptionsBelongToTheirOwner(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	sub := models.WebhookSubscription{Id: uuid.New(), Owner: owner.String(), UserId: &owner}
+	legacy := models.WebhookSubscription{Id: uuid.New()}
+
+	tests := []struct {
+		name      string
+		principal auth.Principal
+		sub       models.WebhookSubscription
+		wantErr   error
+		wantCount int
+	}{
+		{
+			name:      "owner",
+			principal: auth.Principal{Subject: owner.String(), UserId: owner, Operator: owner.String()},
+			sub:       sub,
+			wantCount: 1,
+		},
+		{
+			name:      "another user",
+			principal: auth.Principal{Subject: other.String(), UserId: other, Operator: other.String()},
+			sub:       sub,
+			wantErr:   ErrForbidden,
+		},
+		{
+			name:      "service of another operator",
+			principal: auth.Principal{Subject: "apikey:" + uuid.NewString(), Operator: "billing", Service: true},
+			sub:       sub,
+			wantErr:   ErrForbidden,
+		},
+		{
+			name:      "admin",
+			principal: auth.Principal{Subject: other.String(), UserId: other, Operator: other.String(), Scopes: []string{auth.ScopeAdmin}},
+			sub:       sub,
+			wantCount: 2,
+		},
+		{
+			name:      "subscription without owner",
+			principal: auth.Principal{Subject: "anonymous", Service: true},
+			sub:       legacy,
+			wantErr:   ErrForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := models.WebhookDelivery{Id: uuid.New(), SubscriptionId: tt.sub.Id}
+			storage := &memSubscriptions{saved: []models.WebhookSubscription{tt.sub, legacy}, delivery: delivery}
+			if tt.sub.Id == legacy.Id {
+				storage.saved = storage.saved[:1]
+			}
+			w := New(newLog(), storage, nil, false)
+			ctx := auth.WithPrincipal(context.Background(), tt.principal)
+
+			if _, err := w.Deliveries(ctx, tt.sub.Id, 10); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Deliveries() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := w.Redeliver(ctx, delivery.Id); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Redeliver() error = %v, want %v", err, tt.wantErr)
+			}
+			if requeued := len(storage.reset) == 1; requeued != (tt.wantErr == nil) {
+				t.Errorf("delivery requeued = %v", requeued)
+			}
+
+			subs, err := w.Subscriptions(ctx, 10)
+			if err != nil {
+				t.Fatalf("Subscriptions() error = %v", err)
+			}
+			if len(subs) != tt.wantCount {
+				t.Errorf("Subscriptions() returned %d subscriptions, want %d", len(subs), tt.wantCount)
+			}
+		})
+	}
+}
+
+func sameId(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
@@ -0,0 +1,18 @@
+package storage
+
+import "context"
+
+type consistencyKey struct{}
+
+// WithStrongConsistency marks ctx so that reads are served by the primary
+// and never by a possibly lagging replica.
+func WithStrongConsistency(ctx context.Context) context.Context {
+	return context.WithValue(ctx, consistencyKey{}, true)
+}
+
+// IsStrongConsistency reports whether ctx requires reads from the primary.
+func IsStrongConsistency(ctx context.Context) bool {
+	strong, _ := ctx.Value(consistencyKey{}).(bool)
+
+	return strong
+}
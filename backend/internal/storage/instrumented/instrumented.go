@@ -0,0 +1,606 @@
+package instrumented
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/lib/metrics"
+	"coin-app/internal/storage"
+	"coin-app/internal/storage/postgres"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("coin-app/internal/storage")
+
+// Storage decorates postgres.Storage with a span, latency and error metrics per method.
+// Methods that are not overridden here are passed through untouched.
+type Storage struct {
+	*postgres.Storage
+	metrics *metrics.Metrics
+}
+
+func New(s *postgres.Storage, m *metrics.Metrics) *Storage {
+	return &Storage{Storage: s, metrics: m}
+}
+
+// start opens a child span for method and returns a function
+// that ends it and records the call in metrics.
+// Expected outcomes like a missing wallet are not counted as errors.
+func (s *Storage) start(ctx context.Context, method string, operation string) (context.Context, func(err error)) {
+	t1 := time.Now()
+
+	ctx, span := tracer.Start(ctx, "storage.postgres."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+		),
+	)
+
+	return ctx, func(err error) {
+		s.metrics.DBQueryDuration.WithLabelValues(method).Observe(time.Since(t1).Seconds())
+
+		if err != nil && !isExpected(err) {
+			s.metrics.DBQueryErrors.WithLabelValues(method).Inc()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		span.End()
+	}
+}
+
+func isExpected(err error) bool {
+	return errors.Is(err, storage.ErrWalletExists) ||
+		errors.Is(err, storage.ErrWalletNotExists) ||
+		errors.Is(err, storage.ErrSubscriptionNotExists) ||
+		errors.Is(err, storage.ErrDeliveryNotExists) ||
+		errors.Is(err, storage.ErrAPIKeyNotExists) ||
+		errors.Is(err, storage.ErrAdjustmentNotExists) ||
+		errors.Is(err, storage.ErrPolicyNotExists) ||
+		errors.Is(err, storage.ErrRuleDecisionNotExists) ||
+		errors.Is(err, storage.ErrTransactionExists) ||
+		errors.Is(err, storage.ErrTransactionNotExists) ||
+		errors.Is(err, storage.ErrScheduleNotExists) ||
+		errors.Is(err, storage.ErrRateNotExists) ||
+		errors.Is(err, storage.ErrQuoteNotExists)
+}
+
+func (s *Storage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	ctx, done := s.start(ctx, "WithinTx", "TRANSACTION")
+	defer func() { done(err) }()
+
+	return s.Storage.WithinTx(ctx, fn)
+}
+
+func (s *Storage) WithinReadCommittedTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	ctx, done := s.start(ctx, "WithinReadCommittedTx", "TRANSACTION")
+	defer func() { done(err) }()
+
+	return s.Storage.WithinReadCommittedTx(ctx, fn)
+}
+
+func (s *Storage) SaveWallet(ctx context.Context, walletId uuid.UUID, userId uuid.UUID, balance int, currency string) (_ uuid.UUID, err error) {
+	ctx, done := s.start(ctx, "SaveWallet", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveWallet(ctx, walletId, userId, balance, currency)
+}
+
+func (s *Storage) SaveTransaction(
+	ctx context.Context,
+	transactionId uuid.UUID,
+	walletId uuid.UUID,
+	operationType string,
+	amount int,
+	details models.TransactionDetails,
+) (_ uuid.UUID, err error) {
+	ctx, done := s.start(ctx, "SaveTransaction", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveTransaction(ctx, transactionId, walletId, operationType, amount, details)
+}
+
+func (s *Storage) TransactionByReference(ctx context.Context, walletId uuid.UUID, reference string) (_ models.Transaction, err error) {
+	ctx, done := s.start(ctx, "TransactionByReference", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.TransactionByReference(ctx, walletId, reference)
+}
+
+func (s *Storage) UpdateBalance(ctx context.Context, walletId uuid.UUID, amount int) (_ models.Wallet, err error) {
+	ctx, done := s.start(ctx, "UpdateBalance", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.UpdateBalance(ctx, walletId, amount)
+}
+
+func (s *Storage) UpdateBalanceDecimal(ctx context.Context, walletId uuid.UUID, amount string) (_ models.Wallet, err error) {
+	ctx, done := s.start(ctx, "UpdateBalanceDecimal", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.UpdateBalanceDecimal(ctx, walletId, amount)
+}
+
+func (s *Storage) GetWallet(ctx context.Context, walletId uuid.UUID) (_ models.Wallet, err error) {
+	ctx, done := s.start(ctx, "GetWallet", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.GetWallet(ctx, walletId)
+}
+
+func (s *Storage) SaveEvent(ctx context.Context, event models.Event) (err error) {
+	ctx, done := s.start(ctx, "SaveEvent", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveEvent(ctx, event)
+}
+
+func (s *Storage) SaveEvents(ctx context.Context, events []models.Event) (err error) {
+	ctx, done := s.start(ctx, "SaveEvents", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveEvents(ctx, events)
+}
+
+func (s *Storage) SaveTransactions(ctx context.Context, transactions []models.Transaction) (err error) {
+	ctx, done := s.start(ctx, "SaveTransactions", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveTransactions(ctx, transactions)
+}
+
+func (s *Storage) TransactionsByReferences(ctx context.Context, walletIds []uuid.UUID, refs []string) (_ []models.Transaction, err error) {
+	ctx, done := s.start(ctx, "TransactionsByReferences", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.TransactionsByReferences(ctx, walletIds, refs)
+}
+
+func (s *Storage) LockWallets(ctx context.Context, walletIds ...uuid.UUID) (err error) {
+	ctx, done := s.start(ctx, "LockWallets", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.LockWallets(ctx, walletIds...)
+}
+
+func (s *Storage) UpdateBalances(ctx context.Context, deltas map[uuid.UUID]int) (_ map[uuid.UUID]models.Wallet, err error) {
+	ctx, done := s.start(ctx, "UpdateBalances", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.UpdateBalances(ctx, deltas)
+}
+
+func (s *Storage) ClaimEvents(ctx context.Context, limit int, lease time.Duration) (_ []models.Event, err error) {
+	ctx, done := s.start(ctx, "ClaimEvents", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.ClaimEvents(ctx, limit, lease)
+}
+
+func (s *Storage) MarkEventPublished(ctx context.Context, eventId uuid.UUID) (err error) {
+	ctx, done := s.start(ctx, "MarkEventPublished", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.MarkEventPublished(ctx, eventId)
+}
+
+func (s *Storage) MarkEventFailed(ctx context.Context, eventId uuid.UUID, retryIn time.Duration, reason string) (err error) {
+	ctx, done := s.start(ctx, "MarkEventFailed", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.MarkEventFailed(ctx, eventId, retryIn, reason)
+}
+
+func (s *Storage) SaveSubscription(ctx context.Context, sub models.WebhookSubscription) (err error) {
+	ctx, done := s.start(ctx, "SaveSubscription", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveSubscription(ctx, sub)
+}
+
+func (s *Storage) GetSubscription(ctx context.Context, subscriptionId uuid.UUID) (_ models.WebhookSubscription, err error) {
+	ctx, done := s.start(ctx, "GetSubscription", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.GetSubscription(ctx, subscriptionId)
+}
+
+func (s *Storage) Subscriptions(ctx context.Context, owner string, limit int) (_ []models.WebhookSubscription, err error) {
+	ctx, done := s.start(ctx, "Subscriptions", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.Subscriptions(ctx, owner, limit)
+}
+
+func (s *Storage) DeactivateSubscription(ctx context.Context, subscriptionId uuid.UUID) (err error) {
+	ctx, done := s.start(ctx, "DeactivateSubscription", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.DeactivateSubscription(ctx, subscriptionId)
+}
+
+func (s *Storage) EnqueueDeliveries(ctx context.Context, event models.Event) (err error) {
+	ctx, done := s.start(ctx, "EnqueueDeliveries", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.EnqueueDeliveries(ctx, event)
+}
+
+func (s *Storage) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) (_ []models.WebhookDelivery, err error) {
+	ctx, done := s.start(ctx, "ClaimDeliveries", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.ClaimDeliveries(ctx, limit, lease)
+}
+
+func (s *Storage) MarkDeliverySucceeded(ctx context.Context, deliveryId uuid.UUID, statusCode int) (err error) {
+	ctx, done := s.start(ctx, "MarkDeliverySucceeded", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.MarkDeliverySucceeded(ctx, deliveryId, statusCode)
+}
+
+func (s *Storage) MarkDeliveryFailed(ctx context.Context, deliveryId uuid.UUID, statusCode int, reason string, retryIn time.Duration, dead bool) (err error) {
+	ctx, done := s.start(ctx, "MarkDeliveryFailed", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.MarkDeliveryFailed(ctx, deliveryId, statusCode, reason, retryIn, dead)
+}
+
+func (s *Storage) Deliveries(ctx context.Context, subscriptionId uuid.UUID, limit int) (_ []models.WebhookDelivery, err error) {
+	ctx, done := s.start(ctx, "Deliveries", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.Deliveries(ctx, subscriptionId, limit)
+}
+
+func (s *Storage) GetDelivery(ctx context.Context, deliveryId uuid.UUID) (_ models.WebhookDelivery, err error) {
+	ctx, done := s.start(ctx, "GetDelivery", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.GetDelivery(ctx, deliveryId)
+}
+
+func (s *Storage) ResetDelivery(ctx context.Context, deliveryId uuid.UUID) (err error) {
+	ctx, done := s.start(ctx, "ResetDelivery", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.ResetDelivery(ctx, deliveryId)
+}
+
+func (s *Storage) SaveAPIKey(ctx context.Context, key models.APIKey, expiresIn time.Duration) (err error) {
+	ctx, done := s.start(ctx, "SaveAPIKey", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveAPIKey(ctx, key, expiresIn)
+}
+
+func (s *Storage) GetAPIKey(ctx context.Context, keyId uuid.UUID) (_ models.APIKey, err error) {
+	ctx, done := s.start(ctx, "GetAPIKey", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.GetAPIKey(ctx, keyId)
+}
+
+func (s *Storage) APIKeyByPrefix(ctx context.Context, prefix string) (_ models.APIKey, err error) {
+	ctx, done := s.start(ctx, "APIKeyByPrefix", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.APIKeyByPrefix(ctx, prefix)
+}
+
+func (s *Storage) APIKeys(ctx context.Context) (_ []models.APIKey, err error) {
+	ctx, done := s.start(ctx, "APIKeys", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.APIKeys(ctx)
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, keyId uuid.UUID) (err error) {
+	ctx, done := s.start(ctx, "RevokeAPIKey", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.RevokeAPIKey(ctx, keyId)
+}
+
+func (s *Storage) ExpireAPIKey(ctx context.Context, keyId uuid.UUID, expiresIn time.Duration) (err error) {
+	ctx, done := s.start(ctx, "ExpireAPIKey", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.ExpireAPIKey(ctx, keyId, expiresIn)
+}
+
+func (s *Storage) TouchAPIKey(ctx context.Context, keyId uuid.UUID) (err error) {
+	ctx, done := s.start(ctx, "TouchAPIKey", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.TouchAPIKey(ctx, keyId)
+}
+
+func (s *Storage) LockAuditLog(ctx context.Context) (_ time.Time, err error) {
+	ctx, done := s.start(ctx, "LockAuditLog", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.LockAuditLog(ctx)
+}
+
+func (s *Storage) LastAuditRecord(ctx context.Context) (_ models.AuditRecord, err error) {
+	ctx, done := s.start(ctx, "LastAuditRecord", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.LastAuditRecord(ctx)
+}
+
+func (s *Storage) SaveAuditRecord(ctx context.Context, rec models.AuditRecord) (err error) {
+	ctx, done := s.start(ctx, "SaveAuditRecord", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveAuditRecord(ctx, rec)
+}
+
+func (s *Storage) AuditRecords(ctx context.Context, filter models.AuditFilter) (_ []models.AuditRecord, err error) {
+	ctx, done := s.start(ctx, "AuditRecords", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.AuditRecords(ctx, filter)
+}
+
+func (s *Storage) SaveAdjustment(ctx context.Context, transactionId uuid.UUID, walletId uuid.UUID, amount int, reason string) (_ uuid.UUID, err error) {
+	ctx, done := s.start(ctx, "SaveAdjustment", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveAdjustment(ctx, transactionId, walletId, amount, reason)
+}
+
+func (s *Storage) SaveFee(ctx context.Context, transactionId uuid.UUID, walletId uuid.UUID, amount int, feeFor uuid.UUID) (_ uuid.UUID, err error) {
+	ctx, done := s.start(ctx, "SaveFee", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveFee(ctx, transactionId, walletId, amount, feeFor)
+}
+
+func (s *Storage) AccrueFees(ctx context.Context, fees []models.Transaction) (err error) {
+	ctx, done := s.start(ctx, "AccrueFees", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.AccrueFees(ctx, fees)
+}
+
+func (s *Storage) SettleFees(ctx context.Context, limit int) (_ int, _ []models.Wallet, err error) {
+	ctx, done := s.start(ctx, "SettleFees", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.SettleFees(ctx, limit)
+}
+
+func (s *Storage) SaveAdjustmentRequest(ctx context.Context, req models.AdjustmentRequest) (err error) {
+	ctx, done := s.start(ctx, "SaveAdjustmentRequest", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveAdjustmentRequest(ctx, req)
+}
+
+func (s *Storage) GetAdjustmentRequest(ctx context.Context, requestId uuid.UUID) (_ models.AdjustmentRequest, err error) {
+	ctx, done := s.start(ctx, "GetAdjustmentRequest", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.GetAdjustmentRequest(ctx, requestId)
+}
+
+func (s *Storage) LockAdjustmentRequest(ctx context.Context, requestId uuid.UUID) (_ models.AdjustmentRequest, err error) {
+	ctx, done := s.start(ctx, "LockAdjustmentRequest", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.LockAdjustmentRequest(ctx, requestId)
+}
+
+func (s *Storage) DecideAdjustmentRequest(ctx context.Context, req models.AdjustmentRequest) (_ models.AdjustmentRequest, err error) {
+	ctx, done := s.start(ctx, "DecideAdjustmentRequest", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.DecideAdjustmentRequest(ctx, req)
+}
+
+func (s *Storage) AdjustmentRequests(ctx context.Context, status models.AdjustmentStatus, limit int) (_ []models.AdjustmentRequest, err error) {
+	ctx, done := s.start(ctx, "AdjustmentRequests", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.AdjustmentRequests(ctx, status, limit)
+}
+
+func (s *Storage) GetWalletPolicy(ctx context.Context, walletId uuid.UUID) (_ models.WalletPolicy, err error) {
+	ctx, done := s.start(ctx, "GetWalletPolicy", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.GetWalletPolicy(ctx, walletId)
+}
+
+func (s *Storage) SaveWalletPolicy(ctx context.Context, p models.WalletPolicy) (err error) {
+	ctx, done := s.start(ctx, "SaveWalletPolicy", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveWalletPolicy(ctx, p)
+}
+
+func (s *Storage) WithdrawalTotals(ctx context.Context, walletId uuid.UUID) (_ int, _ int, err error) {
+	ctx, done := s.start(ctx, "WithdrawalTotals", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.WithdrawalTotals(ctx, walletId)
+}
+
+func (s *Storage) CountTransactions(ctx context.Context, walletId uuid.UUID, operationType string, window time.Duration) (_ int, err error) {
+	ctx, done := s.start(ctx, "CountTransactions", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.CountTransactions(ctx, walletId, operationType, window)
+}
+
+func (s *Storage) WalletAge(ctx context.Context, walletId uuid.UUID) (_ time.Duration, err error) {
+	ctx, done := s.start(ctx, "WalletAge", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.WalletAge(ctx, walletId)
+}
+
+func (s *Storage) AverageAmount(ctx context.Context, walletId uuid.UUID, operationType string, excludeId uuid.UUID) (_ float64, _ int, err error) {
+	ctx, done := s.start(ctx, "AverageAmount", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.AverageAmount(ctx, walletId, operationType, excludeId)
+}
+
+func (s *Storage) SaveRuleDecision(ctx context.Context, d models.RuleDecision) (err error) {
+	ctx, done := s.start(ctx, "SaveRuleDecision", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveRuleDecision(ctx, d)
+}
+
+func (s *Storage) GetRuleDecision(ctx context.Context, decisionId uuid.UUID) (_ models.RuleDecision, err error) {
+	ctx, done := s.start(ctx, "GetRuleDecision", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.GetRuleDecision(ctx, decisionId)
+}
+
+func (s *Storage) ReviewRuleDecision(ctx context.Context, d models.RuleDecision) (_ models.RuleDecision, err error) {
+	ctx, done := s.start(ctx, "ReviewRuleDecision", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.ReviewRuleDecision(ctx, d)
+}
+
+func (s *Storage) RuleDecisions(ctx context.Context, status models.ReviewStatus, limit int) (_ []models.RuleDecision, err error) {
+	ctx, done := s.start(ctx, "RuleDecisions", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.RuleDecisions(ctx, status, limit)
+}
+
+func (s *Storage) SaveSchedule(ctx context.Context, sched models.ScheduledOperation) (err error) {
+	ctx, done := s.start(ctx, "SaveSchedule", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveSchedule(ctx, sched)
+}
+
+func (s *Storage) GetSchedule(ctx context.Context, scheduleId uuid.UUID) (_ models.ScheduledOperation, err error) {
+	ctx, done := s.start(ctx, "GetSchedule", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.GetSchedule(ctx, scheduleId)
+}
+
+func (s *Storage) LockSchedule(ctx context.Context, scheduleId uuid.UUID) (_ models.ScheduledOperation, err error) {
+	ctx, done := s.start(ctx, "LockSchedule", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.LockSchedule(ctx, scheduleId)
+}
+
+func (s *Storage) LockDueSchedule(ctx context.Context) (_ models.ScheduledOperation, err error) {
+	ctx, done := s.start(ctx, "LockDueSchedule", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.LockDueSchedule(ctx)
+}
+
+func (s *Storage) Schedules(ctx context.Context, walletId uuid.UUID, limit int) (_ []models.ScheduledOperation, err error) {
+	ctx, done := s.start(ctx, "Schedules", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.Schedules(ctx, walletId, limit)
+}
+
+func (s *Storage) UpdateSchedule(ctx context.Context, sched models.ScheduledOperation) (_ models.ScheduledOperation, err error) {
+	ctx, done := s.start(ctx, "UpdateSchedule", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.UpdateSchedule(ctx, sched)
+}
+
+func (s *Storage) SaveScheduledRun(ctx context.Context, run models.ScheduledRun) (err error) {
+	ctx, done := s.start(ctx, "SaveScheduledRun", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveScheduledRun(ctx, run)
+}
+
+func (s *Storage) ScheduledRuns(ctx context.Context, scheduleId uuid.UUID, limit int) (_ []models.ScheduledRun, err error) {
+	ctx, done := s.start(ctx, "ScheduledRuns", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.ScheduledRuns(ctx, scheduleId, limit)
+}
+
+func (s *Storage) SaveRates(ctx context.Context, rates []models.FXRate) (err error) {
+	ctx, done := s.start(ctx, "SaveRates", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveRates(ctx, rates)
+}
+
+func (s *Storage) CurrentRate(ctx context.Context, base, quote string) (_ models.FXRate, err error) {
+	ctx, done := s.start(ctx, "CurrentRate", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.CurrentRate(ctx, base, quote)
+}
+
+func (s *Storage) CurrentRates(ctx context.Context) (_ []models.FXRate, err error) {
+	ctx, done := s.start(ctx, "CurrentRates", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.CurrentRates(ctx)
+}
+
+func (s *Storage) SaveQuote(ctx context.Context, q models.FXQuote) (err error) {
+	ctx, done := s.start(ctx, "SaveQuote", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveQuote(ctx, q)
+}
+
+func (s *Storage) GetQuote(ctx context.Context, quoteId uuid.UUID) (_ models.FXQuote, err error) {
+	ctx, done := s.start(ctx, "GetQuote", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.GetQuote(ctx, quoteId)
+}
+
+func (s *Storage) LockQuote(ctx context.Context, quoteId uuid.UUID) (_ models.FXQuote, err error) {
+	ctx, done := s.start(ctx, "LockQuote", "SELECT")
+	defer func() { done(err) }()
+
+	return s.Storage.LockQuote(ctx, quoteId)
+}
+
+func (s *Storage) ExecuteQuote(ctx context.Context, quoteId, debitId, creditId uuid.UUID) (_ models.FXQuote, err error) {
+	ctx, done := s.start(ctx, "ExecuteQuote", "UPDATE")
+	defer func() { done(err) }()
+
+	return s.Storage.ExecuteQuote(ctx, quoteId, debitId, creditId)
+}
+
+func (s *Storage) SaveConversionTransaction(
+	ctx context.Context,
+	transactionId uuid.UUID,
+	walletId uuid.UUID,
+	operationType string,
+	amount string,
+	q models.FXQuote,
+) (_ uuid.UUID, err error) {
+	ctx, done := s.start(ctx, "SaveConversionTransaction", "INSERT")
+	defer func() { done(err) }()
+
+	return s.Storage.SaveConversionTransaction(ctx, transactionId, walletId, operationType, amount, q)
+}
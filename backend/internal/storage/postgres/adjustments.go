@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const adjustmentColumns = `id, wallet_id, amount, reason, status, proposed_by, COALESCE(decided_by, ''), proposer, COALESCE(decider, ''),
+	COALESCE(decision_comment, ''), transaction_id, created_at, decided_at`
+
+// SaveAdjustment posts a signed ADJUSTMENT entry with its reason to the ledger.
+func (s *Storage) SaveAdjustment(ctx context.Context, transactionId uuid.UUID, walletId uuid.UUID, amount int, reason string) (uuid.UUID, error) {
+	const op = "storage.postgres.SaveAdjustment"
+
+	var id uuid.UUID
+	err := s.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO transactions(id, wallet_id, operation_type, amount, reason)
+		VALUES($1, $2, 'ADJUSTMENT', $3, $4)
+		RETURNING id`,
+		transactionId, walletId, amount, reason,
+	).Scan(&id)
+	if err != nil {
+		// 23503 - foreign_key_violation
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return uuid.UUID{}, fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		}
+
+		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// SaveAdjustmentRequest saves a pending adjustment request.
+func (s *Storage) SaveAdjustmentRequest(ctx context.Context, req models.AdjustmentRequest) error {
+	const op = "storage.postgres.SaveAdjustmentRequest"
+
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO adjustment_requests(id, wallet_id, amount, reason, proposed_by, proposer)
+		VALUES($1, $2, $3, $4, $5, $6)`,
+		req.Id, req.WalletId, req.Amount, req.Reason, req.ProposedBy, req.Proposer,
+	)
+	if err != nil {
+		// 23503 - foreign_key_violation
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetAdjustmentRequest retrieves the request.
+func (s *Storage) GetAdjustmentRequest(ctx context.Context, requestId uuid.UUID) (models.AdjustmentRequest, error) {
+	const op = "storage.postgres.GetAdjustmentRequest"
+
+	return s.getAdjustmentRequest(ctx, op, "SELECT "+adjustmentColumns+" FROM adjustment_requests WHERE id = $1", requestId)
+}
+
+// LockAdjustmentRequest retrieves the request and locks it until the end of the transaction,
+// so two operators cannot decide it at once. Must be called within WithinTx.
+func (s *Storage) LockAdjustmentRequest(ctx context.Context, requestId uuid.UUID) (models.AdjustmentRequest, error) {
+	const op = "storage.postgres.LockAdjustmentRequest"
+
+	return s.getAdjustmentRequest(ctx, op, "SELECT "+adjustmentColumns+" FROM adjustment_requests WHERE id = $1 FOR UPDATE", requestId)
+}
+
+func (s *Storage) getAdjustmentRequest(ctx context.Context, op string, query string, requestId uuid.UUID) (models.AdjustmentRequest, error) {
+	req, err := scanAdjustmentRequest(s.conn(ctx).QueryRowContext(ctx, query, requestId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, storage.ErrAdjustmentNotExists)
+		}
+
+		return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return req, nil
+}
+
+// DecideAdjustmentRequest records the decision on a pending request.
+func (s *Storage) DecideAdjustmentRequest(ctx context.Context, req models.AdjustmentRequest) (models.AdjustmentRequest, error) {
+	const op = "storage.postgres.DecideAdjustmentRequest"
+
+	decided, err := scanAdjustmentRequest(s.conn(ctx).QueryRowContext(ctx, `
+		UPDATE adjustment_requests
+		SET status = $2, decided_by = $3, decider = $4, decision_comment = $5, transaction_id = $6, decided_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'PENDING'
+		RETURNING `+adjustmentColumns,
+		req.Id, req.Status, req.DecidedBy, req.Decider, req.Comment, req.TransactionId,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, storage.ErrAdjustmentNotExists)
+		}
+
+		return models.AdjustmentRequest{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return decided, nil
+}
+
+// AdjustmentRequests lists requests, optionally of one status, newest first.
+func (s *Storage) AdjustmentRequests(ctx context.Context, status models.AdjustmentStatus, limit int) ([]models.AdjustmentRequest, error) {
+	const op = "storage.postgres.AdjustmentRequests"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		SELECT `+adjustmentColumns+` FROM adjustment_requests
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at DESC
+		LIMIT $2`,
+		status, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	requests := []models.AdjustmentRequest{}
+	for rows.Next() {
+		req, err := scanAdjustmentRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		requests = append(requests, req)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return requests, nil
+}
+
+func scanAdjustmentRequest(row rowScanner) (models.AdjustmentRequest, error) {
+	var req models.AdjustmentRequest
+	var transactionId uuid.NullUUID
+	var decidedAt sql.NullTime
+
+	err := row.Scan(&req.Id, &req.WalletId, &req.Amount, &req.Reason, &req.Status, &req.ProposedBy,
+		&req.DecidedBy, &req.Proposer, &req.Decider, &req.Comment, &transactionId, &req.CreatedAt, &decidedAt)
+	if err != nil {
+		return models.AdjustmentRequest{}, err
+	}
+
+	if transactionId.Valid {
+		req.TransactionId = &transactionId.UUID
+	}
+	if decidedAt.Valid {
+		req.DecidedAt = &decidedAt.Time
+	}
+
+	return req, nil
+}
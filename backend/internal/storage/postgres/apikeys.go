@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = `id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, rotated_from, owner, created_at,
+	COALESCE(expires_at <= CURRENT_TIMESTAMP, FALSE)`
+
+// SaveAPIKey saves api key to db. A positive expiresIn sets the expiry relative to the database clock.
+func (s *Storage) SaveAPIKey(ctx context.Context, key models.APIKey, expiresIn time.Duration) error {
+	const op = "storage.postgres.SaveAPIKey"
+
+	var expires sql.NullInt64
+	if expiresIn > 0 {
+		expires = sql.NullInt64{Int64: expiresIn.Milliseconds(), Valid: true}
+	}
+
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO api_keys(id, name, prefix, key_hash, scopes, expires_at, rotated_from, owner)
+		VALUES($1, $2, $3, $4, $5, CURRENT_TIMESTAMP + $6 * INTERVAL '1 millisecond', $7, $8)`,
+		key.Id, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), expires, key.RotatedFrom, key.Owner,
+	)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
+			return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetAPIKey retrieves api key from db.
+func (s *Storage) GetAPIKey(ctx context.Context, keyId uuid.UUID) (models.APIKey, error) {
+	const op = "storage.postgres.GetAPIKey"
+
+	key, err := scanAPIKey(s.conn(ctx).QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1", keyId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotExists)
+		}
+
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// APIKeyByPrefix retrieves api key by the public part of the key.
+func (s *Storage) APIKeyByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	const op = "storage.postgres.APIKeyByPrefix"
+
+	key, err := scanAPIKey(s.conn(ctx).QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE prefix = $1", prefix))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotExists)
+		}
+
+		return models.APIKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// APIKeys lists api keys, newest first.
+func (s *Storage) APIKeys(ctx context.Context) ([]models.APIKey, error) {
+	const op = "storage.postgres.APIKeys"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys ORDER BY created_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey disables the key immediately.
+func (s *Storage) RevokeAPIKey(ctx context.Context, keyId uuid.UUID) error {
+	const op = "storage.postgres.RevokeAPIKey"
+
+	res, err := s.conn(ctx).ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP) WHERE id = $1",
+		keyId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotExists)
+	}
+
+	return nil
+}
+
+// ExpireAPIKey shortens the lifetime of the key to at most expiresIn from now.
+func (s *Storage) ExpireAPIKey(ctx context.Context, keyId uuid.UUID, expiresIn time.Duration) error {
+	const op = "storage.postgres.ExpireAPIKey"
+
+	res, err := s.conn(ctx).ExecContext(ctx, `
+		UPDATE api_keys
+		SET expires_at = LEAST(
+			COALESCE(expires_at, 'infinity'::timestamp),
+			CURRENT_TIMESTAMP + $1 * INTERVAL '1 millisecond'
+		)
+		WHERE id = $2`,
+		expiresIn.Milliseconds(), keyId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotExists)
+	}
+
+	return nil
+}
+
+// TouchAPIKey records that the key has just been used.
+func (s *Storage) TouchAPIKey(ctx context.Context, keyId uuid.UUID) error {
+	const op = "storage.postgres.TouchAPIKey"
+
+	_, err := s.conn(ctx).ExecContext(ctx, "UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1", keyId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var key models.APIKey
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	var rotatedFrom uuid.NullUUID
+
+	err := row.Scan(&key.Id, &key.Name, &key.Prefix, &key.Hash, pq.Array(&key.Scopes),
+		&expiresAt, &lastUsedAt, &revokedAt, &rotatedFrom, &key.Owner, &key.CreatedAt, &key.Expired)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	if rotatedFrom.Valid {
+		key.RotatedFrom = &rotatedFrom.UUID
+	}
+
+	return key, nil
+}
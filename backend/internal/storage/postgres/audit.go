@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"coin-app/internal/domain/models"
+)
+
+// auditLockKey is the advisory lock serializing appends to the audit log.
+// It is one lock for the whole chain: a mutating request makes two appends, so all of them
+// together, whatever wallets they touch, are bounded by half the rate of appends.
+// The lock is held only by the short transaction of an append, never across a handler
+// or a scheduled run.
+// The cost is accepted for a single chain that audit-verify walks in order;
+// the load test runs with the audit log on, so its figure includes it.
+const auditLockKey = 0x6175646974 // "audit"
+
+const auditColumns = `seq, created_at, actor, client_ip, request_id, action, path, status, outcome, payload_digest, prev_hash, hash`
+
+// LockAuditLog takes the append lock of the audit log until the end of the transaction
+// and returns the database clock to stamp the next record with.
+// Must be called within WithinReadCommittedTx: a transaction with a snapshot taken
+// before the lock would read a stale head in LastAuditRecord and fork the chain.
+func (s *Storage) LockAuditLog(ctx context.Context) (time.Time, error) {
+	const op = "storage.postgres.LockAuditLog"
+
+	if _, err := s.conn(ctx).ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditLockKey); err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Read after the lock is held, so the clock never goes back along the chain
+	var now time.Time
+	if err := s.conn(ctx).QueryRowContext(ctx, "SELECT clock_timestamp()::timestamp").Scan(&now); err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return now, nil
+}
+
+// LastAuditRecord returns the head of the audit log, a zero record if it is empty.
+// Called after LockAuditLog in a READ COMMITTED transaction, it sees the record of the previous append.
+func (s *Storage) LastAuditRecord(ctx context.Context) (models.AuditRecord, error) {
+	const op = "storage.postgres.LastAuditRecord"
+
+	rec, err := scanAuditRecord(s.conn(ctx).QueryRowContext(ctx,
+		"SELECT "+auditColumns+" FROM audit_log ORDER BY seq DESC LIMIT 1",
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AuditRecord{}, nil
+		}
+
+		return models.AuditRecord{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rec, nil
+}
+
+// SaveAuditRecord appends a sealed record to the audit log.
+func (s *Storage) SaveAuditRecord(ctx context.Context, rec models.AuditRecord) error {
+	const op = "storage.postgres.SaveAuditRecord"
+
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO audit_log(`+auditColumns+`)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		rec.Seq, rec.CreatedAt, rec.Actor, rec.ClientIP, rec.RequestId, rec.Action, rec.Path,
+		rec.Status, rec.Outcome, rec.PayloadDigest, rec.PrevHash, rec.Hash,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AuditRecords returns records matching filter in seq order.
+// Outside a transaction they are read from a replica unless ctx requires strong consistency,
+// so the newest records may be missing until the replica catches up.
+func (s *Storage) AuditRecords(ctx context.Context, filter models.AuditFilter) ([]models.AuditRecord, error) {
+	const op = "storage.postgres.AuditRecords"
+
+	conn, replica := s.reader(ctx)
+
+	records, err := auditRecords(ctx, conn, filter)
+	if err != nil && replica != nil {
+		replica.healthy.Store(false)
+
+		records, err = auditRecords(ctx, s.db, filter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return records, nil
+}
+
+func auditRecords(ctx context.Context, conn executor, filter models.AuditFilter) ([]models.AuditRecord, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT `+auditColumns+` FROM audit_log
+		WHERE seq > $1
+			AND ($2 = '' OR actor = $2)
+			AND ($3 = '' OR request_id = $3)
+		ORDER BY seq
+		LIMIT $4`,
+		filter.AfterSeq, filter.Actor, filter.RequestId, filter.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []models.AuditRecord{}
+	for rows.Next() {
+		rec, err := scanAuditRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func scanAuditRecord(row rowScanner) (models.AuditRecord, error) {
+	var rec models.AuditRecord
+
+	err := row.Scan(&rec.Seq, &rec.CreatedAt, &rec.Actor, &rec.ClientIP, &rec.RequestId, &rec.Action, &rec.Path,
+		&rec.Status, &rec.Outcome, &rec.PayloadDigest, &rec.PrevHash, &rec.Hash)
+
+	return rec, err
+}
@@ -0,0 +1,205 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// The bulk statements below pass their rows as arrays and unnest them,
+// so a batch of any size is one round trip without a prepared statement per row.
+
+// SaveTransactions inserts ledger entries in one statement. Empty texts are stored as NULL.
+// A reference already used on a wallet returns storage.ErrTransactionExists.
+func (s *Storage) SaveTransactions(ctx context.Context, transactions []models.Transaction) error {
+	const op = "storage.postgres.SaveTransactions"
+
+	n := len(transactions)
+	ids, walletIds, types, amounts := make([]string, n), make([]string, n), make([]string, n), make([]string, n)
+	descriptions, metadata, references, feeFor := make([]string, n), make([]string, n), make([]string, n), make([]string, n)
+	for i, t := range transactions {
+		ids[i] = t.Id.String()
+		walletIds[i] = t.WalletId.String()
+		types[i] = t.OperationType
+		amounts[i] = strconv.FormatFloat(t.Amount, 'f', -1, 64)
+		descriptions[i] = t.Description
+		metadata[i] = string(t.Metadata)
+		references[i] = t.ExternalReference
+		if t.FeeFor != nil {
+			feeFor[i] = t.FeeFor.String()
+		}
+	}
+
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO transactions(id, wallet_id, operation_type, amount, description, metadata, external_reference, fee_for)
+		SELECT id::UUID, wallet_id::UUID, operation_type::operation_type, amount::NUMERIC,
+		       NULLIF(description, ''), NULLIF(metadata, '')::JSONB, NULLIF(external_reference, ''), NULLIF(fee_for, '')::UUID
+		FROM unnest($1::TEXT[], $2::TEXT[], $3::TEXT[], $4::TEXT[], $5::TEXT[], $6::TEXT[], $7::TEXT[], $8::TEXT[])
+			AS t(id, wallet_id, operation_type, amount, description, metadata, external_reference, fee_for)`,
+		pq.Array(ids), pq.Array(walletIds), pq.Array(types), pq.Array(amounts),
+		pq.Array(descriptions), pq.Array(metadata), pq.Array(references), pq.Array(feeFor),
+	)
+	if err != nil {
+		if pgErr, ok := err.(*pq.Error); ok {
+			switch pgErr.Code {
+			// 23503 - foreign_key_violation
+			case "23503":
+				return fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+			// 23505 - unique_violation, only the external reference can collide
+			case "23505":
+				return fmt.Errorf("%s: %w", op, storage.ErrTransactionExists)
+			}
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TransactionsByReferences returns the transactions posted with the references, refs[i] on walletIds[i].
+func (s *Storage) TransactionsByReferences(ctx context.Context, walletIds []uuid.UUID, refs []string) ([]models.Transaction, error) {
+	const op = "storage.postgres.TransactionsByReferences"
+
+	ids := make([]string, len(walletIds))
+	for i, id := range walletIds {
+		ids[i] = id.String()
+	}
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		SELECT t.id, t.wallet_id, t.operation_type, t.amount, t.description, t.metadata, t.external_reference,
+		       COALESCE((SELECT f.amount FROM transactions f WHERE f.fee_for = t.id AND f.amount > 0), 0)::BIGINT,
+		       t.created_at
+		FROM transactions t
+		JOIN unnest($1::UUID[], $2::TEXT[]) AS r(wallet_id, external_reference)
+			ON t.wallet_id = r.wallet_id AND t.external_reference = r.external_reference`,
+		pq.Array(ids), pq.Array(refs),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		var t models.Transaction
+		var description, externalReference sql.NullString
+		var metadata []byte
+		if err := rows.Scan(&t.Id, &t.WalletId, &t.OperationType, &t.Amount, &description, &metadata, &externalReference, &t.Fee, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		t.Description = description.String
+		t.Metadata = metadata
+		t.ExternalReference = externalReference.String
+		transactions = append(transactions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return transactions, nil
+}
+
+// LockWallets locks the wallets until the end of the transaction in the order of their ids,
+// so operations on the same wallets lock them in one order and cannot deadlock each other.
+// Missing wallets are skipped. Must be called within WithinTx.
+func (s *Storage) LockWallets(ctx context.Context, walletIds ...uuid.UUID) error {
+	const op = "storage.postgres.LockWallets"
+
+	ids := make([]string, len(walletIds))
+	for i, id := range walletIds {
+		ids[i] = id.String()
+	}
+
+	_, err := s.conn(ctx).ExecContext(ctx, `SELECT 1 FROM wallets WHERE id = ANY($1::UUID[]) ORDER BY id FOR UPDATE`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UpdateBalances changes the balances of several wallets by signed deltas and returns their new state.
+// The rows are locked in id order first, so concurrent batches do not deadlock. Call it within a transaction.
+// Wallets that do not exist are missing from the result.
+func (s *Storage) UpdateBalances(ctx context.Context, deltas map[uuid.UUID]int) (map[uuid.UUID]models.Wallet, error) {
+	const op = "storage.postgres.UpdateBalances"
+
+	walletIds := make([]uuid.UUID, 0, len(deltas))
+	for id := range deltas {
+		walletIds = append(walletIds, id)
+	}
+
+	if err := s.LockWallets(ctx, walletIds...); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ids, amounts := make([]string, len(walletIds)), make([]int64, len(walletIds))
+	for i, id := range walletIds {
+		ids[i] = id.String()
+		amounts[i] = int64(deltas[id])
+	}
+
+	conn := s.conn(ctx)
+
+	rows, err := conn.QueryContext(ctx, `
+		UPDATE wallets w SET balance = w.balance + d.amount, version = w.version + 1
+		FROM unnest($1::UUID[], $2::NUMERIC[]) AS d(id, amount)
+		WHERE w.id = d.id
+		RETURNING w.id, w.user_id, w.balance, w.currency, w.version, w.created_at, w.updated_at`,
+		pq.Array(ids), pq.Array(amounts),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	wallets := make(map[uuid.UUID]models.Wallet, len(ids))
+	for rows.Next() {
+		var wallet models.Wallet
+		if err := rows.Scan(&wallet.Id, &wallet.UserId, &wallet.Balance, &wallet.Currency, &wallet.Version, &wallet.CreatedAt, &wallet.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		wallets[wallet.Id] = wallet
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return wallets, nil
+}
+
+// SaveEvents writes events to the outbox in one statement, keeping their order.
+func (s *Storage) SaveEvents(ctx context.Context, events []models.Event) error {
+	const op = "storage.postgres.SaveEvents"
+
+	n := len(events)
+	ids, aggregateIds, types, payloads := make([]string, n), make([]string, n), make([]string, n), make([]string, n)
+	for i, event := range events {
+		ids[i] = event.Id.String()
+		aggregateIds[i] = event.AggregateId.String()
+		types[i] = string(event.Type)
+		payloads[i] = string(event.Payload)
+	}
+
+	// seq is assigned in the order of the rows
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO outbox_events(id, aggregate_id, event_type, payload)
+		SELECT id::UUID, aggregate_id::UUID, event_type, payload::JSONB
+		FROM unnest($1::TEXT[], $2::TEXT[], $3::TEXT[], $4::TEXT[]) WITH ORDINALITY AS e(id, aggregate_id, event_type, payload, n)
+		ORDER BY n`,
+		pq.Array(ids), pq.Array(aggregateIds), pq.Array(types), pq.Array(payloads),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// SaveFee posts a signed FEE entry linked to the operation it was charged on.
+func (s *Storage) SaveFee(ctx context.Context, transactionId uuid.UUID, walletId uuid.UUID, amount int, feeFor uuid.UUID) (uuid.UUID, error) {
+	const op = "storage.postgres.SaveFee"
+
+	var id uuid.UUID
+	err := s.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO transactions(id, wallet_id, operation_type, amount, fee_for)
+		VALUES($1, $2, 'FEE', $3, $4)
+		RETURNING id`,
+		transactionId, walletId, amount, feeFor,
+	).Scan(&id)
+	if err != nil {
+		// 23503 - foreign_key_violation
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return uuid.UUID{}, fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		}
+
+		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// AccrueFees posts FEE entries crediting revenue wallets and accrues them for SettleFees,
+// without touching the wallet rows.
+func (s *Storage) AccrueFees(ctx context.Context, fees []models.Transaction) error {
+	const op = "storage.postgres.AccrueFees"
+
+	n := len(fees)
+	ids, walletIds, amounts, feeFor := make([]string, n), make([]string, n), make([]int64, n), make([]string, n)
+	for i, f := range fees {
+		ids[i] = f.Id.String()
+		walletIds[i] = f.WalletId.String()
+		amounts[i] = int64(f.Amount)
+		if f.FeeFor != nil {
+			feeFor[i] = f.FeeFor.String()
+		}
+	}
+
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		WITH t AS (
+			INSERT INTO transactions(id, wallet_id, operation_type, amount, fee_for)
+			SELECT id::UUID, wallet_id::UUID, 'FEE', amount, NULLIF(fee_for, '')::UUID
+			FROM unnest($1::TEXT[], $2::TEXT[], $3::BIGINT[], $4::TEXT[]) AS f(id, wallet_id, amount, fee_for)
+			RETURNING id, wallet_id, amount
+		)
+		INSERT INTO fee_accruals(transaction_id, wallet_id, amount)
+		SELECT id, wallet_id, amount FROM t`,
+		pq.Array(ids), pq.Array(walletIds), pq.Array(amounts), pq.Array(feeFor),
+	)
+	if err != nil {
+		// 23503 - foreign_key_violation
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SettleFees credits up to limit of the oldest fee accruals to their wallets and removes them.
+// Accruals claimed by a concurrent settlement are skipped.
+// It returns the number of settled accruals and the credited wallets.
+func (s *Storage) SettleFees(ctx context.Context, limit int) (int, []models.Wallet, error) {
+	const op = "storage.postgres.SettleFees"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		WITH settled AS (
+			DELETE FROM fee_accruals
+			WHERE transaction_id IN (
+				SELECT transaction_id FROM fee_accruals
+				ORDER BY created_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING wallet_id, amount
+		)
+		UPDATE wallets w SET balance = w.balance + s.amount, version = w.version + 1
+		FROM (SELECT wallet_id, SUM(amount) AS amount FROM settled GROUP BY wallet_id) s
+		WHERE w.id = s.wallet_id
+		RETURNING w.id, w.user_id, w.balance, w.currency, w.version, w.created_at, w.updated_at,
+		          (SELECT COUNT(*) FROM settled)`,
+		limit,
+	)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var settled int
+	var wallets []models.Wallet
+	for rows.Next() {
+		var wallet models.Wallet
+		if err := rows.Scan(&wallet.Id, &wallet.UserId, &wallet.Balance, &wallet.Currency, &wallet.Version, &wallet.CreatedAt, &wallet.UpdatedAt, &settled); err != nil {
+			return 0, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		wallets = append(wallets, wallet)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return settled, wallets, nil
+}
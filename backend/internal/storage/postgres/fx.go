@@ -0,0 +1,234 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const quoteColumns = `id, from_wallet_id, to_wallet_id, from_currency, to_currency, sell_amount, buy_amount::TEXT,
+	rate::TEXT, spread::TEXT, applied_rate::TEXT, expires_at, created_by, created_at, executed_at,
+	debit_transaction_id, credit_transaction_id`
+
+// SaveRates stores the rates, a rate already stored for the pair
+// and the same effective time is replaced.
+func (s *Storage) SaveRates(ctx context.Context, rates []models.FXRate) error {
+	const op = "storage.postgres.SaveRates"
+
+	stmt, err := s.conn(ctx).PrepareContext(ctx, `
+		INSERT INTO fx_rates(base, quote, rate, effective_at, source)
+		VALUES($1, $2, $3, $4, $5)
+		ON CONFLICT (base, quote, effective_at) DO UPDATE SET
+			rate = EXCLUDED.rate,
+			source = EXCLUDED.source,
+			created_at = CURRENT_TIMESTAMP`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	for _, rate := range rates {
+		if _, err := stmt.ExecContext(ctx, rate.Base, rate.Quote, rate.Rate, rate.EffectiveAt, rate.Source); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// CurrentRate returns the latest rate of the pair effective by the database clock.
+func (s *Storage) CurrentRate(ctx context.Context, base, quote string) (models.FXRate, error) {
+	const op = "storage.postgres.CurrentRate"
+
+	rate, err := scanRate(s.conn(ctx).QueryRowContext(ctx, `
+		SELECT base, quote, rate::TEXT, effective_at, source
+		FROM fx_rates
+		WHERE base = $1 AND quote = $2 AND effective_at <= now()
+		ORDER BY effective_at DESC
+		LIMIT 1`,
+		base, quote,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.FXRate{}, fmt.Errorf("%s: %w", op, storage.ErrRateNotExists)
+		}
+
+		return models.FXRate{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rate, nil
+}
+
+// CurrentRates returns the rate effective now of every pair.
+func (s *Storage) CurrentRates(ctx context.Context) ([]models.FXRate, error) {
+	const op = "storage.postgres.CurrentRates"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		SELECT DISTINCT ON (base, quote) base, quote, rate::TEXT, effective_at, source
+		FROM fx_rates
+		WHERE effective_at <= now()
+		ORDER BY base, quote, effective_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	rates := []models.FXRate{}
+	for rows.Next() {
+		rate, err := scanRate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rates, nil
+}
+
+// SaveQuote saves a new quote.
+func (s *Storage) SaveQuote(ctx context.Context, q models.FXQuote) error {
+	const op = "storage.postgres.SaveQuote"
+
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO fx_quotes(id, from_wallet_id, to_wallet_id, from_currency, to_currency, sell_amount, buy_amount,
+			rate, spread, applied_rate, expires_at, created_by)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		q.Id, q.FromWalletId, q.ToWalletId, q.FromCurrency, q.ToCurrency, q.SellAmount, q.BuyAmount,
+		q.Rate, q.Spread, q.AppliedRate, q.ExpiresAt, q.CreatedBy,
+	)
+	if err != nil {
+		// 23503 - foreign_key_violation
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetQuote retrieves the quote.
+func (s *Storage) GetQuote(ctx context.Context, quoteId uuid.UUID) (models.FXQuote, error) {
+	const op = "storage.postgres.GetQuote"
+
+	return s.getQuote(ctx, op, "SELECT "+quoteColumns+" FROM fx_quotes WHERE id = $1", quoteId)
+}
+
+// LockQuote retrieves the quote and locks it until the end of the transaction,
+// so it is executed once.
+func (s *Storage) LockQuote(ctx context.Context, quoteId uuid.UUID) (models.FXQuote, error) {
+	const op = "storage.postgres.LockQuote"
+
+	return s.getQuote(ctx, op, "SELECT "+quoteColumns+" FROM fx_quotes WHERE id = $1 FOR UPDATE", quoteId)
+}
+
+// ExecuteQuote marks the quote executed with the transactions of both legs.
+func (s *Storage) ExecuteQuote(ctx context.Context, quoteId, debitId, creditId uuid.UUID) (models.FXQuote, error) {
+	const op = "storage.postgres.ExecuteQuote"
+
+	return s.getQuote(ctx, op, `
+		UPDATE fx_quotes
+		SET executed_at = CURRENT_TIMESTAMP, debit_transaction_id = $2, credit_transaction_id = $3
+		WHERE id = $1 AND executed_at IS NULL
+		RETURNING `+quoteColumns,
+		quoteId, debitId, creditId,
+	)
+}
+
+// SaveConversionTransaction posts a leg of the conversion quoted by q with its rate and spread,
+// amount is a decimal string in the currency of the wallet.
+func (s *Storage) SaveConversionTransaction(
+	ctx context.Context,
+	transactionId uuid.UUID,
+	walletId uuid.UUID,
+	operationType string,
+	amount string,
+	q models.FXQuote,
+) (uuid.UUID, error) {
+	const op = "storage.postgres.SaveConversionTransaction"
+
+	var id uuid.UUID
+	err := s.conn(ctx).QueryRowContext(ctx, `
+		INSERT INTO transactions(id, wallet_id, operation_type, amount, description, fx_quote_id, fx_rate, fx_spread)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		transactionId, walletId, operationType, amount,
+		fmt.Sprintf("Conversion %s to %s", q.FromCurrency, q.ToCurrency), q.Id, q.AppliedRate, q.Spread,
+	).Scan(&id)
+	if err != nil {
+		// 23503 - foreign_key_violation
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return uuid.UUID{}, fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		}
+
+		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+func (s *Storage) getQuote(ctx context.Context, op string, query string, args ...any) (models.FXQuote, error) {
+	var q models.FXQuote
+	var executedAt sql.NullTime
+	var debitId, creditId uuid.NullUUID
+
+	err := s.conn(ctx).QueryRowContext(ctx, query, args...).Scan(&q.Id, &q.FromWalletId, &q.ToWalletId,
+		&q.FromCurrency, &q.ToCurrency, &q.SellAmount, &q.BuyAmount, &q.Rate, &q.Spread, &q.AppliedRate,
+		&q.ExpiresAt, &q.CreatedBy, &q.CreatedAt, &executedAt, &debitId, &creditId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.FXQuote{}, fmt.Errorf("%s: %w", op, storage.ErrQuoteNotExists)
+		}
+
+		return models.FXQuote{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	q.BuyAmount = trimZeros(q.BuyAmount)
+	q.Rate = trimZeros(q.Rate)
+	q.Spread = trimZeros(q.Spread)
+	q.AppliedRate = trimZeros(q.AppliedRate)
+	if executedAt.Valid {
+		q.ExecutedAt = &executedAt.Time
+	}
+	if debitId.Valid {
+		q.DebitTransactionId = &debitId.UUID
+	}
+	if creditId.Valid {
+		q.CreditTransactionId = &creditId.UUID
+	}
+
+	return q, nil
+}
+
+func scanRate(row rowScanner) (models.FXRate, error) {
+	var rate models.FXRate
+
+	err := row.Scan(&rate.Base, &rate.Quote, &rate.Rate, &rate.EffectiveAt, &rate.Source)
+	if err != nil {
+		return models.FXRate{}, err
+	}
+	rate.Rate = trimZeros(rate.Rate)
+
+	return rate, nil
+}
+
+// trimZeros drops the trailing zeros NUMERIC pads its scale with, "92.3400000000" becomes "92.34".
+func trimZeros(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+
+	return strings.TrimRight(strings.TrimRight(s, "0"), ".")
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// NonceStore remembers request nonces in postgres, so that a request
+// replayed against another replica is rejected too.
+type NonceStore struct {
+	s *Storage
+}
+
+func NewNonceStore(s *Storage) *NonceStore {
+	return &NonceStore{s: s}
+}
+
+// Remember stores the nonce of clientId for ttl and reports whether it was unseen.
+// A nonce whose previous use has expired counts as unseen.
+func (n *NonceStore) Remember(ctx context.Context, clientId string, nonce string, ttl time.Duration) (bool, error) {
+	const op = "storage.postgres.NonceStore.Remember"
+
+	res, err := n.s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO request_nonces(client_id, nonce, expires_at)
+		VALUES($1, $2, CURRENT_TIMESTAMP + $3 * INTERVAL '1 millisecond')
+		ON CONFLICT (client_id, nonce) DO UPDATE SET expires_at = EXCLUDED.expires_at
+		WHERE request_nonces.expires_at <= CURRENT_TIMESTAMP`,
+		clientId, nonce, ttl.Milliseconds(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return inserted == 1, nil
+}
+
+// DeleteExpired removes nonces that can no longer be replayed.
+func (n *NonceStore) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "storage.postgres.NonceStore.DeleteExpired"
+
+	res, err := n.s.db.ExecContext(ctx, "DELETE FROM request_nonces WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deleted, nil
+}
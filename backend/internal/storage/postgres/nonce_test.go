@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestNonceStoreRemember uses the migrated database in TEST_POSTGRES_DSN from two stores,
+// as two replicas would, and checks a nonce is accepted once until it expires.
+func TestNonceStoreRemember(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	s, err := New(Options{DSN: dsn, MaxOpenConns: 4, Isolation: sql.LevelSerializable})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	clientId := "test-" + uuid.NewString()
+	t.Cleanup(func() {
+		_, _ = s.db.Exec("DELETE FROM request_nonces WHERE client_id = $1", clientId)
+	})
+
+	ctx := context.Background()
+	replicas := []*NonceStore{NewNonceStore(s), NewNonceStore(s)}
+
+	steps := []struct {
+		name    string
+		replica int
+		nonce   string
+		ttl     time.Duration
+		wait    time.Duration
+		fresh   bool
+	}{
+		{name: "first use", replica: 0, nonce: "n-1", ttl: 500 * time.Millisecond, fresh: true},
+		{name: "replay on the same replica", replica: 0, nonce: "n-1", ttl: 500 * time.Millisecond},
+		{name: "replay on another replica", replica: 1, nonce: "n-1", ttl: 500 * time.Millisecond},
+		{name: "another nonce", replica: 1, nonce: "n-2", ttl: 500 * time.Millisecond, fresh: true},
+		{name: "after expiry", replica: 1, nonce: "n-1", ttl: 500 * time.Millisecond, wait: 600 * time.Millisecond, fresh: true},
+		{name: "replay after reuse", replica: 0, nonce: "n-1", ttl: 500 * time.Millisecond},
+	}
+
+	for _, step := range steps {
+		time.Sleep(step.wait)
+
+		fresh, err := replicas[step.replica].Remember(ctx, clientId, step.nonce, step.ttl)
+		if err != nil {
+			t.Fatalf("%s: Remember() error = %v", step.name, err)
+		}
+		if fresh != step.fresh {
+			t.Errorf("%s: Remember() = %v, want %v", step.name, fresh, step.fresh)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"coin-app/internal/domain/models"
+
+	"github.com/google/uuid"
+)
+
+// SaveEvent saves event to the outbox.
+// Call it within the transaction that changes the aggregate.
+func (s *Storage) SaveEvent(ctx context.Context, event models.Event) error {
+	const op = "storage.postgres.SaveEvent"
+
+	stmt, err := s.conn(ctx).PrepareContext(ctx, "INSERT INTO outbox_events(id, aggregate_id, event_type, payload) VALUES($1, $2, $3, $4)")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, event.Id, event.AggregateId, event.Type, []byte(event.Payload))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ClaimEvents leases unpublished events that are due for delivery and returns them in order.
+// Only the oldest unpublished event of every aggregate is returned,
+// so events of one wallet are never published out of order.
+// A claimed event is not due again until the lease expires, so the caller publishes it
+// without holding a transaction and another relay takes it over if the caller dies.
+// Rows claimed concurrently by another relay are skipped.
+func (s *Storage) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]models.Event, error) {
+	const op = "storage.postgres.ClaimEvents"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		WITH claimed AS (
+			UPDATE outbox_events SET next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 millisecond'
+			WHERE seq IN (
+				SELECT e.seq
+				FROM outbox_events e
+				WHERE e.published_at IS NULL
+				  AND e.next_attempt_at <= CURRENT_TIMESTAMP
+				  AND NOT EXISTS (
+					SELECT 1 FROM outbox_events p
+					WHERE p.aggregate_id = e.aggregate_id
+					  AND p.published_at IS NULL
+					  AND p.seq < e.seq
+				  )
+				ORDER BY e.seq
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING seq, id, aggregate_id, event_type, payload, created_at, attempts
+		)
+		SELECT id, aggregate_id, event_type, payload, created_at, attempts FROM claimed ORDER BY seq`,
+		limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.Event
+	for rows.Next() {
+		var event models.Event
+		var payload []byte
+		if err := rows.Scan(&event.Id, &event.AggregateId, &event.Type, &payload, &event.CreatedAt, &event.Attempts); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return events, nil
+}
+
+// MarkEventPublished marks event as delivered.
+func (s *Storage) MarkEventPublished(ctx context.Context, eventId uuid.UUID) error {
+	const op = "storage.postgres.MarkEventPublished"
+
+	_, err := s.conn(ctx).ExecContext(ctx, "UPDATE outbox_events SET published_at = CURRENT_TIMESTAMP, last_error = NULL WHERE id = $1", eventId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MarkEventFailed records a failed delivery and schedules the next attempt.
+func (s *Storage) MarkEventFailed(ctx context.Context, eventId uuid.UUID, retryIn time.Duration, reason string) error {
+	const op = "storage.postgres.MarkEventFailed"
+
+	_, err := s.conn(ctx).ExecContext(ctx,
+		"UPDATE outbox_events SET attempts = attempts + 1, next_attempt_at = CURRENT_TIMESTAMP + $1 * INTERVAL '1 millisecond', last_error = $2 WHERE id = $3",
+		retryIn.Milliseconds(), reason, eventId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+)
+
+// TestClaimEventsReclaimsExpiredLeases saves events to the migrated database in TEST_POSTGRES_DSN
+// and checks a claimed event is withheld until its lease expires, one event of a wallet at a time.
+func TestClaimEventsReclaimsExpiredLeases(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	s, err := New(Options{DSN: dsn, MaxOpenConns: 4, Isolation: sql.LevelSerializable})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	ctx := context.Background()
+	wallet, other := uuid.New(), uuid.New()
+	t.Cleanup(func() {
+		_, _ = s.db.Exec("DELETE FROM outbox_events WHERE aggregate_id IN ($1, $2)", wallet, other)
+	})
+
+	save := func(aggregateId uuid.UUID) uuid.UUID {
+		event := models.Event{Id: uuid.New(), AggregateId: aggregateId, Type: models.EventFundsDeposited, Payload: []byte(`{}`)}
+		if err := s.SaveEvent(ctx, event); err != nil {
+			t.Fatalf("SaveEvent() error = %v", err)
+		}
+
+		return event.Id
+	}
+	first, second, unrelated := save(wallet), save(wallet), save(other)
+
+	const lease = time.Second
+	steps := []struct {
+		name string
+		// before runs ahead of the claim
+		before func()
+		want   []uuid.UUID
+	}{
+		{name: "oldest event of every wallet", want: []uuid.UUID{first, unrelated}},
+		{name: "lease held"},
+		{name: "lease expired", before: func() { time.Sleep(lease + 200*time.Millisecond) }, want: []uuid.UUID{first, unrelated}},
+		{
+			name: "next event once the first is published",
+			before: func() {
+				if err := s.MarkEventPublished(ctx, first); err != nil {
+					t.Fatalf("MarkEventPublished() error = %v", err)
+				}
+			},
+			want: []uuid.UUID{second},
+		},
+		{
+			name: "failed event due after its backoff",
+			before: func() {
+				if err := s.MarkEventFailed(ctx, second, 0, "broker unavailable"); err != nil {
+					t.Fatalf("MarkEventFailed() error = %v", err)
+				}
+			},
+			want: []uuid.UUID{second},
+		},
+	}
+
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+
+		// Events of other tests may be pending too, only ours are checked
+		events, err := s.ClaimEvents(ctx, 1000, lease)
+		if err != nil {
+			t.Fatalf("%s: ClaimEvents() error = %v", step.name, err)
+		}
+		var claimed []uuid.UUID
+		for _, event := range events {
+			if event.AggregateId == wallet || event.AggregateId == other {
+				claimed = append(claimed, event.Id)
+			}
+		}
+
+		if !slices.Equal(claimed, step.want) {
+			t.Errorf("%s: claimed %v, want %v", step.name, claimed, step.want)
+		}
+	}
+}
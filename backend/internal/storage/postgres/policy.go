@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// GetWalletPolicy retrieves the tier and overrides assigned to the wallet.
+func (s *Storage) GetWalletPolicy(ctx context.Context, walletId uuid.UUID) (models.WalletPolicy, error) {
+	const op = "storage.postgres.GetWalletPolicy"
+
+	p := models.WalletPolicy{WalletId: walletId}
+	var maxBalance, minAmount, maxAmount, daily, monthly sql.NullInt64
+	var updatedAt sql.NullTime
+
+	err := s.conn(ctx).QueryRowContext(ctx, `
+		SELECT tier, max_balance, min_amount, max_amount, daily_withdrawal, monthly_withdrawal, updated_at
+		FROM wallet_policies
+		WHERE wallet_id = $1`,
+		walletId,
+	).Scan(&p.Tier, &maxBalance, &minAmount, &maxAmount, &daily, &monthly, &updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WalletPolicy{}, fmt.Errorf("%s: %w", op, storage.ErrPolicyNotExists)
+		}
+
+		return models.WalletPolicy{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	p.Overrides = models.Limits{
+		MaxBalance:        nullInt(maxBalance),
+		MinAmount:         nullInt(minAmount),
+		MaxAmount:         nullInt(maxAmount),
+		DailyWithdrawal:   nullInt(daily),
+		MonthlyWithdrawal: nullInt(monthly),
+	}
+	if updatedAt.Valid {
+		p.UpdatedAt = &updatedAt.Time
+	}
+
+	return p, nil
+}
+
+// SaveWalletPolicy assigns the tier and replaces the overrides of the wallet.
+func (s *Storage) SaveWalletPolicy(ctx context.Context, p models.WalletPolicy) error {
+	const op = "storage.postgres.SaveWalletPolicy"
+
+	o := p.Overrides
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO wallet_policies(wallet_id, tier, max_balance, min_amount, max_amount, daily_withdrawal, monthly_withdrawal)
+		VALUES($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (wallet_id) DO UPDATE SET
+			tier = EXCLUDED.tier,
+			max_balance = EXCLUDED.max_balance,
+			min_amount = EXCLUDED.min_amount,
+			max_amount = EXCLUDED.max_amount,
+			daily_withdrawal = EXCLUDED.daily_withdrawal,
+			monthly_withdrawal = EXCLUDED.monthly_withdrawal,
+			updated_at = CURRENT_TIMESTAMP`,
+		p.WalletId, p.Tier, o.MaxBalance, o.MinAmount, o.MaxAmount, o.DailyWithdrawal, o.MonthlyWithdrawal,
+	)
+	if err != nil {
+		// 23503 - foreign_key_violation
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// WithdrawalTotals sums the withdrawals of the wallet since the start of the current day and month
+// by the database clock. Within a transaction that has updated the wallet the row is locked,
+// so concurrent withdrawals are counted one after another.
+func (s *Storage) WithdrawalTotals(ctx context.Context, walletId uuid.UUID) (daily int, monthly int, err error) {
+	const op = "storage.postgres.WithdrawalTotals"
+
+	err = s.conn(ctx).QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(amount) FILTER (WHERE created_at >= date_trunc('day', LOCALTIMESTAMP)), 0)::BIGINT,
+			COALESCE(SUM(amount), 0)::BIGINT
+		FROM transactions
+		WHERE wallet_id = $1
+			AND operation_type = 'WITHDRAW'
+			AND created_at >= date_trunc('month', LOCALTIMESTAMP)`,
+		walletId,
+	).Scan(&daily, &monthly)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return daily, monthly, nil
+}
+
+func nullInt(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+
+	i := int(v.Int64)
+
+	return &i
+}
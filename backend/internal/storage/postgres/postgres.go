@@ -3,8 +3,12 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"coin-app/internal/domain/models"
 	"coin-app/internal/storage"
@@ -14,36 +18,226 @@ import (
 )
 
 type Storage struct {
-	db *sql.DB
+	db        *sql.DB
+	replicas  []*replica
+	next      atomic.Uint64
+	stop      chan struct{}
+	wg        sync.WaitGroup
+	isolation sql.IsolationLevel
+	retry     RetryPolicy
 }
 
-func New() (*Storage, error) {
+type Options struct {
+	// DSN of the primary, e.g. "host=dbase user=postgres dbname=storage sslmode=disable".
+	DSN             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	Replicas        ReplicaOptions
+	// Isolation of transactions started by WithinTx.
+	Isolation sql.IsolationLevel
+	Retry     RetryPolicy
+}
+
+// executor is implemented by both *sql.DB and *sql.Tx.
+type executor interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// txState is the transaction bound to a context and what runs once it commits.
+type txState struct {
+	tx          *sql.Tx
+	afterCommit []func()
+}
+
+// txFrom returns the transaction state bound to ctx, nil if there is none.
+func txFrom(ctx context.Context) *txState {
+	state, _ := ctx.Value(txKey{}).(*txState)
+
+	return state
+}
+
+func New(opts Options) (*Storage, error) {
 	const op = "storage.postgres.New"
 
-	user := os.Getenv("POSTGRES_USER")
-	password := os.Getenv("POSTGRES_PASSWORD")
-	dbname := os.Getenv("POSTGRES_DB")
+	db, err := sql.Open("postgres", opts.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
 
-	connStr := fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=disable", "dbase", user, password, dbname)
-	db, err := sql.Open("postgres", connStr)
+	replicas, err := openReplicas(opts.Replicas.DSNs)
 	if err != nil {
+		db.Close()
+
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Storage{db: db}, nil
+	if opts.Retry.MaxAttempts < 1 {
+		opts.Retry.MaxAttempts = 1
+	}
+
+	s := &Storage{
+		db:        db,
+		replicas:  replicas,
+		stop:      make(chan struct{}),
+		isolation: opts.Isolation,
+		retry:     opts.Retry,
+	}
+
+	if len(replicas) > 0 {
+		s.wg.Add(1)
+		go s.checkReplicas(opts.Replicas)
+	}
+
+	return s, nil
+}
+
+// Close stops replica health checks and closes all connections.
+func (s *Storage) Close() error {
+	const op = "storage.postgres.Close"
+
+	close(s.stop)
+	s.wg.Wait()
+
+	for _, r := range s.replicas {
+		r.db.Close()
+	}
+
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// DB returns the primary connection pool, e.g. to export its stats.
+func (s *Storage) DB() *sql.DB {
+	return s.db
+}
+
+// WithinTx runs fn in a database transaction.
+// Storage calls made with the context passed to fn are executed in that transaction.
+// If fn returns an error, the transaction is rolled back.
+// Nested calls reuse the outer transaction.
+// Serialization failures and deadlocks roll the transaction back and run fn again
+// according to the retry policy; once it is exhausted the error wraps storage.ErrConflict.
+func (s *Storage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if txFrom(ctx) != nil {
+		return fn(ctx)
+	}
+
+	return s.retryTx(ctx, s.isolation, fn)
+}
+
+// WithinReadCommittedTx runs fn in a new READ COMMITTED transaction, whatever the configured
+// isolation and even if ctx is bound to a transaction: fn commits on its own.
+// It is meant for appends serialized by a lock of their own, e.g. LockAuditLog: every statement
+// after the lock sees what the previous holder committed, which a snapshot taken before it would not.
+// Conflicts are retried like in WithinTx.
+func (s *Storage) WithinReadCommittedTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.retryTx(s.WithoutTx(ctx), sql.LevelReadCommitted, fn)
+}
+
+// WithoutTx returns a copy of ctx that is not bound to its transaction, if any.
+// Storage calls made with it commit on their own even if the outer transaction rolls back.
+func (s *Storage) WithoutTx(ctx context.Context) context.Context {
+	return context.WithValue(ctx, txKey{}, nil)
+}
+
+// AfterCommit runs fn once the transaction bound to ctx commits, or right away if there is none.
+// A nested WithinTx returns before the outermost transaction commits, so whatever must only see
+// committed state, e.g. a cache update, is queued here. Nothing runs if the transaction rolls back,
+// and a retried transaction drops what the failed attempt queued.
+func (s *Storage) AfterCommit(ctx context.Context, fn func()) {
+	if state := txFrom(ctx); state != nil {
+		state.afterCommit = append(state.afterCommit, fn)
+
+		return
+	}
+
+	fn()
+}
+
+// retryTx runs fn in a new transaction and runs it again on conflicts according to the retry policy.
+func (s *Storage) retryTx(ctx context.Context, isolation sql.IsolationLevel, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := s.runTx(ctx, isolation, fn)
+
+		reason := conflictReason(err)
+		if reason == "" {
+			return err
+		}
+
+		if attempt >= s.retry.MaxAttempts || !wait(ctx, s.retry.backoff(attempt)) {
+			if s.retry.OnGiveUp != nil {
+				s.retry.OnGiveUp(reason)
+			}
+
+			return fmt.Errorf("%w: %w", storage.ErrConflict, err)
+		}
+
+		if s.retry.OnRetry != nil {
+			s.retry.OnRetry(reason)
+		}
+	}
+}
+
+func (s *Storage) runTx(ctx context.Context, isolation sql.IsolationLevel, fn func(ctx context.Context) error) error {
+	const op = "storage.postgres.WithinTx"
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: isolation})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	state := &txState{tx: tx}
+	if err := fn(context.WithValue(ctx, txKey{}, state)); err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, fn := range state.afterCommit {
+		fn()
+	}
+
+	return nil
+}
+
+// conn returns the transaction bound to ctx or the db itself.
+func (s *Storage) conn(ctx context.Context) executor {
+	if state := txFrom(ctx); state != nil {
+		return state.tx
+	}
+
+	return s.db
 }
 
 // SaveWallet saves wallet to db.
-func (s *Storage) SaveWallet(ctx context.Context, walletId uuid.UUID, userId uuid.UUID, balance int) (uuid.UUID, error) {
+func (s *Storage) SaveWallet(ctx context.Context, walletId uuid.UUID, userId uuid.UUID, balance int, currency string) (uuid.UUID, error) {
 	const op = "storage.postgres.SaveWallet"
 
-	stmt, err := s.db.Prepare("INSERT INTO wallets(id, user_id, balance) VALUES($1, $2, $3) RETURNING id")
+	stmt, err := s.conn(ctx).PrepareContext(ctx, "INSERT INTO wallets(id, user_id, balance, opening_balance, currency) VALUES($1, $2, $3, $3, $4) RETURNING id")
 	if err != nil {
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
 	}
+	defer stmt.Close()
 
 	var id uuid.UUID
-	err = stmt.QueryRowContext(ctx, walletId, userId, balance).Scan(&id)
+	err = stmt.QueryRowContext(ctx, walletId, userId, balance, currency).Scan(&id)
 	if err != nil {
 		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
 			return uuid.UUID{}, fmt.Errorf("%s: %w", op, storage.ErrWalletExists)
@@ -56,19 +250,40 @@ func (s *Storage) SaveWallet(ctx context.Context, walletId uuid.UUID, userId uui
 }
 
 // SaveTransaction saves deposit to db.
-func (s *Storage) SaveTransaction(ctx context.Context, transactionId uuid.UUID, walletId uuid.UUID, operationType string, amount int) (uuid.UUID, error) {
+// A reference already used on the wallet returns storage.ErrTransactionExists.
+func (s *Storage) SaveTransaction(
+	ctx context.Context,
+	transactionId uuid.UUID,
+	walletId uuid.UUID,
+	operationType string,
+	amount int,
+	details models.TransactionDetails,
+) (uuid.UUID, error) {
 	const op = "storage.postgres.SaveDeposit"
 
-	stmt, err := s.db.Prepare("INSERT INTO transactions(id, wallet_id, operation_type, amount) VALUES($1, $2, $3, $4) RETURNING id")
+	stmt, err := s.conn(ctx).PrepareContext(ctx, `
+		INSERT INTO transactions(id, wallet_id, operation_type, amount, description, metadata, external_reference)
+		VALUES($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''))
+		RETURNING id`)
 	if err != nil {
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
 	}
+	defer stmt.Close()
 
 	var id uuid.UUID
-	err = stmt.QueryRowContext(ctx, transactionId, walletId, operationType, amount).Scan(&id)
+	err = stmt.QueryRowContext(ctx, transactionId, walletId, operationType, amount,
+		details.Description, nullJSON(details.Metadata), details.ExternalReference,
+	).Scan(&id)
 	if err != nil {
-		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23505" {
-			return uuid.UUID{}, fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		if pgErr, ok := err.(*pq.Error); ok {
+			switch pgErr.Code {
+			// 23503 - foreign_key_violation
+			case "23503":
+				return uuid.UUID{}, fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+			// 23505 - unique_violation, only the external reference can collide
+			case "23505":
+				return uuid.UUID{}, fmt.Errorf("%s: %w", op, storage.ErrTransactionExists)
+			}
 		}
 
 		return uuid.UUID{}, fmt.Errorf("%s: %w", op, err)
@@ -77,41 +292,141 @@ func (s *Storage) SaveTransaction(ctx context.Context, transactionId uuid.UUID,
 	return id, nil
 }
 
-// UpdateWallet updates wallet to db.
-func (s *Storage) UpdateBalance(ctx context.Context, walletId uuid.UUID, amount int) error {
+// TransactionByReference retrieves the transaction posted with the external reference on the wallet.
+// Outside a transaction it is read from a replica unless ctx requires strong consistency.
+// If the replica fails or does not know the transaction yet, the primary is asked.
+func (s *Storage) TransactionByReference(ctx context.Context, walletId uuid.UUID, reference string) (models.Transaction, error) {
+	const op = "storage.postgres.TransactionByReference"
+
+	conn, replica := s.reader(ctx)
+
+	t, err := transactionByReference(ctx, conn, walletId, reference)
+	if err != nil && replica != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			replica.healthy.Store(false)
+		}
+
+		t, err = transactionByReference(ctx, s.db, walletId, reference)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Transaction{}, fmt.Errorf("%s: %w", op, storage.ErrTransactionNotExists)
+		}
+
+		return models.Transaction{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return t, nil
+}
+
+func transactionByReference(ctx context.Context, conn executor, walletId uuid.UUID, reference string) (models.Transaction, error) {
+	var t models.Transaction
+	var description, externalReference sql.NullString
+	var metadata []byte
+
+	// The fee is the credit of the revenue wallet, a deposit also has the matching debit
+	err := conn.QueryRowContext(ctx, `
+		SELECT t.id, t.wallet_id, t.operation_type, t.amount, t.description, t.metadata, t.external_reference,
+		       COALESCE((SELECT f.amount FROM transactions f WHERE f.fee_for = t.id AND f.amount > 0), 0)::BIGINT,
+		       t.fee_for, t.created_at
+		FROM transactions t
+		WHERE t.wallet_id = $1 AND t.external_reference = $2`,
+		walletId, reference,
+	).Scan(&t.Id, &t.WalletId, &t.OperationType, &t.Amount, &description, &metadata, &externalReference, &t.Fee, &t.FeeFor, &t.CreatedAt)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	t.Description = description.String
+	t.Metadata = metadata
+	t.ExternalReference = externalReference.String
+
+	return t, nil
+}
+
+// nullJSON passes an empty document as NULL and the rest as text, pq would send []byte as bytea.
+func nullJSON(doc json.RawMessage) any {
+	if len(doc) == 0 {
+		return nil
+	}
+
+	return string(doc)
+}
+
+// UpdateWallet updates wallet to db and returns its new state.
+func (s *Storage) UpdateBalance(ctx context.Context, walletId uuid.UUID, amount int) (models.Wallet, error) {
 	const op = "storage.postgres.UpdateWallet"
 
-	stmt, err := s.db.Prepare("UPDATE wallets SET balance = balance + $1 WHERE id = $2")
+	return s.updateBalance(ctx, op, walletId, amount)
+}
+
+// UpdateBalanceDecimal changes the balance by a signed decimal string such as "-12.5",
+// for amounts with a fractional part.
+func (s *Storage) UpdateBalanceDecimal(ctx context.Context, walletId uuid.UUID, amount string) (models.Wallet, error) {
+	const op = "storage.postgres.UpdateBalanceDecimal"
+
+	return s.updateBalance(ctx, op, walletId, amount)
+}
+
+func (s *Storage) updateBalance(ctx context.Context, op string, walletId uuid.UUID, amount any) (models.Wallet, error) {
+	stmt, err := s.conn(ctx).PrepareContext(ctx, `
+		UPDATE wallets SET balance = balance + $1::NUMERIC, version = version + 1
+		WHERE id = $2
+		RETURNING id, user_id, balance, currency, version, created_at, updated_at`)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return models.Wallet{}, fmt.Errorf("%s: %w", op, err)
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, amount, walletId)
+	var wallet models.Wallet
+	err = stmt.QueryRowContext(ctx, amount, walletId).Scan(&wallet.Id, &wallet.UserId, &wallet.Balance, &wallet.Currency, &wallet.Version, &wallet.CreatedAt, &wallet.UpdatedAt)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Wallet{}, fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		}
+		return models.Wallet{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return nil
+	return wallet, nil
 }
 
 // GetWallet retrieves wallet from db.
+// The wallet is read from a replica unless ctx requires strong consistency.
+// If the replica fails or does not know the wallet yet, the primary is asked.
 func (s *Storage) GetWallet(ctx context.Context, walletId uuid.UUID) (models.Wallet, error) {
 	const op = "storage.postgres.GetWallet"
 
-	stmt, err := s.db.Prepare("SELECT id, user_id, balance, created_at, updated_at FROM wallets WHERE id = $1")
+	conn, replica := s.reader(ctx)
+
+	wallet, err := getWallet(ctx, conn, walletId)
+	if err != nil && replica != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			replica.healthy.Store(false)
+		}
+
+		wallet, err = getWallet(ctx, s.db, walletId)
+	}
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Wallet{}, fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		}
 		return models.Wallet{}, fmt.Errorf("%s: %w", op, err)
 	}
+
+	return wallet, nil
+}
+
+func getWallet(ctx context.Context, conn executor, walletId uuid.UUID) (models.Wallet, error) {
+	stmt, err := conn.PrepareContext(ctx, "SELECT id, user_id, balance, currency, version, created_at, updated_at FROM wallets WHERE id = $1")
+	if err != nil {
+		return models.Wallet{}, err
+	}
 	defer stmt.Close()
 
 	var wallet models.Wallet
-	err = stmt.QueryRowContext(ctx, walletId).Scan(&wallet.Id, &wallet.UserId, &wallet.Balance, &wallet.CreatedAt, &wallet.UpdatedAt)
+	err = stmt.QueryRowContext(ctx, walletId).Scan(&wallet.Id, &wallet.UserId, &wallet.Balance, &wallet.Currency, &wallet.Version, &wallet.CreatedAt, &wallet.UpdatedAt)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return models.Wallet{}, fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
-		}
-		return models.Wallet{}, fmt.Errorf("%s: %w", op, err)
+		return models.Wallet{}, err
 	}
 
 	return wallet, nil
@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"coin-app/internal/lib/ratelimit"
+)
+
+// RateLimiter keeps token buckets in postgres, so that all replicas share one limit.
+type RateLimiter struct {
+	s *Storage
+}
+
+func NewRateLimiter(s *Storage) *RateLimiter {
+	return &RateLimiter{s: s}
+}
+
+// Allow takes a token from the bucket stored under key.
+// The bucket row is locked for the duration of the check.
+func (l *RateLimiter) Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	const op = "storage.postgres.RateLimiter.Allow"
+
+	var res ratelimit.Result
+	err := l.s.WithinTx(ctx, func(ctx context.Context) error {
+		conn := l.s.conn(ctx)
+
+		_, err := conn.ExecContext(ctx, `
+			INSERT INTO rate_limits(key, tokens) VALUES($1, $2)
+			ON CONFLICT (key) DO NOTHING`,
+			key, limit.Burst,
+		)
+		if err != nil {
+			return err
+		}
+
+		var tokens, elapsed float64
+		err = conn.QueryRowContext(ctx, `
+			SELECT tokens, GREATEST(EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - updated_at), 0)
+			FROM rate_limits
+			WHERE key = $1
+			FOR UPDATE`,
+			key,
+		).Scan(&tokens, &elapsed)
+		if err != nil {
+			return err
+		}
+
+		tokens, res = ratelimit.Take(tokens, time.Duration(elapsed*float64(time.Second)), limit)
+
+		_, err = conn.ExecContext(ctx, `
+			UPDATE rate_limits SET tokens = $1, updated_at = CURRENT_TIMESTAMP
+			WHERE key = $2`,
+			tokens, key,
+		)
+
+		return err
+	})
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return res, nil
+}
+
+// DeleteIdle removes buckets untouched for idle; they would be full by now anyway.
+func (l *RateLimiter) DeleteIdle(ctx context.Context, idle time.Duration) (int64, error) {
+	const op = "storage.postgres.RateLimiter.DeleteIdle"
+
+	res, err := l.s.db.ExecContext(ctx,
+		"DELETE FROM rate_limits WHERE updated_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 millisecond'",
+		idle.Milliseconds(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return n, nil
+}
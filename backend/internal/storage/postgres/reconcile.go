@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// ledgerSum is the signed sum of the ledger entries of wallet w.
+// Fees accrued but not settled yet are left out, the balance does not hold them either.
+const ledgerSum = `
+	COALESCE((
+		SELECT SUM(CASE t.operation_type
+			WHEN 'DEPOSIT' THEN t.amount
+			WHEN 'WITHDRAW' THEN -t.amount
+			ELSE t.amount
+		END)
+		FROM transactions t
+		WHERE t.wallet_id = w.id
+		  AND NOT EXISTS (SELECT 1 FROM fee_accruals a WHERE a.transaction_id = t.id)
+	), 0)`
+
+// LedgerBalances returns up to limit wallets with id greater than afterId, ordered by id,
+// together with their ledger sums. Every batch is read from a single snapshot.
+func (s *Storage) LedgerBalances(ctx context.Context, afterId uuid.UUID, limit int) ([]models.LedgerBalance, error) {
+	const op = "storage.postgres.LedgerBalances"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		SELECT id, opening_balance, balance, ledger_sum,
+		       opening_balance + ledger_sum,
+		       balance - (opening_balance + ledger_sum)
+		FROM (
+			SELECT w.id, w.opening_balance, w.balance, `+ledgerSum+` AS ledger_sum
+			FROM wallets w
+			WHERE w.id > $1
+			ORDER BY w.id
+			LIMIT $2
+		) b
+		ORDER BY id`, afterId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var balances []models.LedgerBalance
+	for rows.Next() {
+		var b models.LedgerBalance
+		if err := rows.Scan(&b.WalletId, &b.OpeningBalance, &b.Balance, &b.LedgerSum, &b.Expected, &b.Difference); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		balances = append(balances, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return balances, nil
+}
+
+// SaveLedgerAdjustment locks the wallet, recomputes its ledger and, if it still does not
+// match the balance, posts an ADJUSTMENT entry for the difference.
+// The balance itself is left untouched. Returns nil if there was nothing to adjust.
+func (s *Storage) SaveLedgerAdjustment(ctx context.Context, transactionId uuid.UUID, walletId uuid.UUID, reason string) (*models.Adjustment, error) {
+	const op = "storage.postgres.SaveLedgerAdjustment"
+
+	var adjustment *models.Adjustment
+	err := s.WithinTx(ctx, func(ctx context.Context) error {
+		var locked uuid.UUID
+		err := s.conn(ctx).QueryRowContext(ctx, "SELECT id FROM wallets WHERE id = $1 FOR UPDATE", walletId).Scan(&locked)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return storage.ErrWalletNotExists
+			}
+			return err
+		}
+
+		a := models.Adjustment{TransactionId: transactionId, WalletId: walletId, Reason: reason}
+		err = s.conn(ctx).QueryRowContext(ctx, `
+			INSERT INTO transactions(id, wallet_id, operation_type, amount, reason)
+			SELECT $1, w.id, 'ADJUSTMENT', w.balance - (w.opening_balance + `+ledgerSum+`), $3
+			FROM wallets w
+			WHERE w.id = $2
+			  AND w.opening_balance IS NOT NULL
+			  AND w.balance <> w.opening_balance + `+ledgerSum+`
+			RETURNING amount`,
+			transactionId, walletId, reason,
+		).Scan(&a.Amount)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		adjustment = &a
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return adjustment, nil
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+)
+
+// TestSaveLedgerAdjustmentFixesDrift tampers with a balance in the migrated database in TEST_POSTGRES_DSN
+// and checks the drift is reported, adjusted once and reported as fixed afterwards.
+func TestSaveLedgerAdjustmentFixesDrift(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	s, err := New(Options{DSN: dsn, MaxOpenConns: 4, Isolation: sql.LevelSerializable})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	ctx := context.Background()
+	walletId := uuid.New()
+	if _, err := s.SaveWallet(ctx, walletId, uuid.New(), 100, "RUB"); err != nil {
+		t.Fatalf("SaveWallet() error = %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = s.db.Exec("DELETE FROM transactions WHERE wallet_id = $1", walletId)
+		_, _ = s.db.Exec("DELETE FROM wallets WHERE id = $1", walletId)
+	})
+
+	// before is the id right below walletId, so that a page after it starts with the wallet
+	before := walletId
+	for i := len(before) - 1; i >= 0; i-- {
+		before[i]--
+		if before[i] != 0xff {
+			break
+		}
+	}
+	balance := func() models.LedgerBalance {
+		t.Helper()
+
+		page, err := s.LedgerBalances(ctx, before, 1)
+		if err != nil {
+			t.Fatalf("LedgerBalances() error = %v", err)
+		}
+		if len(page) != 1 || page[0].WalletId != walletId {
+			t.Fatalf("LedgerBalances() = %+v, want wallet %s", page, walletId)
+		}
+
+		return page[0]
+	}
+
+	if b := balance(); b.Difference == nil || *b.Difference != "0.00" {
+		t.Fatalf("difference of a new wallet = %v, want 0.00", b.Difference)
+	}
+
+	if _, err := s.db.Exec("UPDATE wallets SET balance = balance + 25 WHERE id = $1", walletId); err != nil {
+		t.Fatalf("tampering with the balance: %v", err)
+	}
+	if b := balance(); b.Difference == nil || *b.Difference != "25.00" {
+		t.Fatalf("difference after tampering = %v, want 25.00", b.Difference)
+	}
+
+	adjustment, err := s.SaveLedgerAdjustment(ctx, uuid.New(), walletId, "INC-42")
+	if err != nil {
+		t.Fatalf("SaveLedgerAdjustment() error = %v", err)
+	}
+	if adjustment == nil || adjustment.Amount != "25.00" || adjustment.Reason != "INC-42" {
+		t.Fatalf("SaveLedgerAdjustment() = %+v, want 25.00 for INC-42", adjustment)
+	}
+	if b := balance(); *b.Difference != "0.00" || b.Balance != "125.00" {
+		t.Errorf("after the fix = %+v, want no difference and the balance untouched", b)
+	}
+
+	// A second run finds nothing to adjust
+	adjustment, err = s.SaveLedgerAdjustment(ctx, uuid.New(), walletId, "INC-42")
+	if err != nil {
+		t.Fatalf("SaveLedgerAdjustment() error = %v", err)
+	}
+	if adjustment != nil {
+		t.Errorf("second SaveLedgerAdjustment() = %+v, want nil", adjustment)
+	}
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"coin-app/internal/storage"
+)
+
+type ReplicaOptions struct {
+	// DSNs of read-only replicas. Reads go to the primary when empty.
+	DSNs []string
+	// MaxLag is the replication lag after which a replica is considered unhealthy.
+	MaxLag time.Duration
+	// HealthCheckInterval is how often replicas are probed.
+	HealthCheckInterval time.Duration
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// openReplicas opens connections to the replicas.
+// Replicas start as unhealthy until the first health check passes.
+func openReplicas(dsns []string) ([]*replica, error) {
+	const op = "storage.postgres.openReplicas"
+
+	replicas := make([]*replica, 0, len(dsns))
+	for _, dsn := range dsns {
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			for _, r := range replicas {
+				r.db.Close()
+			}
+
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		replicas = append(replicas, &replica{db: db})
+	}
+
+	return replicas, nil
+}
+
+// checkReplicas probes replicas until stop is closed.
+func (s *Storage) checkReplicas(opts ReplicaOptions) {
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(opts.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, r := range s.replicas {
+			err := probeReplica(ctx, r.db, opts)
+			// A probe cut short by Close says nothing about the replica
+			if ctx.Err() != nil {
+				return
+			}
+			r.healthy.Store(err == nil)
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeReplica checks that the replica is reachable, is in recovery
+// and does not lag behind more than allowed.
+// A replica streaming from the primary that has replayed all the WAL it received is caught up,
+// whatever its last replay timestamp: on an idle primary that timestamp stops advancing.
+// Otherwise the lag is the age of the last replayed transaction.
+func probeReplica(ctx context.Context, db *sql.DB, opts ReplicaOptions) error {
+	ctx, cancel := context.WithTimeout(ctx, opts.HealthCheckInterval)
+	defer cancel()
+
+	var inRecovery, caughtUp bool
+	var lag float64
+	err := db.QueryRowContext(ctx, `
+		SELECT pg_is_in_recovery(),
+		       COALESCE(EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status = 'streaming')
+		                AND pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn(), FALSE),
+		       COALESCE(EXTRACT(EPOCH FROM (now() - pg_last_xact_replay_timestamp())), 0)`,
+	).Scan(&inRecovery, &caughtUp, &lag)
+	if err != nil {
+		return err
+	}
+
+	if !inRecovery {
+		return fmt.Errorf("replica is not in recovery")
+	}
+
+	if !caughtUp && opts.MaxLag > 0 && time.Duration(lag*float64(time.Second)) > opts.MaxLag {
+		return fmt.Errorf("replica lag %.3fs exceeds %s", lag, opts.MaxLag)
+	}
+
+	return nil
+}
+
+// reader returns the connection read-only queries should use:
+// the current transaction, the primary if strong consistency is requested,
+// or the next healthy replica. The second result is the replica picked, if any.
+func (s *Storage) reader(ctx context.Context) (executor, *replica) {
+	if state := txFrom(ctx); state != nil {
+		return state.tx, nil
+	}
+
+	if storage.IsStrongConsistency(ctx) || len(s.replicas) == 0 {
+		return s.db, nil
+	}
+
+	n := uint64(len(s.replicas))
+	start := s.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		r := s.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r.db, r
+		}
+	}
+
+	return s.db, nil
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RetryPolicy re-runs transactions that failed with a serialization failure or a deadlock.
+// MaxAttempts includes the first attempt, so 1 disables retries.
+type RetryPolicy struct {
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// OnRetry is called before every repeated attempt with the conflict reason.
+	OnRetry func(reason string)
+	// OnGiveUp is called when a conflict is returned to the caller.
+	OnGiveUp func(reason string)
+}
+
+// conflictCodes are the errors after which the transaction may be run again.
+var conflictCodes = map[pq.ErrorCode]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+}
+
+// conflictReason returns the condition name of a retryable error, or "" for any other error.
+func conflictReason(err error) string {
+	var pgErr *pq.Error
+	if errors.As(err, &pgErr) && conflictCodes[pgErr.Code] {
+		return pgErr.Code.Name()
+	}
+
+	return ""
+}
+
+// ParseIsolation maps an isolation level name like "repeatable read" to sql.IsolationLevel.
+// An empty name keeps the server default.
+func ParseIsolation(name string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "":
+		return sql.LevelDefault, nil
+	case "read committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unknown isolation level: %s", name)
+	}
+}
+
+// backoff returns a full-jitter exponential delay before the attempt following attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff << (attempt - 1)
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int64N(int64(d))) + 1
+}
+
+// wait sleeps for d unless ctx is done earlier or its deadline leaves no room for another attempt.
+func wait(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return false
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"coin-app/internal/storage"
+)
+
+func TestConflictReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "serialization failure", err: &pq.Error{Code: "40001"}, want: "serialization_failure"},
+		{name: "deadlock", err: &pq.Error{Code: "40P01"}, want: "deadlock_detected"},
+		{name: "wrapped", err: fmt.Errorf("storage.postgres.UpdateWallet: %w", &pq.Error{Code: "40001"}), want: "serialization_failure"},
+		{name: "unique violation", err: &pq.Error{Code: "23505"}},
+		{name: "other error", err: errors.New("connection refused")},
+		{name: "nil", err: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conflictReason(tt.err); got != tt.want {
+				t.Errorf("conflictReason(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// testStorage connects to the database in TEST_POSTGRES_DSN and creates a table of two accounts.
+func testStorage(t *testing.T, retry RetryPolicy) (*Storage, string) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	s, err := New(Options{DSN: dsn, MaxOpenConns: 4, Isolation: sql.LevelSerializable, Retry: retry})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	table := fmt.Sprintf("retry_test_%d", time.Now().UnixNano())
+	_, err = s.db.Exec(`CREATE TABLE ` + table + ` (id INT PRIMARY KEY, balance INT NOT NULL);
+		INSERT INTO ` + table + ` VALUES (1, 0), (2, 0)`)
+	if err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+	t.Cleanup(func() { _, _ = s.db.Exec(`DROP TABLE ` + table) })
+
+	return s, table
+}
+
+// reasons records the conflict reasons passed to a retry policy hook.
+type reasons struct {
+	mu   sync.Mutex
+	list []string
+}
+
+func (r *reasons) add(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.list = append(r.list, reason)
+}
+
+func (r *reasons) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.list...)
+}
+
+func TestWithinTxRetriesOppositeOrderUpdates(t *testing.T) {
+	var retried, gaveUp reasons
+	s, table := testStorage(t, RetryPolicy{
+		MaxAttempts: 5,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+		OnRetry:     retried.add,
+		OnGiveUp:    gaveUp.add,
+	})
+
+	// Both first attempts hold the lock on their first row before asking for the other one,
+	// so one of them is certainly chosen as the deadlock victim
+	var firstLocked sync.WaitGroup
+	firstLocked.Add(2)
+
+	transfer := func(first, second int) error {
+		var attempts atomic.Int32
+
+		return s.WithinTx(context.Background(), func(ctx context.Context) error {
+			attempt := attempts.Add(1)
+
+			for i, id := range []int{first, second} {
+				if _, err := s.conn(ctx).ExecContext(ctx, `UPDATE `+table+` SET balance = balance + 1 WHERE id = $1`, id); err != nil {
+					return err
+				}
+				if i == 0 && attempt == 1 {
+					firstLocked.Done()
+					firstLocked.Wait()
+				}
+			}
+
+			return nil
+		})
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- transfer(1, 2) }()
+	go func() { errs <- transfer(2, 1) }()
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("WithinTx() error = %v, want the conflict to be retried", err)
+		}
+	}
+
+	if got := retried.get(); len(got) == 0 {
+		t.Errorf("OnRetry was not called")
+	} else {
+		for _, reason := range got {
+			if reason != "deadlock_detected" && reason != "serialization_failure" {
+				t.Errorf("OnRetry(%q), want a conflict reason", reason)
+			}
+		}
+	}
+	if got := gaveUp.get(); len(got) != 0 {
+		t.Errorf("OnGiveUp(%v) called, want no give up", got)
+	}
+
+	rows, err := s.db.Query(`SELECT balance FROM ` + table + ` ORDER BY id`)
+	if err != nil {
+		t.Fatalf("select balances: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var balance int
+		if err := rows.Scan(&balance); err != nil {
+			t.Fatalf("scan balance: %v", err)
+		}
+		if balance != 2 {
+			t.Errorf("balance = %d, want 2: both transfers must apply exactly once", balance)
+		}
+	}
+}
+
+func TestWithinTxGivesUpAfterMaxAttempts(t *testing.T) {
+	const maxAttempts = 3
+
+	var retried, gaveUp reasons
+	s, table := testStorage(t, RetryPolicy{
+		MaxAttempts: maxAttempts,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+		OnRetry:     retried.add,
+		OnGiveUp:    gaveUp.add,
+	})
+
+	// Every attempt reads the row, sees it changed by a concurrent commit and fails to update it
+	attempts := 0
+	err := s.WithinTx(context.Background(), func(ctx context.Context) error {
+		attempts++
+
+		var balance int
+		if err := s.conn(ctx).QueryRowContext(ctx, `SELECT balance FROM `+table+` WHERE id = 1`).Scan(&balance); err != nil {
+			return err
+		}
+		if _, err := s.db.Exec(`UPDATE ` + table + ` SET balance = balance + 1 WHERE id = 1`); err != nil {
+			return err
+		}
+		_, err := s.conn(ctx).ExecContext(ctx, `UPDATE `+table+` SET balance = $1 WHERE id = 1`, balance+100)
+
+		return err
+	})
+
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("WithinTx() error = %v, want %v", err, storage.ErrConflict)
+	}
+	if attempts != maxAttempts {
+		t.Errorf("fn ran %d times, want %d", attempts, maxAttempts)
+	}
+	if got := retried.get(); len(got) != maxAttempts-1 {
+		t.Errorf("OnRetry called %d times, want %d", len(got), maxAttempts-1)
+	}
+	if got := gaveUp.get(); len(got) != 1 || got[0] != "serialization_failure" {
+		t.Errorf("OnGiveUp calls = %v, want [serialization_failure]", got)
+	}
+}
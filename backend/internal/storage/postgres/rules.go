@@ -0,0 +1,184 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+const ruleDecisionColumns = `id, transaction_id, wallet_id, operation_type, amount, action, COALESCE(rule, ''), COALESCE(reason, ''), actor,
+	COALESCE(review_status, ''), COALESCE(reviewed_by, ''), COALESCE(review_comment, ''), created_at, reviewed_at`
+
+// CountTransactions counts operations of the type on the wallet within window by the database clock,
+// including those saved earlier in the current transaction.
+func (s *Storage) CountTransactions(ctx context.Context, walletId uuid.UUID, operationType string, window time.Duration) (int, error) {
+	const op = "storage.postgres.CountTransactions"
+
+	var count int
+	err := s.conn(ctx).QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM transactions
+		WHERE wallet_id = $1
+			AND operation_type = $2
+			AND created_at > LOCALTIMESTAMP - $3 * INTERVAL '1 millisecond'`,
+		walletId, operationType, window.Milliseconds(),
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+// WalletAge returns how long ago the wallet was created by the database clock.
+func (s *Storage) WalletAge(ctx context.Context, walletId uuid.UUID) (time.Duration, error) {
+	const op = "storage.postgres.WalletAge"
+
+	var ms float64
+	err := s.conn(ctx).QueryRowContext(ctx, `
+		SELECT EXTRACT(EPOCH FROM LOCALTIMESTAMP - created_at) * 1000
+		FROM wallets
+		WHERE id = $1`,
+		walletId,
+	).Scan(&ms)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		}
+
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// AverageAmount returns the mean amount of the operations of the type on the wallet
+// and how many there were, leaving out the transaction excludeId.
+func (s *Storage) AverageAmount(ctx context.Context, walletId uuid.UUID, operationType string, excludeId uuid.UUID) (float64, int, error) {
+	const op = "storage.postgres.AverageAmount"
+
+	var avg float64
+	var count int
+	err := s.conn(ctx).QueryRowContext(ctx, `
+		SELECT COALESCE(AVG(amount), 0)::FLOAT8, COUNT(*)
+		FROM transactions
+		WHERE wallet_id = $1 AND operation_type = $2 AND id <> $3`,
+		walletId, operationType, excludeId,
+	).Scan(&avg, &count)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return avg, count, nil
+}
+
+// SaveRuleDecision records the screening of an operation.
+func (s *Storage) SaveRuleDecision(ctx context.Context, d models.RuleDecision) error {
+	const op = "storage.postgres.SaveRuleDecision"
+
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO rule_decisions(id, transaction_id, wallet_id, operation_type, amount, action, rule, reason, actor, review_status)
+		VALUES($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''), $9, NULLIF($10, ''))`,
+		d.Id, d.TransactionId, d.WalletId, d.OperationType, d.Amount, d.Action, d.Rule, d.Reason, d.Actor, d.ReviewStatus,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetRuleDecision retrieves the decision.
+func (s *Storage) GetRuleDecision(ctx context.Context, decisionId uuid.UUID) (models.RuleDecision, error) {
+	const op = "storage.postgres.GetRuleDecision"
+
+	d, err := scanRuleDecision(s.conn(ctx).QueryRowContext(ctx,
+		"SELECT "+ruleDecisionColumns+" FROM rule_decisions WHERE id = $1", decisionId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RuleDecision{}, fmt.Errorf("%s: %w", op, storage.ErrRuleDecisionNotExists)
+		}
+
+		return models.RuleDecision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return d, nil
+}
+
+// ReviewRuleDecision closes the review of a flagged decision that is still pending,
+// returns storage.ErrRuleDecisionNotExists if there is no such decision.
+func (s *Storage) ReviewRuleDecision(ctx context.Context, d models.RuleDecision) (models.RuleDecision, error) {
+	const op = "storage.postgres.ReviewRuleDecision"
+
+	reviewed, err := scanRuleDecision(s.conn(ctx).QueryRowContext(ctx, `
+		UPDATE rule_decisions
+		SET review_status = $2, reviewed_by = $3, review_comment = $4, reviewed_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND review_status = 'PENDING'
+		RETURNING `+ruleDecisionColumns,
+		d.Id, d.ReviewStatus, d.ReviewedBy, d.ReviewComment,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RuleDecision{}, fmt.Errorf("%s: %w", op, storage.ErrRuleDecisionNotExists)
+		}
+
+		return models.RuleDecision{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return reviewed, nil
+}
+
+// RuleDecisions lists flagged decisions, optionally of one review status, oldest first.
+func (s *Storage) RuleDecisions(ctx context.Context, status models.ReviewStatus, limit int) ([]models.RuleDecision, error) {
+	const op = "storage.postgres.RuleDecisions"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		SELECT `+ruleDecisionColumns+` FROM rule_decisions
+		WHERE review_status IS NOT NULL AND ($1 = '' OR review_status = $1)
+		ORDER BY created_at
+		LIMIT $2`,
+		status, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	decisions := []models.RuleDecision{}
+	for rows.Next() {
+		d, err := scanRuleDecision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		decisions = append(decisions, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return decisions, nil
+}
+
+func scanRuleDecision(row rowScanner) (models.RuleDecision, error) {
+	var d models.RuleDecision
+	var reviewedAt sql.NullTime
+
+	err := row.Scan(&d.Id, &d.TransactionId, &d.WalletId, &d.OperationType, &d.Amount, &d.Action, &d.Rule, &d.Reason,
+		&d.Actor, &d.ReviewStatus, &d.ReviewedBy, &d.ReviewComment, &d.CreatedAt, &reviewedAt)
+	if err != nil {
+		return models.RuleDecision{}, err
+	}
+
+	if reviewedAt.Valid {
+		d.ReviewedAt = &reviewedAt.Time
+	}
+
+	return d, nil
+}
@@ -0,0 +1,246 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const scheduleColumns = `id, operation_type, wallet_id, target_wallet_id, amount, COALESCE(description, ''),
+	COALESCE(cron, ''), COALESCE(every, ''), status, due_at, next_run_at, runs, attempts, COALESCE(last_error, ''),
+	created_by, created_at, updated_at`
+
+// SaveSchedule saves a new scheduled operation.
+func (s *Storage) SaveSchedule(ctx context.Context, sched models.ScheduledOperation) error {
+	const op = "storage.postgres.SaveSchedule"
+
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO scheduled_operations(id, operation_type, wallet_id, target_wallet_id, amount, description,
+			cron, every, status, due_at, next_run_at, created_by)
+		VALUES($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), $9, $10, $10, $11)`,
+		sched.Id, sched.OperationType, sched.WalletId, sched.TargetWalletId, sched.Amount, sched.Description,
+		sched.Cron, sched.Every, sched.Status, sched.DueAt, sched.CreatedBy,
+	)
+	if err != nil {
+		// 23503 - foreign_key_violation
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetSchedule retrieves the scheduled operation.
+func (s *Storage) GetSchedule(ctx context.Context, scheduleId uuid.UUID) (models.ScheduledOperation, error) {
+	const op = "storage.postgres.GetSchedule"
+
+	sched, err := scanSchedule(s.conn(ctx).QueryRowContext(ctx,
+		"SELECT "+scheduleColumns+" FROM scheduled_operations WHERE id = $1", scheduleId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, storage.ErrScheduleNotExists)
+		}
+
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sched, nil
+}
+
+// LockSchedule retrieves the scheduled operation and locks it until the end of the transaction,
+// waiting for a run in progress.
+func (s *Storage) LockSchedule(ctx context.Context, scheduleId uuid.UUID) (models.ScheduledOperation, error) {
+	const op = "storage.postgres.LockSchedule"
+
+	sched, err := scanSchedule(s.conn(ctx).QueryRowContext(ctx,
+		"SELECT "+scheduleColumns+" FROM scheduled_operations WHERE id = $1 FOR UPDATE", scheduleId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, storage.ErrScheduleNotExists)
+		}
+
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sched, nil
+}
+
+// LockDueSchedule claims the earliest active operation due by the database clock
+// and locks it until the end of the transaction. Operations locked by other replicas are skipped,
+// storage.ErrScheduleNotExists means nothing else is due.
+func (s *Storage) LockDueSchedule(ctx context.Context) (models.ScheduledOperation, error) {
+	const op = "storage.postgres.LockDueSchedule"
+
+	sched, err := scanSchedule(s.conn(ctx).QueryRowContext(ctx, `
+		SELECT `+scheduleColumns+` FROM scheduled_operations
+		WHERE status = 'ACTIVE' AND next_run_at <= now()
+		ORDER BY next_run_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, storage.ErrScheduleNotExists)
+		}
+
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sched, nil
+}
+
+// Schedules lists the operations debiting or crediting the wallet, newest first.
+func (s *Storage) Schedules(ctx context.Context, walletId uuid.UUID, limit int) ([]models.ScheduledOperation, error) {
+	const op = "storage.postgres.Schedules"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		SELECT `+scheduleColumns+` FROM scheduled_operations
+		WHERE wallet_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`,
+		walletId, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	schedules := []models.ScheduledOperation{}
+	for rows.Next() {
+		sched, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		schedules = append(schedules, sched)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return schedules, nil
+}
+
+// UpdateSchedule saves the mutable state of the operation and returns it as stored.
+func (s *Storage) UpdateSchedule(ctx context.Context, sched models.ScheduledOperation) (models.ScheduledOperation, error) {
+	const op = "storage.postgres.UpdateSchedule"
+
+	updated, err := scanSchedule(s.conn(ctx).QueryRowContext(ctx, `
+		UPDATE scheduled_operations
+		SET amount = $2, description = NULLIF($3, ''), status = $4, due_at = $5, next_run_at = $6,
+			runs = $7, attempts = $8, last_error = NULLIF($9, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+scheduleColumns,
+		sched.Id, sched.Amount, sched.Description, sched.Status, sched.DueAt, sched.NextRunAt,
+		sched.Runs, sched.Attempts, sched.LastError,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, storage.ErrScheduleNotExists)
+		}
+
+		return models.ScheduledOperation{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return updated, nil
+}
+
+// SaveScheduledRun records an attempt to run an occurrence.
+func (s *Storage) SaveScheduledRun(ctx context.Context, run models.ScheduledRun) error {
+	const op = "storage.postgres.SaveScheduledRun"
+
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO scheduled_runs(id, schedule_id, due_at, attempt, status, transaction_ids, error)
+		VALUES($1, $2, $3, $4, $5, $6, NULLIF($7, ''))`,
+		run.Id, run.ScheduleId, run.DueAt, run.Attempt, run.Status, pq.Array(transactionIds(run.TransactionIds)), run.Error,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ScheduledRuns lists the attempts of the operation, newest first.
+func (s *Storage) ScheduledRuns(ctx context.Context, scheduleId uuid.UUID, limit int) ([]models.ScheduledRun, error) {
+	const op = "storage.postgres.ScheduledRuns"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		SELECT id, schedule_id, due_at, attempt, status, COALESCE(transaction_ids, '{}'), COALESCE(error, ''), created_at
+		FROM scheduled_runs
+		WHERE schedule_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`,
+		scheduleId, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	runs := []models.ScheduledRun{}
+	for rows.Next() {
+		var run models.ScheduledRun
+		var ids []string
+		err := rows.Scan(&run.Id, &run.ScheduleId, &run.DueAt, &run.Attempt, &run.Status, pq.Array(&ids), &run.Error, &run.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		for _, id := range ids {
+			transactionId, err := uuid.Parse(id)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			run.TransactionIds = append(run.TransactionIds, transactionId)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return runs, nil
+}
+
+// transactionIds passes the IDs as text, pq has no array support for uuid.UUID.
+func transactionIds(ids []uuid.UUID) []string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = id.String()
+	}
+
+	return s
+}
+
+func scanSchedule(row rowScanner) (models.ScheduledOperation, error) {
+	var sched models.ScheduledOperation
+	var targetWalletId uuid.NullUUID
+	var dueAt, nextRunAt sql.NullTime
+
+	err := row.Scan(&sched.Id, &sched.OperationType, &sched.WalletId, &targetWalletId, &sched.Amount, &sched.Description,
+		&sched.Cron, &sched.Every, &sched.Status, &dueAt, &nextRunAt, &sched.Runs, &sched.Attempts, &sched.LastError,
+		&sched.CreatedBy, &sched.CreatedAt, &sched.UpdatedAt)
+	if err != nil {
+		return models.ScheduledOperation{}, err
+	}
+
+	if targetWalletId.Valid {
+		sched.TargetWalletId = &targetWalletId.UUID
+	}
+	if dueAt.Valid {
+		sched.DueAt = &dueAt.Time
+	}
+	if nextRunAt.Valid {
+		sched.NextRunAt = &nextRunAt.Time
+	}
+
+	return sched, nil
+}
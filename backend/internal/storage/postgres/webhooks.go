@@ -0,0 +1,315 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"coin-app/internal/domain/models"
+	"coin-app/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// SaveSubscription saves webhook subscription to db.
+func (s *Storage) SaveSubscription(ctx context.Context, sub models.WebhookSubscription) error {
+	const op = "storage.postgres.SaveSubscription"
+
+	eventTypes := make([]string, 0, len(sub.EventTypes))
+	for _, t := range sub.EventTypes {
+		eventTypes = append(eventTypes, string(t))
+	}
+
+	_, err := s.conn(ctx).ExecContext(ctx,
+		"INSERT INTO webhook_subscriptions(id, url, event_types, secret, owner, user_id, wallet_id) VALUES($1, $2, $3, $4, $5, $6, $7)",
+		sub.Id, sub.URL, pq.Array(eventTypes), sub.Secret, sub.Owner, sub.UserId, sub.WalletId,
+	)
+	if err != nil {
+		// 23503 - foreign_key_violation
+		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrWalletNotExists)
+		}
+
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetSubscription retrieves webhook subscription from db.
+func (s *Storage) GetSubscription(ctx context.Context, subscriptionId uuid.UUID) (models.WebhookSubscription, error) {
+	const op = "storage.postgres.GetSubscription"
+
+	sub, err := scanSubscription(s.conn(ctx).QueryRowContext(ctx,
+		"SELECT "+subscriptionColumns+" FROM webhook_subscriptions WHERE id = $1",
+		subscriptionId,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotExists)
+		}
+		return models.WebhookSubscription{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return sub, nil
+}
+
+// Subscriptions returns the subscriptions of owner, newest first. An empty owner returns all of them.
+func (s *Storage) Subscriptions(ctx context.Context, owner string, limit int) ([]models.WebhookSubscription, error) {
+	const op = "storage.postgres.Subscriptions"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		SELECT `+subscriptionColumns+`
+		FROM webhook_subscriptions
+		WHERE $1 = '' OR owner = $1
+		ORDER BY created_at DESC
+		LIMIT $2`, owner, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	subs := []models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return subs, nil
+}
+
+const subscriptionColumns = "id, url, event_types, secret, active, created_at, owner, user_id, wallet_id"
+
+func scanSubscription(row rowScanner) (models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	var eventTypes []string
+	err := row.Scan(&sub.Id, &sub.URL, pq.Array(&eventTypes), &sub.Secret, &sub.Active, &sub.CreatedAt, &sub.Owner, &sub.UserId, &sub.WalletId)
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+
+	for _, t := range eventTypes {
+		sub.EventTypes = append(sub.EventTypes, models.EventType(t))
+	}
+
+	return sub, nil
+}
+
+// DeactivateSubscription stops new deliveries for the subscription.
+func (s *Storage) DeactivateSubscription(ctx context.Context, subscriptionId uuid.UUID) error {
+	const op = "storage.postgres.DeactivateSubscription"
+
+	res, err := s.conn(ctx).ExecContext(ctx, "UPDATE webhook_subscriptions SET active = FALSE WHERE id = $1", subscriptionId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrSubscriptionNotExists)
+	}
+
+	return nil
+}
+
+// EnqueueDeliveries creates a pending delivery of event for every active subscription to its type
+// whose user and wallet, if set, are those of the event's wallet.
+// Enqueuing the same event twice is a no-op.
+func (s *Storage) EnqueueDeliveries(ctx context.Context, event models.Event) error {
+	const op = "storage.postgres.EnqueueDeliveries"
+
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		INSERT INTO webhook_deliveries(id, subscription_id, event_id, aggregate_id, event_type, payload)
+		SELECT gen_random_uuid(), id, $1, $2, $3, $4
+		FROM webhook_subscriptions
+		WHERE active AND $3 = ANY(event_types)
+		  AND (wallet_id IS NULL OR wallet_id = $2)
+		  AND (user_id IS NULL OR user_id = (SELECT user_id FROM wallets WHERE id = $2))
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`,
+		event.Id, event.AggregateId, string(event.Type), []byte(event.Payload),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ClaimDeliveries leases deliveries that are due and returns them, oldest first.
+// A claimed delivery is not due again until the lease expires, so the caller sends it
+// without holding a transaction and another dispatcher takes it over if the caller dies.
+// Rows claimed concurrently by another dispatcher are skipped.
+func (s *Storage) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	const op = "storage.postgres.ClaimDeliveries"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		WITH claimed AS (
+			UPDATE webhook_deliveries SET next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 millisecond'
+			WHERE id IN (
+				SELECT id
+				FROM webhook_deliveries
+				WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, subscription_id, event_id, aggregate_id, event_type, payload, attempts, created_at
+		)
+		SELECT c.id, c.subscription_id, c.event_id, c.aggregate_id, c.event_type, c.payload, c.attempts, c.created_at, s.url, s.secret
+		FROM claimed c
+		JOIN webhook_subscriptions s ON s.id = c.subscription_id
+		ORDER BY c.created_at`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload []byte
+		if err := rows.Scan(&d.Id, &d.SubscriptionId, &d.EventId, &d.AggregateId, &d.EventType, &payload, &d.Attempts, &d.CreatedAt, &d.URL, &d.Secret); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		d.Payload = payload
+		d.Status = models.DeliveryPending
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// MarkDeliverySucceeded marks delivery as delivered.
+func (s *Storage) MarkDeliverySucceeded(ctx context.Context, deliveryId uuid.UUID, statusCode int) error {
+	const op = "storage.postgres.MarkDeliverySucceeded"
+
+	_, err := s.conn(ctx).ExecContext(ctx,
+		"UPDATE webhook_deliveries SET status = 'delivered', attempts = attempts + 1, last_status_code = $1, last_error = NULL WHERE id = $2",
+		statusCode, deliveryId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// MarkDeliveryFailed records a failed attempt.
+// If dead is true the delivery is moved to the dead-letter state, otherwise it is retried after retryIn.
+func (s *Storage) MarkDeliveryFailed(ctx context.Context, deliveryId uuid.UUID, statusCode int, reason string, retryIn time.Duration, dead bool) error {
+	const op = "storage.postgres.MarkDeliveryFailed"
+
+	status := models.DeliveryPending
+	if dead {
+		status = models.DeliveryDead
+	}
+
+	var code sql.NullInt64
+	if statusCode != 0 {
+		code = sql.NullInt64{Int64: int64(statusCode), Valid: true}
+	}
+
+	_, err := s.conn(ctx).ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $1,
+		    attempts = attempts + 1,
+		    next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 millisecond',
+		    last_status_code = $3,
+		    last_error = $4
+		WHERE id = $5`,
+		string(status), retryIn.Milliseconds(), code, reason, deliveryId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Deliveries returns the latest deliveries of the subscription, newest first.
+func (s *Storage) Deliveries(ctx context.Context, subscriptionId uuid.UUID, limit int) ([]models.WebhookDelivery, error) {
+	const op = "storage.postgres.Deliveries"
+
+	rows, err := s.conn(ctx).QueryContext(ctx, `
+		SELECT id, subscription_id, event_id, aggregate_id, event_type, payload, status, attempts, next_attempt_at,
+		       last_status_code, last_error, created_at, updated_at
+		FROM webhook_deliveries
+		WHERE subscription_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`, subscriptionId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload []byte
+		err := rows.Scan(&d.Id, &d.SubscriptionId, &d.EventId, &d.AggregateId, &d.EventType, &payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return deliveries, nil
+}
+
+// GetDelivery retrieves webhook delivery from db.
+func (s *Storage) GetDelivery(ctx context.Context, deliveryId uuid.UUID) (models.WebhookDelivery, error) {
+	const op = "storage.postgres.GetDelivery"
+
+	var d models.WebhookDelivery
+	var payload []byte
+	err := s.conn(ctx).QueryRowContext(ctx, `
+		SELECT id, subscription_id, event_id, aggregate_id, event_type, payload, status, attempts, next_attempt_at,
+		       last_status_code, last_error, created_at, updated_at
+		FROM webhook_deliveries
+		WHERE id = $1`, deliveryId,
+	).Scan(&d.Id, &d.SubscriptionId, &d.EventId, &d.AggregateId, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, storage.ErrDeliveryNotExists)
+		}
+		return models.WebhookDelivery{}, fmt.Errorf("%s: %w", op, err)
+	}
+	d.Payload = payload
+
+	return d, nil
+}
+
+// ResetDelivery schedules delivery for an immediate new round of attempts.
+func (s *Storage) ResetDelivery(ctx context.Context, deliveryId uuid.UUID) error {
+	const op = "storage.postgres.ResetDelivery"
+
+	res, err := s.conn(ctx).ExecContext(ctx,
+		"UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP WHERE id = $1",
+		deliveryId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrDeliveryNotExists)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"coin-app/internal/domain/models"
+)
+
+// TestEnqueueDeliveriesMatchesWallets queues an event in the migrated database in TEST_POSTGRES_DSN
+// and checks only the subscriptions to all wallets, to the user of the wallet or to the wallet get it.
+func TestEnqueueDeliveriesMatchesWallets(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	s, err := New(Options{DSN: dsn, MaxOpenConns: 4, Isolation: sql.LevelSerializable})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	ctx := context.Background()
+	user, other := uuid.New(), uuid.New()
+	walletId, sibling, foreign := uuid.New(), uuid.New(), uuid.New()
+	for _, w := range []struct{ id, userId uuid.UUID }{{walletId, user}, {sibling, user}, {foreign, other}} {
+		if _, err := s.SaveWallet(ctx, w.id, w.userId, 0, "RUB"); err != nil {
+			t.Fatalf("SaveWallet() error = %v", err)
+		}
+	}
+	// Cleanups run last in first out, so the wallets go after the subscriptions on them
+	t.Cleanup(func() {
+		_, _ = s.db.Exec("DELETE FROM wallets WHERE id IN ($1, $2, $3)", walletId, sibling, foreign)
+	})
+
+	subscribe := func(userId, walletId *uuid.UUID) uuid.UUID {
+		sub := models.WebhookSubscription{
+			Id:         uuid.New(),
+			URL:        "https://partner.example.com/hook",
+			EventTypes: []models.EventType{models.EventFundsDeposited},
+			Secret:     "whsec_test",
+			Owner:      "test",
+			UserId:     userId,
+			WalletId:   walletId,
+		}
+		if err := s.SaveSubscription(ctx, sub); err != nil {
+			t.Fatalf("SaveSubscription() error = %v", err)
+		}
+		t.Cleanup(func() {
+			_, _ = s.db.Exec("DELETE FROM webhook_deliveries WHERE subscription_id = $1", sub.Id)
+			_, _ = s.db.Exec("DELETE FROM webhook_subscriptions WHERE id = $1", sub.Id)
+		})
+
+		return sub.Id
+	}
+
+	want := map[uuid.UUID]bool{
+		subscribe(nil, nil):         true,
+		subscribe(&user, nil):       true,
+		subscribe(&user, &walletId): true,
+		subscribe(nil, &walletId):   true,
+		subscribe(&user, &sibling):  false,
+		subscribe(&other, nil):      false,
+		subscribe(nil, &foreign):    false,
+	}
+
+	event := models.Event{Id: uuid.New(), AggregateId: walletId, Type: models.EventFundsDeposited, Payload: []byte(`{}`)}
+	if err := s.EnqueueDeliveries(ctx, event); err != nil {
+		t.Fatalf("EnqueueDeliveries() error = %v", err)
+	}
+
+	for subId, delivered := range want {
+		deliveries, err := s.Deliveries(ctx, subId, 10)
+		if err != nil {
+			t.Fatalf("Deliveries() error = %v", err)
+		}
+		if got := len(deliveries) == 1; got != delivered {
+			t.Errorf("subscription %s got the event = %v, want %v", subId, got, delivered)
+		}
+	}
+}
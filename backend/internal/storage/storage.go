@@ -3,6 +3,21 @@ package storage
 import "errors"
 
 var (
-	ErrWalletExists    = errors.New("wallet already exists")
-	ErrWalletNotExists = errors.New("wallet not exists")
+	ErrWalletExists          = errors.New("wallet already exists")
+	ErrWalletNotExists       = errors.New("wallet not exists")
+	ErrSubscriptionNotExists = errors.New("subscription not exists")
+	ErrDeliveryNotExists     = errors.New("delivery not exists")
+	ErrAPIKeyExists          = errors.New("api key already exists")
+	ErrAPIKeyNotExists       = errors.New("api key not exists")
+	ErrAdjustmentNotExists   = errors.New("adjustment request not exists")
+	ErrPolicyNotExists       = errors.New("wallet policy not exists")
+	ErrRuleDecisionNotExists = errors.New("rule decision not exists")
+	ErrTransactionExists     = errors.New("transaction with this reference already exists")
+	ErrTransactionNotExists  = errors.New("transaction not exists")
+	ErrScheduleNotExists     = errors.New("scheduled operation not exists")
+	ErrRateNotExists         = errors.New("exchange rate not exists")
+	ErrQuoteNotExists        = errors.New("quote not exists")
+	// ErrConflict means the transaction lost a serialization conflict or a deadlock
+	// and may succeed if the whole unit of work is run again.
+	ErrConflict = errors.New("transaction conflict")
 )
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS adjustment_requests (
+    id UUID PRIMARY KEY,
+    wallet_id UUID NOT NULL REFERENCES wallets(id),
+    -- signed, a positive amount credits the wallet
+    amount BIGINT NOT NULL CHECK (amount <> 0),
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'APPROVED', 'REJECTED')),
+    proposed_by TEXT NOT NULL,
+    decided_by TEXT,
+    decision_comment TEXT,
+    transaction_id UUID REFERENCES transactions(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    decided_at TIMESTAMP,
+    -- maker-checker: the proposer may withdraw a request but never approve it
+    CONSTRAINT adjustment_requests_no_self_approval CHECK (status <> 'APPROVED' OR decided_by <> proposed_by)
+);
+
+CREATE INDEX IF NOT EXISTS adjustment_requests_status_idx ON adjustment_requests (status, created_at);
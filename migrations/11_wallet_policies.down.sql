@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS transactions_wallet_id_created_at_idx;
+DROP TABLE IF EXISTS wallet_policies;
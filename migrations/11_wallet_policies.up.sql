@@ -0,0 +1,15 @@
+-- Tiers are defined in the config, a wallet without a row here gets the default tier.
+-- A NULL limit falls back to the tier.
+CREATE TABLE IF NOT EXISTS wallet_policies (
+    wallet_id UUID PRIMARY KEY REFERENCES wallets(id),
+    tier TEXT NOT NULL,
+    max_balance BIGINT,
+    min_amount BIGINT,
+    max_amount BIGINT,
+    daily_withdrawal BIGINT,
+    monthly_withdrawal BIGINT,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Withdrawal totals are summed per wallet over the current day and month
+CREATE INDEX IF NOT EXISTS transactions_wallet_id_created_at_idx ON transactions (wallet_id, created_at);
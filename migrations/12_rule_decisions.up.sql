@@ -0,0 +1,24 @@
+-- Every screening of an operation by the rules engine. transaction_id is not a foreign key:
+-- a blocked operation is never saved to transactions.
+CREATE TABLE IF NOT EXISTS rule_decisions (
+    id UUID PRIMARY KEY,
+    transaction_id UUID NOT NULL,
+    wallet_id UUID NOT NULL REFERENCES wallets(id),
+    operation_type operation_type NOT NULL,
+    amount BIGINT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('ALLOW', 'BLOCK', 'FLAG')),
+    -- the rule that decided, NULL when no rule matched
+    rule TEXT,
+    reason TEXT,
+    actor TEXT NOT NULL,
+    -- only flagged operations are reviewed
+    review_status TEXT CHECK (review_status IN ('PENDING', 'CLEARED', 'FRAUD')),
+    reviewed_by TEXT,
+    review_comment TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP,
+    CONSTRAINT rule_decisions_review_of_flag CHECK ((action = 'FLAG') = (review_status IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS rule_decisions_wallet_id_idx ON rule_decisions (wallet_id, created_at);
+CREATE INDEX IF NOT EXISTS rule_decisions_review_idx ON rule_decisions (review_status, created_at) WHERE review_status IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS transactions_wallet_id_external_reference_idx;
+ALTER TABLE transactions DROP COLUMN IF EXISTS external_reference;
+ALTER TABLE transactions DROP COLUMN IF EXISTS metadata;
+ALTER TABLE transactions DROP COLUMN IF EXISTS description;
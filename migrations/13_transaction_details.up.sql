@@ -0,0 +1,7 @@
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS metadata JSONB CHECK (jsonb_typeof(metadata) = 'object');
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS external_reference TEXT;
+
+-- A reference posts once per wallet, a retry finds the original transaction
+CREATE UNIQUE INDEX IF NOT EXISTS transactions_wallet_id_external_reference_idx
+    ON transactions (wallet_id, external_reference) WHERE external_reference IS NOT NULL;
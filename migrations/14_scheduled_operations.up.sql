@@ -0,0 +1,42 @@
+-- Run times are TIMESTAMPTZ: they are computed by the scheduler from cron expressions
+-- and compared with now() on any replica, whatever the session time zone.
+CREATE TABLE IF NOT EXISTS scheduled_operations (
+    id UUID PRIMARY KEY,
+    operation_type TEXT NOT NULL CHECK (operation_type IN ('DEPOSIT', 'WITHDRAW', 'TRANSFER')),
+    -- the debited wallet of a WITHDRAW or TRANSFER, the credited one of a DEPOSIT
+    wallet_id UUID NOT NULL REFERENCES wallets(id),
+    target_wallet_id UUID REFERENCES wallets(id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    description TEXT,
+    -- a one-off operation has neither
+    cron TEXT,
+    every TEXT,
+    status TEXT NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'PAUSED', 'COMPLETED', 'FAILED', 'CANCELED')),
+    -- the occurrence being run and when to try it next, later than due_at after a failure
+    due_at TIMESTAMPTZ,
+    next_run_at TIMESTAMPTZ,
+    runs INT NOT NULL DEFAULT 0,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT scheduled_operations_target CHECK ((operation_type = 'TRANSFER') = (target_wallet_id IS NOT NULL)),
+    CONSTRAINT scheduled_operations_one_schedule CHECK (cron IS NULL OR every IS NULL)
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_operations_due_idx ON scheduled_operations (next_run_at) WHERE status = 'ACTIVE';
+CREATE INDEX IF NOT EXISTS scheduled_operations_wallet_id_idx ON scheduled_operations (wallet_id);
+
+CREATE TABLE IF NOT EXISTS scheduled_runs (
+    id UUID PRIMARY KEY,
+    schedule_id UUID NOT NULL REFERENCES scheduled_operations(id),
+    due_at TIMESTAMPTZ NOT NULL,
+    attempt INT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('SUCCEEDED', 'FAILED')),
+    transaction_ids UUID[],
+    error TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_runs_schedule_id_idx ON scheduled_runs (schedule_id, created_at);
@@ -0,0 +1,6 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_spread;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_rate;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_quote_id;
+DROP TABLE IF EXISTS fx_quotes;
+DROP TABLE IF EXISTS fx_rates;
+ALTER TABLE wallets DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,40 @@
+-- ISO 4217 code, wallets opened before currencies were introduced hold roubles
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+-- Units of quote per one unit of base, the latest rate effective by now applies
+CREATE TABLE IF NOT EXISTS fx_rates (
+    base CHAR(3) NOT NULL,
+    quote CHAR(3) NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+    effective_at TIMESTAMPTZ NOT NULL,
+    source TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (base, quote, effective_at),
+    CHECK (base <> quote)
+);
+
+-- A quote locks the rate until expires_at, executing it posts the conversion
+CREATE TABLE IF NOT EXISTS fx_quotes (
+    id UUID PRIMARY KEY,
+    from_wallet_id UUID NOT NULL REFERENCES wallets(id),
+    to_wallet_id UUID NOT NULL REFERENCES wallets(id),
+    from_currency CHAR(3) NOT NULL,
+    to_currency CHAR(3) NOT NULL,
+    sell_amount BIGINT NOT NULL CHECK (sell_amount > 0),
+    buy_amount DECIMAL(10, 2) NOT NULL CHECK (buy_amount > 0),
+    -- the market rate, the spread as a fraction and the rate applied after the spread
+    rate NUMERIC(20, 10) NOT NULL,
+    spread NUMERIC(10, 6) NOT NULL,
+    applied_rate NUMERIC(20, 10) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    executed_at TIMESTAMP,
+    debit_transaction_id UUID REFERENCES transactions(id),
+    credit_transaction_id UUID REFERENCES transactions(id)
+);
+
+-- Both legs of a conversion carry its quote, rate and spread
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_quote_id UUID REFERENCES fx_quotes(id);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_rate NUMERIC(20, 10);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_spread NUMERIC(10, 6);
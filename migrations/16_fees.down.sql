@@ -0,0 +1,3 @@
+-- Postgres can not drop a value from an enum, FEE stays in operation_type.
+DROP INDEX IF EXISTS transactions_fee_for_idx;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fee_for;
@@ -0,0 +1,7 @@
+-- A FEE entry is signed like an ADJUSTMENT: it debits the paying wallet or credits the revenue wallet.
+ALTER TYPE operation_type ADD VALUE IF NOT EXISTS 'FEE';
+
+-- fee_for links a FEE entry to the deposit or withdrawal it was charged on
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee_for UUID REFERENCES transactions(id);
+
+CREATE INDEX IF NOT EXISTS transactions_fee_for_idx ON transactions (fee_for) WHERE fee_for IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE adjustment_requests DROP CONSTRAINT IF EXISTS adjustment_requests_no_self_approval_by_operator;
+ALTER TABLE adjustment_requests DROP COLUMN IF EXISTS decider;
+ALTER TABLE adjustment_requests DROP COLUMN IF EXISTS proposer;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS owner;
@@ -0,0 +1,12 @@
+-- owner is the operator who issued the key, empty for keys issued before it was recorded
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS owner TEXT NOT NULL DEFAULT '';
+
+-- proposer and decider are the operators behind proposed_by and decided_by,
+-- one person may hold a token and several API keys
+ALTER TABLE adjustment_requests ADD COLUMN IF NOT EXISTS proposer TEXT;
+ALTER TABLE adjustment_requests ADD COLUMN IF NOT EXISTS decider TEXT;
+UPDATE adjustment_requests SET proposer = proposed_by, decider = decided_by;
+ALTER TABLE adjustment_requests ALTER COLUMN proposer SET NOT NULL;
+
+ALTER TABLE adjustment_requests
+    ADD CONSTRAINT adjustment_requests_no_self_approval_by_operator CHECK (status <> 'APPROVED' OR decider <> proposer);
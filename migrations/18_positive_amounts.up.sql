@@ -0,0 +1,6 @@
+-- DEPOSIT and WITHDRAW carry the direction in operation_type, only ADJUSTMENT and FEE are signed.
+-- NOT VALID keeps the migration from failing on rows posted before Post checked amounts:
+-- new rows are checked, run VALIDATE CONSTRAINT once the old ones are corrected.
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_positive_amount
+    CHECK (operation_type NOT IN ('DEPOSIT', 'WITHDRAW') OR amount > 0) NOT VALID;
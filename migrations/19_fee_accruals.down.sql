@@ -0,0 +1,6 @@
+-- Pending accruals are settled before the table goes, so the balances keep matching the ledger.
+UPDATE wallets w SET balance = w.balance + a.amount, version = w.version + 1
+FROM (SELECT wallet_id, SUM(amount) AS amount FROM fee_accruals GROUP BY wallet_id) a
+WHERE w.id = a.wallet_id;
+
+DROP TABLE IF EXISTS fee_accruals;
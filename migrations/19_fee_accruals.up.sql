@@ -0,0 +1,11 @@
+-- A fee credited to a revenue wallet is accrued here together with its FEE entry and settled
+-- into the wallet balance in the background, so fee-bearing operations never lock the revenue wallet.
+-- The ledger sum of a wallet leaves its pending accruals out until they are settled.
+CREATE TABLE IF NOT EXISTS fee_accruals (
+    transaction_id UUID PRIMARY KEY REFERENCES transactions(id),
+    wallet_id UUID NOT NULL REFERENCES wallets(id),
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS fee_accruals_created_at_idx ON fee_accruals (created_at);
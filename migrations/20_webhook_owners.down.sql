@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS webhook_subscriptions_owner_idx;
+
+ALTER TABLE webhook_subscriptions DROP COLUMN IF EXISTS wallet_id;
+ALTER TABLE webhook_subscriptions DROP COLUMN IF EXISTS user_id;
+ALTER TABLE webhook_subscriptions DROP COLUMN IF EXISTS owner;
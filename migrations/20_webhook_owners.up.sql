@@ -0,0 +1,8 @@
+-- owner is the operator of the caller that subscribed, empty for subscriptions made before it was
+-- recorded, which only admins see. A subscription with user_id gets the events of that user's wallets
+-- only, one with wallet_id the events of that wallet only.
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS user_id UUID;
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS wallet_id UUID REFERENCES wallets(id);
+
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_owner_idx ON webhook_subscriptions (owner);
@@ -0,0 +1,4 @@
+-- Postgres can not drop a value from an enum, ADJUSTMENT stays in operation_type.
+DROP INDEX IF EXISTS transactions_wallet_id_idx;
+ALTER TABLE transactions DROP COLUMN IF EXISTS reason;
+ALTER TABLE wallets DROP COLUMN IF EXISTS opening_balance;
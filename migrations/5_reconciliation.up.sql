@@ -0,0 +1,11 @@
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS opening_balance DECIMAL(10, 2);
+
+-- Wallets that were never updated still hold their opening balance.
+-- For the rest it is unknown and they are reported as unverifiable.
+UPDATE wallets SET opening_balance = balance WHERE updated_at = created_at;
+
+ALTER TYPE operation_type ADD VALUE IF NOT EXISTS 'ADJUSTMENT';
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reason TEXT;
+
+CREATE INDEX IF NOT EXISTS transactions_wallet_id_idx ON transactions (wallet_id);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    -- prefix is the public part of the key used to find it, the rest is only stored hashed
+    prefix VARCHAR(16) NOT NULL UNIQUE,
+    key_hash BYTEA NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    -- rotated_from links a key to the one it replaces
+    rotated_from UUID REFERENCES api_keys(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
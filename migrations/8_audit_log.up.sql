@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    -- seq is assigned by the writer under an advisory lock, so gaps are tampering too
+    seq BIGINT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    actor TEXT NOT NULL,
+    client_ip TEXT NOT NULL,
+    request_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    path TEXT NOT NULL,
+    status INT NOT NULL,
+    outcome TEXT NOT NULL,
+    payload_digest TEXT NOT NULL,
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor, seq);
+
+-- The log is append-only, rows can be neither changed nor removed
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
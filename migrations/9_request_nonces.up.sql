@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS request_nonces (
+    client_id TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    -- an expired nonce may be reused, its timestamp is outside of the skew window by then
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (client_id, nonce)
+);
+
+CREATE INDEX IF NOT EXISTS request_nonces_expires_at_idx ON request_nonces (expires_at);